@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ackMaxAge is how long acknowledgements are kept; older events have left every recent list by then
+const ackMaxAge = 30 * 24 * time.Hour
+
+// unseenWindow is how far back a notification can still be unseen; older ones no longer need attention
+const unseenWindow = 24 * time.Hour
+
+// unseenListLimit is how many unseen notifications `cmdbell status` lists
+const unseenListLimit = 10
+
+var acksMu sync.Mutex
+
+// loadAcks reads the acknowledged event IDs from ~/.cmdbell/acks.json. The file is read on every
+// call so acknowledgements from launchers reach the daemon's D-Bus service without a restart.
+func loadAcks() map[string]time.Time {
+	acks := make(map[string]time.Time)
+	path, err := getDataPath("acks.json")
+	if err != nil {
+		return acks
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &acks)
+	}
+	return acks
+}
+
+// acknowledgeEvents records the given event IDs and returns the ones that were not acknowledged yet
+func acknowledgeEvents(ids []string) ([]string, error) {
+	acksMu.Lock()
+	defer acksMu.Unlock()
+
+	acks := loadAcks()
+	now := time.Now()
+	for id, at := range acks {
+		if now.Sub(at) > ackMaxAge {
+			delete(acks, id)
+		}
+	}
+
+	var changed []string
+	for _, id := range ids {
+		if _, ok := acks[id]; ok {
+			continue
+		}
+		acks[id] = now
+		changed = append(changed, id)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	path, err := getDataPath("acks.json")
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(acks, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// unseenEvents returns the history entries from the last unseenWindow that have not been
+// acknowledged, newest first
+func unseenEvents() ([]HistoryEntry, error) {
+	store := getHistoryStore()
+	if store == nil {
+		return nil, nil
+	}
+	entries, err := store.Query(HistoryFilter{Since: time.Now().Add(-unseenWindow)})
+	if err != nil {
+		return nil, err
+	}
+
+	acks := loadAcks()
+	unseen := []HistoryEntry{}
+	for _, entry := range entries {
+		if _, ok := acks[entry.ID]; !ok {
+			unseen = append(unseen, entry)
+		}
+	}
+	return unseen, nil
+}
+
+// unseenCount is the number of unseen notifications, 0 when history cannot be read
+func unseenCount() int {
+	unseen, _ := unseenEvents()
+	return len(unseen)
+}
+
+// clickAckCommand is the command a clicked toast runs to acknowledge its event, or nil when
+// this executable cannot be found
+func clickAckCommand(eventID string) []string {
+	if eventID == "" {
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	return []string{executable, "open", deepLink("ack", eventID)}
+}
+
+// handleAckCommand handles `cmdbell ack [<id>...]`, marking the given notifications, or every
+// unseen one, as seen. IDs may be shortened to any unique prefix of an unseen notification.
+func handleAckCommand() {
+	unseen, err := unseenEvents()
+	if err != nil {
+		errorf("❌ Failed to read history: %v\n", err)
+		os.Exit(1)
+	}
+
+	var ids []string
+	if len(os.Args) > 2 {
+		for _, arg := range os.Args[2:] {
+			if strings.HasPrefix(arg, "-") {
+				fmt.Println("Usage: cmdbell ack [<id>...]")
+				os.Exit(1)
+			}
+			ids = append(ids, expandUnseenID(unseen, arg))
+		}
+	} else {
+		for _, entry := range unseen {
+			ids = append(ids, entry.ID)
+		}
+	}
+
+	// Toasts also leave Windows Action Center, all of CmdBell's when acknowledging everything
+	if err := clearWindowsToasts(ids, len(os.Args) == 2); err != nil {
+		errorf("⚠️  Could not clear Action Center: %v\n", err)
+	}
+	if len(ids) == 0 {
+		fmt.Println("Nothing to acknowledge")
+		return
+	}
+
+	changed, err := acknowledgeEvents(ids)
+	if err != nil {
+		errorf("❌ Failed to acknowledge: %v\n", err)
+		os.Exit(1)
+	}
+	if len(changed) == 0 {
+		fmt.Println("Already acknowledged")
+		return
+	}
+	fmt.Printf("✅ Acknowledged %d notification(s)\n", len(changed))
+}
+
+// expandUnseenID completes a shortened ID when exactly one unseen notification starts with it
+func expandUnseenID(unseen []HistoryEntry, id string) string {
+	match := ""
+	for _, entry := range unseen {
+		if strings.HasPrefix(entry.ID, id) {
+			if match != "" {
+				return id
+			}
+			match = entry.ID
+		}
+	}
+	if match == "" {
+		return id
+	}
+	return match
+}
+
+// handleStatusCommand handles `cmdbell status [--count|--prompt|--json]`. It reads history
+// directly rather than asking the daemon, so prompt segments stay fast.
+func handleStatusCommand() {
+	mode := ""
+	if len(os.Args) > 2 {
+		mode = os.Args[2]
+	}
+	switch mode {
+	case "", "--count", "--prompt", "--json":
+	default:
+		fmt.Println("Usage: cmdbell status [--count|--prompt|--json]")
+		os.Exit(1)
+	}
+
+	unseen, err := unseenEvents()
+	if err != nil && mode != "--prompt" {
+		errorf("❌ Failed to read history: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch mode {
+	case "--count":
+		fmt.Println(len(unseen))
+	case "--prompt":
+		// Prints nothing when everything has been seen, so prompts can use the output as is
+		if len(unseen) > 0 {
+			fmt.Printf("🔔 %d\n", len(unseen))
+		}
+	case "--json":
+		data, _ := json.MarshalIndent(map[string]interface{}{"unseen": len(unseen), "events": unseen}, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(unseen) == 0 {
+			fmt.Println("🔕 No unseen notifications")
+			return
+		}
+		fmt.Printf("🔔 %d unseen notification(s)\n", len(unseen))
+		for i, entry := range unseen {
+			if i == unseenListLimit {
+				fmt.Printf("   … and %d more\n", len(unseen)-unseenListLimit)
+				break
+			}
+			fmt.Printf("   %s %s  %s  %s\n", historyIcon(entry), entry.ID[:min(8, len(entry.ID))], entry.Time.Local().Format("15:04"), entry.Message)
+		}
+		fmt.Println("Run 'cmdbell ack' to mark them seen")
+	}
+}
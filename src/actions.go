@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+)
+
+// actionTimeout bounds a configured action, which runs in the background
+const actionTimeout = 5 * time.Minute
+
+var actionNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// actionLink is the deep link that runs the named action for an event
+func actionLink(name, eventID string) string {
+	return deepLink("action", name+"/"+eventID)
+}
+
+// problem describes what makes the action unusable, or returns "" when it is fine
+func (a ActionConfig) problem() string {
+	kinds := 0
+	for _, set := range []bool{a.Command != "", a.Shortcut != "", a.Focus} {
+		if set {
+			kinds++
+		}
+	}
+
+	switch {
+	case !actionNamePattern.MatchString(a.Name):
+		return fmt.Sprintf("action name %q must be letters, digits, \"-\", \"_\" or \".\"", a.Name)
+	case kinds != 1:
+		return fmt.Sprintf("action %q needs exactly one of command, shortcut and focus", a.Name)
+	}
+	return ""
+}
+
+func findAction(name string) (ActionConfig, bool) {
+	if globalConfig == nil {
+		return ActionConfig{}, false
+	}
+	for _, action := range globalConfig.Actions {
+		if action.Name == name && action.problem() == "" {
+			return action, true
+		}
+	}
+	return ActionConfig{}, false
+}
+
+// notificationButtons returns the actions offered as buttons on n's desktop notification. Actions
+// find their event in history, so there are none while history is disabled.
+func notificationButtons(n *Notification) []ActionConfig {
+	if globalConfig == nil || n.ID == "" || n.Running || getHistoryStore() == nil {
+		return nil
+	}
+
+	var buttons []ActionConfig
+	for _, action := range globalConfig.Actions {
+		if action.Button == "" || action.problem() != "" {
+			continue
+		}
+		if (action.On == "failure" && n.Success) || (action.On == "success" && !n.Success) {
+			continue
+		}
+		if action.Focus && focusable(n.Host, n.Terminal) != nil {
+			continue
+		}
+		buttons = append(buttons, action)
+	}
+	return buttons
+}
+
+// runAction runs action for a history entry. Commands get the event in CMDBELL_EVENT_* variables
+// and start in the home directory; shortcuts get it as JSON input.
+func runAction(ctx context.Context, action ActionConfig, entry HistoryEntry) error {
+	var cmd *exec.Cmd
+	if action.Focus {
+		var err error
+		if cmd, err = focusCommand(ctx, entry); err != nil {
+			return err
+		}
+	} else if action.Shortcut != "" {
+		if runtime.GOOS != "darwin" {
+			return errors.New("shortcuts are only available on macOS")
+		}
+		input, err := os.CreateTemp("", "cmdbell-event-*.json")
+		if err != nil {
+			return fmt.Errorf("failed to create shortcut input: %v", err)
+		}
+		defer os.Remove(input.Name())
+		err = json.NewEncoder(input).Encode(entry)
+		input.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write shortcut input: %v", err)
+		}
+		cmd = exec.CommandContext(ctx, "shortcuts", "run", action.Shortcut, "--input-path", input.Name())
+	} else {
+		argv := shellArgv(action.Command)
+		cmd = exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Env = append(os.Environ(), actionEnv(entry)...)
+	}
+	cmd.Dir, _ = os.UserHomeDir()
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if detail := strings.TrimSpace(string(output)); detail != "" {
+			return fmt.Errorf("%v: %s", err, detail)
+		}
+		return err
+	}
+	return nil
+}
+
+// actionEnv describes an event to an action command
+func actionEnv(entry HistoryEntry) []string {
+	return []string{
+		"CMDBELL_EVENT_ID=" + entry.ID,
+		"CMDBELL_EVENT_CORRELATION_ID=" + entry.CorrelationID,
+		"CMDBELL_EVENT_SOURCE=" + entry.Source,
+		"CMDBELL_EVENT_HOST=" + entry.Host,
+		"CMDBELL_EVENT_TITLE=" + entry.Title,
+		"CMDBELL_EVENT_MESSAGE=" + entry.Message,
+		"CMDBELL_EVENT_COMMAND=" + entry.Command,
+		"CMDBELL_EVENT_CONTAINER=" + entry.ContainerName,
+		"CMDBELL_EVENT_SUCCESS=" + strconv.FormatBool(entry.Success),
+		"CMDBELL_EVENT_DURATION=" + strconv.FormatFloat(entry.DurationSeconds, 'f', 0, 64),
+		"CMDBELL_EVENT_URL=" + entry.URL,
+		"CMDBELL_EVENT_LOG=" + entryOutputLog(entry),
+	}
+}
+
+// entryOutputLog is the local path of the output saved with an event, or "" when there is none
+func entryOutputLog(entry HistoryEntry) string {
+	if path, ok := strings.CutPrefix(entry.URL, "file://"); ok {
+		return path
+	}
+	if _, name, ok := strings.Cut(entry.URL, "/outputs/"); ok && name == filepath.Base(name) {
+		if dir, err := outputLogDir(); err == nil {
+			return filepath.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// handleAction runs a configured action for a notification, the callback behind notification
+// buttons and cmdbell://action links. Actions run commands on this machine, so only it may ask.
+func (hs *HTTPServer) handleAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !fromLocalClient(r) {
+		http.Error(w, "Actions are only accepted from this machine", http.StatusForbidden)
+		return
+	}
+	// A JSON content type cannot be sent cross-origin without a preflight
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Expected Content-Type: application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/actions/")
+	action, ok := findAction(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("No action %q", name), http.StatusNotFound)
+		return
+	}
+
+	var req client.ActionRequest
+	if err := decodePayload(r.Body, "action", &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.ID == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	entry, err := findHistoryEntry(req.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+		defer cancel()
+		if err := runAction(ctx, action, entry); err != nil {
+			log.Printf("❌ Action '%s' failed for %s: %v", action.Name, entry.ID, err)
+			return
+		}
+		log.Printf("⚡ Ran action '%s' for %s", action.Name, entry.ID)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "action": action.Name, "id": entry.ID}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// followActionLink hands cmdbell://action/<name>/<id> to the daemon, or runs the action here
+// when the daemon is not running
+func followActionLink(name, eventID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := localDaemonClient().RunAction(ctx, name, eventID)
+	var apiErr *client.APIError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &apiErr):
+		return err
+	}
+
+	action, ok := findAction(name)
+	if !ok {
+		return fmt.Errorf("no action %q in the config", name)
+	}
+	entry, err := findHistoryEntry(eventID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+	return runAction(ctx, action, entry)
+}
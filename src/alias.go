@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func handleAliasCommand() {
+	args := os.Args[2:]
+	shell := filepath.Base(os.Getenv("SHELL"))
+
+	if len(args) >= 2 && args[0] == "--shell" {
+		shell = args[1]
+		args = args[2:]
+	}
+
+	if len(args) == 0 {
+		fmt.Println("Usage: cmdbell alias [--shell bash|zsh|fish] <command>...")
+		fmt.Println("Example: eval \"$(cmdbell alias make cargo npm)\"")
+		os.Exit(1)
+	}
+
+	executablePath, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Failed to get executable path: %v\n", err)
+		os.Exit(1)
+	}
+
+	aliases, err := generateAliases(shell, executablePath, args)
+	if err != nil {
+		fmt.Printf("Failed to generate aliases: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(aliases)
+}
+
+// generateAliases returns shell functions that run each command through `cmdbell run`
+func generateAliases(shell, executablePath string, commands []string) (string, error) {
+	var b strings.Builder
+
+	for _, command := range commands {
+		if !isValidCommandName(command) {
+			return "", fmt.Errorf("invalid command name: %q", command)
+		}
+
+		switch shell {
+		case "bash", "zsh", "sh", "":
+			fmt.Fprintf(&b, "%s() { %s run %s \"$@\"; }\n", command, shellQuote(executablePath), command)
+		case "fish":
+			fmt.Fprintf(&b, "function %s; %s run %s $argv; end\n", command, shellQuote(executablePath), command)
+		default:
+			return "", fmt.Errorf("unsupported shell: %s", shell)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// isValidCommandName reports whether name can be embedded unquoted in generated shell code
+func isValidCommandName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\n'\"$`;|&<>()*?[]{}\\")
+}
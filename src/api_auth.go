@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEntry records a single API action in the audit log
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Client     string    `json:"client"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Scope      string    `json:"scope"`
+	Allowed    bool      `json:"allowed"`
+}
+
+// AuditLog appends API actions as JSON lines to ~/.cmdbell/audit.log
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewAuditLog() (*AuditLog, error) {
+	path, err := getDataPath("audit.log")
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{path: path}, nil
+}
+
+func (a *AuditLog) Record(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode audit entry: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Printf("Failed to open audit log: %v", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "%s\n", data); err != nil {
+		log.Printf("Failed to write audit entry: %v", err)
+	}
+}
+
+// tokenRequired reports whether a request must carry a token: always once http.tokens is set,
+// and once devices are paired with `cmdbell pair` for everything but this machine's own hooks
+// and CLI, so pairing a phone does not leave the API open to the rest of the LAN
+func (hs *HTTPServer) tokenRequired(r *http.Request) bool {
+	if len(hs.tokens) > 0 {
+		return true
+	}
+	devices, err := loadPairedDevices()
+	return (err != nil || len(devices) > 0) && !fromLocalClient(r)
+}
+
+// fromLocalClient reports whether a request comes over loopback from a program rather than a
+// browser page, which always sends an Origin with the requests it could forge
+func fromLocalClient(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	return err == nil && net.ParseIP(host).IsLoopback() && r.Header.Get("Origin") == ""
+}
+
+// authorize wraps a handler so it requires a token with the given scope when tokenRequired says so
+func (hs *HTTPServer) authorize(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tracef("received", "%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		client := "anonymous"
+		allowed := true
+		if name := certClient(r); name != "" {
+			client = name
+			r = r.WithContext(context.WithValue(r.Context(), requestClientKey{}, client))
+		}
+
+		if hs.tokenRequired(r) {
+			token, ok := hs.lookupToken(requestToken(r))
+			allowed = ok && token.hasScope(scope)
+			if ok {
+				client = token.Name
+				r = withRequestUser(r, token.User)
+				r = r.WithContext(context.WithValue(r.Context(), requestClientKey{}, client))
+			}
+		}
+
+		hs.audit.Record(AuditEntry{
+			Time:       time.Now(),
+			Client:     client,
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Scope:      scope,
+			Allowed:    allowed,
+		})
+
+		if !allowed {
+			log.Printf("🔒 Rejected %s %s from %s (client: %s)", r.Method, r.URL.Path, r.RemoteAddr, client)
+			if client == "anonymous" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+			}
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// tokenGrants reports whether a request carries a token with the scope, whether or not
+// http.tokens is set. Routes that store what a peer sends need one even where the rest are open.
+func (hs *HTTPServer) tokenGrants(r *http.Request, scope string) bool {
+	token, ok := hs.lookupToken(requestToken(r))
+	return ok && token.hasScope(scope)
+}
+
+type requestClientKey struct{}
+
+// requestClient returns the name of the token a request was authorized with, empty without tokens
+func requestClient(r *http.Request) string {
+	name, _ := r.Context().Value(requestClientKey{}).(string)
+	return name
+}
+
+func (hs *HTTPServer) lookupToken(presented string) (APIToken, bool) {
+	if presented == "" {
+		return APIToken{}, false
+	}
+
+	tokens := hs.tokens
+	// Devices paired with `cmdbell pair` are read per request so pairing works without a restart
+	if devices, err := loadPairedDevices(); err == nil {
+		for _, device := range devices {
+			tokens = append(tokens[:len(tokens):len(tokens)], device.apiToken())
+		}
+	}
+
+	for _, token := range tokens {
+		if token.Token != "" && subtle.ConstantTimeCompare([]byte(token.Token), []byte(presented)) == 1 {
+			return token, true
+		}
+	}
+	return APIToken{}, false
+}
+
+func (t APIToken) hasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+// requestToken extracts the API token from the Authorization or X-CmdBell-Token header
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.Header.Get("X-CmdBell-Token")
+}
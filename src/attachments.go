@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// defaultAttachMaxBytes caps each attachment unless a channel sets attach_max_bytes
+const defaultAttachMaxBytes = 32 * 1024
+
+// attachment is a file sent along with an event by channels that can carry one
+type attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// attachments returns what the channel's attach, attach_on and attach_max_bytes settings allow
+// for an event: its captured output and a JSON report of the run. Without attach, output is sent
+// as before and no report is.
+func (c *httpChannel) attachments(n *Notification) []attachment {
+	kinds := c.config.Attach
+	if kinds == nil {
+		kinds = []string{"output"}
+	}
+	if c.config.AttachOn == "failure" && n.Success {
+		return nil
+	}
+
+	limit := c.config.AttachMaxBytes
+	if limit <= 0 {
+		limit = defaultAttachMaxBytes
+	}
+
+	var attached []attachment
+	if containsString(kinds, "output") && n.Output != "" {
+		attached = append(attached, attachment{
+			Name:        "output.txt",
+			ContentType: "text/plain; charset=utf-8",
+			Data:        []byte(truncateOutput(n.Output, limit)),
+		})
+	}
+	if containsString(kinds, "report") {
+		// The report describes the run; its output travels as the output attachment
+		report := newChannelPayload(n)
+		report.Output = ""
+		if data, err := json.MarshalIndent(report, "", "  "); err == nil && len(data) <= limit {
+			attached = append(attached, attachment{Name: "report.json", ContentType: "application/json", Data: data})
+		}
+	}
+	return attached
+}
+
+// attachedOutput returns the output attachment's text, empty when the channel does not attach it
+func attachedOutput(attached []attachment) string {
+	for _, a := range attached {
+		if a.Name == "output.txt" {
+			return string(a.Data)
+		}
+	}
+	return ""
+}
+
+// truncateOutput keeps the last limit bytes of output, where errors usually are
+func truncateOutput(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+
+	const marker = "[output truncated]\n"
+	output = strings.TrimPrefix(output, marker)
+	start := len(output) - limit + len(marker)
+	if start < 0 {
+		start = 0
+	}
+	// Start on a line, or at least a rune, boundary
+	if i := strings.IndexByte(output[start:], '\n'); i >= 0 && i < 200 {
+		start += i + 1
+	}
+	for start < len(output) && output[start]&0xC0 == 0x80 {
+		start++
+	}
+	return marker + output[start:]
+}
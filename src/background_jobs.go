@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+)
+
+// backgroundPollInterval is how often the daemon checks whether watched background jobs are still running
+const backgroundPollInterval = 2 * time.Second
+
+// BackgroundJob is a job a shell put in the background (`cmd &`), watched by PID because the
+// shell only learns it finished, if at all, at some later prompt. Its exit status is unknown
+// to the daemon, which is not its parent.
+type BackgroundJob struct {
+	PID       int       `json:"pid"`
+	Command   string    `json:"command"`
+	StartTime time.Time `json:"start_time"`
+	Identity  string    `json:"identity,omitempty"` // guards against the PID being reused, where the platform allows
+}
+
+// backgroundJobs holds the watched jobs, saved to ~/.cmdbell/background.json so they survive a
+// daemon restart like marks do
+type backgroundJobs struct {
+	mu   sync.Mutex
+	path string
+	jobs map[int]*BackgroundJob
+	stop chan struct{}
+}
+
+func newBackgroundJobs() *backgroundJobs {
+	b := &backgroundJobs{jobs: make(map[int]*BackgroundJob), stop: make(chan struct{})}
+	path, err := getDataPath("background.json")
+	if err != nil {
+		return b
+	}
+	b.path = path
+	if data, err := os.ReadFile(path); err == nil {
+		var jobs []*BackgroundJob
+		if data, err = openData(data); err != nil {
+			log.Printf("Failed to read background jobs: %v", err)
+		}
+		json.Unmarshal(data, &jobs)
+		for _, job := range jobs {
+			b.jobs[job.PID] = job
+		}
+	}
+	return b
+}
+
+func (b *backgroundJobs) watch(job *BackgroundJob) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.jobs[job.PID] = job
+	b.save()
+}
+
+// run notifies for watched jobs as they exit, until Stop
+func (b *backgroundJobs) run() {
+	ticker := time.NewTicker(backgroundPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			for _, job := range b.finished() {
+				notifyBackgroundJob(job, time.Since(job.StartTime))
+			}
+		}
+	}
+}
+
+func (b *backgroundJobs) Stop() {
+	close(b.stop)
+}
+
+// finished removes and returns the jobs whose process is gone
+func (b *backgroundJobs) finished() []*BackgroundJob {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var done []*BackgroundJob
+	for pid, job := range b.jobs {
+		if !processAlive(pid, job.Identity) {
+			delete(b.jobs, pid)
+			done = append(done, job)
+		}
+	}
+	if len(done) > 0 {
+		b.save()
+		activeDBusService.jobsChanged()
+	}
+	return done
+}
+
+// running lists the background jobs still being watched
+func (b *backgroundJobs) running() []RunningJob {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	jobs := make([]RunningJob, 0, len(b.jobs))
+	for _, job := range b.jobs {
+		jobs = append(jobs, RunningJob{Source: "background", Name: job.Command, Detail: strconv.Itoa(job.PID), PID: job.PID, Started: job.StartTime})
+	}
+	return jobs
+}
+
+// save writes the jobs to disk; callers hold b.mu
+func (b *backgroundJobs) save() {
+	if b.path == "" {
+		return
+	}
+	jobs := make([]*BackgroundJob, 0, len(b.jobs))
+	for _, job := range b.jobs {
+		jobs = append(jobs, job)
+	}
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err == nil {
+		data, err = sealData(data)
+	}
+	if err != nil {
+		log.Printf("Failed to save background jobs: %v", err)
+		return
+	}
+	if err := writeFileAtomic(b.path, data, 0600); err != nil {
+		log.Printf("Failed to save background jobs: %v", err)
+	}
+}
+
+// notifyBackgroundJob reports a finished background job under the command's usual threshold
+func notifyBackgroundJob(job *BackgroundJob, duration time.Duration) {
+	resolution := resolveThreshold(job.Command)
+	log.Printf("🏁 Background job %d (%s) finished after %s", job.PID, job.Command, duration.Round(time.Second))
+	if globalConfig != nil && !globalConfig.General.EnableNotify {
+		recordSuppressed(job.Command, duration, resolution, "notifications disabled")
+		return
+	}
+	if duration < resolution.Duration {
+		recordSuppressed(job.Command, duration, resolution, "below threshold")
+		return
+	}
+
+	deliverNotification(&Notification{
+		Title:     "CmdBell",
+		Message:   fmt.Sprintf("Background job '%s' finished after %s", displayCommand(job.Command), formatDuration(duration)),
+		Icon:      "🏁",
+		Source:    "background",
+		Command:   job.Command,
+		Duration:  duration,
+		Success:   true,
+		StartTime: job.StartTime,
+	})
+}
+
+// processAlive reports whether pid is still the process that was registered
+func processAlive(pid int, identity string) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		process.Release()
+		return true
+	}
+
+	// Signal 0 only checks; EPERM means the process exists under another user
+	if err := process.Signal(syscall.Signal(0)); err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	return identity == "" || processIdentity(pid) == identity
+}
+
+// processIdentity returns the process's start time in clock ticks on Linux, which differs when a
+// PID is reused, and "" elsewhere
+func processIdentity(pid int) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return ""
+	}
+	// The command name in parentheses may contain spaces; fields resume after the last ')'
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 20 {
+		return ""
+	}
+	return fields[19]
+}
+
+func (hs *HTTPServer) handleBackground(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req client.BackgroundRequest
+	if err := decodePayload(r.Body, "background", &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.PID <= 0 || req.Command == "" {
+		http.Error(w, "pid and command are required", http.StatusBadRequest)
+		return
+	}
+	if !processAlive(req.PID, "") {
+		http.Error(w, fmt.Sprintf("No process %d on this machine", req.PID), http.StatusNotFound)
+		return
+	}
+
+	startTime := time.Now()
+	if req.StartTime != "" {
+		if parsed, err := parseStartTime(req.StartTime); err == nil {
+			startTime = parsed
+		}
+	}
+
+	hs.background.watch(&BackgroundJob{PID: req.PID, Command: req.Command, StartTime: startTime, Identity: processIdentity(req.PID)})
+	log.Printf("👀 Watching background job %d (%s)", req.PID, req.Command)
+	activeDBusService.jobsChanged()
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"status": "success",
+		"pid":    req.PID,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// handleBackgroundCommand handles `cmdbell background <pid> <start_time> <command>`, which the
+// shell hooks run when a command line leaves a job in the background
+func handleBackgroundCommand() {
+	if len(os.Args) != 5 {
+		fmt.Println("Usage: cmdbell background <pid> <start_time> <command>")
+		os.Exit(1)
+	}
+	pid, err := strconv.Atoi(os.Args[2])
+	if err != nil {
+		fmt.Printf("❌ Invalid PID: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := client.BackgroundRequest{PID: pid, StartTime: os.Args[3], Command: os.Args[4]}
+	if err := localDaemonClient().WatchBackground(ctx, req); err != nil {
+		fmt.Printf("❌ Failed to watch background job: %v\n", err)
+		os.Exit(1)
+	}
+}
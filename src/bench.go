@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultBenchRuns = 5
+
+// handleBenchCommand runs a command several times and notifies with its duration statistics,
+// compared against the previous bench of the same command:
+//
+//	cmdbell bench -n 5 -- make build
+func handleBenchCommand() {
+	args := os.Args[2:]
+	runs := defaultBenchRuns
+	if len(args) >= 2 && args[0] == "-n" {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			fmt.Printf("Invalid run count %q, expected a positive number\n", args[1])
+			os.Exit(1)
+		}
+		runs = n
+		args = args[2:]
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Println("Usage: cmdbell bench [-n runs] [--] <command> [args...]")
+		os.Exit(1)
+	}
+
+	commandLine := strings.Join(args, " ")
+	previous, hasPrevious := previousBench(commandLine)
+
+	var durations []time.Duration
+	failed := 0
+	for i := 1; i <= runs; i++ {
+		fmt.Printf("⏱️  Run %d/%d: %s\n", i, runs, commandLine)
+
+		startTime := time.Now()
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Env = append(os.Environ(), wrappedEnv+"=1")
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err := cmd.Run()
+		duration := time.Since(startTime)
+
+		status := "completed"
+		if err != nil {
+			status = "failed"
+			failed++
+		}
+		fmt.Printf("⏱️  Run %d/%d %s after %s\n", i, runs, status, benchDuration(duration))
+		durations = append(durations, duration)
+
+		// Each run goes to history on its own, the summary below is what notifies
+		recordHistory(&Notification{
+			ID:        newEventID(),
+			Time:      time.Now(),
+			Title:     "CmdBell",
+			Message:   fmt.Sprintf("Bench run %d/%d of '%s' %s after %s", i, runs, displayCommand(commandLine), status, benchDuration(duration)),
+			Source:    "bench-run",
+			Command:   commandLine,
+			Duration:  duration,
+			Success:   err == nil,
+			StartTime: startTime,
+		})
+	}
+
+	minimum, median, maximum := benchStats(durations)
+	message := fmt.Sprintf("Bench '%s' (%d runs): min %s, median %s, max %s",
+		displayCommand(commandLine), runs, benchDuration(minimum), benchDuration(median), benchDuration(maximum))
+	if hasPrevious {
+		message += "; " + benchComparison(median, previous)
+	}
+	if failed > 0 {
+		message += fmt.Sprintf("; %d of %d runs failed", failed, runs)
+	}
+
+	icon := "⏱️"
+	if failed > 0 {
+		icon = "❌"
+	}
+	if globalConfig != nil && globalConfig.General.EnableNotify {
+		// The median stands for the bench in history, which is what the next bench compares against
+		deliverNotification(&Notification{
+			Title:    "CmdBell",
+			Message:  message,
+			Icon:     icon,
+			Source:   "bench",
+			Command:  commandLine,
+			Duration: median,
+			Success:  failed == 0,
+		})
+		waitForDeliveries()
+	} else {
+		fmt.Println(message)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// previousBench returns the median of the last bench of the same command, as recorded in history
+func previousBench(commandLine string) (time.Duration, bool) {
+	store := getHistoryStore()
+	if store == nil {
+		return 0, false
+	}
+
+	entries, err := store.Query(HistoryFilter{Source: "bench"})
+	if err != nil {
+		return 0, false
+	}
+	command := sanitizeCommand(commandLine)
+	for _, entry := range entries {
+		if entry.Command == command {
+			return time.Duration(entry.DurationSeconds * float64(time.Second)), true
+		}
+	}
+	return 0, false
+}
+
+func benchStats(durations []time.Duration) (minimum, median, maximum time.Duration) {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	middle := len(sorted) / 2
+	median = sorted[middle]
+	if len(sorted)%2 == 0 {
+		median = (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[0], median, sorted[len(sorted)-1]
+}
+
+// benchComparison describes a median against the previous bench's, e.g. "12% faster than the previous bench (1m 14s)"
+func benchComparison(median, previous time.Duration) string {
+	if previous <= 0 {
+		return fmt.Sprintf("previous bench took %s", benchDuration(previous))
+	}
+
+	change := math.Round(float64(median-previous) / float64(previous) * 100)
+	switch {
+	case change < 0:
+		return fmt.Sprintf("%.0f%% faster than the previous bench (%s)", -change, benchDuration(previous))
+	case change > 0:
+		return fmt.Sprintf("%.0f%% slower than the previous bench (%s)", change, benchDuration(previous))
+	default:
+		return fmt.Sprintf("same as the previous bench (%s)", benchDuration(previous))
+	}
+}
+
+// benchDuration keeps sub-second precision for short runs, where it is what a bench is about
+func benchDuration(d time.Duration) string {
+	if d < 10*time.Second {
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	}
+	return formatDuration(d)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// commandCategory is a built-in class of commands, matched like a thresholds rule
+type commandCategory struct {
+	Name     string
+	Icon     string
+	Commands []string
+}
+
+// builtinCategories are checked in order after categories.rules, so tests run through make or
+// npm are tests rather than builds
+var builtinCategories = []commandCategory{
+	{"test", "🧪", []string{
+		"go test", "cargo test", "pytest", "tox", "jest", "vitest", "rspec", "phpunit", "ctest",
+		"npm test", "npm run test", "yarn test", "pnpm test", "make test", "make check",
+		"mvn test", "gradle test", "./gradlew test", "dotnet test", "bazel test", "mix test",
+	}},
+	{"deploy", "🚀", []string{
+		"kubectl apply", "kubectl rollout", "helm install", "helm upgrade", "terraform apply",
+		"pulumi up", "ansible-playbook", "fly deploy", "flyctl deploy", "vercel", "netlify deploy",
+		"serverless deploy", "sls deploy", "cap", "eb deploy", "gcloud app deploy", "firebase deploy",
+		"make deploy", "npm run deploy",
+	}},
+	{"package-manager", "📦", []string{
+		"npm install", "npm ci", "npm update", "yarn install", "yarn add", "pnpm install", "pnpm add",
+		"pip install", "pip3 install", "poetry install", "uv sync", "conda install", "apt", "apt-get",
+		"brew", "dnf", "yum", "pacman", "zypper", "apk", "snap", "cargo install", "cargo fetch",
+		"go mod download", "go get", "bundle install", "gem install", "composer install", "nix-env",
+	}},
+	{"data", "💾", []string{
+		"rsync", "scp", "rclone", "aws s3", "gsutil", "azcopy", "pg_dump", "pg_restore", "mysqldump",
+		"mongodump", "mongorestore", "sqlite3", "psql", "mysql", "dd", "tar", "zip", "unzip", "7z",
+		"wget", "curl", "restic", "borg",
+	}},
+	{"build", "🔨", []string{
+		"make", "cmake", "ninja", "meson", "bazel build", "go build", "go install", "cargo build",
+		"npm run build", "yarn build", "pnpm build", "tsc", "webpack", "vite build", "next build",
+		"mvn", "gradle", "./gradlew", "dotnet build", "msbuild", "docker build", "docker buildx",
+		"podman build", "gcc", "g++", "clang", "javac", "swift build", "xcodebuild", "hugo",
+	}},
+}
+
+// classifyCommand returns the category of commandLine: the first categories rule that matches,
+// then the built-in patterns, or "" when none apply
+func classifyCommand(commandLine string) string {
+	if commandLine == "" {
+		return ""
+	}
+	if globalConfig != nil {
+		for _, rule := range globalConfig.Categories.Rules {
+			if matchCommandRule(rule.Commands, rule.Pattern, commandLine) != "" {
+				return rule.Category
+			}
+		}
+	}
+	for _, category := range builtinCategories {
+		if matchCommandRule(category.Commands, "", commandLine) != "" {
+			return category.Name
+		}
+	}
+	return ""
+}
+
+// categoryIcon returns the emoji for a category, from categories.icons or the built-ins
+func categoryIcon(category string) string {
+	if globalConfig != nil {
+		if icon, ok := globalConfig.Categories.Icons[category]; ok {
+			return icon
+		}
+	}
+	for _, builtin := range builtinCategories {
+		if builtin.Name == category {
+			return builtin.Icon
+		}
+	}
+	return ""
+}
+
+// entryCategory is an entry's recorded category, classified now for entries from before categories
+func entryCategory(entry HistoryEntry) string {
+	if entry.Category != "" {
+		return entry.Category
+	}
+	return classifyCommand(entry.Command)
+}
+
+// categoryStats summarizes the history entries of one category
+type categoryStats struct {
+	Category  string
+	Runs      int
+	Failed    int
+	Total     time.Duration
+	durations []time.Duration
+}
+
+func (s *categoryStats) Median() time.Duration {
+	if len(s.durations) == 0 {
+		return 0
+	}
+	sort.Slice(s.durations, func(i, j int) bool { return s.durations[i] < s.durations[j] })
+	return s.durations[len(s.durations)/2]
+}
+
+// handleHistoryStatsCommand handles `cmdbell history stats [--source S] [--host H]`, breaking
+// command history down by category
+func handleHistoryStatsCommand(args []string) {
+	filter := HistoryFilter{}
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--source" && i+1 < len(args):
+			i++
+			filter.Source = args[i]
+		case args[i] == "--host" && i+1 < len(args):
+			i++
+			filter.Host = args[i]
+		default:
+			fmt.Println("Usage: cmdbell history stats [--source S] [--host H]")
+			os.Exit(1)
+		}
+	}
+
+	store := getHistoryStore()
+	if store == nil {
+		fmt.Println("History is disabled (history.enabled: false)")
+		os.Exit(1)
+	}
+	entries, err := store.Query(filter)
+	if err != nil {
+		fmt.Printf("Failed to read history: %v\n", err)
+		os.Exit(1)
+	}
+
+	byCategory := map[string]*categoryStats{}
+	for _, entry := range entries {
+		if entry.Command == "" {
+			continue
+		}
+		category := entryCategory(entry)
+		if category == "" {
+			category = "other"
+		}
+		stats, ok := byCategory[category]
+		if !ok {
+			stats = &categoryStats{Category: category}
+			byCategory[category] = stats
+		}
+		duration := time.Duration(entry.DurationSeconds * float64(time.Second))
+		stats.Runs++
+		stats.Total += duration
+		stats.durations = append(stats.durations, duration)
+		if !entry.Success {
+			stats.Failed++
+		}
+	}
+	if len(byCategory) == 0 {
+		fmt.Println("No commands in history yet")
+		return
+	}
+
+	var sorted []*categoryStats
+	for _, stats := range byCategory {
+		sorted = append(sorted, stats)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Total > sorted[j].Total })
+
+	fmt.Printf("   %-16s %6s %7s %12s %12s\n", "CATEGORY", "RUNS", "FAILED", "TOTAL", "MEDIAN")
+	for _, stats := range sorted {
+		icon := categoryIcon(stats.Category)
+		if icon == "" {
+			icon = "  "
+		}
+		fmt.Printf("%s %-16s %6d %7d %12s %12s\n", icon, stats.Category,
+			stats.Runs, stats.Failed, formatDuration(stats.Total), formatDuration(stats.Median()))
+	}
+}
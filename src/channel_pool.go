@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Each channel delivers from its own queue with a few workers, so a slow channel only delays its
+// own sends, and a circuit breaker pauses a channel that keeps failing instead of hammering it
+const (
+	defaultChannelConcurrency = 2
+	defaultBreakerFailures    = 5
+	defaultBreakerCooldown    = 5 * time.Minute
+	channelQueueSize          = 256
+)
+
+var (
+	channelPoolsMu sync.Mutex
+	channelPools   = map[string]*channelPool{}
+)
+
+type channelSend struct {
+	n       *Notification
+	trace   *decisionTrace
+	timeout time.Duration
+}
+
+type channelPool struct {
+	channel  Channel
+	sends    chan channelSend
+	failures int // consecutive failures that open the breaker, 0 or less never opens it
+	cooldown time.Duration
+
+	mu          sync.Mutex
+	consecutive int
+	openUntil   time.Time
+	probing     bool // the breaker let one send through after its cooldown
+}
+
+// channelPoolFor returns the pool delivering to channel, starting its workers on first use
+func channelPoolFor(channel Channel) *channelPool {
+	channelPoolsMu.Lock()
+	defer channelPoolsMu.Unlock()
+
+	if pool, ok := channelPools[channel.Name()]; ok {
+		return pool
+	}
+
+	pool := &channelPool{
+		channel:  channel,
+		failures: defaultBreakerFailures,
+		cooldown: defaultBreakerCooldown,
+	}
+	concurrency := defaultChannelConcurrency
+	if config, ok := channelConfigNamed(channel.Name()); ok {
+		if config.Concurrency > 0 {
+			concurrency = config.Concurrency
+		}
+		if config.BreakerFailures != 0 {
+			pool.failures = config.BreakerFailures
+		}
+		if cooldown, err := time.ParseDuration(config.BreakerCooldown); err == nil && cooldown > 0 {
+			pool.cooldown = cooldown
+		}
+	}
+	pool.sends = make(chan channelSend, channelQueueSize)
+	for i := 0; i < concurrency; i++ {
+		go pool.work()
+	}
+	channelPools[channel.Name()] = pool
+	return pool
+}
+
+// channelConfigNamed finds a channel's settings by the name channels go by
+func channelConfigNamed(name string) (ChannelConfig, bool) {
+	if globalConfig == nil {
+		return ChannelConfig{}, false
+	}
+	for _, config := range globalConfig.Channels {
+		configName := config.Name
+		if configName == "" {
+			configName = config.Type
+		}
+		if configName == name {
+			return config, true
+		}
+	}
+	return ChannelConfig{}, false
+}
+
+// submit queues n for the channel without waiting; when the queue is full the send fails
+func (p *channelPool) submit(trace *decisionTrace, n *Notification, timeout time.Duration) {
+	pendingDeliveries.Add(1)
+	trace.wg.Add(1)
+	select {
+	case p.sends <- channelSend{n: n, trace: trace, timeout: timeout}:
+	default:
+		defer pendingDeliveries.Done()
+		defer trace.wg.Done()
+		err := fmt.Errorf("%d sends already queued", cap(p.sends))
+		errorf("Failed to send notification to channel %s: %v\n", p.channel.Name(), err)
+		trace.record("channel "+p.channel.Name(), "failed", err)
+	}
+}
+
+func (p *channelPool) work() {
+	for send := range p.sends {
+		p.deliver(send)
+	}
+}
+
+func (p *channelPool) deliver(send channelSend) {
+	defer pendingDeliveries.Done()
+	defer send.trace.wg.Done()
+
+	target := "channel " + p.channel.Name()
+	if err := p.allow(); err != nil {
+		send.trace.record(target, "skipped", err)
+		tracef("delivered", "%s skips %s: %v", send.trace.decision.ID, target, err)
+		return
+	}
+	err := runDelivery(send.trace, "notification to "+target, target, send.timeout, func(ctx context.Context) error {
+		return p.channel.Send(ctx, send.n)
+	})
+	p.report(err)
+}
+
+// allow returns why the channel is paused, or nil when a send may go out. Once the cooldown is
+// over a single send probes the channel; the breaker closes again if it succeeds.
+func (p *channelPool) allow() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failures <= 0 || p.consecutive < p.failures {
+		return nil
+	}
+	if time.Now().Before(p.openUntil) {
+		return fmt.Errorf("paused until %s after %d consecutive failures", p.openUntil.Local().Format("15:04:05"), p.consecutive)
+	}
+	if p.probing {
+		return fmt.Errorf("paused while a retry is in progress after %d consecutive failures", p.consecutive)
+	}
+	p.probing = true
+	return nil
+}
+
+// report feeds a send's outcome to the circuit breaker
+func (p *channelPool) report(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	probe := p.probing
+	p.probing = false
+	if err == nil {
+		if p.failures > 0 && p.consecutive >= p.failures {
+			log.Printf("▶️  Channel %s is delivering again", p.channel.Name())
+		}
+		p.consecutive = 0
+		return
+	}
+
+	p.consecutive++
+	if p.failures > 0 && p.consecutive >= p.failures && (probe || p.consecutive == p.failures) {
+		p.openUntil = time.Now().Add(p.cooldown)
+		log.Printf("⏸️  Pausing channel %s for %s after %d consecutive failures: %v", p.channel.Name(), formatDuration(p.cooldown), p.consecutive, err)
+	}
+}
+
+// addChannelMetrics reports sends waiting in channel queues and channels paused by their breaker
+func addChannelMetrics(metrics map[string]int) {
+	channelPoolsMu.Lock()
+	defer channelPoolsMu.Unlock()
+
+	queued, paused := 0, 0
+	now := time.Now()
+	for _, pool := range channelPools {
+		queued += len(pool.sends)
+		pool.mu.Lock()
+		if pool.failures > 0 && pool.consecutive >= pool.failures && now.Before(pool.openUntil) {
+			paused++
+		}
+		pool.mu.Unlock()
+	}
+	metrics["channel_sends_queued"] = queued
+	metrics["channels_paused"] = paused
+}
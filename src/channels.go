@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Channel is a remote destination notifications are forwarded to
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, n *Notification) error
+}
+
+// ChannelPayload is the JSON document sent to webhooks and, encrypted, to any channel
+type ChannelPayload struct {
+	SchemaVersion   int       `json:"schema_version"`
+	ID              string    `json:"id,omitempty"`
+	CorrelationID   string    `json:"correlation_id,omitempty"`
+	Title           string    `json:"title"`
+	Message         string    `json:"message"`
+	Icon            string    `json:"icon,omitempty"`
+	Source          string    `json:"source"`
+	Command         string    `json:"command,omitempty"`
+	ContainerName   string    `json:"container_name,omitempty"`
+	Service         string    `json:"service,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Success         bool      `json:"success"`
+	Host            string    `json:"host"`
+	URL             string    `json:"url,omitempty"`
+	Output          string    `json:"output,omitempty"`
+	TimedOut        bool      `json:"timed_out,omitempty"`
+	Terminal        Terminal  `json:"terminal,omitzero"`
+	StartedAt       time.Time `json:"started_at,omitzero"`
+	Time            time.Time `json:"time"`
+}
+
+var (
+	channelsOnce sync.Once
+	channels     []Channel
+)
+
+func NewChannel(config ChannelConfig) (Channel, error) {
+	if err := resolveChannelSecrets(&config); err != nil {
+		return nil, fmt.Errorf("channel %q: %v", config.Name, err)
+	}
+	if config.URL == "" && config.Type == "telegram" {
+		config.URL = telegramAPI
+	}
+	if config.URL == "" && config.Type != "hub" && config.Type != "queue" {
+		return nil, fmt.Errorf("channel %q has no url", config.Name)
+	}
+	if config.Name == "" {
+		config.Name = config.Type
+	}
+
+	format, err := resolveChannelFormat(config)
+	if err != nil {
+		return nil, fmt.Errorf("channel %q: %v", config.Name, err)
+	}
+	config.Format = format
+
+	var key []byte
+	if config.EncryptionKey != "" {
+		key, err = parseEncryptionKey(config.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("channel %q: %v", config.Name, err)
+		}
+	}
+
+	transport, err := tlsTransport(config.TLSCA, config.TLSCert, config.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("channel %q: %v", config.Name, err)
+	}
+	base := httpChannel{
+		config: config,
+		key:    key,
+		client: &http.Client{Transport: transport},
+	}
+
+	switch config.Type {
+	case "webhook":
+		return &webhookChannel{base}, nil
+	case "ntfy":
+		return &ntfyChannel{base}, nil
+	case "hub":
+		return &hubChannel{base}, nil
+	case "telegram":
+		if config.Token == "" || config.ChatID == "" {
+			return nil, fmt.Errorf("channel %q needs token and chat_id", config.Name)
+		}
+		return &telegramChannel{base}, nil
+	case "queue":
+		return &queueChannel{name: config.Name}, nil
+	default:
+		return nil, fmt.Errorf("channel %q has unsupported type: %s", config.Name, config.Type)
+	}
+}
+
+// resolveChannelSecrets replaces keychain: references in the settings that hold credentials
+func resolveChannelSecrets(config *ChannelConfig) error {
+	var err error
+	if config.URL, err = resolveSecret(config.URL); err != nil {
+		return err
+	}
+	if config.Token, err = resolveSecret(config.Token); err != nil {
+		return err
+	}
+	if config.EncryptionKey, err = resolveSecret(config.EncryptionKey); err != nil {
+		return err
+	}
+
+	headers := make(map[string]string, len(config.Headers))
+	for name, value := range config.Headers {
+		if headers[name], err = resolveSecret(value); err != nil {
+			return err
+		}
+	}
+	config.Headers = headers
+	return nil
+}
+
+func loadChannels(config *Config) []Channel {
+	var loaded []Channel
+	if config == nil {
+		return loaded
+	}
+
+	for _, channelConfig := range config.Channels {
+		channel, err := NewChannel(channelConfig)
+		if err != nil {
+			log.Printf("⚠️  Skipping channel: %v", err)
+			continue
+		}
+		if channelConfig.DryRun || dryRunAll() {
+			channel = &dryRunChannel{channel}
+		}
+		loaded = append(loaded, channel)
+	}
+	return loaded
+}
+
+func getChannels() []Channel {
+	channelsOnce.Do(func() {
+		channels = loadChannels(globalConfig)
+	})
+	return channels
+}
+
+// channelNotifiesOn reports whether the channel's notify_on takes the event's outcome, such as
+// a chat channel that only hears about failures while the desktop hears everything
+func channelNotifiesOn(channel Channel, n *Notification) bool {
+	channelConfig, ok := channelConfigNamed(channel.Name())
+	return !ok || len(channelConfig.NotifyOn) == 0 || slices.Contains(channelConfig.NotifyOn, n.outcome())
+}
+
+func newChannelPayload(n *Notification) ChannelPayload {
+	host, _ := os.Hostname()
+	return ChannelPayload{
+		SchemaVersion:   schemaVersion,
+		ID:              n.ID,
+		CorrelationID:   n.CorrelationID,
+		Title:           n.Title,
+		Message:         n.RemoteMessage(),
+		Icon:            n.Icon,
+		Source:          n.Source,
+		Command:         sanitizeCommand(n.Command),
+		ContainerName:   n.ContainerName,
+		Service:         n.Service,
+		DurationSeconds: n.Duration.Seconds(),
+		Success:         n.Success,
+		Host:            host,
+		URL:             n.URL,
+		Output:          n.Output,
+		TimedOut:        n.TimedOut,
+		Terminal:        n.Terminal,
+		StartedAt:       n.StartTime,
+		Time:            n.Time,
+	}
+}
+
+// toNotification rebuilds an event received from another CmdBell instance, titled with its origin host
+func (p ChannelPayload) toNotification() *Notification {
+	title := p.Title
+	if title == "" {
+		title = "CmdBell"
+	}
+
+	// The origin's event ID is kept, so both ends record the event under the same ID
+	id := p.ID
+	if !eventIDPattern.MatchString(id) {
+		id = ""
+	}
+
+	return &Notification{
+		ID:            id,
+		CorrelationID: p.CorrelationID,
+		Title:         fmt.Sprintf("%s (%s)", title, p.Host),
+		Message:       p.Message,
+		Icon:          p.Icon,
+		Source:        p.Source,
+		Command:       p.Command,
+		ContainerName: p.ContainerName,
+		Service:       p.Service,
+		Duration:      time.Duration(p.DurationSeconds * float64(time.Second)),
+		Success:       p.Success,
+		Time:          p.Time,
+		Host:          p.Host,
+		URL:           p.URL,
+		Output:        p.Output,
+		TimedOut:      p.TimedOut,
+		Terminal:      p.Terminal,
+		StartTime:     p.StartedAt,
+	}
+}
+
+type httpChannel struct {
+	config ChannelConfig
+	key    []byte
+	client *http.Client
+}
+
+func (c *httpChannel) Name() string {
+	return c.config.Name
+}
+
+// encryptedBody returns the payload sealed with the channel key, or nil when encryption is off
+func (c *httpChannel) encryptedBody(n *Notification) ([]byte, error) {
+	if c.key == nil {
+		return nil, nil
+	}
+
+	payload, err := json.Marshal(c.payload(n))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	return encryptPayload(c.key, payload)
+}
+
+// payload is the event's ChannelPayload with only the output the channel's attach settings allow
+func (c *httpChannel) payload(n *Notification) ChannelPayload {
+	payload := newChannelPayload(n)
+	payload.Output = attachedOutput(c.attachments(n))
+	return payload
+}
+
+func (c *httpChannel) post(ctx context.Context, body []byte, headers map[string]string) error {
+	return c.postTo(ctx, c.config.URL, body, headers)
+}
+
+func (c *httpChannel) postTo(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+	for name, value := range c.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+type webhookChannel struct {
+	httpChannel
+}
+
+func (c *webhookChannel) Send(ctx context.Context, n *Notification) error {
+	body, err := c.encryptedBody(n)
+	if err != nil {
+		return err
+	}
+
+	if body == nil {
+		// Chat webhooks cannot take files, so output goes into the message as a code block;
+		// the JSON payload is the report itself
+		output := attachedOutput(c.attachments(n))
+		switch c.config.Format {
+		case formatMarkdown:
+			body, err = json.Marshal(slackBody(n, output, c.config.SlackSigningSecret != ""))
+		case formatShort:
+			body, err = json.Marshal(map[string]string{"text": n.RemoteMessage() + codeBlock(output)})
+		case formatLong:
+			body, err = json.Marshal(map[string]string{"text": longText(n.RemoteMessage(), messageFields(n, sanitizeCommand(n.Command))) + codeBlock(output)})
+		default:
+			body, err = json.Marshal(c.payload(n))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload: %v", err)
+		}
+	}
+
+	return c.post(ctx, body, map[string]string{"Content-Type": "application/json"})
+}
+
+type ntfyChannel struct {
+	httpChannel
+}
+
+func (c *ntfyChannel) Send(ctx context.Context, n *Notification) error {
+	body, err := c.encryptedBody(n)
+	if err != nil {
+		return err
+	}
+
+	// Encrypted messages carry no readable title or tags for the ntfy server to see
+	if body != nil {
+		return c.post(ctx, body, map[string]string{"Tags": "lock"})
+	}
+
+	tag := "white_check_mark"
+	if !n.Success {
+		tag = "x"
+	}
+
+	headers := map[string]string{
+		"Title": strings.TrimSpace(n.Title),
+		"Tags":  tag,
+	}
+	if n.URL != "" {
+		headers["Click"] = n.URL
+	}
+	if n.Escalated {
+		headers["Priority"] = ntfyPriority("critical")
+	} else if style := styleFor(n); style.Bucket != "" {
+		headers["Priority"] = ntfyPriority(style.Urgency)
+	}
+
+	message := n.RemoteMessage()
+	switch c.config.Format {
+	case formatLong:
+		message = longText(message, messageFields(n, sanitizeCommand(n.Command)))
+	case formatMarkdown:
+		message = markdownText(message, messageFields(n, sanitizeCommand(n.Command)))
+		headers["Markdown"] = "yes"
+	}
+
+	// ntfy takes one file per message, uploaded as the body with the message moved to a header
+	if attached := c.attachments(n); len(attached) > 0 {
+		headers["Filename"] = attached[0].Name
+		headers["Message"] = strings.ReplaceAll(message, "\n", `\n`) // ntfy turns \n back into line breaks
+		return c.post(ctx, attached[0].Data, headers)
+	}
+	return c.post(ctx, []byte(message), headers)
+}
+
+// hubChannel forwards events to a central CmdBell daemon that delivers them on its desktop
+type hubChannel struct {
+	httpChannel
+}
+
+func (c *hubChannel) Send(ctx context.Context, n *Notification) error {
+	body, err := json.Marshal(newChannelPayload(n))
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	if c.config.URL != "" {
+		return c.postTo(ctx, strings.TrimRight(c.config.URL, "/")+"/events", body, headers)
+	}
+
+	// Without a URL, forward to the hub paired with `cmdbell hub pair`
+	hub, err := loadPairedHub()
+	if err != nil {
+		return fmt.Errorf("no url set and no hub paired: %v", err)
+	}
+
+	err = c.postTo(ctx, hub.URL+"/events", body, headers)
+	var netErr net.Error
+	if err == nil || !errors.As(err, &netErr) {
+		return err
+	}
+
+	// The hub may have moved to a new address since it was paired
+	hub, rediscoverErr := rediscoverPairedHub(ctx, hub)
+	if rediscoverErr != nil {
+		return fmt.Errorf("%v (%v)", err, rediscoverErr)
+	}
+	return c.postTo(ctx, hub.URL+"/events", body, headers)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// CmdBell's own messages around a wrapped command go to stderr, so the command's stdout stays
+// byte-for-byte its own and `cmdbell make | jq` works. --quiet (or CMDBELL_QUIET=1) silences
+// them; NO_COLOR or TERM=dumb drops their emoji decoration.
+
+// quietMode reports whether --quiet or CMDBELL_QUIET=1 silenced status output
+func quietMode() bool {
+	return os.Getenv("CMDBELL_QUIET") == "1"
+}
+
+// plainOutput reports whether the terminal asked for undecorated output (https://no-color.org)
+func plainOutput() bool {
+	return os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb"
+}
+
+// statusf prints a status message to stderr unless quiet
+func statusf(format string, args ...interface{}) {
+	if quietMode() {
+		return
+	}
+	fmt.Fprint(os.Stderr, decorate(fmt.Sprintf(format, args...)))
+}
+
+// errorf prints an error to stderr, even when quiet
+func errorf(format string, args ...interface{}) {
+	fmt.Fprint(os.Stderr, decorate(fmt.Sprintf(format, args...)))
+}
+
+// decorate drops the leading emoji of each line for plain output
+func decorate(message string) string {
+	if !plainOutput() {
+		return message
+	}
+
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		rest := strings.TrimLeftFunc(line[len(indent):], func(r rune) bool {
+			// Emoji, their variation selectors and joiners, and the spaces after them
+			return r >= 0x2000 || unicode.IsSpace(r)
+		})
+		if rest != line[len(indent):] {
+			lines[i] = indent + rest
+		}
+	}
+	return strings.Join(lines, "\n")
+}
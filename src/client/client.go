@@ -0,0 +1,490 @@
+// Package client is a Go client for the CmdBell daemon HTTP API.
+//
+// It follows the contract in openapi.json, which the daemon also serves at
+// /openapi.json. Types and methods map one to one onto the schemas and
+// operations of that document.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultURL is where a local daemon listens with the default configuration
+const DefaultURL = "http://localhost:59721"
+
+// SchemaVersion is the version of the request and event payloads this package describes. The
+// client declares it as schema_version in every request body, so a newer daemon translates the
+// payload instead of misreading it.
+const SchemaVersion = 1
+
+// RuntimeFile is where a running daemon records how to reach it, relative to the home
+// directory. The port differs from the configured one when the daemon had to fall back to a
+// free port.
+const RuntimeFile = ".cmdbell/runtime.json"
+
+// Runtime describes a running daemon, as recorded in RuntimeFile
+type Runtime struct {
+	PID       int       `json:"pid"`
+	Port      int       `json:"port"`
+	URL       string    `json:"url"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// LocalURL returns the URL of the daemon running on this machine as recorded in RuntimeFile,
+// or DefaultURL when no daemon has recorded one
+func LocalURL() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return DefaultURL
+	}
+	data, err := os.ReadFile(filepath.Join(home, RuntimeFile))
+	if err != nil {
+		return DefaultURL
+	}
+	var runtime Runtime
+	if json.Unmarshal(data, &runtime) != nil || runtime.URL == "" {
+		return DefaultURL
+	}
+	return runtime.URL
+}
+
+// Client talks to one CmdBell daemon
+type Client struct {
+	BaseURL    string
+	Token      string // sent as a bearer token when set
+	HTTPClient *http.Client
+	// CorrelationID is sent as the X-Correlation-ID header when set, tagging the events the
+	// client reports with the caller's own job ID
+	CorrelationID string
+}
+
+// New returns a client for the daemon at baseURL, or DefaultURL when empty
+func New(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NotificationRequest reports a finished command (POST /notify)
+type NotificationRequest struct {
+	Command       string   `json:"command"`
+	ContainerName string   `json:"container_name,omitempty"`
+	Duration      string   `json:"duration"`
+	Success       bool     `json:"success"`
+	StartTime     string   `json:"start_time,omitempty"` // Unix seconds or RFC 3339
+	CorrelationID string   `json:"correlation_id,omitempty"`
+	Terminal      Terminal `json:"terminal,omitzero"`
+}
+
+// Terminal identifies the terminal a command ran in
+type Terminal struct {
+	ShellPID int    `json:"shell_pid,omitempty"`
+	TTY      string `json:"tty,omitempty"`
+	Emulator string `json:"emulator,omitempty"` // e.g. kitty, iTerm2, vscode or tmux
+	Window   string `json:"window,omitempty"`   // the emulator's ID for the window, tab or pane
+	Control  string `json:"control,omitempty"`  // where the emulator takes remote control, e.g. kitty's socket
+}
+
+// BackgroundRequest asks the daemon to notify when a backgrounded job exits (POST /background)
+type BackgroundRequest struct {
+	PID       int    `json:"pid"`
+	Command   string `json:"command"`
+	StartTime string `json:"start_time,omitempty"` // Unix seconds or RFC 3339
+}
+
+// MuteRequest silences commands matching Pattern for Duration (POST /mutes)
+type MuteRequest struct {
+	Pattern  string `json:"pattern"`            // command name or prefix, such as "npm run dev"
+	Duration string `json:"duration,omitempty"` // Go duration, 1h when empty
+}
+
+// ActionRequest runs a configured action for a notification (POST /actions/{name})
+type ActionRequest struct {
+	ID string `json:"id"` // history ID of the notification
+}
+
+// Mute is an active temporary suppression rule
+type Mute struct {
+	ID      string    `json:"id"`
+	Pattern string    `json:"pattern"`
+	Created time.Time `json:"created"`
+	Until   time.Time `json:"until"`
+}
+
+// TaskRequest reports a task finished by an editor task runner (POST /tasks)
+type TaskRequest struct {
+	Task            string  `json:"task"`
+	Source          string  `json:"source"`
+	Workspace       string  `json:"workspace,omitempty"`
+	Duration        string  `json:"duration,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Success         bool    `json:"success"`
+	ExitCode        *int    `json:"exit_code,omitempty"` // overrides Success when set
+	URL             string  `json:"url,omitempty"`
+	CorrelationID   string  `json:"correlation_id,omitempty"`
+}
+
+// Event is an event forwarded to a hub daemon (POST /events)
+type Event struct {
+	ID              string    `json:"id,omitempty"` // the origin's event ID, kept by the hub
+	CorrelationID   string    `json:"correlation_id,omitempty"`
+	Title           string    `json:"title,omitempty"`
+	Message         string    `json:"message"`
+	Icon            string    `json:"icon,omitempty"`
+	Source          string    `json:"source,omitempty"`
+	Command         string    `json:"command,omitempty"`
+	ContainerName   string    `json:"container_name,omitempty"`
+	Service         string    `json:"service,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Success         bool      `json:"success"`
+	Host            string    `json:"host,omitempty"`
+	URL             string    `json:"url,omitempty"`
+	TimedOut        bool      `json:"timed_out,omitempty"` // the work ran past its timeout or budget
+	Terminal        Terminal  `json:"terminal,omitzero"`
+	StartedAt       time.Time `json:"started_at,omitzero"`
+	Time            time.Time `json:"time"`
+}
+
+// HistoryEntry is one delivered notification
+type HistoryEntry struct {
+	ID              string    `json:"id"`
+	Time            time.Time `json:"time"`
+	Host            string    `json:"host"`
+	Source          string    `json:"source"`
+	Title           string    `json:"title"`
+	Message         string    `json:"message"`
+	Command         string    `json:"command,omitempty"`
+	Category        string    `json:"category,omitempty"`
+	ContainerName   string    `json:"container_name,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Success         bool      `json:"success"`
+	URL             string    `json:"url,omitempty"`
+	StartedAt       time.Time `json:"started_at,omitzero"`
+	User            string    `json:"user,omitempty"` // set by daemons in system mode
+	CorrelationID   string    `json:"correlation_id,omitempty"`
+	Terminal        Terminal  `json:"terminal,omitzero"`
+	OriginID        string    `json:"origin_id,omitempty"` // the entry's ID on the machine it was merged from
+}
+
+// HistoryQuery filters GET /history; zero values match everything
+type HistoryQuery struct {
+	Limit    int
+	Source   string
+	Host     string
+	Search   string
+	Category string
+	Failed   bool
+	// CorrelationID matches events tagged with a caller's job ID
+	CorrelationID string
+	// Terminal matches commands run in a terminal by its TTY, window ID or shell PID
+	Terminal string
+}
+
+// QueuedEvent is an event waiting in a daemon's outbox
+type QueuedEvent struct {
+	Seq   int64 `json:"seq"`
+	Event Event `json:"event"`
+}
+
+// PollResponse is returned by GET /poll; pass Cursor as since on the next poll
+type PollResponse struct {
+	Events []QueuedEvent `json:"events"`
+	Cursor int64         `json:"cursor"`
+}
+
+// Status is the daemon state reported by GET /status
+type Status struct {
+	Status              string          `json:"status"`
+	Host                string          `json:"host"`
+	PID                 int             `json:"pid"`
+	StartedAt           time.Time       `json:"started_at"`
+	UptimeSeconds       int             `json:"uptime_seconds"`
+	HookVersion         int             `json:"hook_version"`
+	SchemaVersion       int             `json:"schema_version"`
+	OldestSchemaVersion int             `json:"oldest_schema_version"` // oldest payload version the daemon still accepts
+	ConfigPath          string          `json:"config_path"`
+	Components          map[string]bool `json:"components"`
+	Metrics             map[string]int  `json:"metrics"`
+	Unseen              int             `json:"unseen"`
+	Profile             string          `json:"profile"`
+}
+
+// PromptStatus is the short summary from GET /prompt, for shell prompt segments
+type PromptStatus struct {
+	Unseen  int `json:"unseen"`
+	Running int `json:"running"`
+}
+
+// Job is work the daemon tracks while it runs, from GET /jobs
+type Job struct {
+	Source  string    `json:"source"` // "container", "mark" or "background"
+	Name    string    `json:"name"`
+	Detail  string    `json:"detail,omitempty"` // container name for execs
+	PID     int       `json:"pid,omitempty"`    // background jobs, which can be signalled
+	Started time.Time `json:"started"`
+}
+
+// Health is the liveness report from GET /health
+type Health struct {
+	Status string `json:"status"`
+	Server string `json:"server"`
+	Port   int    `json:"port"`
+}
+
+// APIError is returned for non-2xx responses
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("cmdbell: %d %s", e.StatusCode, e.Message)
+}
+
+// Notify reports a finished command
+func (c *Client) Notify(ctx context.Context, req NotificationRequest) error {
+	return c.do(ctx, http.MethodPost, "/notify", req, nil)
+}
+
+// ReportTask reports a task finished by an editor
+func (c *Client) ReportTask(ctx context.Context, req TaskRequest) error {
+	return c.do(ctx, http.MethodPost, "/tasks", req, nil)
+}
+
+// WatchBackground has the daemon watch a background job's PID and notify when it exits
+func (c *Client) WatchBackground(ctx context.Context, req BackgroundRequest) error {
+	return c.do(ctx, http.MethodPost, "/background", req, nil)
+}
+
+// Mutes lists the active mutes, soonest to expire first
+func (c *Client) Mutes(ctx context.Context) ([]Mute, error) {
+	var mutes []Mute
+	if err := c.do(ctx, http.MethodGet, "/mutes", nil, &mutes); err != nil {
+		return nil, err
+	}
+	return mutes, nil
+}
+
+// Mute silences matching commands until the mute expires
+func (c *Client) Mute(ctx context.Context, req MuteRequest) (*Mute, error) {
+	var mute Mute
+	if err := c.do(ctx, http.MethodPost, "/mutes", req, &mute); err != nil {
+		return nil, err
+	}
+	return &mute, nil
+}
+
+// Unmute lifts a mute by its ID or pattern
+func (c *Client) Unmute(ctx context.Context, idOrPattern string) error {
+	return c.do(ctx, http.MethodDelete, "/mutes?id="+url.QueryEscape(idOrPattern), nil, nil)
+}
+
+// RunAction asks the daemon to run a configured action for a notification; it returns once the
+// action has started
+func (c *Client) RunAction(ctx context.Context, name, id string) error {
+	return c.do(ctx, http.MethodPost, "/actions/"+url.PathEscape(name), ActionRequest{ID: id}, nil)
+}
+
+// StartMark records the start of a labelled run on the daemon
+func (c *Client) StartMark(ctx context.Context, label string) error {
+	return c.do(ctx, http.MethodPost, "/marks/start", map[string]interface{}{"label": label}, nil)
+}
+
+// FinishMark reports that a labelled run finished; the daemon times it from StartMark and notifies
+func (c *Client) FinishMark(ctx context.Context, label string, exitCode int) (time.Duration, error) {
+	var response struct {
+		DurationSeconds float64 `json:"duration_seconds"`
+	}
+	body := map[string]interface{}{"label": label, "exit_code": exitCode}
+	if err := c.do(ctx, http.MethodPost, "/marks/done", body, &response); err != nil {
+		return 0, err
+	}
+	return time.Duration(response.DurationSeconds * float64(time.Second)), nil
+}
+
+// PostEvent delivers an event on a hub daemon
+func (c *Client) PostEvent(ctx context.Context, event Event) error {
+	return c.do(ctx, http.MethodPost, "/events", event, nil)
+}
+
+// History returns matching history entries, newest first
+func (c *Client) History(ctx context.Context, query HistoryQuery) ([]HistoryEntry, error) {
+	params := url.Values{}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Source != "" {
+		params.Set("source", query.Source)
+	}
+	if query.Host != "" {
+		params.Set("host", query.Host)
+	}
+	if query.Search != "" {
+		params.Set("search", query.Search)
+	}
+	if query.Category != "" {
+		params.Set("category", query.Category)
+	}
+	if query.Failed {
+		params.Set("failed", "true")
+	}
+	if query.CorrelationID != "" {
+		params.Set("correlation_id", query.CorrelationID)
+	}
+	if query.Terminal != "" {
+		params.Set("terminal", query.Terminal)
+	}
+
+	path := "/history"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	var entries []HistoryEntry
+	if err := c.do(ctx, http.MethodGet, path, nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// MergeHistory adds entries the daemon does not have yet and returns how many were added
+func (c *Client) MergeHistory(ctx context.Context, entries []HistoryEntry) (int, error) {
+	var response struct {
+		Added int `json:"added"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/history", entries, &response); err != nil {
+		return 0, err
+	}
+	return response.Added, nil
+}
+
+// Poll waits up to wait for events queued after since. A since of 0 or less only
+// returns the current cursor, which is how a new consumer skips the backlog.
+func (c *Client) Poll(ctx context.Context, since int64, wait time.Duration) (*PollResponse, error) {
+	params := url.Values{}
+	if since > 0 {
+		params.Set("since", strconv.FormatInt(since, 10))
+	}
+	if wait > 0 {
+		params.Set("wait", wait.String())
+	}
+
+	var response PollResponse
+	if err := c.do(ctx, http.MethodGet, "/poll?"+params.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Status returns the daemon status and its running components
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodGet, "/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Prompt returns the unseen notification and running job counts, cached by the daemon for a second
+func (c *Client) Prompt(ctx context.Context) (*PromptStatus, error) {
+	var status PromptStatus
+	if err := c.do(ctx, http.MethodGet, "/prompt", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Jobs lists the work the daemon tracks while it runs: container execs, marks and background jobs
+func (c *Client) Jobs(ctx context.Context) ([]Job, error) {
+	var jobs []Job
+	if err := c.do(ctx, http.MethodGet, "/jobs", nil, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Health checks that the daemon HTTP server is up
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.do(ctx, http.MethodGet, "/health", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// withSchemaVersion adds schema_version to a JSON object
+func withSchemaVersion(data []byte) []byte {
+	if len(data) < 2 || data[0] != '{' {
+		return data
+	}
+	versioned := fmt.Appendf(nil, `{"schema_version":%d`, SchemaVersion)
+	if len(data) > 2 {
+		versioned = append(versioned, ',')
+	}
+	return append(versioned, data[1:]...)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		reader = bytes.NewReader(withSchemaVersion(data))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.CorrelationID != "" {
+		req.Header.Set("X-Correlation-ID", c.CorrelationID)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
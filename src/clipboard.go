@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommand returns the tool that copies its stdin to the system clipboard
+func clipboardCommand() ([]string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"pbcopy"}, nil
+	case "windows":
+		return []string{"clip"}, nil
+	case "linux":
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return nil, fmt.Errorf("no GUI environment detected (headless mode)")
+		}
+
+		var candidates [][]string
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-copy"})
+		}
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", "clipboard"},
+			[]string{"xsel", "--clipboard", "--input"},
+		)
+		for _, candidate := range candidates {
+			if _, err := exec.LookPath(candidate[0]); err == nil {
+				return candidate, nil
+			}
+		}
+		return nil, fmt.Errorf("no clipboard tool found (install wl-clipboard, xclip or xsel)")
+	default:
+		return nil, fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	}
+}
+
+func copyToClipboard(text string) error {
+	tool, err := clipboardCommand()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(tool[0], tool[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v %s", tool[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// shellQuote wraps s in single quotes for /bin/sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// handleCopyCommand puts a command from history on the clipboard, for editing and re-running a failure
+func handleCopyCommand() {
+	args := os.Args[2:]
+	if len(args) != 1 {
+		printCopyUsage()
+		os.Exit(1)
+	}
+
+	var match func(HistoryEntry) bool
+	filter := HistoryFilter{}
+	switch {
+	case args[0] == "--last-failed":
+		filter.FailedOnly = true
+		match = func(HistoryEntry) bool { return true }
+	case !strings.HasPrefix(args[0], "-"):
+		match = func(entry HistoryEntry) bool { return strings.HasPrefix(entry.ID, args[0]) }
+	default:
+		printCopyUsage()
+		os.Exit(1)
+	}
+
+	store := getHistoryStore()
+	if store == nil {
+		fmt.Println("History is disabled (history.enabled: false)")
+		os.Exit(1)
+	}
+
+	entries, err := store.Query(filter)
+	if err != nil {
+		fmt.Printf("Failed to read history: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, entry := range entries {
+		if entry.Command == "" || !match(entry) {
+			continue
+		}
+
+		if err := copyToClipboard(entry.Command); err != nil {
+			fmt.Printf("❌ Failed to copy to the clipboard: %v\n", err)
+			fmt.Println(entry.Command)
+			os.Exit(1)
+		}
+		fmt.Printf("📋 Copied: %s\n", entry.Command)
+		return
+	}
+
+	fmt.Println("No matching command in history")
+	os.Exit(1)
+}
+
+func printCopyUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  cmdbell copy --last-failed   - Copy the most recent failed command to the clipboard")
+	fmt.Println("  cmdbell copy <id>            - Copy the command of a history entry (IDs from 'cmdbell history --json')")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// argValue returns the value of a flag given as "--flag value" or "--flag=value"
+func argValue(args []string, names ...string) string {
+	for i, arg := range args {
+		for _, name := range names {
+			if arg == name && i+1 < len(args) {
+				return args[i+1]
+			}
+			if value, ok := strings.CutPrefix(arg, name+"="); ok {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// firstArgs reports whether args starts with the given words, e.g. "cloudformation", "deploy"
+func firstArgs(args []string, words ...string) bool {
+	if len(args) < len(words) {
+		return false
+	}
+	for i, word := range words {
+		if args[i] != word {
+			return false
+		}
+	}
+	return true
+}
+
+// outcomeEnricher keeps an identifier from the arguments and the outcome line the tool printed last
+type outcomeEnricher struct {
+	writer   *lineWriter
+	label    string // e.g. "stack my-app"
+	outcome  string
+	patterns []outcomePattern
+}
+
+// outcomePattern maps a line of tool output to an outcome; format may reference submatches via %[n]s
+type outcomePattern struct {
+	re     *regexp.Regexp
+	format string
+	// keepFirst stops later matches of this pattern from replacing the outcome, used for the first error
+	keepFirst bool
+}
+
+func newOutcomeEnricher(label string, patterns []outcomePattern) *outcomeEnricher {
+	e := &outcomeEnricher{label: label, patterns: patterns}
+	// On a terminal these tools draw spinners redrawn with \r, which would otherwise run into
+	// the start of the next line and keep the patterns from matching it
+	e.writer = &lineWriter{onLine: e.handleLine, redraws: true}
+	return e
+}
+
+func (e *outcomeEnricher) Output() *lineWriter {
+	return e.writer
+}
+
+func (e *outcomeEnricher) handleLine(line string, at time.Time) {
+	line = strings.TrimSpace(line)
+	for _, pattern := range e.patterns {
+		match := pattern.re.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if pattern.keepFirst && e.outcome != "" {
+			return
+		}
+
+		args := make([]interface{}, len(match)-1)
+		for i, group := range match[1:] {
+			args[i] = group
+		}
+		e.outcome = fmt.Sprintf(pattern.format, args...)
+		return
+	}
+}
+
+func (e *outcomeEnricher) Summary() string {
+	e.writer.mu.Lock()
+	defer e.writer.mu.Unlock()
+
+	switch {
+	case e.label == "":
+		return e.outcome
+	case e.outcome == "":
+		return e.label
+	default:
+		return e.label + ": " + e.outcome
+	}
+}
+
+func newCloudFormationEnricher(args []string) commandEnricher {
+	label := ""
+	if stack := argValue(args, "--stack-name"); stack != "" {
+		label = "stack " + stack
+	}
+	return newOutcomeEnricher(label, []outcomePattern{
+		{re: regexp.MustCompile(`^Successfully (created/updated|created|updated) stack - \S+`), format: "successfully %[1]s"},
+		{re: regexp.MustCompile(`^No changes to deploy\.`), format: "no changes"},
+		{re: regexp.MustCompile(`^Failed to (create/update|create|update) the stack`), format: "failed to %[1]s the stack"},
+		{re: regexp.MustCompile(`^An error occurred \(([^)]+)\)`), format: "error %[1]s"},
+	})
+}
+
+// newGCloudBuildEnricher takes the build ID from output, since gcloud assigns it after submission
+func newGCloudBuildEnricher() commandEnricher {
+	return newOutcomeEnricher("", []outcomePattern{
+		{re: regexp.MustCompile(`^ERROR: \(gcloud\.builds\.submit\) build (\S+) completed with status "([^"]+)"`), format: "build %[1]s %[2]s"},
+		// Final status table row: ID CREATE_TIME DURATION SOURCE IMAGES STATUS
+		{re: regexp.MustCompile(`^([0-9a-f]{8}-[0-9a-f-]{27})\s+\S+\s+(\S+)\s+.*\s(SUCCESS|FAILURE|TIMEOUT|CANCELLED|INTERNAL_ERROR)$`), format: "build %[1]s %[3]s in %[2]s"},
+		{re: regexp.MustCompile(`^Created \[https://cloudbuild\.googleapis\.com/.*/builds/([^\]]+)\]`), format: "build %[1]s", keepFirst: true},
+	})
+}
+
+func newAzureDeploymentEnricher(args []string) commandEnricher {
+	label := ""
+	if name := argValue(args, "--name", "-n"); name != "" {
+		label = "deployment " + name
+	}
+	if group := argValue(args, "--resource-group", "-g"); group != "" {
+		label = strings.TrimSpace(label + " in " + group)
+	}
+	return newOutcomeEnricher(label, []outcomePattern{
+		{re: regexp.MustCompile(`"provisioningState":\s*"([^"]+)"`), format: "%[1]s"},
+		{re: regexp.MustCompile(`^(?:ERROR: )?\((\w+)\)\s*(.*)$`), format: "%[1]s: %[2]s", keepFirst: true},
+		{re: regexp.MustCompile(`^ERROR: (.+)$`), format: "error: %[1]s", keepFirst: true},
+	})
+}
+
+func newTerraformApplyEnricher() commandEnricher {
+	return newOutcomeEnricher("", []outcomePattern{
+		{re: regexp.MustCompile(`^Apply complete! Resources: (.+)\.$`), format: "%[1]s"},
+		{re: regexp.MustCompile(`^Destroy complete! Resources: (.+)\.$`), format: "%[1]s"},
+		{re: regexp.MustCompile(`^No changes\.`), format: "no changes"},
+		{re: regexp.MustCompile(`^│?\s*Error: (.+)$`), format: "error: %[1]s", keepFirst: true},
+	})
+}
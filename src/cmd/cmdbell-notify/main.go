@@ -0,0 +1,136 @@
+// Command cmdbell-notify is the notify-only CmdBell client for container images and CI runners.
+// It has no daemon, Docker monitoring or shell integration: it runs a command, or takes a finished
+// one's result, and POSTs it to a CmdBell daemon or hub, retrying while the daemon is unreachable.
+//
+// Build it static with
+//
+//	CGO_ENABLED=0 go build -ldflags="-s -w" ./cmd/cmdbell-notify
+//
+// Usage:
+//
+//	cmdbell-notify [flags] -- <command> [args...]
+//	cmdbell-notify [flags] report <command> <duration_seconds> <exit_code>
+//
+// The daemon URL and token come from --url and --token, or CMDBELL_URL and CMDBELL_TOKEN. Without
+// either, it reports to the daemon running on this machine, at the port it recorded in
+// ~/.cmdbell/runtime.json.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+	"github.com/cmdbell/cmd-bell/pkg/cmdbell"
+)
+
+func main() {
+	flags := flag.NewFlagSet("cmdbell-notify", flag.ExitOnError)
+	url := flags.String("url", envOr("CMDBELL_URL", client.LocalURL()), "daemon or hub URL")
+	token := flags.String("token", os.Getenv("CMDBELL_TOKEN"), "API token with the notify scope")
+	name := flags.String("name", "", "name reported as the container, defaults to the hostname")
+	retries := flags.Int("retries", cmdbell.DefaultRetries, "attempts after the first while the daemon is unreachable")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cmdbell-notify [flags] -- <command> [args...]")
+		fmt.Fprintln(os.Stderr, "       cmdbell-notify [flags] report <command> <duration_seconds> <exit_code>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *name == "" {
+		*name, _ = os.Hostname()
+	}
+
+	var notification cmdbell.Notification
+	exitCode := 0
+	if args[0] == "report" {
+		if len(args) != 4 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		seconds, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cmdbell-notify: invalid duration %q\n", args[2])
+			os.Exit(2)
+		}
+		code, err := strconv.Atoi(args[3])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cmdbell-notify: invalid exit code %q\n", args[3])
+			os.Exit(2)
+		}
+		notification = cmdbell.Notification{Command: args[1], Duration: time.Duration(seconds * float64(time.Second)), Success: code == 0}
+	} else {
+		var duration time.Duration
+		exitCode, duration = run(args)
+		notification = cmdbell.Notification{Command: strings.Join(args, " "), Duration: duration, Success: exitCode == 0}
+	}
+	notification.Host = *name
+
+	notifier := cmdbell.New(*url, *token)
+	notifier.Retries = *retries
+	if err := notifier.Notify(context.Background(), notification); err != nil {
+		fmt.Fprintf(os.Stderr, "cmdbell-notify: %v\n", err)
+		if exitCode == 0 {
+			exitCode = 1
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// run executes the command with signals passed through, as it is often PID 1's child in a container
+func run(args []string) (int, time.Duration) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	startTime := time.Now()
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "cmdbell-notify: %v\n", err)
+		return 127, 0
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	signal.Stop(signals)
+	duration := time.Since(startTime)
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0, duration
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode(), duration
+	default:
+		return 1, duration
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
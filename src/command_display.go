@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSubcommands is how many words after the binary are kept as subcommands, e.g. "docker compose up"
+const maxSubcommands = 2
+
+// displayCommand shortens a command line for notification messages to notification.max_command_length;
+// history entries and remote payloads keep the full command in their command field
+func displayCommand(command string) string {
+	limit := 80
+	if globalConfig != nil {
+		limit = globalConfig.Notification.MaxCommandLength
+	}
+	return truncateCommand(command, limit)
+}
+
+// truncateCommand keeps the binary name, its leading subcommands and as many trailing arguments
+// as fit, e.g. "docker compose … --build web", eliding the middle; limit <= 0 disables it
+func truncateCommand(command string, limit int) string {
+	command = strings.TrimSpace(command)
+	if limit <= 0 || utf8.RuneCountInString(command) <= limit {
+		return command
+	}
+
+	const ellipsis = " … "
+	fields := strings.Fields(command)
+
+	// The binary, after any VAR=value prefixes, then words that look like subcommands
+	head := 0
+	for head < len(fields) && strings.Contains(fields[head], "=") {
+		head++
+	}
+	head++
+	for subcommands := 0; head < len(fields) && subcommands < maxSubcommands && isSubcommand(fields[head]); subcommands++ {
+		head++
+	}
+	if head > len(fields) {
+		head = len(fields)
+	}
+	prefix := strings.Join(fields[:head], " ")
+
+	// Fill the rest with trailing arguments, which usually name what the command acted on
+	budget := limit - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(ellipsis)
+	tail := []string{}
+	used := 0
+	for i := len(fields) - 1; i >= head; i-- {
+		length := utf8.RuneCountInString(fields[i])
+		if len(tail) > 0 {
+			length++
+		}
+		if used+length > budget {
+			break
+		}
+		tail = append([]string{fields[i]}, tail...)
+		used += length
+	}
+
+	if budget < 0 || len(tail) == 0 {
+		runes := []rune(prefix)
+		if len(runes) > limit-1 {
+			runes = runes[:limit-1]
+		}
+		return strings.TrimSpace(string(runes)) + "…"
+	}
+	return prefix + ellipsis + strings.Join(tail, " ")
+}
+
+// isSubcommand reports whether an argument looks like a subcommand rather than a flag, path or value
+func isSubcommand(arg string) bool {
+	if arg == "" || len(arg) > 20 || strings.ContainsAny(arg, "-/.=:@'\"") {
+		return false
+	}
+	return arg[0] >= 'a' && arg[0] <= 'z'
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// composeSettle is how long a bring-up must stay quiet before it counts as finished,
+// so services that start a moment later are still part of the same operation
+const composeSettle = 3 * time.Second
+
+type composeService struct {
+	Name        string
+	State       string // "starting", "running", "healthy", "completed", "unhealthy" or "exited"
+	ExitCode    string
+	Healthcheck bool
+}
+
+func (s *composeService) ready() bool {
+	switch s.State {
+	case "healthy", "completed":
+		return true
+	case "running":
+		return !s.Healthcheck
+	}
+	return false
+}
+
+func (s *composeService) failed() bool {
+	return s.State == "unhealthy" || s.State == "exited"
+}
+
+func (s *composeService) String() string {
+	if s.State == "exited" {
+		return fmt.Sprintf("%s exited (%s)", s.Name, s.ExitCode)
+	}
+	return s.Name + " " + s.State
+}
+
+// composeBringUp is one `docker compose up` of a project, tracked from its first container start
+type composeBringUp struct {
+	Project   string
+	StartedAt time.Time
+	LastEvent time.Time
+	Services  map[string]*composeService
+	Wrapped   bool // run by 'cmdbell docker compose', which notifies itself
+}
+
+func (b *composeBringUp) breakdown() string {
+	names := make([]string, 0, len(b.Services))
+	for name := range b.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, b.Services[name].String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ComposeTracker groups container events by compose project and reports each bring-up as one operation
+type ComposeTracker struct {
+	mu      sync.Mutex
+	active  map[string]*composeBringUp
+	timeout time.Duration
+}
+
+func NewComposeTracker(timeout time.Duration) *ComposeTracker {
+	return &ComposeTracker{
+		active:  make(map[string]*composeBringUp),
+		timeout: timeout,
+	}
+}
+
+func (ct *ComposeTracker) HandleEvent(event DockerEvent) {
+	project := event.Actor.Attributes["com.docker.compose.project"]
+	serviceName := event.Actor.Attributes["com.docker.compose.service"]
+	if project == "" || serviceName == "" {
+		return
+	}
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	at := event.Timestamp()
+	bringUp, exists := ct.active[project]
+	if !exists {
+		// Only container creation or start begins a bring-up; stop and die events alone do not
+		if event.Action != "create" && event.Action != "start" {
+			return
+		}
+		bringUp = &composeBringUp{
+			Project:   project,
+			StartedAt: at,
+			Services:  make(map[string]*composeService),
+			Wrapped:   composeProjectWrapped(project),
+		}
+		ct.active[project] = bringUp
+		log.Printf("🐙 Compose project %s is coming up", project)
+	}
+	bringUp.LastEvent = at
+
+	service, exists := bringUp.Services[serviceName]
+	if !exists {
+		service = &composeService{Name: serviceName, State: "starting"}
+		bringUp.Services[serviceName] = service
+	}
+
+	switch {
+	case event.Action == "start":
+		service.State = "running"
+		service.Healthcheck = hasHealthcheck(event.ID)
+	case event.Action == "health_status: healthy":
+		service.State = "healthy"
+	case event.Action == "health_status: unhealthy":
+		service.State = "unhealthy"
+	case event.Action == "die":
+		service.ExitCode = event.Actor.Attributes["exitCode"]
+		if service.ExitCode == "0" {
+			service.State = "completed"
+		} else {
+			service.State = "exited"
+		}
+	}
+}
+
+// Run evaluates active bring-ups until ctx is cancelled
+func (ct *ComposeTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ct.evaluate(now)
+		}
+	}
+}
+
+func (ct *ComposeTracker) evaluate(now time.Time) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	for project, bringUp := range ct.active {
+		failed, ready := false, true
+		for _, service := range bringUp.Services {
+			failed = failed || service.failed()
+			ready = ready && service.ready()
+		}
+
+		switch {
+		case failed:
+			ct.finish(bringUp, now, false, "failed")
+		case ready && now.Sub(bringUp.LastEvent) >= composeSettle:
+			ct.finish(bringUp, bringUp.LastEvent, true, "is up")
+		case ct.timeout > 0 && now.Sub(bringUp.StartedAt) >= ct.timeout:
+			ct.finish(bringUp, now, false, "timed out")
+		default:
+			continue
+		}
+		delete(ct.active, project)
+	}
+}
+
+func (ct *ComposeTracker) finish(bringUp *composeBringUp, end time.Time, success bool, outcome string) {
+	duration := end.Sub(bringUp.StartedAt)
+	log.Printf("🐙 Compose project %s %s after %s: %s", bringUp.Project, outcome, duration.Round(time.Second), bringUp.breakdown())
+
+	if bringUp.Wrapped {
+		tracef("filtered", "compose project %s is run by cmdbell docker compose, which notifies instead", bringUp.Project)
+	} else if globalConfig != nil && duration >= globalConfig.General.MinDurationTime && globalConfig.General.EnableNotify {
+		sendComposeNotification(bringUp.Project, outcome, bringUp.breakdown(), duration, success)
+	}
+}
+
+// hasHealthcheck reports whether a container defines a healthcheck, in which case it is ready only once healthy
+func hasHealthcheck(containerID string) bool {
+	output, err := exec.Command("docker", "inspect", "--format", "{{if .Config.Healthcheck}}true{{end}}", containerID).Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -14,23 +15,295 @@ type Config struct {
 		MinDuration     string `yaml:"min_duration"`
 		MinDurationTime time.Duration
 		EnableNotify    bool `yaml:"enable_notify"`
+		AutoWrap        []string `yaml:"auto_wrap"` // when set, shell hooks only time these commands
+		Nested          string   `yaml:"nested"`    // "wrapper" or "hook": which notifies for 'cmdbell run' at a hooked prompt
+		ConfigErrors    string   `yaml:"config_errors"` // "default" or "fail": whether the daemon starts with settings it could not use defaulted
 	} `yaml:"general"`
 	
 	Docker struct {
 		Monitor bool `yaml:"monitor"`
 		Filters []string `yaml:"filters"`
+		ExecMaxAge string `yaml:"exec_max_age"` // forget execs that never reported exec_die after this long
+		ComposeTimeout string `yaml:"compose_timeout"` // report a compose bring-up as failed if not up by then
+		StaleAfter string `yaml:"stale_after"` // restart the event stream when it has missed events for this long
 	} `yaml:"docker"`
 	
+	Thresholds struct {
+		Tiers map[string]string `yaml:"tiers"` // named minimum durations, e.g. builds: 2m
+		Rules []ThresholdRule   `yaml:"rules"` // first matching rule picks the tier, otherwise general.min_duration
+		Ignore []string `yaml:"ignore"` // command names or prefixes that never notify, such as "vim" or "ssh"
+	} `yaml:"thresholds"`
+	
+	Streaks struct {
+		Count int          `yaml:"count"` // consecutive failures of the same command that escalate its notification, 0 disables
+		Rules []StreakRule `yaml:"rules"` // the first matching rule sets the count for its commands
+	} `yaml:"streaks"`
+	
+	Cron struct {
+		MinDuration string `yaml:"min_duration"` // successful cron jobs notify only when they ran at least this long
+	} `yaml:"cron"`
+	
+	Categories struct {
+		Rules []CategoryRule  `yaml:"rules"` // checked before the built-in build, test, deploy, package-manager and data patterns
+		Icons map[string]string `yaml:"icons"` // emoji per category, overriding the built-in ones
+	} `yaml:"categories"`
+	
+	Guard struct {
+		MinDisk   string   `yaml:"min_disk"`   // free space wrapped commands need before they start, e.g. 10GB
+		MinMemory string   `yaml:"min_memory"` // available memory they need, e.g. 2GB
+		Paths     []string `yaml:"paths"`      // filesystems min_disk checks; defaults to the working directory
+		Action    string   `yaml:"action"`     // "warn" or "abort" when a minimum is not met
+	} `yaml:"guard"`
+	
+	System struct {
+		Enabled   bool   `yaml:"enabled"`    // one daemon for a shared machine, routing events to the user who started them
+		UserLabel string `yaml:"user_label"` // container label naming the user an exec belongs to
+	} `yaml:"system"`
+	
+	Daemon struct {
+		Autostart bool `yaml:"autostart"` // shell hooks start the daemon when it is not running
+		DBus      bool `yaml:"dbus"`      // expose org.cmdbell on the session bus for panel indicators (Linux)
+		QueueSize int    `yaml:"queue_size"` // events each internal queue holds, Docker events and notifications
+		Overflow  string `yaml:"overflow"`   // "drop-oldest" or "drop-newest" when a queue is full
+	} `yaml:"daemon"`
+	
+	Hub struct {
+		Advertise bool         `yaml:"advertise"` // announce this daemon on the LAN via mDNS
+		Name      string       `yaml:"name"`      // advertised name, defaults to the hostname
+		Poll      []PollTarget `yaml:"poll"`      // remote daemons whose queued events this hub fetches
+		PollWait  string       `yaml:"poll_wait"` // how long each long poll waits for new events
+	} `yaml:"hub"`
+	
 	HTTP struct {
-		Port    int  `yaml:"port"`
-		Enabled bool `yaml:"enabled"`
+		Port         int        `yaml:"port"`
+		PortFallback bool       `yaml:"port_fallback"` // listen on a free port when port is taken, recorded in ~/.cmdbell/runtime.json
+		Enabled      bool       `yaml:"enabled"`
+		Tokens       []APIToken `yaml:"tokens"`         // when set, API requests must present one of these
+		MaxBodyBytes int64      `yaml:"max_body_bytes"` // request size limit for /notify and /events
+		CORSOrigins  []string   `yaml:"cors_origins"`   // browser origins allowed to call the API, "*" for any
+		IdleTimeout  string     `yaml:"idle_timeout"`   // how long keep-alive connections stay open
+
+		TLS ServerTLSConfig `yaml:"tls"` // HTTPS for remote forwarders, on its own port
 	} `yaml:"http"`
 	
 	Notification struct {
 		Method   string `yaml:"method"`
 		Sound    bool   `yaml:"sound"`
 		Position string `yaml:"position"`
+		Timeout  string `yaml:"timeout"` // per-channel delivery deadline
+		Locale   string `yaml:"locale"`  // duration units, e.g. "de" or "ja_JP.UTF-8"; "auto" follows LANG
+		Format   string `yaml:"format"`  // desktop message profile, "short" or "long"
+
+		MaxCommandLength int `yaml:"max_command_length"` // longer commands are shortened in messages; 0 keeps them whole
+
+		Buckets []DurationBucket `yaml:"buckets"` // sound and urgency by how long the finished work ran
+
+		MacOSStyle        string `yaml:"macos_style"`         // "banner" fades away, "alert" stays until dismissed
+		MacOSFailureStyle string `yaml:"macos_failure_style"` // style for failures; empty follows macos_style
+
+		// Desktop notifications arrive without sound or popup, straight into the notification
+		// center where the platform has one; channels are not affected
+		QuietHours struct {
+			Start string `yaml:"start"` // e.g. "22:00"; quiet hours are off while empty
+			End   string `yaml:"end"`   // e.g. "07:00"; before start spans midnight
+		} `yaml:"quiet_hours"`
 	} `yaml:"notification"`
+
+	Channels []ChannelConfig `yaml:"channels"`
+	
+	History struct {
+		Enabled        bool     `yaml:"enabled"`
+		Backend        string   `yaml:"backend"`         // storage engine, currently "jsonl"
+		Path           string   `yaml:"path"`            // defaults to ~/.cmdbell/history.jsonl
+		StoreArguments bool     `yaml:"store_arguments"` // false keeps only the binary name
+		Redact         string   `yaml:"redact"`          // "mask", "hash" or "off"
+		RedactPatterns []string `yaml:"redact_patterns"` // extra regexes, a "secret" group limits the redaction
+		Encryption     string   `yaml:"encryption"`      // "off", "passphrase" (CMDBELL_HISTORY_PASSPHRASE) or "keychain"
+		
+		Sync struct {
+			Type     string `yaml:"type"`     // "webdav" or "daemon"
+			URL      string `yaml:"url"`      // WebDAV file URL or remote daemon base URL
+			Username string `yaml:"username"` // WebDAV basic auth
+			Password string `yaml:"password"`
+			Token    string `yaml:"token"`    // remote daemon API token with the history scope
+			Interval string `yaml:"interval"` // periodic sync in the daemon, empty to sync manually
+		} `yaml:"sync"`
+	} `yaml:"history"`
+	
+	Schedules []ScheduleConfig `yaml:"schedules"`
+	
+	FileWatch struct {
+		Interval string          `yaml:"interval"` // how long a file must stay unchanged before notifying, for rules without settle
+		Rules    []FileWatchRule `yaml:"rules"`
+	} `yaml:"file_watch"`
+	
+	LogWatch struct {
+		Interval string         `yaml:"interval"` // how often running containers are checked for ones to follow
+		Rules    []LogWatchRule `yaml:"rules"`
+	} `yaml:"log_watch"`
+	
+	Endpoints struct {
+		Interval string           `yaml:"interval"`
+		Timeout  string           `yaml:"timeout"`
+		Targets  []EndpointTarget `yaml:"targets"`
+	} `yaml:"endpoints"`
+	
+	// OpenTelemetry export of each notification's path through CmdBell, as traces
+	Telemetry struct {
+		OTLPEndpoint string            `yaml:"otlp_endpoint"` // OTLP/HTTP collector, e.g. http://localhost:4318; off while unset
+		Headers      map[string]string `yaml:"headers"`       // e.g. an API key for a hosted backend; keychain: references allowed
+		ServiceName  string            `yaml:"service_name"`
+	} `yaml:"telemetry"`
+	
+	// Automations run from notification buttons and cmdbell://action/<name>/<id> links
+	Actions []ActionConfig `yaml:"actions"`
+	
+	// Named overlays of this config, such as work and home, selected with CMDBELL_PROFILE or --profile
+	Profiles map[string]yaml.Node `yaml:"profiles,omitempty"`
+	
+	// Overlays applied on machines whose hostname matches the key, a glob such as "build-*"
+	Hosts map[string]yaml.Node `yaml:"hosts,omitempty"`
+	
+	// overlaid is set once a hosts section or profile has been applied, so the config is a runtime
+	// view that must not be saved over the file it was read from
+	overlaid bool
+}
+
+// APIToken grants a named client access to the daemon HTTP API
+type APIToken struct {
+	Name   string   `yaml:"name"`
+	Token  string   `yaml:"token"`
+	Scopes []string `yaml:"scopes"` // "notify", "history" or "admin"; admin implies every scope
+	User   string   `yaml:"user"`   // in system mode, events sent with this token go to this user
+}
+
+// ThresholdRule assigns commands to a threshold tier
+type ThresholdRule struct {
+	Tier     string   `yaml:"tier"`
+	Commands []string `yaml:"commands"` // command names or prefixes such as "npm run build"
+	Pattern  string   `yaml:"pattern"`  // regex matched against the whole command line
+	Cooldown string   `yaml:"cooldown"` // notify matching commands at most once per this long, such as 1h
+}
+
+// StreakRule sets how many consecutive failures escalate matching commands' notifications
+type StreakRule struct {
+	Count    int      `yaml:"count"`    // 0 never escalates them
+	Commands []string `yaml:"commands"` // command names or prefixes such as "npm test"
+	Pattern  string   `yaml:"pattern"`  // regex matched against the whole command line
+}
+
+// CategoryRule files matching commands under a category
+type CategoryRule struct {
+	Category string   `yaml:"category"`
+	Commands []string `yaml:"commands"` // command names or prefixes such as "npm run build"
+	Pattern  string   `yaml:"pattern"`  // regex matched against the whole command line
+}
+
+// DurationBucket styles desktop notifications for work that ran at least Min
+type DurationBucket struct {
+	Name    string `yaml:"name"`
+	Min     string `yaml:"min"`
+	Sound   string `yaml:"sound"`   // "ping", "chime", "alarm" or a platform sound name; needs notification.sound
+	Urgency string `yaml:"urgency"` // "low", "normal" or "critical"
+}
+
+// ChannelConfig describes a remote channel notifications are forwarded to
+type ChannelConfig struct {
+	Name          string            `yaml:"name"`
+	Type          string            `yaml:"type"` // "webhook", "ntfy", "hub", "queue" or "telegram"
+	URL           string            `yaml:"url"`
+	Token         string            `yaml:"token"` // bearer token, e.g. the hub daemon's API token, or a Telegram bot's token
+	Headers       map[string]string `yaml:"headers"`
+	EncryptionKey string            `yaml:"encryption_key"` // base64 AES-256 key from `cmdbell decrypt --new-key`
+	DryRun        bool              `yaml:"dry_run"`        // log what would be sent instead of sending
+	User          string            `yaml:"user"`           // in system mode, only this user's events are sent here
+	Format        string            `yaml:"format"`         // "short", "long", "markdown" or "json"; defaults by type
+	NotifyOn      []string          `yaml:"notify_on"`      // "success", "failure" and/or "timeout"; every event when unset
+
+	SlackSigningSecret string `yaml:"slack_signing_secret"` // the Slack app's, adding Rerun and Mute buttons answered at /slack
+
+	ChatID       string   `yaml:"chat_id"`       // the Telegram chat the bot writes to
+	Commands     bool     `yaml:"commands"`      // answer /status, /history, /mute and /rerun sent to the Telegram bot
+	AllowedChats []string `yaml:"allowed_chats"` // Telegram chats besides chat_id whose commands are taken
+	AllowedUsers []string `yaml:"allowed_users"` // Telegram user IDs whose commands are taken; chat_id's owner in a private chat by default
+
+	Attach         []string `yaml:"attach"`           // "output" and/or "report"; output only when unset
+	AttachOn       string   `yaml:"attach_on"`        // "always" (default) or "failure"
+	AttachMaxBytes int      `yaml:"attach_max_bytes"` // per attachment, 32 KiB by default
+
+	TLSCA   string `yaml:"tls_ca"`   // CA the server's certificate must be signed by, e.g. a hub's from `cmdbell cert`
+	TLSCert string `yaml:"tls_cert"` // client certificate presented to a hub requiring one
+	TLSKey  string `yaml:"tls_key"`
+
+	Concurrency     int    `yaml:"concurrency"`      // sends in flight at once, 2 by default
+	BreakerFailures int    `yaml:"breaker_failures"` // consecutive failures that pause the channel, 5 by default; -1 never pauses
+	BreakerCooldown string `yaml:"breaker_cooldown"` // how long the channel stays paused, 5m by default
+}
+
+// ActionConfig is an automation the daemon runs for a notification, such as opening its log or
+// focusing a window. Exactly one of Command and Shortcut is set.
+type ActionConfig struct {
+	Name     string `yaml:"name"`     // used in links, letters, digits, "-", "_" and "."
+	Command  string `yaml:"command"`  // run through the shell with the event in CMDBELL_EVENT_* variables
+	Shortcut string `yaml:"shortcut"` // macOS Shortcuts shortcut, given the event as JSON input
+	Focus    bool   `yaml:"focus"`    // bring the terminal tab or pane the command ran in to the front
+	Button   string `yaml:"button"`   // label of a desktop notification button running it; no button when empty
+	On       string `yaml:"on"`       // "always" (default), "failure" or "success": which notifications get the button
+}
+
+// ScheduleConfig describes a command the daemon runs on a fixed interval
+type ScheduleConfig struct {
+	Name     string   `yaml:"name"`
+	Command  string   `yaml:"command"`
+	Interval string   `yaml:"interval"`
+	Dir      string   `yaml:"dir"`
+	NotifyOn []string `yaml:"notify_on"` // "failure", "change"; both when empty
+	Budget   string   `yaml:"budget"`    // expected maximum duration; a run still going past it warns early
+}
+
+// LogWatchRule notifies when a line in a container's logs matches a regex
+type LogWatchRule struct {
+	Name      string `yaml:"name"`
+	Container string `yaml:"container"` // container name or glob such as "web-*"; every container when empty
+	Pattern   string `yaml:"pattern"`   // regex matched against each log line, e.g. "Compiled successfully"
+	Cooldown  string `yaml:"cooldown"`  // minimum time between notifications per container, 1m by default
+	Failure   bool   `yaml:"failure"`   // report matches as failures, e.g. for OutOfMemoryError
+}
+
+// FileWatchRule describes a glob whose matching files trigger notifications
+type FileWatchRule struct {
+	Name    string   `yaml:"name"`
+	Pattern string   `yaml:"pattern"` // glob such as ~/Downloads/*.iso; * matches one directory level, ** is not supported
+	Events  []string `yaml:"events"`  // "create", "modify"; both when empty
+	Settle  string   `yaml:"settle"`  // how long a file must stay unchanged before notifying; defaults to file_watch.interval
+}
+
+// PollTarget is a remote daemon with a queue channel that the hub long-polls over GET /poll
+type PollTarget struct {
+	Name  string `yaml:"name"`
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"` // needs the history scope on the remote daemon
+
+	TLSCA   string `yaml:"tls_ca"`   // CA the remote daemon's certificate must be signed by
+	TLSCert string `yaml:"tls_cert"` // client certificate presented to a daemon requiring one
+	TLSKey  string `yaml:"tls_key"`
+}
+
+// ServerTLSConfig serves the API over HTTPS on a port of its own, so forwarders on untrusted
+// networks can reach a hub without a VPN while local clients keep using plain HTTP. Certificates
+// come from `cmdbell cert`.
+type ServerTLSConfig struct {
+	Port     int    `yaml:"port"`
+	Cert     string `yaml:"cert"`      // HTTPS is off while unset
+	Key      string `yaml:"key"`
+	ClientCA string `yaml:"client_ca"` // when set, clients must present a certificate signed by this CA, and plain HTTP only listens on localhost
+}
+
+// EndpointTarget is a TCP address or HTTP URL polled for availability
+type EndpointTarget struct {
+	Name    string `yaml:"name"`
+	Address string `yaml:"address"` // host:port checked with a TCP dial
+	URL     string `yaml:"url"`     // checked with GET, any status below 400 is up
 }
 
 const (
@@ -43,16 +316,90 @@ func getDefaultConfig() Config {
 	config.General.MinDuration = "15s"
 	config.General.MinDurationTime = 15 * time.Second
 	config.General.EnableNotify = true
+	config.General.AutoWrap = []string{}
+	config.General.Nested = "wrapper"
+	config.General.ConfigErrors = "default"
 	
 	config.Docker.Monitor = true
 	config.Docker.Filters = []string{}
+	config.Docker.ExecMaxAge = "24h"
+	config.Docker.ComposeTimeout = "10m"
+	config.Docker.StaleAfter = "10m"
+	
+	config.Thresholds.Tiers = map[string]string{
+		"interactive": "15s",
+		"builds":      "2m",
+		"deploys":     "0s",
+	}
+	config.Thresholds.Rules = []ThresholdRule{}
+	
+	config.Streaks.Count = 3
+	config.Streaks.Rules = []StreakRule{}
+	
+	config.Cron.MinDuration = "10m"
+	
+	config.Categories.Rules = []CategoryRule{}
+	config.Categories.Icons = map[string]string{}
+	
+	config.Guard.Paths = []string{}
+	config.Guard.Action = "warn"
+	
+	config.System.UserLabel = "com.cmdbell.user"
+	
+	config.Daemon.DBus = true
+	config.Daemon.QueueSize = defaultQueueSize
+	config.Daemon.Overflow = dropOldest
+	
+	config.Hub.Poll = []PollTarget{}
+	config.Hub.PollWait = "30s"
 	
 	config.HTTP.Port = 59721
 	config.HTTP.Enabled = true
+	config.HTTP.Tokens = []APIToken{}
+	config.HTTP.MaxBodyBytes = 64 * 1024
+	config.HTTP.CORSOrigins = []string{}
+	config.HTTP.IdleTimeout = "60s"
+	config.HTTP.TLS.Port = defaultTLSPort
 	
 	config.Notification.Method = "auto"
 	config.Notification.Sound = true
 	config.Notification.Position = "top-right"
+	config.Notification.Timeout = "10s"
+	config.Notification.Locale = "auto"
+	config.Notification.Format = "short"
+	config.Notification.MaxCommandLength = 80
+	config.Notification.MacOSStyle = "banner"
+	config.Notification.Buckets = []DurationBucket{
+		{Name: "long", Min: "15s", Sound: "ping", Urgency: "normal"},
+		{Name: "very_long", Min: "5m", Sound: "chime", Urgency: "normal"},
+		{Name: "epic", Min: "1h", Sound: "alarm", Urgency: "critical"},
+	}
+	
+	config.Channels = []ChannelConfig{}
+	
+	config.History.Enabled = true
+	config.History.Backend = "jsonl"
+	config.History.StoreArguments = true
+	config.History.Redact = "mask"
+	config.History.Encryption = "off"
+	config.History.RedactPatterns = []string{}
+	
+	config.Schedules = []ScheduleConfig{}
+	
+	config.Actions = []ActionConfig{}
+	
+	config.FileWatch.Interval = "2s"
+	config.FileWatch.Rules = []FileWatchRule{}
+	
+	config.LogWatch.Interval = "5s"
+	config.LogWatch.Rules = []LogWatchRule{}
+	
+	config.Endpoints.Interval = "5s"
+	config.Endpoints.Timeout = "3s"
+	config.Endpoints.Targets = []EndpointTarget{}
+	
+	config.Telemetry.Headers = map[string]string{}
+	config.Telemetry.ServiceName = "cmdbell"
 	
 	return config
 }
@@ -64,6 +411,15 @@ func getConfigPath() (string, error) {
 	}
 	
 	configDir := filepath.Join(homeDir, DefaultConfigDir)
+	
+	// Use whichever supported format already exists, YAML by default
+	for _, name := range configFileNames {
+		candidate := filepath.Join(configDir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	
 	configPath := filepath.Join(configDir, DefaultConfigFile)
 	
 	return configPath, nil
@@ -86,7 +442,54 @@ func ensureConfigDir() error {
 	return nil
 }
 
+// expandHome replaces a leading "~" with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	
+	return filepath.Join(homeDir, path[1:])
+}
+
+// getDataPath returns the path of a file inside the config directory, creating the directory if needed
+func getDataPath(name string) (string, error) {
+	if err := ensureConfigDir(); err != nil {
+		return "", err
+	}
+	
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	
+	return filepath.Join(homeDir, DefaultConfigDir, name), nil
+}
+
 func LoadConfig() (*Config, error) {
+	config, err := LoadBaseConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := applyOverlays(config); err != nil {
+		errorf("⚠️  %v\n", err)
+	}
+	if err := parseMinDuration(config); err != nil {
+		return nil, err
+	}
+	if err := configRules(config).Compile(); err != nil {
+		errorf("⚠️  %v\n", err)
+	}
+	return config, nil
+}
+
+// LoadBaseConfig reads the config file as written, without the hosts sections and profile that
+// LoadConfig applies on top, for code that edits the file and saves it back
+func LoadBaseConfig() (*Config, error) {
 	configPath, err := getConfigPath()
 	if err != nil {
 		return nil, err
@@ -108,26 +511,42 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 	
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	data, err = configToYAML(data, configFormat(configPath))
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 	
-	// Parse duration string to time.Duration
-	if config.General.MinDuration != "" {
-		duration, err := time.ParseDuration(config.General.MinDuration)
-		if err != nil {
-			return nil, fmt.Errorf("invalid min_duration format: %w", err)
-		}
-		config.General.MinDurationTime = duration
-	} else {
-		config.General.MinDurationTime = 15 * time.Second
+	// Start from defaults so sections missing from older config files keep sane values
+	config := getDefaultConfig()
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := parseMinDuration(&config); err != nil {
+		return nil, err
 	}
 	
 	return &config, nil
 }
 
+// parseMinDuration parses general.min_duration into MinDurationTime
+func parseMinDuration(config *Config) error {
+	if config.General.MinDuration == "" {
+		config.General.MinDurationTime = 15 * time.Second
+		return nil
+	}
+	duration, err := time.ParseDuration(config.General.MinDuration)
+	if err != nil {
+		return fmt.Errorf("invalid min_duration format: %w", err)
+	}
+	config.General.MinDurationTime = duration
+	return nil
+}
+
 func SaveConfig(config *Config) error {
+	if config.overlaid {
+		return fmt.Errorf("refusing to save a config with hosts sections or a profile applied; edit the config from LoadBaseConfig")
+	}
+	
 	if err := ensureConfigDir(); err != nil {
 		return err
 	}
@@ -142,9 +561,20 @@ func SaveConfig(config *Config) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 	
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	data, err = configFromYAML(data, configFormat(configPath))
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 	
-	return nil
+	return auditedConfigWrite("config.save", func() error {
+		// The config holds API tokens and channel secrets, so only the user may read it
+		if err := os.WriteFile(configPath, data, 0600); err != nil {
+			return fmt.Errorf("failed to write config file: %w", err)
+		}
+		// WriteFile keeps the mode of a config that already exists
+		if err := os.Chmod(configPath, 0600); err != nil {
+			return fmt.Errorf("failed to restrict config file permissions: %w", err)
+		}
+		return nil
+	})
 }
\ No newline at end of file
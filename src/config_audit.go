@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxConfigChanges bounds the config audit log; the oldest changes are dropped first
+const maxConfigChanges = 500
+
+// configEditActor is who changed the config when CmdBell only finds out afterwards
+const configEditActor = "edited outside CmdBell"
+
+var (
+	configAuditMu    sync.Mutex // guards the audit log
+	configSnapshotMu sync.Mutex // guards the snapshot of the config as last audited
+)
+
+// ConfigChange records one change to the config file or to rules kept beside it, such as mutes
+// and paired devices, in ~/.cmdbell/config-audit.jsonl
+type ConfigChange struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`  // who made the change, e.g. "alice via 'cmdbell setup'" or "API client 'ci' from 10.0.0.5"
+	Action  string    `json:"action"` // e.g. config.save, config.edit, mute.add, device.revoke
+	Target  string    `json:"target"` // the file or rule that changed
+	Summary string    `json:"summary,omitempty"`
+	Diff    string    `json:"diff,omitempty"` // line diff of the config, secrets masked
+}
+
+// configSecretLine matches config lines whose value is a secret, in YAML or TOML
+var configSecretLine = regexp.MustCompile(`(?i)^(\s*(?:- )?"?[a-z0-9_]*(?:token|password|secret|key)[a-z0-9_]*"?\s*[:=]\s*)(\S.*)$`)
+
+// recordConfigChange appends a change to the config audit log
+func recordConfigChange(change ConfigChange) {
+	if change.Time.IsZero() {
+		change.Time = time.Now()
+	}
+	if err := appendConfigChange(change); err != nil {
+		errorf("Failed to record config change: %v\n", err)
+	}
+}
+
+func appendConfigChange(change ConfigChange) error {
+	path, err := getDataPath("config-audit.jsonl")
+	if err != nil {
+		return err
+	}
+
+	configAuditMu.Lock()
+	defer configAuditMu.Unlock()
+
+	changes, err := loadConfigChanges()
+	if err != nil {
+		return err
+	}
+	changes = append(changes, change)
+	if len(changes) > maxConfigChanges {
+		changes = changes[len(changes)-maxConfigChanges:]
+	}
+
+	var data []byte
+	for _, c := range changes {
+		line, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// loadConfigChanges returns the recorded changes, oldest first
+func loadConfigChanges() ([]ConfigChange, error) {
+	path, err := getDataPath("config-audit.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var changes []ConfigChange
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var change ConfigChange
+		if err := json.Unmarshal(scanner.Bytes(), &change); err == nil {
+			changes = append(changes, change)
+		}
+	}
+	return changes, scanner.Err()
+}
+
+// cliActor describes the user running this cmdbell command
+func cliActor() string {
+	name := "unknown user"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	command := "cmdbell"
+	if len(os.Args) > 1 {
+		command += " " + os.Args[1]
+	}
+	return fmt.Sprintf("%s via '%s'", name, command)
+}
+
+// apiActor describes the API client behind a request
+func apiActor(r *http.Request) string {
+	if name := requestClient(r); name != "" {
+		return fmt.Sprintf("API client '%s' from %s", name, r.RemoteAddr)
+	}
+	return "API from " + r.RemoteAddr
+}
+
+// configSnapshotPath keeps the config as last audited, to tell which changes CmdBell did not make
+func configSnapshotPath() (string, error) {
+	return getDataPath("config-audit.snapshot")
+}
+
+// auditedConfigWrite runs write, which replaces the config file, and records the change it made.
+// Edits made to the file by hand since it was last audited are recorded first, on their own.
+func auditedConfigWrite(action string, write func() error) error {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return write()
+	}
+
+	configSnapshotMu.Lock()
+	defer configSnapshotMu.Unlock()
+
+	detectConfigEditsLocked(configPath)
+	before, _ := os.ReadFile(configPath)
+	if err := write(); err != nil {
+		return err
+	}
+
+	after, _ := os.ReadFile(configPath)
+	if string(before) != string(after) {
+		change := ConfigChange{Actor: cliActor(), Action: action, Target: configPath}
+		if len(before) == 0 {
+			change.Summary = "created"
+		} else {
+			change.Diff = configDiff(string(before), string(after))
+		}
+		recordConfigChange(change)
+	}
+	saveConfigSnapshot(after)
+	return nil
+}
+
+// detectConfigEdits records changes made to the config file outside CmdBell, such as in an
+// editor, since it was last audited. The daemon checks when it loads the config.
+func detectConfigEdits() {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return
+	}
+	configSnapshotMu.Lock()
+	defer configSnapshotMu.Unlock()
+	detectConfigEditsLocked(configPath)
+}
+
+func detectConfigEditsLocked(configPath string) {
+	current, err := os.ReadFile(configPath)
+	if err != nil {
+		return
+	}
+	snapshotPath, err := configSnapshotPath()
+	if err != nil {
+		return
+	}
+	snapshot, err := os.ReadFile(snapshotPath)
+	switch {
+	case os.IsNotExist(err):
+		// Nothing to compare with yet; changes are tracked from here on
+		saveConfigSnapshot(current)
+		return
+	case err != nil || string(snapshot) == string(current):
+		return
+	}
+
+	when := time.Now()
+	if info, err := os.Stat(configPath); err == nil {
+		when = info.ModTime()
+	}
+	recordConfigChange(ConfigChange{
+		Time:   when,
+		Actor:  configEditActor,
+		Action: "config.edit",
+		Target: configPath,
+		Diff:   configDiff(string(snapshot), string(current)),
+	})
+	saveConfigSnapshot(current)
+}
+
+func saveConfigSnapshot(content []byte) {
+	path, err := configSnapshotPath()
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(path, content, 0600); err != nil {
+		errorf("Failed to save config snapshot: %v\n", err)
+	}
+}
+
+// configDiff is a line diff from before to after with two lines of context around each change,
+// with secret values masked
+func configDiff(before, after string) string {
+	a := strings.Split(strings.TrimSuffix(before, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(after, "\n"), "\n")
+
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type diffLine struct {
+		op   byte
+		text string
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	const context = 2
+	keep := make([]bool, len(lines))
+	for k, line := range lines {
+		if line.op == ' ' {
+			continue
+		}
+		for c := max(0, k-context); c <= min(len(lines)-1, k+context); c++ {
+			keep[c] = true
+		}
+	}
+
+	redactor := NewCommandRedactor(nil)
+	var out strings.Builder
+	for k, line := range lines {
+		if !keep[k] {
+			continue
+		}
+		if k > 0 && !keep[k-1] && out.Len() > 0 {
+			out.WriteString("  ...\n")
+		}
+		fmt.Fprintf(&out, "%c %s\n", line.op, maskConfigSecrets(redactor, line.text))
+	}
+	return out.String()
+}
+
+// maskConfigSecrets hides the value of token, password, secret and key settings, unless it
+// refers to a secret kept elsewhere, and anything the built-in redact patterns catch
+func maskConfigSecrets(redactor *CommandRedactor, line string) string {
+	if m := configSecretLine.FindStringSubmatch(line); m != nil {
+		value := strings.Trim(m[2], `"'`)
+		if !strings.HasPrefix(value, secretRefPrefix) && !strings.HasPrefix(value, "${") && value != "" {
+			return m[1] + "********"
+		}
+	}
+	return redactor.Sanitize(line)
+}
+
+// handleConfigHistoryCommand handles `cmdbell config history [--limit N] [--json]`
+func handleConfigHistoryCommand(args []string) {
+	limit := 20
+	jsonOutput := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--limit", "-n":
+			if i+1 < len(args) {
+				i++
+				parsed, err := strconv.Atoi(args[i])
+				if err != nil {
+					fmt.Printf("Invalid limit: %s\n", args[i])
+					os.Exit(1)
+				}
+				limit = parsed
+			}
+		case "--json":
+			jsonOutput = true
+		default:
+			fmt.Println("Usage: cmdbell config history [--limit N] [--json]")
+			os.Exit(1)
+		}
+	}
+
+	detectConfigEdits()
+	changes, err := loadConfigChanges()
+	if err != nil {
+		fmt.Printf("Failed to read config history: %v\n", err)
+		os.Exit(1)
+	}
+	if limit > 0 && len(changes) > limit {
+		changes = changes[len(changes)-limit:]
+	}
+
+	// Newest first, like cmdbell history
+	for l, r := 0, len(changes)-1; l < r; l, r = l+1, r-1 {
+		changes[l], changes[r] = changes[r], changes[l]
+	}
+
+	if jsonOutput {
+		if changes == nil {
+			changes = []ConfigChange{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(changes); err != nil {
+			fmt.Printf("Failed to encode config history: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No config changes recorded yet")
+		return
+	}
+	for _, change := range changes {
+		fmt.Printf("📝 %s  %-14s %s  (%s)\n", change.Time.Local().Format("2006-01-02 15:04:05"), change.Action, change.Target, change.Actor)
+		if change.Summary != "" {
+			fmt.Printf("   %s\n", change.Summary)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(change.Diff, "\n"), "\n") {
+			if line != "" {
+				fmt.Printf("   %s\n", line)
+			}
+		}
+	}
+}
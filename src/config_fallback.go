@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// general.config_errors decides what the daemon does with a config file it cannot fully use:
+// "default" starts with the affected settings at their defaults, "fail" refuses to start.
+// CMDBELL_CONFIG_ERRORS overrides it, which is the only way to choose for a file that does not parse.
+const configErrorsEnv = "CMDBELL_CONFIG_ERRORS"
+
+// configFallbackFile records what a running daemon defaulted, for 'cmdbell --daemon status'
+const configFallbackFile = "config-fallback.json"
+
+// LoadConfigWithFallback loads the config like LoadConfig, but keeps whatever parsed and returns
+// the settings that were ignored or defaulted instead of failing on the first problem. It only
+// returns an error when the config error policy is "fail" and something fell back.
+func LoadConfigWithFallback() (*Config, []string, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		config, err := LoadConfig()
+		return config, nil, err
+	}
+
+	config := getDefaultConfig()
+	var fallbacks []string
+
+	data, err := os.ReadFile(configPath)
+	if err == nil {
+		data, err = configToYAML(data, configFormat(configPath))
+	}
+	if err != nil {
+		fallbacks = append(fallbacks, fmt.Sprintf("entire config: %v", err))
+		return &config, fallbacks, configErrorPolicy(&config, fallbacks)
+	}
+
+	// Fields of the wrong type keep their defaults; the rest of the file still applies
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			config = getDefaultConfig()
+			fallbacks = append(fallbacks, fmt.Sprintf("entire config: %v", err))
+			return &config, fallbacks, configErrorPolicy(&config, fallbacks)
+		}
+		for _, message := range typeErr.Errors {
+			fallbacks = append(fallbacks, strings.TrimPrefix(message, "yaml: "))
+		}
+	}
+	if err := applyOverlays(&config); err != nil {
+		fallbacks = append(fallbacks, err.Error())
+	}
+
+	config.General.MinDurationTime = 15 * time.Second
+	if config.General.MinDuration != "" {
+		if duration, err := time.ParseDuration(config.General.MinDuration); err == nil {
+			config.General.MinDurationTime = duration
+		}
+	}
+
+	// Unknown keys are ignored and invalid values fall back where they are used
+	var root yaml.Node
+	if yaml.Unmarshal(data, &root) == nil && len(root.Content) > 0 {
+		var issues []ConfigIssue
+		validateNode(root.Content[0], reflect.TypeOf(Config{}), "", "", &issues)
+		for _, issue := range issues {
+			fallbacks = append(fallbacks, issue.String())
+		}
+	}
+
+	return &config, fallbacks, configErrorPolicy(&config, fallbacks)
+}
+
+// configErrorPolicy returns an error when fallbacks are not acceptable under the config error policy
+func configErrorPolicy(config *Config, fallbacks []string) error {
+	policy := os.Getenv(configErrorsEnv)
+	if policy == "" {
+		policy = config.General.ConfigErrors
+	}
+	if policy != "fail" || len(fallbacks) == 0 {
+		return nil
+	}
+	return fmt.Errorf("config has %d problem(s) and general.config_errors is fail:\n  %s",
+		len(fallbacks), strings.Join(fallbacks, "\n  "))
+}
+
+// writeConfigFallback records the daemon's fallbacks, removing the record when there are none
+func writeConfigFallback(fallbacks []string) error {
+	path, err := getDataPath(configFallbackFile)
+	if err != nil {
+		return err
+	}
+	if len(fallbacks) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(fallbacks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// readConfigFallback returns what the running daemon defaulted
+func readConfigFallback() []string {
+	path, err := getDataPath(configFallbackFile)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var fallbacks []string
+	json.Unmarshal(data, &fallbacks)
+	return fallbacks
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config file names searched in order; the first one that exists is used
+var configFileNames = []string{DefaultConfigFile, "config.yml", "config.toml", "config.json"}
+
+// configFormat detects the config format from the file extension
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return "toml"
+	case ".json":
+		return "json"
+	default:
+		return "yaml"
+	}
+}
+
+// configToYAML converts config file contents to YAML so every format shares
+// the same decoder, defaults and validation. JSON is already valid YAML.
+func configToYAML(data []byte, format string) ([]byte, error) {
+	if format != "toml" {
+		return data, nil
+	}
+
+	var values map[string]interface{}
+	if _, err := toml.Decode(string(data), &values); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(values)
+}
+
+// configFromYAML converts YAML produced by marshalling Config into the given format
+func configFromYAML(data []byte, format string) ([]byte, error) {
+	if format == "yaml" {
+		return data, nil
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "json":
+		encoded, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(encoded, '\n'), nil
+	case "toml":
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(values); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported config format: %s", format)
+	}
+}
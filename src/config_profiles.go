@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profileEnv selects a config profile; `cmdbell --profile <name>` sets it for the command and
+// everything it starts, including a daemon
+const profileEnv = "CMDBELL_PROFILE"
+
+// activeProfile is the name of the selected profile, or "" for the base config alone
+func activeProfile() string {
+	return os.Getenv(profileEnv)
+}
+
+// applyOverlays applies the hosts sections matching this machine, then the selected profile, so
+// a profile chosen on purpose wins over what the machine implies
+func applyOverlays(config *Config) error {
+	hostname, _ := os.Hostname()
+	for _, pattern := range matchingHosts(config, hostname) {
+		section := config.Hosts[pattern]
+		config.overlaid = true
+		if err := section.Decode(config); err != nil {
+			return fmt.Errorf("failed to apply hosts.%s: %w", pattern, err)
+		}
+	}
+	return applyProfile(config)
+}
+
+// matchingHosts returns the hosts patterns matching hostname, or its short name before the first
+// dot, in the order they apply: globs before exact names, so the most specific section wins
+func matchingHosts(config *Config, hostname string) []string {
+	hostname = strings.ToLower(hostname)
+	short, _, _ := strings.Cut(hostname, ".")
+
+	var globs, exact []string
+	for pattern := range config.Hosts {
+		lower := strings.ToLower(pattern)
+		if lower == hostname || lower == short {
+			exact = append(exact, pattern)
+			continue
+		}
+		if matched, _ := path.Match(lower, hostname); matched {
+			globs = append(globs, pattern)
+		} else if matched, _ := path.Match(lower, short); matched {
+			globs = append(globs, pattern)
+		}
+	}
+	sort.Strings(globs)
+	sort.Strings(exact)
+	return append(globs, exact...)
+}
+
+// applyProfile overlays the selected profile onto config. A profile is written like the config
+// itself: the keys it sets replace the base config's, lists such as channels are replaced whole
+// and maps such as thresholds.tiers are merged.
+func applyProfile(config *Config) error {
+	name := activeProfile()
+	if name == "" {
+		return nil
+	}
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (%s=%s), using the base config; profiles: %s",
+			name, profileEnv, name, strings.Join(profileNames(config), ", "))
+	}
+	config.overlaid = true
+	if err := profile.Decode(config); err != nil {
+		return fmt.Errorf("failed to apply profile %q: %w", name, err)
+	}
+	return nil
+}
+
+// profileNames returns the config's profiles in order
+func profileNames(config *Config) []string {
+	return overlayNames(config.Profiles)
+}
+
+func overlayNames(overlays map[string]yaml.Node) []string {
+	names := []string{}
+	for name := range overlays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printProfiles handles `cmdbell config profiles`, marking the profile in use and the hosts
+// sections that match this machine
+func printProfiles() {
+	if len(globalConfig.Hosts) > 0 {
+		hostname, _ := os.Hostname()
+		matched := map[string]bool{}
+		for _, pattern := range matchingHosts(globalConfig, hostname) {
+			matched[pattern] = true
+		}
+		fmt.Printf("Hosts sections (this host is %s):\n", hostname)
+		for _, pattern := range overlayNames(globalConfig.Hosts) {
+			if matched[pattern] {
+				fmt.Printf("* %s\n", pattern)
+			} else {
+				fmt.Printf("  %s\n", pattern)
+			}
+		}
+		fmt.Println()
+	}
+
+	names := profileNames(globalConfig)
+	if len(names) == 0 {
+		fmt.Println("No profiles configured")
+		return
+	}
+	fmt.Println("Profiles:")
+	for _, name := range names {
+		marker := " "
+		if name == activeProfile() {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, name)
+	}
+	if activeProfile() == "" {
+		fmt.Printf("No profile selected; set %s or pass --profile <name>\n", profileEnv)
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigIssue is a problem found while validating the config file
+type ConfigIssue struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+func (i ConfigIssue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.Path, i.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", i.Line, i.Path, i.Message)
+}
+
+// Keys whose values must parse as Go durations, addressed by schema path
+// minDurations are the shortest values duration keys accept, where shorter ones would spin
+var minDurations = map[string]time.Duration{
+	"hub.poll_wait": minPollWait,
+}
+
+var durationKeys = map[string]bool{
+	"general.min_duration":        true,
+	"notification.timeout":        true,
+	"http.idle_timeout":           true,
+	"docker.exec_max_age":         true,
+	"docker.compose_timeout":      true,
+	"docker.stale_after":          true,
+	"cron.min_duration":           true,
+	"notification.buckets[].min":  true,
+	"thresholds.tiers.*":          true,
+	"hub.poll_wait":               true,
+	"schedules[].interval":        true,
+	"schedules[].budget":          true,
+	"file_watch.interval":         true,
+	"file_watch.rules[].settle":   true,
+	"log_watch.interval":          true,
+	"log_watch.rules[].cooldown":  true,
+	"thresholds.rules[].cooldown": true,
+	"endpoints.interval":          true,
+	"endpoints.timeout":           true,
+	"history.sync.interval":       true,
+	"channels[].breaker_cooldown": true,
+}
+
+// Keys restricted to a fixed set of values, addressed by schema path
+var enumKeys = map[string][]string{
+	"general.nested":                   {"wrapper", "hook"},
+	"general.config_errors":            {"default", "fail"},
+	"guard.action":                     {"warn", "abort"},
+	"history.redact":                   {"mask", "hash", "off"},
+	"daemon.overflow":                  {dropOldest, dropNewest},
+	"history.backend":                  {"jsonl"},
+	"history.encryption":               {"off", "passphrase", "keychain"},
+	"history.sync.type":                {"webdav", "daemon"},
+	"channels[].type":                  {"webhook", "ntfy", "hub", "queue", "telegram"},
+	"channels[].format":                {"short", "long", "markdown", "json"},
+	"channels[].attach[]":              {"output", "report"},
+	"channels[].attach_on":             {"always", "failure"},
+	"channels[].notify_on[]":           {"success", "failure", "timeout"},
+	"notification.format":              {"short", "long"},
+	"notification.buckets[].urgency":   {"low", "normal", "critical"},
+	"notification.macos_style":         {"banner", "alert"},
+	"notification.macos_failure_style": {"banner", "alert"},
+	"http.tokens[].scopes[]":           {"notify", "history", "admin"},
+	"schedules[].notify_on[]":          {"failure", "change"},
+	"file_watch.rules[].events[]":      {"create", "modify"},
+	"actions[].on":                     {"always", "failure", "success"},
+}
+
+// Keys whose values must be times of day such as 22:00
+var clockKeys = map[string]bool{
+	"notification.quiet_hours.start": true,
+	"notification.quiet_hours.end":   true,
+}
+
+// Keys whose values must parse as sizes such as 512MB or 10GB
+var sizeKeys = map[string]bool{
+	"guard.min_disk":   true,
+	"guard.min_memory": true,
+}
+
+// ValidateConfigFile checks the config file against the Config schema and reports
+// unknown keys, invalid durations or sizes and unsupported values with their line numbers
+func ValidateConfigFile() ([]ConfigIssue, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	format := configFormat(configPath)
+	data, err = configToYAML(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	issues, err := validateConfigData(data)
+	if format == "toml" {
+		// Line numbers refer to the converted document, not the TOML source
+		for i := range issues {
+			issues[i].Line = 0
+		}
+	}
+	return issues, err
+}
+
+func validateConfigData(data []byte) ([]ConfigIssue, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	// Type mismatches are reported by the regular decoder with their own line numbers
+	config := getDefaultConfig()
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	var issues []ConfigIssue
+	if len(root.Content) > 0 {
+		validateNode(root.Content[0], reflect.TypeOf(Config{}), "", "", &issues)
+	}
+	for _, pattern := range overlayNames(config.Hosts) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			issues = append(issues, ConfigIssue{Path: "hosts", Message: fmt.Sprintf("invalid hostname pattern %q", pattern)})
+		}
+	}
+	if config.Daemon.QueueSize < 0 {
+		issues = append(issues, ConfigIssue{Path: "daemon.queue_size", Message: "must not be negative"})
+	}
+	if tls := config.HTTP.TLS; (tls.Cert == "") != (tls.Key == "") {
+		issues = append(issues, ConfigIssue{Path: "http.tls", Message: "cert and key must be set together"})
+	} else if tls.Cert != "" && tls.Port == config.HTTP.Port {
+		issues = append(issues, ConfigIssue{Path: "http.tls.port", Message: "must differ from http.port"})
+	}
+	if endpoint := config.Telemetry.OTLPEndpoint; endpoint != "" && !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		issues = append(issues, ConfigIssue{Path: "telemetry.otlp_endpoint", Message: "must be an http:// or https:// URL"})
+	}
+	for i, channel := range config.Channels {
+		if channel.Concurrency < 0 {
+			issues = append(issues, ConfigIssue{Path: fmt.Sprintf("channels[%d].concurrency", i), Message: "must not be negative"})
+		}
+		if (channel.TLSCert == "") != (channel.TLSKey == "") {
+			issues = append(issues, ConfigIssue{Path: fmt.Sprintf("channels[%d]", i), Message: "tls_cert and tls_key must be set together"})
+		}
+		if channel.Type == "telegram" && (channel.Token == "" || channel.ChatID == "") {
+			issues = append(issues, ConfigIssue{Path: fmt.Sprintf("channels[%d]", i), Message: "telegram channels need token and chat_id"})
+		}
+		if (channel.Commands || len(channel.AllowedChats) > 0 || len(channel.AllowedUsers) > 0) && channel.Type != "telegram" {
+			issues = append(issues, ConfigIssue{Path: fmt.Sprintf("channels[%d].commands", i), Message: "only telegram channels take commands"})
+		}
+		if channel.Commands && strings.HasPrefix(channel.ChatID, "-") && len(channel.AllowedUsers) == 0 {
+			issues = append(issues, ConfigIssue{Path: fmt.Sprintf("channels[%d].allowed_users", i), Message: "commands in a group chat_id are only taken from allowed_users, which is empty"})
+		}
+		if channel.SlackSigningSecret != "" && (channel.Type != "webhook" || channel.Format != formatMarkdown) {
+			issues = append(issues, ConfigIssue{Path: fmt.Sprintf("channels[%d].slack_signing_secret", i), Message: "only webhook channels with format: markdown post Slack buttons"})
+		}
+	}
+	seen := map[string]bool{}
+	for i, action := range config.Actions {
+		problem := action.problem()
+		if problem == "" && seen[action.Name] {
+			problem = fmt.Sprintf("action %q is defined more than once", action.Name)
+		}
+		seen[action.Name] = true
+		if problem != "" {
+			issues = append(issues, ConfigIssue{Path: fmt.Sprintf("actions[%d]", i), Message: problem})
+		}
+	}
+	if name := activeProfile(); name != "" {
+		if _, ok := config.Profiles[name]; !ok {
+			issues = append(issues, ConfigIssue{Path: "profiles", Message: fmt.Sprintf("%s selects %q, which is not defined", profileEnv, name)})
+		}
+	}
+	return issues, nil
+}
+
+func validateNode(node *yaml.Node, t reflect.Type, path, schemaPath string, issues *[]ConfigIssue) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// Profiles and host sections are checked like the config they overlay
+	if schemaPath == "profiles.*" || schemaPath == "hosts.*" {
+		validateNode(node, reflect.TypeOf(Config{}), path, "", issues)
+		return
+	}
+
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := joinConfigPath(path, key.Value)
+			childSchema := joinConfigPath(schemaPath, key.Value)
+
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				message := fmt.Sprintf("unknown key %q", key.Value)
+				if suggestion := suggestKey(key.Value, fields); suggestion != "" {
+					message += fmt.Sprintf(", did you mean %q?", suggestion)
+				}
+				*issues = append(*issues, ConfigIssue{Line: key.Line, Path: childPath, Message: message})
+				continue
+			}
+			validateNode(value, fieldType, childPath, childSchema, issues)
+		}
+
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			validateNode(value, t.Elem(), joinConfigPath(path, key.Value), schemaPath+".*", issues)
+		}
+
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, item := range node.Content {
+			validateNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), schemaPath+"[]", issues)
+		}
+
+	case node.Kind == yaml.ScalarNode:
+		validateScalar(node, path, schemaPath, issues)
+	}
+}
+
+func validateScalar(node *yaml.Node, path, schemaPath string, issues *[]ConfigIssue) {
+	if durationKeys[schemaPath] && node.Value != "" {
+		if duration, err := time.ParseDuration(node.Value); err != nil {
+			*issues = append(*issues, ConfigIssue{
+				Line:    node.Line,
+				Path:    path,
+				Message: fmt.Sprintf("invalid duration %q (use values like 30s, 5m, 1h)", node.Value),
+			})
+		} else if minimum, ok := minDurations[schemaPath]; ok && duration < minimum {
+			*issues = append(*issues, ConfigIssue{
+				Line:    node.Line,
+				Path:    path,
+				Message: fmt.Sprintf("%s is too short, use at least %s", node.Value, minimum),
+			})
+		}
+	}
+
+	if clockKeys[schemaPath] && node.Value != "" {
+		if _, err := time.Parse(clockLayout, node.Value); err != nil {
+			*issues = append(*issues, ConfigIssue{
+				Line:    node.Line,
+				Path:    path,
+				Message: fmt.Sprintf("invalid time %q (use 24-hour values like 22:00)", node.Value),
+			})
+		}
+	}
+
+	if sizeKeys[schemaPath] && node.Value != "" {
+		if _, err := parseSize(node.Value); err != nil {
+			*issues = append(*issues, ConfigIssue{
+				Line:    node.Line,
+				Path:    path,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if allowed, ok := enumKeys[schemaPath]; ok && node.Value != "" {
+		for _, value := range allowed {
+			if node.Value == value {
+				return
+			}
+		}
+		*issues = append(*issues, ConfigIssue{
+			Line:    node.Line,
+			Path:    path,
+			Message: fmt.Sprintf("unsupported value %q (expected one of: %s)", node.Value, strings.Join(allowed, ", ")),
+		})
+	}
+}
+
+// yamlFields maps the YAML keys of a struct to their field types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// suggestKey returns the closest known key when it is a plausible typo of key
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	candidates := make([]string, 0, len(fields))
+	for name := range fields {
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+
+	best, bestDistance := "", len(key)/3+2
+	for _, candidate := range candidates {
+		if distance := levenshtein(key, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func handleConfigCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Config command required: validate, profiles, history")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "validate":
+		issues, err := ValidateConfigFile()
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(issues) == 0 {
+			fmt.Println("✅ Configuration is valid")
+			return
+		}
+
+		for _, issue := range issues {
+			fmt.Printf("⚠️  %s\n", issue)
+		}
+		os.Exit(1)
+
+	case "profiles":
+		printProfiles()
+
+	case "history":
+		handleConfigHistoryCommand(os.Args[3:])
+
+	default:
+		fmt.Println("Invalid config command. Use: validate, profiles, history")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var cooldownsMu sync.Mutex
+
+// commandCooldown is the cooldown of the thresholds rule matching a command line
+type commandCooldown struct {
+	Rule   int // 1-based, as `cmdbell explain` and decisions name rules
+	Key    string
+	Period time.Duration
+}
+
+// cooldownKey identifies a rule by what it matches rather than its position, so reordering
+// the rules keeps their cooldowns running
+func cooldownKey(rule ThresholdRule) string {
+	return fmt.Sprintf("commands=%s pattern=%s", strings.Join(rule.Commands, ","), rule.Pattern)
+}
+
+// cooldownFor returns the cooldown of the first thresholds rule matching commandLine, the
+// rule that also picks its tier, or nil when that rule sets none
+func cooldownFor(commandLine string) *commandCooldown {
+	if globalConfig == nil {
+		return nil
+	}
+	for i, rule := range globalConfig.Thresholds.Rules {
+		if matchThresholdRule(rule, commandLine) == "" {
+			continue
+		}
+		period, err := time.ParseDuration(rule.Cooldown)
+		if err != nil || period <= 0 {
+			return nil
+		}
+		return &commandCooldown{Rule: i + 1, Key: cooldownKey(rule), Period: period}
+	}
+	return nil
+}
+
+// startCooldown records a notification for cooldown in ~/.cmdbell/cooldowns.json, which wrapped
+// commands and the daemon share so the cooldown also holds across daemon restarts. While the
+// previous one is still cooling down it records nothing and returns when the cooldown ends.
+func startCooldown(cooldown *commandCooldown, now time.Time) (time.Time, error) {
+	path, err := getDataPath("cooldowns.json")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	cooldownsMu.Lock()
+	defer cooldownsMu.Unlock()
+
+	last := map[string]time.Time{}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &last)
+	}
+	if until := last[cooldown.Key].Add(cooldown.Period); now.Before(until) {
+		return until, nil
+	}
+
+	// Only rules still cooling down are worth keeping
+	kept := map[string]time.Time{cooldown.Key: now}
+	for _, rule := range globalConfig.Thresholds.Rules {
+		key := cooldownKey(rule)
+		period, err := time.ParseDuration(rule.Cooldown)
+		if _, seen := kept[key]; !seen && err == nil && now.Before(last[key].Add(period)) {
+			kept[key] = last[key]
+		}
+	}
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, writeFileAtomic(path, data, 0600)
+}
+
+// suppressCooldown drops a command's notification while its thresholds rule is cooling down
+// from the last one, such as a `docker compose logs -f` that may notify once an hour. Events
+// forwarded from other hosts had their cooldown applied there.
+func suppressCooldown(n *Notification) bool {
+	if n.Command == "" || n.Host != "" {
+		return false
+	}
+	cooldown := cooldownFor(n.Command)
+	if cooldown == nil {
+		return false
+	}
+	until, err := startCooldown(cooldown, time.Now())
+	if err != nil {
+		errorf("Failed to record cooldown: %v\n", err)
+		return false
+	}
+	if until.IsZero() {
+		return false
+	}
+
+	appendDecision(Decision{
+		ID:              newEventID(),
+		Time:            time.Now(),
+		Source:          n.Source,
+		Command:         sanitizeCommand(n.Command),
+		Message:         n.RemoteMessage(),
+		DurationSeconds: n.Duration.Seconds(),
+		Success:         n.Success,
+		Outcome:         fmt.Sprintf("cooling down, thresholds rule %d notifies at most every %s, next after %s", cooldown.Rule, formatDuration(cooldown.Period), until.Local().Format("15:04")),
+	})
+	tracef("filtered", "dropped, thresholds rule %d is cooling down until %s", cooldown.Rule, until.Local().Format("15:04"))
+	return true
+}
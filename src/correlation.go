@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"unicode"
+)
+
+// Correlation IDs let external systems, such as a CI server or job scheduler, link CmdBell events
+// to their own records. Clients pass one in the X-Correlation-ID header or a correlation_id field;
+// `cmdbell --correlation-id <id>` and CMDBELL_CORRELATION_ID tag events sent from the CLI and
+// shell hooks. It is stored in history and sent to channels next to the event's own ID.
+const (
+	correlationHeader = "X-Correlation-ID"
+	correlationEnv    = "CMDBELL_CORRELATION_ID"
+
+	maxCorrelationIDLength = 128
+)
+
+// checkCorrelationID rejects IDs that would not survive headers, logs and history filters intact
+func checkCorrelationID(id string) error {
+	if len(id) > maxCorrelationIDLength {
+		return fmt.Errorf("correlation ID is longer than %d bytes", maxCorrelationIDLength)
+	}
+	for _, r := range id {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return fmt.Errorf("correlation ID %q contains whitespace or control characters", id)
+		}
+	}
+	return nil
+}
+
+// requestCorrelationID returns the correlation ID of an API request, from the request body's
+// correlation_id field or else the X-Correlation-ID header
+func requestCorrelationID(r *http.Request, field string) (string, error) {
+	id := field
+	if id == "" {
+		id = r.Header.Get(correlationHeader)
+	}
+	return id, checkCorrelationID(id)
+}
+
+// envCorrelationID is the correlation ID set for this invocation, for events the CLI delivers itself
+func envCorrelationID() string {
+	id := os.Getenv(correlationEnv)
+	if checkCorrelationID(id) != nil {
+		return ""
+	}
+	return id
+}
+
+// withEventIDs adds the IDs of a delivered notification to an API response and echoes the
+// correlation ID in the X-Correlation-ID header, so callers can record the event with their job
+func withEventIDs(w http.ResponseWriter, response map[string]interface{}, n *Notification) map[string]interface{} {
+	response["id"] = n.ID
+	if n.CorrelationID != "" {
+		response["correlation_id"] = n.CorrelationID
+		w.Header().Set(correlationHeader, n.CorrelationID)
+	}
+	return response
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cronOutputLimit caps the attached output, keeping the end where errors usually are.
+// It stays well under the default http.max_body_bytes so hubs accept forwarded events.
+const cronOutputLimit = 32 * 1024
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu        sync.Mutex
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, p...)
+	if over := len(b.data) - b.limit; over > 0 {
+		b.data = append(b.data[:0], b.data[over:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.truncated {
+		return "[output truncated]\n" + string(b.data)
+	}
+	return string(b.data)
+}
+
+// handleCronCommand runs a crontab entry in place of MAILTO: output is captured, and remote channels
+// are notified with it attached when the job fails or runs long. It is printed for cron to mail
+// only when no channel took the notification. With
+// --budget, channels also hear about a job still running past it, before it finishes.
+func handleCronCommand() {
+	args := os.Args[2:]
+	var budget time.Duration
+	if len(args) > 1 && args[0] == "--budget" {
+		parsed, err := time.ParseDuration(args[1])
+		if err != nil || parsed <= 0 {
+			errorf("❌ Invalid budget %q, expected a duration such as 2h\n", args[1])
+			os.Exit(1)
+		}
+		budget = parsed
+		args = args[2:]
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Println("Usage: cmdbell cron [--budget 2h] -- <command> [args...]")
+		os.Exit(1)
+	}
+	command := strings.Join(args, " ")
+
+	output := &tailBuffer{limit: cronOutputLimit}
+	startTime := time.Now()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	exitCode := 0
+	running := startJobBudget(budget, func() {
+		if globalConfig != nil && globalConfig.General.EnableNotify {
+			n := budgetNotification("CmdBell - Cron", fmt.Sprintf("Cron job '%s'", displayCommand(command)), "cron", command, "", budget, startTime)
+			n.RemoteOnly = true
+			deliverNotification(n)
+		}
+	})
+	err := cmd.Run()
+	duration := time.Since(startTime)
+	overBudget := running.Stop()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		fmt.Fprintf(output, "cmdbell: failed to run %s: %v\n", args[0], err)
+		exitCode = 127
+	}
+
+	if globalConfig != nil && globalConfig.General.EnableNotify && (exitCode != 0 || overBudget || duration >= cronMinDuration()) {
+		id := sendCronNotification(command, duration, exitCode, output.String())
+		waitForDeliveries()
+		// Unless a channel took the job, print so cron's own MAILTO still reports it
+		if !deliveredToChannel(id) {
+			fmt.Printf("Cron job '%s' exited with %d after %s\n\n%s", command, exitCode, formatDuration(duration), output)
+		}
+	}
+
+	os.Exit(exitCode)
+}
+
+func cronMinDuration() time.Duration {
+	if globalConfig != nil {
+		if duration, err := time.ParseDuration(globalConfig.Cron.MinDuration); err == nil {
+			return duration
+		}
+	}
+	return 10 * time.Minute
+}
@@ -7,36 +7,52 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"syscall"
 	"time"
 )
 
 type Daemon struct {
-	monitor    *DockerMonitor
-	httpServer *HTTPServer
-	config     *Config
-	pidFile    string
-	logFile    string
-	ctx        context.Context
-	cancel     context.CancelFunc
-	isRunning  bool
+	monitor     *DockerMonitor
+	httpServer  *HTTPServer
+	scheduler   *Scheduler
+	watcher     *FileWatcher
+	logs        *LogWatcher
+	endpoints   *EndpointWatcher
+	historySync *HistorySyncer
+	advertiser  *HubAdvertiser
+	poller      *HubPoller
+	telegram    *TelegramBot
+	dbus        *DBusService
+	config      *Config
+	configErr   error    // set when general.config_errors is fail and the config has problems
+	fallbacks   []string // settings ignored or defaulted because the config could not use them
+	state       daemonState
+	pidFile     string
+	logFile     string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	isRunning   bool
 }
 
 func NewDaemon() *Daemon {
 	ctx, cancel := context.WithCancel(context.Background())
 	homeDir, _ := os.UserHomeDir()
 	
-	// Load configuration
-	config, err := LoadConfig()
-	if err != nil {
+	// Load configuration, keeping what parses and recording what falls back to defaults
+	config, fallbacks, err := LoadConfigWithFallback()
+	if config == nil {
 		log.Printf("Failed to load config, using defaults: %v", err)
 		defaultConfig := getDefaultConfig()
 		config = &defaultConfig
+		fallbacks = []string{fmt.Sprintf("entire config: %v", err)}
 	}
 	
 	return &Daemon{
-		config:  config,
+		config:    config,
+		configErr: err,
+		fallbacks: fallbacks,
 		pidFile: filepath.Join(homeDir, ".cmdbell.pid"),
 		logFile: filepath.Join(homeDir, ".cmdbell.log"),
 		ctx:     ctx,
@@ -50,6 +66,13 @@ func (d *Daemon) Start() error {
 		return fmt.Errorf("cmdbell daemon is already running (PID: %d)", d.GetPID())
 	}
 
+	if d.configErr != nil {
+		return d.configErr
+	}
+
+	// A correlation ID tags one command's events, never everything the daemon delivers
+	os.Unsetenv(correlationEnv)
+
 	// Write PID file
 	if err := d.writePIDFile(); err != nil {
 		return fmt.Errorf("failed to write PID file: %v", err)
@@ -60,23 +83,100 @@ func (d *Daemon) Start() error {
 		return fmt.Errorf("failed to setup logging: %v", err)
 	}
 
+	// Record edits made to the config since it was last loaded
+	detectConfigEdits()
+
+	// Report config problems that would otherwise silently fall back to defaults
+	for _, fallback := range d.fallbacks {
+		log.Printf("⚠️  Config fallback: %s", fallback)
+	}
+	if err := writeConfigFallback(d.fallbacks); err != nil {
+		log.Printf("Failed to record config fallbacks: %v", err)
+	}
+	globalConfig = d.config
+
+	// Deliver notifications from a bounded queue, so bursts cannot pile up goroutines
+	startNotificationBus()
+	startTelemetryBus()
+
+	// Pick up where the previous daemon left off, whether it was restarted or crashed
+	previous, err := loadDaemonState()
+	if err != nil {
+		log.Printf("⚠️  Could not read the previous daemon state: %v", err)
+	}
+
 	// Create and start HTTP server if enabled
 	if d.config.HTTP.Enabled {
-		d.httpServer = NewHTTPServer(d.config.HTTP.Port)
+		d.httpServer = NewHTTPServer(d.config)
+		d.httpServer.components = d.components
+		d.httpServer.metrics = d.metrics
+		d.httpServer.jobs = d.runningJobs
 		if err := d.httpServer.Start(); err != nil {
 			d.cleanup()
 			return fmt.Errorf("failed to start HTTP server: %v", err)
 		}
+		if err := writeRuntime(d.httpServer.port); err != nil {
+			log.Printf("Failed to record the daemon port: %v", err)
+		}
+	}
+
+	// Publish running jobs and events for desktop panel indicators
+	if d.config.Daemon.DBus && runtime.GOOS == "linux" {
+		service, err := NewDBusService(d.runningJobs)
+		if err != nil {
+			log.Printf("⚠️  D-Bus service not available: %v", err)
+		} else {
+			d.dbus = service
+			d.dbus.Start()
+		}
+	}
+
+	// Announce this daemon as a hub on the LAN
+	if d.config.Hub.Advertise && d.httpServer != nil {
+		advertiser, err := NewHubAdvertiser(d.config.Hub.Name, d.httpServer.port)
+		if err == nil {
+			err = advertiser.Start()
+		}
+		if err != nil {
+			log.Printf("⚠️  Hub advertising not available: %v", err)
+		} else {
+			d.advertiser = advertiser
+		}
+	}
+
+	// Fetch queued events from remote daemons that cannot push to this hub
+	if len(d.config.Hub.Poll) > 0 {
+		poller, err := NewHubPoller(d.config.Hub.Poll, d.config.Hub.PollWait)
+		if err != nil {
+			log.Printf("⚠️  Hub poller not available: %v", err)
+		} else {
+			d.poller = poller
+			d.poller.Start()
+		}
+	}
+
+	// Answer commands sent to Telegram bots from their allowed chats
+	if bots := telegramCommandChannels(d.config.Channels); len(bots) > 0 {
+		bot, err := NewTelegramBot(bots, d.runningJobs)
+		if err != nil {
+			log.Printf("⚠️  Telegram bot not available: %v", err)
+		} else {
+			d.telegram = bot
+			d.telegram.Start()
+		}
 	}
 
 	// Create and start Docker monitor
 	if d.config.Docker.Monitor {
-		monitor, err := NewDockerMonitor()
+		monitor, err := NewDockerMonitor(d.config)
 		if err != nil {
 			log.Printf("⚠️  Docker monitor not available: %v", err)
 			log.Println("🔄 Continuing with HTTP server only...")
 		} else {
 			d.monitor = monitor
+			if previous != nil {
+				d.monitor.restore(previous.Execs, previous.DockerHandled)
+			}
 			if err := d.monitor.Start(); err != nil {
 				log.Printf("⚠️  Failed to start Docker monitoring: %v", err)
 				log.Println("🔄 Continuing with HTTP server only...")
@@ -85,6 +185,66 @@ func (d *Daemon) Start() error {
 		}
 	}
 
+	// Create and start scheduler for recurring commands
+	if len(d.config.Schedules) > 0 {
+		scheduler, err := NewScheduler(d.config.Schedules)
+		if err != nil {
+			log.Printf("⚠️  Scheduler not available: %v", err)
+		} else {
+			d.scheduler = scheduler
+			d.scheduler.Start()
+		}
+	}
+
+	// Create and start file watcher
+	if len(d.config.FileWatch.Rules) > 0 {
+		watcher, err := NewFileWatcher(d.config.FileWatch.Interval, d.config.FileWatch.Rules)
+		if err != nil {
+			log.Printf("⚠️  File watcher not available: %v", err)
+		} else {
+			d.watcher = watcher
+			d.watcher.Start()
+		}
+	}
+
+	// Create and start container log watcher
+	if len(d.config.LogWatch.Rules) > 0 {
+		logs, err := NewLogWatcher(d.config.LogWatch.Interval, d.config.LogWatch.Rules)
+		if err != nil {
+			log.Printf("⚠️  Log watcher not available: %v", err)
+		} else {
+			d.logs = logs
+			d.logs.Start()
+		}
+	}
+
+	// Create and start endpoint watcher
+	if len(d.config.Endpoints.Targets) > 0 {
+		endpoints, err := NewEndpointWatcher(d.config.Endpoints.Interval, d.config.Endpoints.Timeout, d.config.Endpoints.Targets)
+		if err != nil {
+			log.Printf("⚠️  Endpoint watcher not available: %v", err)
+		} else {
+			d.endpoints = endpoints
+			d.endpoints.Start()
+		}
+	}
+
+	// Create and start periodic history sync
+	if d.config.History.Sync.Interval != "" {
+		syncer, err := NewHistorySyncer(d.config)
+		if err != nil {
+			log.Printf("⚠️  History sync not available: %v", err)
+		} else {
+			d.historySync = syncer
+			d.historySync.Start()
+		}
+	}
+
+	if previous != nil {
+		d.restoreState(previous)
+	}
+	go d.state.saveEvery(d)
+
 	d.isRunning = true
 	log.Println("🚀 CmdBell daemon started successfully")
 	
@@ -126,14 +286,6 @@ func (d *Daemon) Stop() error {
 	return nil
 }
 
-func (d *Daemon) Status() {
-	if d.IsRunning() {
-		fmt.Printf("✅ CmdBell daemon is running (PID: %d)\n", d.GetPID())
-	} else {
-		fmt.Println("❌ CmdBell daemon is not running")
-	}
-}
-
 func (d *Daemon) IsRunning() bool {
 	pid := d.GetPID()
 	if pid == 0 {
@@ -200,6 +352,75 @@ func (d *Daemon) handleSignals() {
 	}
 }
 
+// components reports which optional parts of the daemon are running
+func (d *Daemon) components() map[string]bool {
+	return map[string]bool{
+		"http_server":      d.httpServer != nil,
+		"docker_monitor":   d.monitor != nil,
+		"scheduler":        d.scheduler != nil,
+		"file_watcher":     d.watcher != nil,
+		"log_watcher":      d.logs != nil,
+		"endpoint_watcher": d.endpoints != nil,
+		"history_sync":     d.historySync != nil,
+		"hub_advertiser":   d.advertiser != nil,
+		"hub_poller":       d.poller != nil,
+		"telegram_bot":     d.telegram != nil,
+		"dbus_service":     d.dbus != nil,
+	}
+}
+
+// restoreState delivers the notifications the previous daemon had queued and reports what it
+// carried over. Tracked execs were handed to the Docker monitor before it started.
+func (d *Daemon) restoreState(previous *DaemonState) {
+	how := "after a crash"
+	if previous.Shutdown {
+		how = "after a restart"
+	}
+	d.state.mu.Lock()
+	d.state.previous = previous
+	d.state.mu.Unlock()
+
+	pending := pendingToRestore(previous)
+	execs := 0
+	if d.monitor != nil {
+		execs = len(previous.Execs)
+	}
+	log.Printf("♻️  Resuming %s at %s: %d tracked execs, %d pending notifications, %d running jobs, %d active mutes, %d unseen notifications",
+		how, previous.SavedAt.Local().Format("15:04:05"), execs, len(pending), len(d.runningJobs()), len(activeMutes(loadMutes())), unseenCount())
+
+	for _, n := range pending {
+		deliverNotification(n)
+	}
+}
+
+// runningJobs collects the work currently being timed, for the D-Bus service
+func (d *Daemon) runningJobs() []RunningJob {
+	var jobs []RunningJob
+	if d.monitor != nil {
+		jobs = append(jobs, d.monitor.RunningExecs()...)
+	}
+	if d.httpServer != nil {
+		jobs = append(jobs, d.httpServer.marks.running()...)
+		jobs = append(jobs, d.httpServer.background.running()...)
+	}
+	return jobs
+}
+
+// metrics reports internal counters, such as execs waiting for their exec_die event
+func (d *Daemon) metrics() map[string]int {
+	metrics := map[string]int{}
+	if d.monitor != nil {
+		metrics["tracked_execs"] = d.monitor.TrackedExecs()
+		metrics["docker_monitor_restarts"] = d.monitor.Restarts()
+		d.monitor.addQueueMetrics(metrics)
+	}
+	notificationBus.Load().addMetrics(metrics, "notifications")
+	telemetryBus.Load().addMetrics(metrics, "telemetry")
+	addChannelMetrics(metrics)
+	metrics["config_fallbacks"] = len(d.fallbacks)
+	return metrics
+}
+
 func (d *Daemon) shutdown() {
 	log.Println("🛑 Shutting down CmdBell daemon...")
 	
@@ -207,10 +428,58 @@ func (d *Daemon) shutdown() {
 		d.monitor.Stop()
 	}
 	
+	if d.advertiser != nil {
+		d.advertiser.Stop()
+	}
+	
+	if d.poller != nil {
+		d.poller.Stop()
+	}
+	
+	if d.telegram != nil {
+		d.telegram.Stop()
+	}
+	
+	// Deliver what is still queued, within the time a single delivery may take; from here on
+	// notifications are delivered directly, and the HTTP server waits for them
+	if bus := notificationBus.Load(); bus != nil {
+		bus.Close(notificationTimeout())
+	}
+	
+	// Traces of the last deliveries go out before the daemon stops
+	telemetryBus.Load().Close(telemetryTimeout)
+
+	// Whatever did not make it out is saved with the execs still running, for the next start
+	d.state.save(d, true)
+	
 	if d.httpServer != nil {
 		d.httpServer.Stop()
 	}
 	
+	if d.scheduler != nil {
+		d.scheduler.Stop()
+	}
+	
+	if d.watcher != nil {
+		d.watcher.Stop()
+	}
+	
+	if d.logs != nil {
+		d.logs.Stop()
+	}
+	
+	if d.endpoints != nil {
+		d.endpoints.Stop()
+	}
+	
+	if d.historySync != nil {
+		d.historySync.Stop()
+	}
+	
+	if d.dbus != nil {
+		d.dbus.Stop()
+	}
+	
 	d.cleanup()
 	d.cancel()
 	d.isRunning = false
@@ -224,4 +493,6 @@ func (d *Daemon) cleanup() {
 	if err := os.Remove(d.pidFile); err != nil && !os.IsNotExist(err) {
 		log.Printf("Failed to remove PID file: %v", err)
 	}
+	removeRuntime()
+	writeConfigFallback(nil)
 }
\ No newline at end of file
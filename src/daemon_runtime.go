@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+)
+
+// runtimePath is client.RuntimeFile, where the daemon records the port it actually listens on
+func runtimePath() (string, error) {
+	return getDataPath("runtime.json")
+}
+
+// writeRuntime records where the running daemon can be reached, for the CLI, shell hooks and
+// injected clients to find it when it listens on a port other than the configured one
+func writeRuntime(port int) error {
+	path, err := runtimePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(client.Runtime{
+		PID:       os.Getpid(),
+		Port:      port,
+		URL:       fmt.Sprintf("http://localhost:%d", port),
+		StartedAt: time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+func removeRuntime() {
+	if path, err := runtimePath(); err == nil {
+		os.Remove(path)
+	}
+}
+
+// daemonPort is the port this machine's daemon listens on: the one it recorded while it runs,
+// otherwise the configured one
+func daemonPort() int {
+	if path, err := runtimePath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			var runtime client.Runtime
+			if json.Unmarshal(data, &runtime) == nil && runtime.Port > 0 && processAlive(runtime.PID, "") {
+				return runtime.Port
+			}
+		}
+	}
+	if globalConfig != nil {
+		return globalConfig.HTTP.Port
+	}
+	return 59721
+}
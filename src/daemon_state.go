@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// stateSaveInterval is how often the running daemon saves its state, bounding what a crash loses
+	stateSaveInterval = 30 * time.Second
+	// maxPendingAge drops restored notifications too old to be worth delivering
+	maxPendingAge = 24 * time.Hour
+)
+
+// DaemonState is what the daemon otherwise only holds in memory, saved to
+// ~/.cmdbell/daemon-state.json on shutdown and every stateSaveInterval, and restored on start.
+// Mutes, marks, background jobs, acknowledged notifications and the hub outbox are written to
+// disk as they change, so they need no snapshot.
+type DaemonState struct {
+	SavedAt  time.Time `json:"saved_at"`
+	Shutdown bool      `json:"shutdown"` // saved by a clean shutdown rather than periodically
+
+	// Docker execs waiting for exec_die, and the timestamp of the last Docker event handled
+	Execs         map[string]*ContainerExecInfo `json:"execs,omitempty"`
+	DockerHandled time.Time                     `json:"docker_handled,omitzero"`
+
+	// Notifications queued for delivery and not delivered yet
+	Pending []*Notification `json:"pending,omitempty"`
+}
+
+// daemonState saves the daemon's state; once the shutdown snapshot is written, periodic saves
+// stop so they cannot replace it
+type daemonState struct {
+	mu       sync.Mutex
+	done     bool
+	previous *DaemonState // kept for the execs when Docker is unavailable this time
+}
+
+func daemonStatePath() (string, error) {
+	return getDataPath("daemon-state.json")
+}
+
+// loadDaemonState returns the state saved by the previous daemon, nil when there is none
+func loadDaemonState() (*DaemonState, error) {
+	path, err := daemonStatePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err == nil {
+		data, err = openData(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state DaemonState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// save writes a snapshot of the daemon. The shutdown snapshot takes the notifications left in
+// the closed queue, which are then no longer delivered by this daemon.
+func (s *daemonState) save(d *Daemon, shutdown bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+
+	state := DaemonState{SavedAt: time.Now(), Shutdown: shutdown}
+	if d.monitor != nil {
+		state.Execs, state.DockerHandled = d.monitor.snapshot()
+	} else if s.previous != nil {
+		state.Execs, state.DockerHandled = s.previous.Execs, s.previous.DockerHandled
+	}
+	if shutdown {
+		state.Pending = notificationBus.Load().Drain()
+		s.done = true
+	} else {
+		state.Pending = notificationBus.Load().Pending()
+	}
+
+	path, err := daemonStatePath()
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		data, err = sealData(data)
+	}
+	if err != nil {
+		log.Printf("Failed to encode daemon state: %v", err)
+		return
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		log.Printf("Failed to save daemon state: %v", err)
+	}
+	if shutdown && (len(state.Execs) > 0 || len(state.Pending) > 0) {
+		log.Printf("💾 Saved %d tracked execs and %d pending notifications for the next start", len(state.Execs), len(state.Pending))
+	}
+}
+
+// saveEvery saves the daemon's state every stateSaveInterval until it stops
+func (s *daemonState) saveEvery(d *Daemon) {
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			s.save(d, false)
+		}
+	}
+}
+
+// pendingToRestore drops saved notifications that are too old, or that the previous daemon
+// delivered after its last periodic save and so are already in history
+func pendingToRestore(state *DaemonState) []*Notification {
+	cutoff := time.Now().Add(-maxPendingAge)
+	var pending []*Notification
+	oldest := time.Now()
+	for _, n := range state.Pending {
+		if n == nil || n.Time.Before(cutoff) {
+			continue
+		}
+		pending = append(pending, n)
+		if n.Time.Before(oldest) {
+			oldest = n.Time
+		}
+	}
+	if len(pending) == 0 || state.Shutdown {
+		return pending
+	}
+
+	store := getHistoryStore()
+	if store == nil {
+		return pending
+	}
+	entries, err := store.Query(HistoryFilter{Since: oldest.Add(-time.Second)})
+	if err != nil {
+		return pending
+	}
+	delivered := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		delivered[entry.ID] = true
+	}
+	undelivered := pending[:0]
+	for _, n := range pending {
+		if !delivered[n.ID] {
+			undelivered = append(undelivered, n)
+		}
+	}
+	return undelivered
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+)
+
+// recentErrorWindow is how far back 'cmdbell --daemon status' lists delivery errors
+const recentErrorWindow = 24 * time.Hour
+
+// DaemonStatus is what 'cmdbell --daemon status' reports, from the PID file, the daemon's
+// /status endpoint and the decision log every delivery is recorded in
+type DaemonStatus struct {
+	Running           bool              `json:"running"`
+	PID               int               `json:"pid,omitempty"`
+	StartedAt         *time.Time        `json:"started_at,omitempty"`
+	UptimeSeconds     int               `json:"uptime_seconds,omitempty"`
+	ConfigPath        string            `json:"config_path"`
+	Profile           string            `json:"profile,omitempty"`
+	ConfigFallbacks   []string          `json:"config_fallbacks,omitempty"`
+	Components        []ComponentStatus `json:"components"`
+	Channels          []ChannelStatus   `json:"channels"`
+	NotificationsHour int               `json:"notifications_last_hour"`
+	Unseen            int               `json:"unseen"`
+	LastNotification  *Decision         `json:"last_notification,omitempty"`
+	RecentErrors      []DeliveryError   `json:"recent_errors"`
+}
+
+// ComponentStatus is one optional part of the daemon
+type ComponentStatus struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Running bool   `json:"running"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// ChannelStatus is a configured channel and how its last delivery went
+type ChannelStatus struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	LastDelivery *time.Time `json:"last_delivery,omitempty"`
+	Healthy      bool       `json:"healthy"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// DeliveryError is a failed delivery from the decision log
+type DeliveryError struct {
+	Time   time.Time `json:"time"`
+	Target string    `json:"target"`
+	Error  string    `json:"error"`
+}
+
+func (d *Daemon) Status(jsonOutput bool) {
+	status := d.collectStatus()
+	if jsonOutput {
+		data, _ := json.MarshalIndent(status, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	printDaemonStatus(status)
+}
+
+func (d *Daemon) collectStatus() DaemonStatus {
+	status := DaemonStatus{Running: d.IsRunning()}
+	status.ConfigPath, _ = getConfigPath()
+	status.Profile = activeProfile()
+
+	var running map[string]bool
+	var metrics map[string]int
+	if status.Running {
+		status.PID = d.GetPID()
+		status.ConfigFallbacks = readConfigFallback()
+
+		// Uptime and component state come from the daemon itself, when its HTTP server is up
+		if d.config.HTTP.Enabled {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			remote, err := client.New(fmt.Sprintf("http://localhost:%d", daemonPort()), "").Status(ctx)
+			cancel()
+			if err == nil {
+				status.StartedAt = &remote.StartedAt
+				status.UptimeSeconds = remote.UptimeSeconds
+				if remote.ConfigPath != "" {
+					status.ConfigPath = remote.ConfigPath
+				}
+				status.Profile = remote.Profile
+				running, metrics = remote.Components, remote.Metrics
+			}
+		}
+	}
+
+	status.Components = d.componentStatus(running, metrics)
+
+	decisions := []Decision{}
+	if path, err := getDataPath("decisions.jsonl"); err == nil {
+		decisions, _ = readDecisions(path)
+	}
+	status.Channels = channelStatus(d.config.Channels, decisions)
+	status.NotificationsHour, status.LastNotification = notificationActivity(decisions)
+	status.Unseen = unseenCount()
+	status.RecentErrors = recentDeliveryErrors(decisions, 5)
+	return status
+}
+
+// componentStatus pairs what the config enables with what the daemon reports running; running is
+// nil when the daemon could not be asked
+func (d *Daemon) componentStatus(running map[string]bool, metrics map[string]int) []ComponentStatus {
+	c := d.config
+	enabled := map[string]bool{
+		"http_server":      c.HTTP.Enabled,
+		"docker_monitor":   c.Docker.Monitor,
+		"scheduler":        len(c.Schedules) > 0,
+		"file_watcher":     len(c.FileWatch.Rules) > 0,
+		"log_watcher":      len(c.LogWatch.Rules) > 0,
+		"endpoint_watcher": len(c.Endpoints.Targets) > 0,
+		"history_sync":     c.History.Sync.Interval != "",
+		"hub_advertiser":   c.Hub.Advertise,
+		"hub_poller":       len(c.Hub.Poll) > 0,
+		"telegram_bot":     slices.ContainsFunc(c.Channels, func(ch ChannelConfig) bool { return ch.Type == "telegram" && ch.Commands }),
+		"dbus_service":     c.Daemon.DBus,
+	}
+
+	var names []string
+	for name := range enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	components := []ComponentStatus{}
+	for _, name := range names {
+		component := ComponentStatus{Name: name, Enabled: enabled[name], Running: running[name]}
+		if name == "docker_monitor" {
+			var details []string
+			if restarts := metrics["docker_monitor_restarts"]; restarts > 0 {
+				details = append(details, fmt.Sprintf("%d restarts", restarts))
+			}
+			if dropped := metrics["docker_events_dropped"]; dropped > 0 {
+				details = append(details, fmt.Sprintf("%d events dropped, queue full", dropped))
+			}
+			component.Detail = strings.Join(details, ", ")
+		}
+		if component.Enabled || component.Running {
+			components = append(components, component)
+		}
+	}
+	return components
+}
+
+// channelStatus reports each configured channel's most recent delivery
+func channelStatus(configs []ChannelConfig, decisions []Decision) []ChannelStatus {
+	channels := []ChannelStatus{}
+	for _, config := range configs {
+		name := config.Name
+		if name == "" {
+			name = config.Type
+		}
+		channel := ChannelStatus{Name: name, Type: config.Type, Healthy: true}
+
+		for i := len(decisions) - 1; i >= 0 && channel.LastDelivery == nil; i-- {
+			for _, delivery := range decisions[i].Deliveries {
+				if delivery.Target != "channel "+name || delivery.Status == "skipped" {
+					continue
+				}
+				when := decisions[i].Time
+				channel.LastDelivery = &when
+				channel.Healthy = delivery.Status == "ok"
+				channel.LastError = delivery.Error
+			}
+		}
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// notificationActivity counts deliveries in the last hour and returns the latest
+func notificationActivity(decisions []Decision) (int, *Decision) {
+	count := 0
+	var last *Decision
+	hourAgo := time.Now().Add(-time.Hour)
+	for i := range decisions {
+		if decisions[i].Outcome != "delivered" {
+			continue
+		}
+		last = &decisions[i]
+		if decisions[i].Time.After(hourAgo) {
+			count++
+		}
+	}
+	return count, last
+}
+
+// recentDeliveryErrors returns the latest failed deliveries, newest first
+func recentDeliveryErrors(decisions []Decision, limit int) []DeliveryError {
+	failures := []DeliveryError{}
+	since := time.Now().Add(-recentErrorWindow)
+	for i := len(decisions) - 1; i >= 0 && len(failures) < limit; i-- {
+		if decisions[i].Time.Before(since) {
+			break
+		}
+		for _, delivery := range decisions[i].Deliveries {
+			if delivery.Status == "failed" && len(failures) < limit {
+				failures = append(failures, DeliveryError{Time: decisions[i].Time, Target: delivery.Target, Error: delivery.Error})
+			}
+		}
+	}
+	return failures
+}
+
+func printDaemonStatus(status DaemonStatus) {
+	if !status.Running {
+		fmt.Println("❌ CmdBell daemon is not running")
+	} else {
+		fmt.Printf("✅ CmdBell daemon is running (PID: %d)\n", status.PID)
+		if status.StartedAt != nil {
+			fmt.Printf("   Uptime:  %s (since %s)\n", formatDuration(time.Duration(status.UptimeSeconds)*time.Second),
+				status.StartedAt.Local().Format("2006-01-02 15:04"))
+		}
+	}
+	fmt.Printf("   Config:  %s\n", status.ConfigPath)
+	if status.Profile != "" {
+		fmt.Printf("   Profile: %s\n", status.Profile)
+	}
+
+	if len(status.ConfigFallbacks) > 0 {
+		fmt.Printf("🚨 RUNNING WITH FALLBACK CONFIG: %d setting(s) were ignored or defaulted\n", len(status.ConfigFallbacks))
+		for _, fallback := range status.ConfigFallbacks {
+			fmt.Printf("   - %s\n", fallback)
+		}
+		fmt.Println("   Fix them ('cmdbell config validate') and run 'cmdbell --daemon restart'")
+	}
+
+	if status.Running && status.StartedAt == nil {
+		fmt.Println("\n⚠️  The daemon's HTTP server did not answer, so component state is unknown")
+	} else if status.Running {
+		fmt.Println("\nComponents:")
+		for _, component := range status.Components {
+			icon, state := "✅", "running"
+			switch {
+			case !component.Running && component.Enabled:
+				icon, state = "⚠️ ", "enabled but not running"
+			case component.Running && !component.Enabled:
+				state = "running, no longer in the config"
+			}
+			if component.Detail != "" {
+				state += ", " + component.Detail
+			}
+			fmt.Printf("   %s %s: %s\n", icon, component.Name, state)
+		}
+	}
+
+	if len(status.Channels) > 0 {
+		fmt.Println("\nChannels:")
+		for _, channel := range status.Channels {
+			switch {
+			case channel.LastDelivery == nil:
+				fmt.Printf("   ⚪ %s (%s): no deliveries yet\n", channel.Name, channel.Type)
+			case channel.Healthy:
+				fmt.Printf("   ✅ %s (%s): last delivered %s ago\n", channel.Name, channel.Type, formatDuration(time.Since(*channel.LastDelivery)))
+			default:
+				fmt.Printf("   ❌ %s (%s): failed %s ago: %s\n", channel.Name, channel.Type, formatDuration(time.Since(*channel.LastDelivery)), channel.LastError)
+			}
+		}
+	}
+
+	fmt.Printf("\nNotifications: %d in the last hour", status.NotificationsHour)
+	if last := status.LastNotification; last != nil {
+		fmt.Printf(", last %s ago: %s", formatDuration(time.Since(last.Time)), strings.TrimSpace(last.Message))
+	}
+	fmt.Println()
+	if status.Unseen > 0 {
+		fmt.Printf("   🔔 %d unseen, see 'cmdbell status' or clear with 'cmdbell ack'\n", status.Unseen)
+	}
+
+	if len(status.RecentErrors) > 0 {
+		fmt.Println("\nRecent delivery errors:")
+		for _, e := range status.RecentErrors {
+			fmt.Printf("   %s %s: %s\n", e.Time.Local().Format("01-02 15:04"), e.Target, e.Error)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const (
+	dbusName      = "org.cmdbell"
+	dbusPath      = dbus.ObjectPath("/org/cmdbell/Daemon")
+	dbusInterface = "org.cmdbell.Daemon"
+	// dbusRecentEvents bounds the events kept for RecentEvents
+	dbusRecentEvents = 50
+)
+
+// RunningJob is work the daemon is timing right now, such as a docker exec or a notify-start mark
+type RunningJob struct {
+	Source  string // "container", "mark" or "background"
+	Name    string
+	Detail  string // container name for execs
+	PID     int    // background jobs, the only ones CmdBell can signal
+	Started time.Time
+}
+
+// dbusJob is a RunningJob on the bus, signature (sssx) with the start in Unix seconds
+type dbusJob struct {
+	Source  string
+	Name    string
+	Detail  string
+	Started int64
+}
+
+// dbusEvent is a delivered notification on the bus, signature (ssssssbdxb) with the time in Unix seconds
+type dbusEvent struct {
+	ID              string
+	Source          string
+	Host            string
+	Title           string
+	Message         string
+	Icon            string
+	Success         bool
+	DurationSeconds float64
+	Time            int64
+	Acknowledged    bool
+}
+
+// DBusService exposes running jobs and recent events on the session bus as org.cmdbell, so panel
+// indicators such as a GNOME Shell extension or KDE widget can follow the daemon through signals
+// instead of polling HTTP
+type DBusService struct {
+	conn *dbus.Conn
+	jobs func() []RunningJob
+
+	mu     sync.Mutex
+	events []dbusEvent // oldest first; Acknowledged is filled in from acks.json when listed
+}
+
+// activeDBusService is the running service, or nil; its methods are safe to call on nil
+var activeDBusService *DBusService
+
+func NewDBusService(jobs func() []RunningJob) (*DBusService, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("D-Bus is only used on Linux")
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the session bus: %v", err)
+	}
+
+	s := &DBusService{conn: conn, jobs: jobs}
+	object := &dbusObject{service: s}
+	if err := conn.Export(object, dbusPath, dbusInterface); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to export %s: %v", dbusInterface, err)
+	}
+
+	node := &introspect.Node{
+		Name: string(dbusPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			{
+				Name:    dbusInterface,
+				Methods: introspect.Methods(object),
+				Signals: []introspect.Signal{
+					{Name: "EventDelivered", Args: []introspect.Arg{{Name: "event", Type: "(ssssssbdxb)"}}},
+					{Name: "Acknowledged", Args: []introspect.Arg{{Name: "ids", Type: "as"}}},
+					{Name: "JobsChanged"},
+				},
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), dbusPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to export introspection data: %v", err)
+	}
+
+	reply, err := conn.RequestName(dbusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to request name %s: %v", dbusName, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, fmt.Errorf("name %s is already taken", dbusName)
+	}
+
+	return s, nil
+}
+
+func (s *DBusService) Start() {
+	activeDBusService = s
+	log.Printf("🖥️  D-Bus service %s available at %s", dbusName, dbusPath)
+}
+
+func (s *DBusService) Stop() {
+	activeDBusService = nil
+	s.conn.Close()
+}
+
+// publishEvent remembers a delivered notification and announces it with EventDelivered
+func (s *DBusService) publishEvent(n *Notification) {
+	if s == nil {
+		return
+	}
+
+	event := dbusEvent{
+		ID:              n.ID,
+		Source:          n.Source,
+		Host:            n.Host,
+		Title:           n.Title,
+		Message:         n.RemoteMessage(),
+		Icon:            n.Icon,
+		Success:         n.Success,
+		DurationSeconds: n.Duration.Seconds(),
+		Time:            n.Time.Unix(),
+	}
+
+	s.mu.Lock()
+	s.events = append(s.events, event)
+	if len(s.events) > dbusRecentEvents {
+		s.events = s.events[len(s.events)-dbusRecentEvents:]
+	}
+	s.mu.Unlock()
+
+	s.emit("EventDelivered", event)
+}
+
+// jobsChanged tells listeners to fetch RunningJobs again
+func (s *DBusService) jobsChanged() {
+	if s == nil {
+		return
+	}
+	s.emit("JobsChanged")
+}
+
+func (s *DBusService) emit(signal string, args ...interface{}) {
+	if err := s.conn.Emit(dbusPath, dbusInterface+"."+signal, args...); err != nil {
+		log.Printf("Failed to emit D-Bus signal %s: %v", signal, err)
+	}
+}
+
+// acknowledge marks the given events, or every recent and unseen event when ids is empty, and
+// announces the IDs that changed
+func (s *DBusService) acknowledge(ids []string) *dbus.Error {
+	if len(ids) == 0 {
+		s.mu.Lock()
+		for _, event := range s.events {
+			ids = append(ids, event.ID)
+		}
+		s.mu.Unlock()
+		unseen, _ := unseenEvents()
+		for _, entry := range unseen {
+			ids = append(ids, entry.ID)
+		}
+	}
+
+	changed, err := acknowledgeEvents(ids)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	if len(changed) > 0 {
+		s.emit("Acknowledged", changed)
+	}
+	return nil
+}
+
+// dbusObject holds the methods callable on org.cmdbell.Daemon; godbus exports every exported method
+type dbusObject struct {
+	service *DBusService
+}
+
+// RunningJobs returns the jobs being timed, oldest first
+func (o *dbusObject) RunningJobs() ([]dbusJob, *dbus.Error) {
+	jobs := []dbusJob{}
+	if o.service.jobs != nil {
+		running := o.service.jobs()
+		sort.Slice(running, func(i, j int) bool { return running[i].Started.Before(running[j].Started) })
+		for _, job := range running {
+			jobs = append(jobs, dbusJob{Source: job.Source, Name: job.Name, Detail: job.Detail, Started: job.Started.Unix()})
+		}
+	}
+	return jobs, nil
+}
+
+// RecentEvents returns up to limit events delivered since the daemon started, newest first; 0 returns all
+func (o *dbusObject) RecentEvents(limit uint32) ([]dbusEvent, *dbus.Error) {
+	acks := loadAcks()
+
+	o.service.mu.Lock()
+	defer o.service.mu.Unlock()
+
+	events := []dbusEvent{}
+	for i := len(o.service.events) - 1; i >= 0; i-- {
+		if limit > 0 && uint32(len(events)) >= limit {
+			break
+		}
+		event := o.service.events[i]
+		_, event.Acknowledged = acks[event.ID]
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Unacknowledged returns how many recent events have not been acknowledged, e.g. for a panel badge
+func (o *dbusObject) Unacknowledged() (uint32, *dbus.Error) {
+	acks := loadAcks()
+
+	o.service.mu.Lock()
+	defer o.service.mu.Unlock()
+
+	count := uint32(0)
+	for _, event := range o.service.events {
+		if _, ok := acks[event.ID]; !ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Ack acknowledges one event by ID, shared with 'cmdbell open cmdbell://ack/<id>'
+func (o *dbusObject) Ack(id string) *dbus.Error {
+	if id == "" {
+		return dbus.MakeFailedError(fmt.Errorf("event ID required, use AckAll to acknowledge everything"))
+	}
+	return o.service.acknowledge([]string{id})
+}
+
+// AckAll acknowledges every recent event
+func (o *dbusObject) AckAll() *dbus.Error {
+	return o.service.acknowledge(nil)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxDecisions is how many decisions ~/.cmdbell/decisions.jsonl keeps once compacted
+	maxDecisions = 500
+	// decisionsCompactSize is how large the file grows from appends before it is compacted
+	decisionsCompactSize = 1 << 20
+)
+
+// Decision traces why an event did or did not notify, for `cmdbell explain`
+type Decision struct {
+	ID              string           `json:"id"` // history entry ID when delivered
+	Time            time.Time        `json:"time"`
+	Source          string           `json:"source"`
+	Command         string           `json:"command,omitempty"`
+	Message         string           `json:"message,omitempty"`
+	DurationSeconds float64          `json:"duration_seconds"`
+	Success         bool             `json:"success"`
+	Threshold       string           `json:"threshold,omitempty"`
+	Tier            string           `json:"tier,omitempty"`
+	Reason          string           `json:"reason,omitempty"` // how the threshold was resolved
+	Outcome         string           `json:"outcome"`          // "delivered", "below threshold" or "notifications disabled"
+	Deliveries      []DeliveryResult `json:"deliveries,omitempty"`
+}
+
+// DeliveryResult is what happened to one notifier or channel
+type DeliveryResult struct {
+	Target  string    `json:"target"`
+	Status  string    `json:"status"` // "ok", "failed" or "skipped"
+	Error   string    `json:"error,omitempty"`
+	Started time.Time `json:"started,omitzero"` // when the send began; zero when it was not attempted
+	Seconds float64   `json:"seconds,omitempty"`
+}
+
+// decisionTrace collects delivery results that arrive concurrently for one notification
+type decisionTrace struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	decision Decision
+}
+
+func (t *decisionTrace) record(target, status string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := DeliveryResult{Target: target, Status: status}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	t.decision.Deliveries = append(t.decision.Deliveries, result)
+}
+
+// recordSend records the outcome of a send that began at started
+func (t *decisionTrace) recordSend(target string, started time.Time, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := DeliveryResult{Target: target, Status: "ok", Started: started, Seconds: time.Since(started).Seconds()}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	t.decision.Deliveries = append(t.decision.Deliveries, result)
+}
+
+// newDeliveredDecision starts the trace of a notification that passed its checks
+func newDeliveredDecision(n *Notification) *decisionTrace {
+	decision := Decision{
+		ID:              n.ID,
+		Time:            n.Time,
+		Source:          n.Source,
+		Command:         sanitizeCommand(n.Command),
+		Message:         n.RemoteMessage(),
+		DurationSeconds: n.Duration.Seconds(),
+		Success:         n.Success,
+		Outcome:         "delivered",
+	}
+	if n.Source == "command" && n.Command != "" && n.Host == "" {
+		resolution := resolveThreshold(n.Command)
+		decision.Threshold = resolution.Duration.String()
+		decision.Tier = resolution.Tier
+		decision.Reason = resolution.Reason
+	}
+	return &decisionTrace{decision: decision}
+}
+
+// recordSuppressed logs a command that did not notify and why
+func recordSuppressed(commandLine string, duration time.Duration, resolution thresholdResolution, outcome string) {
+	appendDecision(Decision{
+		ID:              newEventID(),
+		Time:            time.Now(),
+		Source:          "command",
+		Command:         sanitizeCommand(commandLine),
+		DurationSeconds: duration.Seconds(),
+		Threshold:       resolution.Duration.String(),
+		Tier:            resolution.Tier,
+		Reason:          resolution.Reason,
+		Outcome:         outcome,
+	})
+}
+
+var decisionsMu sync.Mutex
+
+// appendDecision appends a decision to ~/.cmdbell/decisions.jsonl, which the daemon and CLI
+// processes share under a file lock, and compacts the file once appends have grown it
+func appendDecision(decision Decision) {
+	path, err := getDataPath("decisions.jsonl")
+	if err != nil {
+		return
+	}
+
+	exportDecision(decision)
+
+	data, err := json.Marshal(decision)
+	if err == nil {
+		data, err = sealData(data)
+	}
+	if err != nil {
+		errorf("Failed to record decision: %v\n", err)
+		return
+	}
+
+	decisionsMu.Lock()
+	defer decisionsMu.Unlock()
+	unlock, err := lockFile(path)
+	if err != nil {
+		errorf("Failed to record decision: %v\n", err)
+		return
+	}
+	defer unlock()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		errorf("Failed to record decision: %v\n", err)
+		return
+	}
+	_, err = file.Write(append(data, '\n'))
+	file.Close()
+	if err != nil {
+		errorf("Failed to record decision: %v\n", err)
+		return
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Size() > decisionsCompactSize {
+		if err := compactDecisions(path); err != nil {
+			errorf("Failed to compact decisions: %v\n", err)
+		}
+	}
+}
+
+// compactDecisions keeps the newest maxDecisions lines as they are, sealed or not, and no more
+// than half of decisionsCompactSize so appends have room before the next compaction. The caller
+// holds the decisions lock.
+func compactDecisions(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	keep, size := 0, 0
+	for i := len(lines) - 1; i >= 0 && keep < maxDecisions; i-- {
+		if size += len(lines[i]) + 1; size > decisionsCompactSize/2 && keep > 0 {
+			break
+		}
+		keep++
+	}
+	lines = lines[len(lines)-keep:]
+	return writeFileAtomic(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
+
+// deliveredToChannel reports whether a remote channel accepted the notification with id
+func deliveredToChannel(id string) bool {
+	path, err := getDataPath("decisions.jsonl")
+	if err != nil {
+		return false
+	}
+	decisions, _ := readDecisions(path)
+	for i := len(decisions) - 1; i >= 0; i-- {
+		if decisions[i].ID != id {
+			continue
+		}
+		for _, delivery := range decisions[i].Deliveries {
+			if strings.HasPrefix(delivery.Target, "channel ") && delivery.Status == "ok" {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func readDecisions(path string) ([]Decision, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var decisions []Decision
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var decision Decision
+		line, err := openData(scanner.Bytes())
+		if err != nil {
+			return decisions, err
+		}
+		if err := json.Unmarshal(line, &decision); err == nil {
+			decisions = append(decisions, decision)
+		}
+	}
+	return decisions, scanner.Err()
+}
+
+// handleExplainCommand handles `cmdbell explain --last | <history-id> | --search <text>`
+func handleExplainCommand() {
+	args := os.Args[2:]
+	if len(args) == 0 {
+		printExplainUsage()
+		os.Exit(1)
+	}
+
+	path, err := getDataPath("decisions.jsonl")
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	decisions, err := readDecisions(path)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Failed to read decisions: %v\n", err)
+		os.Exit(1)
+	}
+
+	var match func(Decision) bool
+	switch {
+	case args[0] == "--last":
+		match = func(Decision) bool { return true }
+	case args[0] == "--search" && len(args) > 1:
+		search := strings.ToLower(strings.Join(args[1:], " "))
+		match = func(d Decision) bool {
+			return strings.Contains(strings.ToLower(d.Command+" "+d.Message), search)
+		}
+	case !strings.HasPrefix(args[0], "-"):
+		match = func(d Decision) bool { return strings.HasPrefix(d.ID, args[0]) }
+	default:
+		printExplainUsage()
+		os.Exit(1)
+	}
+
+	for i := len(decisions) - 1; i >= 0; i-- {
+		if match(decisions[i]) {
+			printDecision(decisions[i])
+			return
+		}
+	}
+	fmt.Println("No matching event; only the last 500 are kept")
+	os.Exit(1)
+}
+
+func printExplainUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  cmdbell explain --last            - Explain the most recent event")
+	fmt.Println("  cmdbell explain <id>              - Explain a history entry (IDs from 'cmdbell history --json')")
+	fmt.Println("  cmdbell explain --search <text>   - Explain the most recent event matching text")
+}
+
+func printDecision(d Decision) {
+	fmt.Printf("Event:     %s (%s)\n", d.ID, d.Time.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Source:    %s\n", d.Source)
+	if d.Command != "" {
+		fmt.Printf("Command:   %s\n", d.Command)
+	}
+	fmt.Printf("Duration:  %s\n", formatDuration(time.Duration(d.DurationSeconds*float64(time.Second))))
+	if d.Threshold != "" {
+		threshold := d.Threshold
+		if d.Tier != "" {
+			threshold += fmt.Sprintf(" (tier %s)", d.Tier)
+		}
+		fmt.Printf("Threshold: %s, from %s\n", threshold, d.Reason)
+	}
+	fmt.Printf("Outcome:   %s\n", d.Outcome)
+
+	for _, delivery := range d.Deliveries {
+		switch delivery.Status {
+		case "ok":
+			fmt.Printf("  ✅ %s\n", delivery.Target)
+		case "skipped":
+			fmt.Printf("  ⏭️  %s: %s\n", delivery.Target, delivery.Error)
+		default:
+			fmt.Printf("  ❌ %s: %s\n", delivery.Target, delivery.Error)
+		}
+	}
+	if d.Outcome == "delivered" && len(d.Deliveries) == 0 {
+		fmt.Println("  No notifier or channel was configured")
+	}
+}
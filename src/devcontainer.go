@@ -0,0 +1,233 @@
+package main
+
+import (
+	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 'cmdbell generate devcontainer-feature' writes a local dev container feature that installs the
+// notify-only client and the container hook from inject.go at image build time, so every container
+// built from a devcontainer.json notifies the host daemon without running 'cmdbell inject'.
+const defaultFeatureDir = ".devcontainer/cmdbell"
+
+// featureArchNames names the bundled client for each architecture, matching install.sh's uname -m mapping
+var featureArchNames = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_ARM:     "arm",
+}
+
+type featureOption struct {
+	Type        string `json:"type"`
+	Default     string `json:"default"`
+	Description string `json:"description"`
+}
+
+type devcontainerFeature struct {
+	ID            string                   `json:"id"`
+	Version       string                   `json:"version"`
+	Name          string                   `json:"name"`
+	Description   string                   `json:"description"`
+	Options       map[string]featureOption `json:"options"`
+	InstallsAfter []string                 `json:"installsAfter"`
+}
+
+func handleGenerateCommand() {
+	if len(os.Args) < 3 || os.Args[2] != "devcontainer-feature" {
+		fmt.Println("Usage: cmdbell generate devcontainer-feature [--dir <dir>] [--url <daemon url>] [--client <cmdbell-notify>]...")
+		os.Exit(1)
+	}
+
+	args := os.Args[3:]
+	dir, url := defaultFeatureDir, ""
+	var clients []string
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			fmt.Printf("Missing value for %s\n", args[i])
+			os.Exit(1)
+		}
+		switch args[i] {
+		case "--dir":
+			dir = args[i+1]
+		case "--url":
+			url = args[i+1]
+		case "--client":
+			clients = append(clients, args[i+1])
+		default:
+			fmt.Printf("Unknown option: %s\n", args[i])
+			os.Exit(1)
+		}
+		i++
+	}
+
+	if url == "" {
+		url = fmt.Sprintf("http://host.docker.internal:%d", daemonPort())
+	}
+	if len(clients) == 0 {
+		client, err := findNotifyClient()
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		clients = []string{client}
+	}
+
+	archs, err := generateDevcontainerFeature(dir, url, clients)
+	if err != nil {
+		fmt.Printf("❌ Failed to generate dev container feature: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Wrote the CmdBell dev container feature to %s for %s\n", dir, strings.Join(archs, ", "))
+	fmt.Println("Add it to devcontainer.json, next to the feature directory:")
+	fmt.Println()
+	fmt.Println(featureSnippet(dir))
+	fmt.Println()
+	fmt.Println("💡 host-gateway makes host.docker.internal resolve on Linux engines; Docker Desktop provides it already")
+}
+
+// generateDevcontainerFeature writes the feature and returns the architectures it bundles a client for
+func generateDevcontainerFeature(dir, url string, clients []string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	var archs []string
+	for _, client := range clients {
+		arch, err := clientArch(client)
+		if err != nil {
+			return nil, err
+		}
+		if err := copyExecutable(client, filepath.Join(dir, "cmdbell-notify-"+arch)); err != nil {
+			return nil, err
+		}
+		archs = append(archs, arch)
+	}
+
+	feature := devcontainerFeature{
+		ID:          "cmdbell",
+		Version:     "1.0.0",
+		Name:        "CmdBell",
+		Description: "Notifies the host's CmdBell daemon when long-running shell commands finish",
+		Options: map[string]featureOption{
+			"url": {
+				Type:        "string",
+				Default:     url,
+				Description: "CmdBell daemon URL as seen from inside the container",
+			},
+			"token": {
+				Type:        "string",
+				Default:     "",
+				Description: "API token with the notify scope; prefer CMDBELL_TOKEN in remoteEnv over committing one",
+			},
+		},
+		InstallsAfter: []string{"ghcr.io/devcontainers/features/common-utils"},
+	}
+	data, err := json.MarshalIndent(feature, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		data string
+		mode os.FileMode
+	}{
+		{"devcontainer-feature.json", string(data) + "\n", 0644},
+		{"install.sh", featureInstallScript(), 0755},
+		{"hook.sh", containerHookFunctions(), 0644},
+	}
+	for _, file := range files {
+		if err := writeFileAtomic(filepath.Join(dir, file.name), []byte(file.data), file.mode); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", file.name, err)
+		}
+	}
+	return archs, nil
+}
+
+// clientArch names the architecture a Linux cmdbell-notify build runs on
+func clientArch(path string) (string, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a Linux executable, pass --client with a GOOS=linux build: %v", path, err)
+	}
+	defer file.Close()
+
+	arch, ok := featureArchNames[file.Machine]
+	if !ok {
+		return "", fmt.Errorf("%s is built for unsupported architecture %s", path, file.Machine)
+	}
+	return arch, nil
+}
+
+func copyExecutable(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %v", src, err)
+	}
+	return out.Close()
+}
+
+// featureInstallScript runs as root during the image build, with the feature's options in URL and TOKEN
+func featureInstallScript() string {
+	return `#!/bin/sh
+# Installs the CmdBell notify-only client and shell hook; generated by 'cmdbell generate devcontainer-feature'
+set -e
+cd "$(dirname "$0")"
+
+case "$(uname -m)" in
+    x86_64|amd64) arch=amd64 ;;
+    aarch64|arm64) arch=arm64 ;;
+    armv7l|armv6l) arch=arm ;;
+    *) arch=$(uname -m) ;;
+esac
+if [ ! -f "cmdbell-notify-$arch" ]; then
+    echo "cmdbell: this feature has no client for $arch; regenerate it with --client <linux/$arch build>" >&2
+    exit 1
+fi
+
+mkdir -p ` + injectDir + `
+cp "cmdbell-notify-$arch" ` + injectClientPath + `
+chmod 755 ` + injectClientPath + `
+{
+    echo "# CmdBell hook installed by the cmdbell dev container feature"
+    echo "export CMDBELL_URL=\"\${CMDBELL_URL:-$URL}\""
+    [ -z "$TOKEN" ] || echo "export CMDBELL_TOKEN='$TOKEN'"
+    echo
+    cat hook.sh
+} > ` + injectHookPath + `
+
+wired=$(` + injectWireScript() + `)
+if [ -z "$wired" ]; then
+    echo "cmdbell: no shell rc file found; source ` + injectHookPath + ` from the shell you use" >&2
+fi
+`
+}
+
+// featureSnippet is the devcontainer.json addition for a feature written to dir. Local features are
+// referenced relative to the .devcontainer folder; the token is taken from the host's environment.
+func featureSnippet(dir string) string {
+	ref := "./" + filepath.ToSlash(filepath.Base(dir))
+	return `"features": {
+    "` + ref + `": {}
+},
+"runArgs": ["--add-host=host.docker.internal:host-gateway"],
+"remoteEnv": {
+    "CMDBELL_TOKEN": "${localEnv:CMDBELL_TOKEN}"
+}`
+}
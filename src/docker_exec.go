@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flags that take a separate value, so the container/service name can be found after them
+var (
+	dockerExecValueFlags = map[string]bool{
+		"-e": true, "--env": true, "--env-file": true,
+		"-u": true, "--user": true,
+		"-w": true, "--workdir": true,
+		"--detach-keys": true,
+	}
+	composeGlobalValueFlags = map[string]bool{
+		"-f": true, "--file": true,
+		"-p": true, "--project-name": true,
+		"--profile": true, "--env-file": true,
+		"--project-directory": true, "--ansi": true, "--progress": true,
+	}
+	composeExecValueFlags = map[string]bool{
+		"-e": true, "--env": true,
+		"-u": true, "--user": true,
+		"-w": true, "--workdir": true,
+		"--index": true,
+	}
+)
+
+// execInvocation is a parsed `docker exec` or `docker compose exec` command line
+type execInvocation struct {
+	target   string   // container for docker exec, service for compose
+	command  []string // command run inside the container
+	detached bool
+}
+
+func handleDockerCommand() {
+	args := os.Args[2:]
+
+	switch {
+	case len(args) > 0 && args[0] == "exec":
+		runDockerExec(args, nil)
+	case len(args) > 0 && args[0] == "compose":
+		globals, rest, ok := splitComposeArgs(args[1:])
+		if !ok {
+			printDockerUsage()
+			os.Exit(1)
+		}
+		if rest[0] == "exec" {
+			runDockerExec(append(append([]string{"compose"}, globals...), rest...), globals)
+		} else {
+			runComposeCommand(args, globals)
+		}
+	default:
+		printDockerUsage()
+		os.Exit(1)
+	}
+}
+
+func printDockerUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  cmdbell docker exec [options] <container> <command> [args...]")
+	fmt.Println("  cmdbell docker compose [options] exec [options] <service> <command> [args...]")
+	fmt.Println("  cmdbell docker compose [options] build|pull|up ... - Run and notify when it finishes")
+}
+
+// splitComposeArgs separates compose's global flags from the subcommand and its arguments
+func splitComposeArgs(args []string) (globals, rest []string, ok bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return args[:i], args[i:], true
+		}
+		if composeGlobalValueFlags[arg] {
+			i++
+		}
+	}
+	return nil, nil, false
+}
+
+// parseExecArgs finds the target and inner command in the arguments following `exec`
+func parseExecArgs(args []string, valueFlags map[string]bool) (*execInvocation, error) {
+	invocation := &execInvocation{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("no command given for %s", arg)
+			}
+			invocation.target = arg
+			invocation.command = args[i+1:]
+			return invocation, nil
+		}
+
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue := strings.Cut(arg, "=")
+			if valueFlags[name] && !hasValue {
+				i++
+			} else if name == "--detach" {
+				detached, err := strconv.ParseBool(value)
+				invocation.detached = !hasValue || (err == nil && detached)
+			}
+			continue
+		}
+
+		// A cluster of short flags such as -dit; a flag taking a value ends it, with the value
+		// attached as in -uroot or in the next argument
+		for j := 1; j < len(arg); j++ {
+			flag := "-" + arg[j:j+1]
+			if valueFlags[flag] {
+				if j == len(arg)-1 {
+					i++
+				}
+				break
+			}
+			if flag == "-d" {
+				invocation.detached = true
+			}
+		}
+	}
+	return nil, fmt.Errorf("no container or service given")
+}
+
+// runDockerExec runs docker with args, times the exec directly and exits with its exit code.
+// composeGlobals is nil for plain `docker exec`.
+func runDockerExec(args []string, composeGlobals []string) {
+	isCompose := composeGlobals != nil
+	execArgs := args[1:]
+	valueFlags := dockerExecValueFlags
+	if isCompose {
+		execArgs = args[len(composeGlobals)+2:]
+		valueFlags = composeExecValueFlags
+	}
+
+	invocation, err := parseExecArgs(execArgs, valueFlags)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		printDockerUsage()
+		os.Exit(1)
+	}
+
+	// The daemon's docker monitor sees this exec too; the marker leaves the notification to us
+	var marker string
+	if !invocation.detached {
+		marker, err = markWrappedDocker(wrappedDocker{
+			Target:  invocation.target,
+			Compose: isCompose,
+			Command: strings.Join(invocation.command, " "),
+		})
+		if err != nil {
+			tracef("filtered", "failed to mark exec as wrapped: %v", err)
+		}
+	}
+
+	duration, exitCode := runDocker(args)
+	if marker != "" {
+		os.Remove(marker)
+	}
+
+	// Detached execs return immediately, so there is nothing to time
+	if !invocation.detached && shouldNotifyCommand(strings.Join(invocation.command, " "), duration) {
+		container, service := invocation.target, ""
+		if isCompose {
+			service = invocation.target
+			container = composeContainerName(composeGlobals, service)
+		}
+		sendExecNotification(strings.Join(invocation.command, " "), container, service, duration, exitCode)
+		waitForDeliveries()
+	}
+
+	os.Exit(exitCode)
+}
+
+// runComposeCommand runs a compose command such as build or pull and notifies when it finishes
+func runComposeCommand(args []string, globals []string) {
+	// The daemon's compose tracker would report an up as well; the marker leaves it to us
+	var marker string
+	if project := composeProjectName(globals); project != "" {
+		var err error
+		marker, err = markWrappedDocker(wrappedDocker{Project: project})
+		if err != nil {
+			tracef("filtered", "failed to mark compose project %s as wrapped: %v", project, err)
+		}
+	}
+
+	duration, exitCode := runDocker(args)
+	if marker != "" {
+		os.Remove(marker)
+	}
+
+	if shouldNotifyCommand("docker "+strings.Join(args, " "), duration) {
+		sendNotification("docker "+strings.Join(args, " "), duration, exitCode == 0)
+		waitForDeliveries()
+	}
+
+	os.Exit(exitCode)
+}
+
+// runDocker runs docker attached to the terminal and returns how long it took and its exit code
+func runDocker(args []string) (time.Duration, int) {
+	startTime := time.Now()
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	err := cmd.Run()
+	duration := time.Since(startTime)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return duration, exitErr.ExitCode()
+	} else if err != nil {
+		fmt.Printf("❌ Failed to run docker: %v\n", err)
+		os.Exit(1)
+	}
+	return duration, 0
+}
+
+// composeProjectName resolves the project a compose command acts on, empty when compose cannot tell
+func composeProjectName(globals []string) string {
+	args := append(append([]string{"compose"}, globals...), "config", "--format", "json")
+	output, err := exec.Command("docker", args...).Output()
+	if err != nil {
+		return ""
+	}
+
+	var config struct {
+		Name string `json:"name"`
+	}
+	if json.Unmarshal(output, &config) != nil {
+		return ""
+	}
+	return config.Name
+}
+
+// composeContainerName resolves the container backing a compose service, falling back to the service name
+func composeContainerName(globals []string, service string) string {
+	args := append(append([]string{"compose"}, globals...), "ps", "--format", "{{.Name}}", service)
+	output, err := exec.Command("docker", args...).Output()
+	if err != nil {
+		return service
+	}
+
+	name, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	if name == "" {
+		return service
+	}
+	return name
+}
@@ -8,15 +8,36 @@ import (
 	"log"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 )
 
 type DockerEvent struct {
-	Type   string           `json:"Type"`
-	Action string           `json:"Action"`
-	ID     string           `json:"id"`
-	Actor  DockerEventActor `json:"Actor"`
-	Time   int64            `json:"time"`
+	Type     string           `json:"Type"`
+	Action   string           `json:"Action"`
+	ID       string           `json:"id"`
+	Actor    DockerEventActor `json:"Actor"`
+	Time     int64            `json:"time"`
+	TimeNano int64            `json:"timeNano"`
+}
+
+// Timestamp is when Docker emitted the event, which can be well before it reaches the monitor
+func (e DockerEvent) Timestamp() time.Time {
+	if e.TimeNano != 0 {
+		return time.Unix(0, e.TimeNano)
+	}
+	if e.Time != 0 {
+		return time.Unix(e.Time, 0)
+	}
+	return time.Now()
+}
+
+// actionDetail returns the part of an action after the colon (e.g. "exec_create: sleep 17" -> "sleep 17")
+func (e DockerEvent) actionDetail() string {
+	if colonIndex := strings.Index(e.Action, ": "); colonIndex != -1 {
+		return e.Action[colonIndex+2:]
+	}
+	return "unknown"
 }
 
 type DockerEventActor struct {
@@ -29,15 +50,48 @@ type ContainerExecInfo struct {
 	ContainerName string
 	Command       string
 	StartTime     time.Time
+	SeenAt        time.Time // when the monitor started tracking the exec
+	User          string    // in system mode, who the exec belongs to
+	Wrapped       bool      // timed by 'cmdbell docker exec', which notifies itself
 }
 
 type DockerMonitor struct {
-	execMap map[string]*ContainerExecInfo
-	ctx     context.Context
-	cancel  context.CancelFunc
+	mu         sync.Mutex
+	execMap    map[string]*ContainerExecInfo
+	execMaxAge time.Duration
+	compose    *ComposeTracker
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	// The docker events stream is supervised: it is restarted when it exits, or when it has been
+	// quiet for staleAfter while Docker reports events it missed, replaying from the checkpoint
+	staleAfter   time.Duration
+	checkpoint   time.Time // Docker timestamp of the last event handled
+	lastActivity time.Time // when the stream last delivered an event or was found up to date
+	streamDone   chan struct{}
+	streamCancel context.CancelFunc
+	restarts     int
+	handled      time.Time // Docker timestamp of the last event handled, saved in the daemon state
+	resumeFrom   time.Time // the previous daemon's handled timestamp, replayed from on start
+
+	// events decouples the stream from handling, so a burst of events never stalls docker events
+	events *eventBus[DockerEvent]
 }
 
-func NewDockerMonitor() (*DockerMonitor, error) {
+func NewDockerMonitor(config *Config) (*DockerMonitor, error) {
+	maxAge, err := parsePositiveDuration(config.Docker.ExecMaxAge, 24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker.exec_max_age: %v", err)
+	}
+	composeTimeout, err := parsePositiveDuration(config.Docker.ComposeTimeout, 10*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker.compose_timeout: %v", err)
+	}
+	staleAfter, err := parsePositiveDuration(config.Docker.StaleAfter, 10*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker.stale_after: %v", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Check if Docker is available
@@ -48,25 +102,66 @@ func NewDockerMonitor() (*DockerMonitor, error) {
 	}
 
 	return &DockerMonitor{
-		execMap: make(map[string]*ContainerExecInfo),
-		ctx:     ctx,
-		cancel:  cancel,
+		execMap:    make(map[string]*ContainerExecInfo),
+		execMaxAge: maxAge,
+		compose:    NewComposeTracker(composeTimeout),
+		ctx:        ctx,
+		cancel:     cancel,
+		staleAfter: staleAfter,
 	}, nil
 }
 
 func (dm *DockerMonitor) Start() error {
-	cmd := exec.CommandContext(dm.ctx, "docker", "events", "--format", "{{json .}}", "--filter", "type=container")
+	now := time.Now()
+	dm.checkpoint, dm.lastActivity = now, now
+	// Replay what happened while the previous daemon was down, so its execs still notify
+	var replay time.Time
+	if !dm.resumeFrom.IsZero() && now.Sub(dm.resumeFrom) < dm.execMaxAge {
+		replay = dm.resumeFrom
+		dm.checkpoint, dm.handled = replay, replay
+	}
+	dm.events = newEventBus("Docker event", dm.handleEvent)
+	if err := dm.startStream(replay); err != nil {
+		dm.events.Close(0)
+		return err
+	}
+
+	go dm.supervise()
+	go dm.collectStaleExecs()
+	go dm.compose.Run(dm.ctx)
+
+	fmt.Println("🐳 Docker container monitoring started...")
+	return nil
+}
+
+// startStream runs docker events, replaying from since when it is set. Replayed events the
+// monitor already handled are skipped by their timestamp.
+func (dm *DockerMonitor) startStream(since time.Time) error {
+	args := []string{"events", "--format", "{{json .}}", "--filter", "type=container"}
+	if !since.IsZero() {
+		args = append(args, "--since", dockerTimestamp(since))
+	}
+	ctx, cancel := context.WithCancel(dm.ctx)
+	cmd := exec.CommandContext(ctx, "docker", args...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
+		cancel()
 		return fmt.Errorf("failed to create stdout pipe: %v", err)
 	}
 
 	if err := cmd.Start(); err != nil {
+		cancel()
 		return fmt.Errorf("failed to start docker events: %v", err)
 	}
 
+	done := make(chan struct{})
+	dm.mu.Lock()
+	dm.streamDone, dm.streamCancel = done, cancel
+	dm.mu.Unlock()
+
 	go func() {
+		defer close(done)
 		defer cmd.Wait()
 		scanner := bufio.NewScanner(stdout)
 		for scanner.Scan() {
@@ -76,15 +171,227 @@ func (dm *DockerMonitor) Start() error {
 				log.Printf("Failed to parse Docker event: %v", err)
 				continue
 			}
-			dm.handleEvent(event)
+			if !dm.advanceCheckpoint(event) {
+				continue
+			}
+			dm.events.Publish(event)
 		}
 	}()
-
-	fmt.Println("🐳 Docker container monitoring started...")
 	return nil
 }
 
+// advanceCheckpoint records an event as handled, reporting false for one already handled
+func (dm *DockerMonitor) advanceCheckpoint(event DockerEvent) bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	timestamp := event.Timestamp()
+	if !timestamp.After(dm.checkpoint) {
+		return false
+	}
+	dm.checkpoint = timestamp
+	dm.lastActivity = time.Now()
+	return true
+}
+
+// supervise restarts the event stream when it exits, e.g. because dockerd restarted, or when it
+// has gone quiet while Docker has events for it
+func (dm *DockerMonitor) supervise() {
+	ticker := time.NewTicker(min(dm.staleAfter/2, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		dm.mu.Lock()
+		done, quietFor := dm.streamDone, time.Since(dm.lastActivity)
+		dm.mu.Unlock()
+
+		select {
+		case <-done:
+			dm.restartStream("docker events exited")
+			continue
+		default:
+		}
+
+		if quietFor < dm.staleAfter {
+			continue
+		}
+		missed, err := dm.missedEvents()
+		if err != nil {
+			log.Printf("⚠️  Could not check the Docker event stream: %v", err)
+			continue
+		}
+		if missed > 0 {
+			dm.restartStream(fmt.Sprintf("no events for %s while Docker reported %d", formatDuration(quietFor), missed))
+			continue
+		}
+
+		// Docker was simply quiet
+		dm.mu.Lock()
+		dm.lastActivity = time.Now()
+		dm.mu.Unlock()
+	}
+}
+
+// missedEvents asks Docker for container events since the checkpoint that the stream has not delivered
+func (dm *DockerMonitor) missedEvents() (int, error) {
+	dm.mu.Lock()
+	checkpoint := dm.checkpoint
+	dm.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(dm.ctx, 30*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "docker", "events", "--format", "{{json .}}", "--filter", "type=container",
+		"--since", dockerTimestamp(checkpoint), "--until", dockerTimestamp(time.Now())).Output()
+	if err != nil {
+		return 0, err
+	}
+
+	// Events the stream delivered while this ran have moved the checkpoint past them
+	dm.mu.Lock()
+	checkpoint = dm.checkpoint
+	dm.mu.Unlock()
+
+	missed := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		var event DockerEvent
+		if json.Unmarshal([]byte(line), &event) == nil && event.Timestamp().After(checkpoint) {
+			missed++
+		}
+	}
+	return missed, nil
+}
+
+// restartStream replaces the event stream with one replaying from the checkpoint, so execs that
+// finished meanwhile still notify
+func (dm *DockerMonitor) restartStream(reason string) {
+	dm.mu.Lock()
+	if dm.streamCancel != nil {
+		dm.streamCancel()
+	}
+	checkpoint := dm.checkpoint
+	dm.mu.Unlock()
+
+	if err := dm.startStream(checkpoint); err != nil {
+		log.Printf("⚠️  Docker monitor is down (%s) and could not restart: %v", reason, err)
+		return
+	}
+
+	dm.mu.Lock()
+	dm.restarts++
+	dm.lastActivity = time.Now()
+	dm.mu.Unlock()
+	log.Printf("🔄 Restarted Docker monitor (%s), replaying events since %s", reason, checkpoint.Format(time.RFC3339))
+}
+
+// snapshot returns the tracked execs and the timestamp of the last event handled
+func (dm *DockerMonitor) snapshot() (map[string]*ContainerExecInfo, time.Time) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	execs := make(map[string]*ContainerExecInfo, len(dm.execMap))
+	for execID, info := range dm.execMap {
+		copied := *info
+		execs[execID] = &copied
+	}
+	return execs, dm.handled
+}
+
+// restore tracks the execs a previous daemon was waiting on and, once started, replays the
+// events since it last handled one
+func (dm *DockerMonitor) restore(execs map[string]*ContainerExecInfo, handled time.Time) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for execID, info := range execs {
+		dm.execMap[execID] = info
+	}
+	dm.resumeFrom = handled
+}
+
+// Restarts returns how many times the supervisor has restarted the event stream
+func (dm *DockerMonitor) Restarts() int {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	return dm.restarts
+}
+
+// addQueueMetrics reports the event queue between the stream and its handling
+func (dm *DockerMonitor) addQueueMetrics(metrics map[string]int) {
+	dm.events.addMetrics(metrics, "docker_events")
+}
+
+// dockerTimestamp formats t for docker events --since and --until
+func dockerTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// collectStaleExecs drops execs that never reported exec_die, e.g. because their container was killed
+func (dm *DockerMonitor) collectStaleExecs() {
+	interval := min(dm.execMaxAge/4, time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case <-ticker.C:
+			dm.removeStaleExecs(time.Now().Add(-dm.execMaxAge))
+		}
+	}
+}
+
+func (dm *DockerMonitor) removeStaleExecs(cutoff time.Time) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for execID, info := range dm.execMap {
+		if info.SeenAt.Before(cutoff) {
+			log.Printf("🧹 Lost track of exec %s in container %s (%s), tracked since %s",
+				shortID(execID), info.ContainerName, info.Command, info.SeenAt.Format(time.RFC3339))
+			delete(dm.execMap, execID)
+		}
+	}
+}
+
+// TrackedExecs returns how many execs are waiting for their exec_die event
+func (dm *DockerMonitor) TrackedExecs() int {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	return len(dm.execMap)
+}
+
+// RunningExecs lists the execs that have started and not yet reported exec_die
+func (dm *DockerMonitor) RunningExecs() []RunningJob {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	var jobs []RunningJob
+	for _, info := range dm.execMap {
+		if info.StartTime.IsZero() {
+			continue
+		}
+		jobs = append(jobs, RunningJob{Source: "container", Name: info.Command, Detail: info.ContainerName, Started: info.StartTime})
+	}
+	return jobs
+}
+
 func (dm *DockerMonitor) handleEvent(event DockerEvent) {
+	tracef("received", "docker %s %s for %s", event.Type, event.Action, shortID(event.ID))
+	dm.compose.HandleEvent(event)
+
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if timestamp := event.Timestamp(); timestamp.After(dm.handled) {
+		dm.handled = timestamp
+	}
 	if strings.HasPrefix(event.Action, "exec_create:") {
 		dm.handleExecCreate(event)
 	} else if strings.HasPrefix(event.Action, "exec_start:") {
@@ -96,62 +403,121 @@ func (dm *DockerMonitor) handleEvent(event DockerEvent) {
 
 func (dm *DockerMonitor) handleExecCreate(event DockerEvent) {
 	execID := event.Actor.Attributes["execID"]
-	containerID := event.ID
-
-	// Get container name
-	cmd := exec.Command("docker", "inspect", "--format", "{{.Name}}", containerID)
-	output, err := cmd.Output()
+	info, err := newContainerExecInfo(event)
 	if err != nil {
-		log.Printf("Failed to get container name for %s: %v", containerID, err)
+		log.Printf("%v", err)
 		return
 	}
-	containerName := strings.TrimPrefix(strings.TrimSpace(string(output)), "/")
+	dm.execMap[execID] = info
 
-	// Extract command from action (e.g., "exec_create: sleep 17" -> "sleep 17")
-	command := "unknown"
-	if colonIndex := strings.Index(event.Action, ": "); colonIndex != -1 {
-		command = event.Action[colonIndex+2:]
-	}
-
-	dm.execMap[execID] = &ContainerExecInfo{
-		ContainerID:   containerID,
-		ContainerName: containerName,
-		Command:       command,
-	}
-
-	fmt.Printf("📋 Exec created in container %s (ID: %s)\n", containerName, execID[:12])
+	fmt.Printf("📋 Exec created in container %s (ID: %s)\n", info.ContainerName, shortID(execID))
 }
 
 func (dm *DockerMonitor) handleExecStart(event DockerEvent) {
 	execID := event.Actor.Attributes["execID"]
-	if info, exists := dm.execMap[execID]; exists {
-		info.StartTime = time.Now()
-		fmt.Printf("▶️  Command started in container %s\n", info.ContainerName)
+	info, exists := dm.execMap[execID]
+	if !exists {
+		// The create event was missed, e.g. the monitor started in between
+		var err error
+		info, err = newContainerExecInfo(event)
+		if err != nil {
+			log.Printf("%v", err)
+			return
+		}
+		dm.execMap[execID] = info
 	}
+
+	info.StartTime = event.Timestamp()
+	fmt.Printf("▶️  Command started in container %s\n", info.ContainerName)
+	activeDBusService.jobsChanged()
 }
 
 func (dm *DockerMonitor) handleExecDie(event DockerEvent) {
 	execID := event.Actor.Attributes["execID"]
-	if info, exists := dm.execMap[execID]; exists {
-		duration := time.Since(info.StartTime)
-		exitCode := event.Actor.Attributes["exitCode"]
-		success := exitCode == "0"
+	info, exists := dm.execMap[execID]
+	if !exists {
+		return
+	}
+	delete(dm.execMap, execID)
+	if !info.StartTime.IsZero() {
+		activeDBusService.jobsChanged()
+	}
+
+	exitCode := event.Actor.Attributes["exitCode"]
+	if info.StartTime.IsZero() {
+		// Started before the monitor was listening, so the duration is unknown
+		fmt.Printf("🏁 Command completed in container %s (duration unknown, exit: %s)\n", info.ContainerName, exitCode)
+		return
+	}
+
+	duration := event.Timestamp().Sub(info.StartTime)
+	success := exitCode == "0"
+
+	if info.Wrapped {
+		tracef("filtered", "exec %s in %s is timed by cmdbell docker exec, which notifies instead", shortID(execID), info.ContainerName)
+	} else if globalConfig != nil && duration >= globalConfig.General.MinDurationTime && globalConfig.General.EnableNotify {
+		tracef("filtered", "exec %s in %s passed after %s", shortID(execID), info.ContainerName, duration.Round(time.Millisecond))
+		dm.sendContainerNotification(info, duration, success)
+	} else {
+		tracef("filtered", "exec %s in %s dropped after %s", shortID(execID), info.ContainerName, duration.Round(time.Millisecond))
+	}
 
-		if globalConfig != nil && duration >= globalConfig.General.MinDurationTime && globalConfig.General.EnableNotify {
-			dm.sendContainerNotification(info, duration, success)
+	fmt.Printf("🏁 Command completed in container %s (duration: %s, exit: %s)\n",
+		info.ContainerName, formatDuration(duration), exitCode)
+}
+
+func newContainerExecInfo(event DockerEvent) (*ContainerExecInfo, error) {
+	containerID := event.ID
+
+	// Get container name, which the event carries as an attribute on current Docker versions
+	containerName := event.Actor.Attributes["name"]
+	if containerName == "" {
+		cmd := exec.Command("docker", "inspect", "--format", "{{.Name}}", containerID)
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get container name for %s: %v", containerID, err)
 		}
+		containerName = strings.TrimPrefix(strings.TrimSpace(string(output)), "/")
+	}
+
+	return &ContainerExecInfo{
+		ContainerID:   containerID,
+		ContainerName: containerName,
+		Command:       event.actionDetail(),
+		SeenAt:        time.Now(),
+		User:          execUser(event.Actor.Attributes),
+		Wrapped:       takeWrappedExec(event),
+	}, nil
+}
+
+// parsePositiveDuration parses value, returning fallback when it is empty
+func parsePositiveDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if parsed <= 0 {
+		return 0, fmt.Errorf("%s is not positive", value)
+	}
+	return parsed, nil
+}
 
-		delete(dm.execMap, execID)
-		fmt.Printf("🏁 Command completed in container %s (duration: %s, exit: %s)\n",
-			info.ContainerName, duration.Round(time.Second), exitCode)
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
 	}
+	return id
 }
 
 func (dm *DockerMonitor) sendContainerNotification(info *ContainerExecInfo, duration time.Duration, success bool) {
-	sendContainerNotification(info.Command, info.ContainerName, duration, success)
+	sendContainerNotification(info.Command, info.ContainerName, info.User, info.StartTime, duration, success)
 }
 
 func (dm *DockerMonitor) Stop() {
 	dm.cancel()
+	dm.events.Close(time.Second)
 	fmt.Println("🛑 Docker monitoring stopped")
 }
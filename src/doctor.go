@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// doctorReport collects check results printed by `cmdbell doctor`
+type doctorReport struct {
+	problems int
+}
+
+func (r *doctorReport) ok(format string, args ...interface{}) {
+	fmt.Printf("✅ "+format+"\n", args...)
+}
+
+func (r *doctorReport) warn(format string, args ...interface{}) {
+	fmt.Printf("⚠️  "+format+"\n", args...)
+	r.problems++
+}
+
+func handleDoctorCommand() {
+	if len(os.Args) > 2 && os.Args[2] == "--explain" {
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: cmdbell doctor --explain <command> [args...]")
+			os.Exit(1)
+		}
+		explainThreshold(strings.Join(os.Args[3:], " "))
+		return
+	}
+
+	report := &doctorReport{}
+
+	report.checkConfig()
+	report.checkHooks()
+	report.checkDaemon()
+	report.checkNotifier()
+	report.checkSystemMode()
+	report.checkHistoryEncryption()
+	report.checkSecrets()
+	report.checkActions()
+
+	if report.problems > 0 {
+		fmt.Printf("\n%d problem(s) found\n", report.problems)
+		os.Exit(1)
+	}
+	fmt.Println("\nEverything looks good")
+}
+
+func (r *doctorReport) checkConfig() {
+	configPath, err := getConfigPath()
+	if err != nil {
+		r.warn("Config: %v", err)
+		return
+	}
+
+	issues, err := ValidateConfigFile()
+	if err != nil {
+		r.warn("Config %s: %v", configPath, err)
+		return
+	}
+	if len(issues) == 0 {
+		r.ok("Config %s is valid", configPath)
+		return
+	}
+	for _, issue := range issues {
+		r.warn("Config %s", issue)
+	}
+}
+
+func (r *doctorReport) checkHooks() {
+	integration, err := NewShellIntegration()
+	if err != nil {
+		r.warn("Shell integration: %v", err)
+		return
+	}
+
+	installed := 0
+	for _, status := range integration.InstalledHooks() {
+		if !status.Installed {
+			continue
+		}
+		installed++
+
+		switch {
+		case status.Version < HookVersion:
+			r.warn("%s hook in %s is version %d, this binary ships version %d; run 'cmdbell upgrade-hooks'",
+				status.Shell, status.Path, status.Version, HookVersion)
+		case status.Version > HookVersion:
+			r.warn("%s hook in %s is version %d, newer than this binary (%d)",
+				status.Shell, status.Path, status.Version, HookVersion)
+		case !status.Current:
+			r.warn("%s hook in %s does not match the current config; run 'cmdbell upgrade-hooks'",
+				status.Shell, status.Path)
+		default:
+			r.ok("%s hook is up to date (version %d)", status.Shell, status.Version)
+		}
+	}
+
+	if installed == 0 {
+		r.warn("No shell integration installed; run 'cmdbell --install'")
+	}
+}
+
+func (r *doctorReport) checkDaemon() {
+	daemon := NewDaemon()
+	if daemon.IsRunning() {
+		r.ok("Daemon is running (PID: %d)", daemon.GetPID())
+	} else {
+		r.warn("Daemon is not running; container notifications need 'cmdbell --daemon start'")
+	}
+
+	if globalConfig != nil && globalConfig.Docker.Monitor {
+		if err := exec.Command("docker", "version").Run(); err != nil {
+			r.warn("Docker monitoring is enabled but docker is not available")
+		} else {
+			r.ok("Docker is available")
+		}
+	}
+}
+
+func (r *doctorReport) checkNotifier() {
+	var tool string
+	switch runtime.GOOS {
+	case "darwin":
+		tool = "osascript"
+	case "windows":
+		tool = "powershell"
+	case "linux":
+		if !guiSession() {
+			r.warn("No GUI session detected; desktop notifications fall back to console output")
+			return
+		}
+		if sandboxed() && portalAvailable() {
+			r.ok("Desktop notifications via xdg-desktop-portal (sandboxed terminal)")
+			return
+		}
+		for _, candidate := range []string{"notify-send", "kdialog", "zenity"} {
+			if _, err := exec.LookPath(candidate); err == nil {
+				tool = candidate
+				break
+			}
+		}
+		if tool == "" && portalAvailable() {
+			r.ok("Desktop notifications via xdg-desktop-portal")
+			return
+		}
+		if tool == "" {
+			r.warn("No notification tool found; install notify-send (libnotify)")
+			return
+		}
+	default:
+		r.warn("Desktop notifications are not supported on %s", runtime.GOOS)
+		return
+	}
+
+	if _, err := exec.LookPath(tool); err != nil {
+		r.warn("Notification tool %s not found", tool)
+		return
+	}
+	r.ok("Desktop notifications via %s", tool)
+}
+
+// checkSystemMode verifies a shared-machine daemon can reach the sessions of the users it routes to
+func (r *doctorReport) checkSystemMode() {
+	if !systemMode() {
+		return
+	}
+
+	if os.Geteuid() != 0 {
+		r.warn("System mode needs root to notify other users' desktop sessions")
+	} else if _, err := exec.LookPath("runuser"); err != nil {
+		r.warn("System mode needs runuser (util-linux) for per-user desktop notifications")
+	}
+
+	var users []string
+	for _, token := range globalConfig.HTTP.Tokens {
+		if token.User != "" && !containsString(users, token.User) {
+			users = append(users, token.User)
+		}
+	}
+	for _, channel := range globalConfig.Channels {
+		if channel.User != "" && !containsString(users, channel.User) {
+			users = append(users, channel.User)
+		}
+	}
+
+	for _, name := range users {
+		if _, err := userSessionBus(name); err != nil {
+			r.warn("User %s: %v; desktop notifications for them will fail", name, err)
+		} else {
+			r.ok("User %s has a session bus", name)
+		}
+	}
+}
+
+// checkHistoryEncryption verifies the history key is available and opens what is stored
+func (r *doctorReport) checkHistoryEncryption() {
+	if globalConfig == nil || !globalConfig.History.Enabled || globalConfig.History.Encryption == "off" {
+		return
+	}
+
+	store := getHistoryStore()
+	if store == nil {
+		r.warn("History encryption (%s): the key is not available, history is not recorded", globalConfig.History.Encryption)
+		return
+	}
+	if _, err := store.Query(HistoryFilter{Limit: 1}); err != nil {
+		r.warn("History encryption (%s): %v", globalConfig.History.Encryption, err)
+		return
+	}
+	r.ok("History is encrypted at rest (%s)", globalConfig.History.Encryption)
+}
+
+// checkActions warns when configured action buttons cannot reach CmdBell
+func (r *doctorReport) checkActions() {
+	if globalConfig == nil || len(globalConfig.Actions) == 0 {
+		return
+	}
+	if !globalConfig.History.Enabled {
+		r.warn("Actions find their notification in history, which is disabled (history.enabled: false)")
+	}
+	if runtime.GOOS == "windows" && !urlSchemeRegistered() {
+		r.warn("Action buttons need cmdbell:// links, run 'cmdbell url-scheme install'")
+	} else if urlSchemeRegistered() {
+		r.ok("cmdbell:// links open with CmdBell")
+	}
+}
+
+// checkSecrets verifies every keychain: reference in the config can be read
+func (r *doctorReport) checkSecrets() {
+	if globalConfig == nil {
+		return
+	}
+
+	values := []string{globalConfig.History.Sync.Password, globalConfig.History.Sync.Token}
+	for _, channel := range globalConfig.Channels {
+		values = append(values, channel.URL, channel.Token, channel.EncryptionKey, channel.SlackSigningSecret)
+		for _, value := range channel.Headers {
+			values = append(values, value)
+		}
+	}
+	for _, target := range globalConfig.Hub.Poll {
+		values = append(values, target.Token)
+	}
+
+	for _, value := range values {
+		if !strings.HasPrefix(value, secretRefPrefix) {
+			continue
+		}
+		if _, err := resolveSecret(value); err != nil {
+			r.warn("Keychain: %v", err)
+		} else {
+			r.ok("Keychain secret %s is readable", strings.TrimPrefix(value, secretRefPrefix))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// durationUnits are the hour, minute and second labels of one locale
+type durationUnits struct {
+	hour, minute, second string
+	separator            string // between number and unit
+	joiner               string // between components
+}
+
+var durationLocales = map[string]durationUnits{
+	"en": {"h", "m", "s", "", " "},
+	"de": {"Std.", "Min.", "Sek.", " ", " "},
+	"fr": {"h", "min", "s", " ", " "},
+	"es": {"h", "min", "s", " ", " "},
+	"pt": {"h", "min", "s", " ", " "},
+	"ja": {"時間", "分", "秒", "", ""},
+	"zh": {"小时", "分钟", "秒", "", ""},
+	"ko": {"시간", "분", "초", "", " "},
+}
+
+// formatDuration renders d for people: seconds under a minute, whole minutes above,
+// e.g. "42s", "12m" or "1h 23m", with units from notification.locale
+func formatDuration(d time.Duration) string {
+	units := durationLocales[durationLocale()]
+
+	if d < time.Minute {
+		seconds := int(d.Round(time.Second) / time.Second)
+		if seconds == 60 {
+			return units.part(1, units.minute)
+		}
+		return units.part(seconds, units.second)
+	}
+
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	hours, minutes := minutes/60, minutes%60
+	switch {
+	case hours == 0:
+		return units.part(minutes, units.minute)
+	case minutes == 0:
+		return units.part(hours, units.hour)
+	default:
+		return units.part(hours, units.hour) + units.joiner + units.part(minutes, units.minute)
+	}
+}
+
+func (u durationUnits) part(value int, unit string) string {
+	return fmt.Sprintf("%d%s%s", value, u.separator, unit)
+}
+
+// durationLocale resolves notification.locale, where "auto" follows LC_ALL, LC_MESSAGES or LANG
+func durationLocale() string {
+	locale := "auto"
+	if globalConfig != nil && globalConfig.Notification.Locale != "" {
+		locale = globalConfig.Notification.Locale
+	}
+	if locale == "auto" {
+		locale = ""
+		for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if value := os.Getenv(name); value != "" {
+				locale = value
+				break
+			}
+		}
+	}
+
+	// "de_DE.UTF-8" and "pt-BR" both select the language table
+	language := strings.ToLower(locale)
+	if i := strings.IndexAny(language, "_-."); i >= 0 {
+		language = language[:i]
+	}
+	if _, ok := durationLocales[language]; ok {
+		return language
+	}
+	return "en"
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// TaskRequest reports a task finished by an editor task runner, such as a VS Code task or JetBrains run configuration
+type TaskRequest struct {
+	Task            string  `json:"task"`
+	Source          string  `json:"source"`              // editor that ran the task, e.g. "vscode" or "jetbrains"
+	Workspace       string  `json:"workspace,omitempty"` // project or folder the task belongs to
+	Duration        string  `json:"duration,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Success         bool    `json:"success"`
+	ExitCode        *int    `json:"exit_code,omitempty"` // overrides success when set
+	URL             string  `json:"url,omitempty"`       // jump-back link, e.g. vscode://file/<path>
+	CorrelationID   string  `json:"correlation_id,omitempty"`
+}
+
+// editorNames are display names for the editor sources CmdBell ships sample payloads for
+var editorNames = map[string]string{
+	"vscode":    "VS Code",
+	"cursor":    "Cursor",
+	"jetbrains": "JetBrains",
+	"intellij":  "IntelliJ IDEA",
+	"goland":    "GoLand",
+	"pycharm":   "PyCharm",
+	"zed":       "Zed",
+	"nvim":      "Neovim",
+}
+
+func editorName(source string) string {
+	if name, ok := editorNames[source]; ok {
+		return name
+	}
+	return source
+}
+
+// handleTask accepts task results from editor integrations so IDE and terminal builds share one notification stream
+func (hs *HTTPServer) handleTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req TaskRequest
+	if err := decodePayload(r.Body, "task", &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if req.Task == "" {
+		http.Error(w, "Missing required field: task", http.StatusBadRequest)
+		return
+	}
+	if req.Source == "" {
+		http.Error(w, "Missing required field: source", http.StatusBadRequest)
+		return
+	}
+
+	duration := time.Duration(req.DurationSeconds * float64(time.Second))
+	if req.Duration != "" {
+		var err error
+		duration, err = time.ParseDuration(req.Duration)
+		if err != nil {
+			http.Error(w, "Invalid duration format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	success := req.Success
+	if req.ExitCode != nil {
+		success = *req.ExitCode == 0
+	}
+	correlationID, err := requestCorrelationID(r, req.CorrelationID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("📨 Received %s task: task='%s', workspace='%s', duration=%s, success=%t",
+		req.Source, req.Task, req.Workspace, duration, success)
+	n := taskNotification(editorName(req.Source), req.Task, req.Workspace, req.URL, duration, success)
+	n.ID = newEventID()
+	n.CorrelationID = correlationID
+	deliverNotification(n)
+
+	w.Header().Set("Content-Type", "application/json")
+	response := withEventIDs(w, map[string]interface{}{
+		"status":  "success",
+		"message": "Notification sent",
+	}, n)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
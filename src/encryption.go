@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// EncryptedEnvelope wraps a channel payload sealed with AES-256-GCM under a shared key
+type EncryptedEnvelope struct {
+	Version    int    `json:"cmdbell_encrypted"`
+	Algorithm  string `json:"alg"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+const envelopeAlgorithm = "A256GCM"
+
+func parseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid encryption key: expected 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+func generateEncryptionKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func encryptPayload(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	return json.Marshal(EncryptedEnvelope{
+		Version:    1,
+		Algorithm:  envelopeAlgorithm,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+	})
+}
+
+func decryptPayload(key, data []byte) ([]byte, error) {
+	var envelope EncryptedEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %v", err)
+	}
+	if envelope.Version != 1 || envelope.Algorithm != envelopeAlgorithm {
+		return nil, fmt.Errorf("unsupported envelope version %d (%s)", envelope.Version, envelope.Algorithm)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %v", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %v", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length")
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: wrong key or tampered payload")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// handleDecryptCommand decrypts an envelope read from stdin on the receiving side
+func handleDecryptCommand() {
+	args := os.Args[2:]
+	key := os.Getenv("CMDBELL_ENCRYPTION_KEY")
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--new-key":
+			newKey, err := generateEncryptionKey()
+			if err != nil {
+				fmt.Printf("Failed to generate key: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(newKey)
+			return
+		case "--key":
+			if i+1 < len(args) {
+				i++
+				key = args[i]
+			}
+		}
+	}
+
+	if key == "" {
+		fmt.Println("Usage: cmdbell decrypt [--key <base64>] < payload.json")
+		fmt.Println("       cmdbell decrypt --new-key")
+		fmt.Println("The key can also be provided via CMDBELL_ENCRYPTION_KEY")
+		os.Exit(1)
+	}
+
+	rawKey, err := parseEncryptionKey(key)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Printf("Failed to read payload: %v\n", err)
+		os.Exit(1)
+	}
+
+	plaintext, err := decryptPayload(rawKey, data)
+	if err != nil {
+		fmt.Printf("Failed to decrypt payload: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(plaintext))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type endpointState struct {
+	target  EndpointTarget
+	checked bool
+	up      bool
+}
+
+// EndpointWatcher polls TCP ports and HTTP endpoints and notifies on availability transitions
+type EndpointWatcher struct {
+	targets  []*endpointState
+	interval time.Duration
+	timeout  time.Duration
+	client   *http.Client
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewEndpointWatcher(interval, timeout string, targets []EndpointTarget) (*EndpointWatcher, error) {
+	pollInterval, err := time.ParseDuration(interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoints interval: %v", err)
+	}
+	if pollInterval <= 0 {
+		return nil, fmt.Errorf("endpoints interval must be positive")
+	}
+
+	checkTimeout, err := time.ParseDuration(timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoints timeout: %v", err)
+	}
+
+	var states []*endpointState
+	for _, target := range targets {
+		if (target.Address == "") == (target.URL == "") {
+			return nil, fmt.Errorf("endpoint %q must set exactly one of address or url", target.Name)
+		}
+		if target.Name == "" {
+			target.Name = target.Address + target.URL
+		}
+		states = append(states, &endpointState{target: target})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &EndpointWatcher{
+		targets:  states,
+		interval: pollInterval,
+		timeout:  checkTimeout,
+		client:   &http.Client{Timeout: checkTimeout},
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+func (ew *EndpointWatcher) Start() {
+	for _, state := range ew.targets {
+		ew.wg.Add(1)
+		go ew.watch(state)
+	}
+
+	log.Printf("📡 Endpoint watcher started with %d target(s)", len(ew.targets))
+}
+
+func (ew *EndpointWatcher) Stop() {
+	ew.cancel()
+	ew.wg.Wait()
+	log.Println("🛑 Endpoint watcher stopped")
+}
+
+func (ew *EndpointWatcher) watch(state *endpointState) {
+	defer ew.wg.Done()
+
+	ticker := time.NewTicker(ew.interval)
+	defer ticker.Stop()
+
+	for {
+		ew.check(state)
+
+		select {
+		case <-ticker.C:
+		case <-ew.ctx.Done():
+			return
+		}
+	}
+}
+
+func (ew *EndpointWatcher) check(state *endpointState) {
+	up := ew.probe(state.target)
+	if ew.ctx.Err() != nil {
+		return
+	}
+
+	// The first probe only establishes the baseline
+	if !state.checked {
+		state.checked = true
+		state.up = up
+		log.Printf("📡 Endpoint '%s' initial state: up=%t", state.target.Name, up)
+		return
+	}
+
+	if up == state.up {
+		return
+	}
+	state.up = up
+
+	target := state.target.Address
+	if target == "" {
+		target = state.target.URL
+	}
+	log.Printf("📡 Endpoint '%s' (%s) changed state: up=%t", state.target.Name, target, up)
+
+	if globalConfig != nil && !globalConfig.General.EnableNotify {
+		return
+	}
+	sendEndpointNotification(state.target.Name, target, up)
+}
+
+func (ew *EndpointWatcher) probe(target EndpointTarget) bool {
+	if target.Address != "" {
+		dialer := net.Dialer{Timeout: ew.timeout}
+		conn, err := dialer.DialContext(ew.ctx, "tcp", target.Address)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	req, err := http.NewRequestWithContext(ew.ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := ew.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < 400
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultQueueSize is how many events each daemon queue holds when daemon.queue_size is unset
+	defaultQueueSize = 1024
+	// maxInFlightDeliveries bounds the desktop sends running at once; delivery waits for a free
+	// slot, so a flood backs up into the notification queue instead of goroutines. Channels are
+	// bounded by their own worker pools.
+	maxInFlightDeliveries = 64
+)
+
+// Overflow policies of daemon.overflow, applied when a queue is full
+const (
+	dropOldest = "drop-oldest" // make room by discarding the event that waited longest
+	dropNewest = "drop-newest" // discard the event being published
+)
+
+var deliverySlots = make(chan struct{}, maxInFlightDeliveries)
+
+// eventBus is a bounded queue between a producer that must never block, such as the docker
+// events stream or an HTTP handler, and the goroutine handling its events. When handling falls
+// behind and the queue is full, events are dropped by the overflow policy and counted.
+type eventBus[T any] struct {
+	name     string
+	capacity int
+	overflow string
+	handle   func(T)
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []T
+	closed  bool
+	dropped int
+	peak    int
+	done    chan struct{}
+}
+
+// newEventBus starts a bus whose events are handled one at a time by handle
+func newEventBus[T any](name string, handle func(T)) *eventBus[T] {
+	b := &eventBus[T]{
+		name:     name,
+		capacity: defaultQueueSize,
+		overflow: dropOldest,
+		handle:   handle,
+		done:     make(chan struct{}),
+	}
+	if globalConfig != nil {
+		if globalConfig.Daemon.QueueSize > 0 {
+			b.capacity = globalConfig.Daemon.QueueSize
+		}
+		if globalConfig.Daemon.Overflow == dropNewest {
+			b.overflow = dropNewest
+		}
+	}
+	b.cond = sync.NewCond(&b.mu)
+	go b.run()
+	return b
+}
+
+// Publish queues an event without blocking. It returns false once the bus is closed, when the
+// caller has to handle the event itself; events dropped on overflow count as published.
+func (b *eventBus[T]) Publish(event T) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return false
+	}
+	if len(b.queue) >= b.capacity {
+		b.dropped++
+		// Log the first drop and then every hundredth, a flood would flood the log too
+		if b.dropped%100 == 1 {
+			log.Printf("⚠️  %s queue is full (%d events), %s: %d dropped so far", b.name, b.capacity, b.overflow, b.dropped)
+		}
+		if b.overflow == dropNewest {
+			return true
+		}
+		var zero T
+		b.queue[0] = zero
+		b.queue = b.queue[1:]
+	}
+	b.queue = append(b.queue, event)
+	b.peak = max(b.peak, len(b.queue))
+	b.cond.Signal()
+	return true
+}
+
+func (b *eventBus[T]) run() {
+	defer close(b.done)
+	for {
+		b.mu.Lock()
+		for len(b.queue) == 0 && !b.closed {
+			b.cond.Wait()
+		}
+		if len(b.queue) == 0 {
+			b.mu.Unlock()
+			return
+		}
+		event := b.queue[0]
+		var zero T
+		b.queue[0] = zero
+		b.queue = b.queue[1:]
+		b.mu.Unlock()
+
+		b.handle(event)
+	}
+}
+
+// Close stops accepting events and waits up to timeout for the queued ones to be handled
+func (b *eventBus[T]) Close(timeout time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-time.After(timeout):
+		b.mu.Lock()
+		if len(b.queue) > 0 {
+			log.Printf("⚠️  %s queue closed with %d events unhandled", b.name, len(b.queue))
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Pending returns a copy of the events waiting to be handled
+func (b *eventBus[T]) Pending() []T {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]T(nil), b.queue...)
+}
+
+// Drain takes the events still waiting once the bus is closed, so they are never handled here
+func (b *eventBus[T]) Drain() []T {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		return nil
+	}
+	events := b.queue
+	b.queue = nil
+	return events
+}
+
+// addMetrics reports the bus in /status metrics as <prefix>_queued, _peak and _dropped
+func (b *eventBus[T]) addMetrics(metrics map[string]int, prefix string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	metrics[prefix+"_queued"] = len(b.queue)
+	metrics[prefix+"_peak"] = b.peak
+	metrics[prefix+"_dropped"] = b.dropped
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxQueuedEvents bounds the outbox; older events are dropped once hubs had a chance to poll them
+	maxQueuedEvents = 1000
+	maxPollWait     = 60 * time.Second
+	// minPollWait keeps a hub from polling in a tight loop when a daemon has nothing queued
+	minPollWait = time.Second
+)
+
+// QueuedEvent is an event waiting in the outbox for a hub to poll it
+type QueuedEvent struct {
+	Seq   int64          `json:"seq"`
+	Event ChannelPayload `json:"event"`
+}
+
+// PollResponse is returned by GET /poll; pass Cursor as `since` on the next poll
+type PollResponse struct {
+	Events []QueuedEvent `json:"events"`
+	Cursor int64         `json:"cursor"`
+}
+
+var outboxMu sync.Mutex
+
+func outboxPath() (string, error) {
+	return getDataPath("outbox.jsonl")
+}
+
+// queueChannel keeps events in the local outbox for a hub that cannot be reached directly
+type queueChannel struct {
+	name string
+}
+
+func (c *queueChannel) Name() string {
+	return c.name
+}
+
+func (c *queueChannel) Send(ctx context.Context, n *Notification) error {
+	return enqueueEvent(newChannelPayload(n))
+}
+
+func enqueueEvent(event ChannelPayload) error {
+	path, err := outboxPath()
+	if err != nil {
+		return err
+	}
+
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	seq, err := nextOutboxSeq()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(QueuedEvent{Seq: seq, Event: event})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+	if data, err = sealData(data); err != nil {
+		return fmt.Errorf("failed to encrypt event: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %v", err)
+	}
+	if _, err := fmt.Fprintf(file, "%s\n", data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write outbox: %v", err)
+	}
+	file.Close()
+
+	return trimOutbox(path)
+}
+
+// nextOutboxSeq returns the position of a new outbox event, one past the last one handed out, kept
+// in outbox.seq so positions only grow across processes and restarts whatever the clock does. It
+// starts from the clock, above the positions outboxes used before the counter existed. The caller
+// holds the outbox lock.
+func nextOutboxSeq() (int64, error) {
+	path, err := getDataPath("outbox.seq")
+	if err != nil {
+		return 0, err
+	}
+
+	seq := time.Now().UnixNano()
+	if data, err := os.ReadFile(path); err == nil {
+		last, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("outbox position %s is damaged: %v", path, err)
+		}
+		seq = last + 1
+	} else if !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to read outbox position: %v", err)
+	}
+
+	if err := writeFileAtomic(path, []byte(strconv.FormatInt(seq, 10)+"\n"), 0600); err != nil {
+		return 0, fmt.Errorf("failed to save outbox position: %v", err)
+	}
+	return seq, nil
+}
+
+// trimOutbox keeps only the newest maxQueuedEvents entries
+func trimOutbox(path string) error {
+	events, err := readOutbox(path)
+	if err != nil || len(events) <= maxQueuedEvents {
+		return err
+	}
+
+	var content strings.Builder
+	for _, event := range events[len(events)-maxQueuedEvents:] {
+		data, err := json.Marshal(event)
+		if err == nil {
+			data, err = sealData(data)
+		}
+		if err != nil {
+			return err
+		}
+		content.Write(data)
+		content.WriteByte('\n')
+	}
+	return writeFileAtomic(path, []byte(content.String()), 0600)
+}
+
+func readOutbox(path string) ([]QueuedEvent, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []QueuedEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event QueuedEvent
+		line, err := openData(scanner.Bytes())
+		if err != nil {
+			return events, err
+		}
+		if err := json.Unmarshal(line, &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// eventsSince returns queued events newer than since and the cursor to continue from
+func eventsSince(since int64) ([]QueuedEvent, int64, error) {
+	path, err := outboxPath()
+	if err != nil {
+		return nil, since, err
+	}
+
+	events, err := readOutbox(path)
+	if err != nil {
+		return nil, since, err
+	}
+
+	cursor := since
+	var newer []QueuedEvent
+	for _, event := range events {
+		if event.Seq > since {
+			newer = append(newer, event)
+			cursor = event.Seq
+		}
+	}
+	return newer, cursor, nil
+}
+
+// handlePoll long-polls the outbox. Without `since` it only returns the current cursor,
+// so a hub connecting for the first time does not replay the whole backlog.
+func (hs *HTTPServer) handlePoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	wait := 30 * time.Second
+	if value := query.Get("wait"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid wait duration", http.StatusBadRequest)
+			return
+		}
+		wait = min(parsed, maxPollWait)
+	}
+
+	var response PollResponse
+	if value := query.Get("since"); value == "" {
+		_, cursor, err := eventsSince(0)
+		if err != nil {
+			log.Printf("Failed to read outbox: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		response.Cursor = cursor
+	} else {
+		since, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid since cursor", http.StatusBadRequest)
+			return
+		}
+
+		// The server-wide write timeout is shorter than a long poll
+		http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 10*time.Second))
+
+		deadline := time.Now().Add(wait)
+		for {
+			events, cursor, err := eventsSince(since)
+			if err != nil {
+				log.Printf("Failed to read outbox: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			response = PollResponse{Events: events, Cursor: cursor}
+			if len(events) > 0 || !time.Now().Before(deadline) {
+				break
+			}
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+	}
+
+	if response.Events == nil {
+		response.Events = []QueuedEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode poll response: %v", err)
+	}
+}
+
+// HubPoller fetches queued events from remote daemons that the hub cannot receive pushes from
+type HubPoller struct {
+	targets []PollTarget
+	wait    time.Duration
+	clients map[string]*http.Client // by target name, each with the target's TLS settings
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewHubPoller(targets []PollTarget, wait string) (*HubPoller, error) {
+	waitDuration := 30 * time.Second
+	if wait != "" {
+		parsed, err := time.ParseDuration(wait)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hub.poll_wait %q: %v", wait, err)
+		}
+		if parsed < minPollWait {
+			return nil, fmt.Errorf("hub.poll_wait %q must be at least %s", wait, minPollWait)
+		}
+		waitDuration = min(parsed, maxPollWait)
+	}
+
+	clients := make(map[string]*http.Client, len(targets))
+	for i, target := range targets {
+		if target.URL == "" {
+			return nil, fmt.Errorf("hub.poll[%d] has no url", i)
+		}
+		if target.Name == "" {
+			targets[i].Name = target.URL
+		}
+		transport, err := tlsTransport(target.TLSCA, target.TLSCert, target.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("hub.poll[%d]: %v", i, err)
+		}
+		clients[targets[i].Name] = &http.Client{Transport: transport, Timeout: waitDuration + 15*time.Second}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HubPoller{
+		targets: targets,
+		wait:    waitDuration,
+		clients: clients,
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+func (hp *HubPoller) Start() {
+	for _, target := range hp.targets {
+		hp.wg.Add(1)
+		go hp.run(target)
+		log.Printf("📥 Polling %s for queued events", target.Name)
+	}
+}
+
+func (hp *HubPoller) Stop() {
+	hp.cancel()
+	hp.wg.Wait()
+	log.Println("🛑 Hub poller stopped")
+}
+
+func (hp *HubPoller) run(target PollTarget) {
+	defer hp.wg.Done()
+
+	cursor := ""
+	if saved, ok := loadPollCursors()[target.Name]; ok {
+		cursor = strconv.FormatInt(saved, 10)
+	}
+
+	backoff := time.Second
+	for hp.ctx.Err() == nil {
+		response, err := hp.poll(target, cursor)
+		if err != nil {
+			if hp.ctx.Err() != nil {
+				return
+			}
+			log.Printf("⚠️  Polling %s failed: %v (retrying in %s)", target.Name, err, backoff)
+			select {
+			case <-hp.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+
+		for _, queued := range response.Events {
+			event := queued.Event
+			if event.Host == "" {
+				event.Host = target.Name
+			}
+			log.Printf("📨 Polled event from %s: %s", event.Host, event.Message)
+			deliverNotification(event.toNotification())
+		}
+
+		next := strconv.FormatInt(response.Cursor, 10)
+		if next != cursor {
+			cursor = next
+			savePollCursor(target.Name, response.Cursor)
+		}
+	}
+}
+
+var pollCursorsMu sync.Mutex
+
+// loadPollCursors returns the last seen outbox position per poll target, so restarts neither replay nor skip events
+func loadPollCursors() map[string]int64 {
+	cursors := make(map[string]int64)
+	path, err := getDataPath("poll-cursors.json")
+	if err != nil {
+		return cursors
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &cursors)
+	}
+	return cursors
+}
+
+func savePollCursor(name string, cursor int64) {
+	pollCursorsMu.Lock()
+	defer pollCursorsMu.Unlock()
+
+	cursors := loadPollCursors()
+	cursors[name] = cursor
+
+	path, err := getDataPath("poll-cursors.json")
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(cursors, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		log.Printf("⚠️  Failed to save poll cursor: %v", err)
+	}
+}
+
+func (hp *HubPoller) poll(target PollTarget, cursor string) (*PollResponse, error) {
+	url := fmt.Sprintf("%s/poll?wait=%s", strings.TrimRight(target.URL, "/"), hp.wait)
+	if cursor != "" {
+		url += "&since=" + cursor
+	}
+
+	req, err := http.NewRequestWithContext(hp.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := resolveSecret(target.Token)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := hp.clients[target.Name].Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var response PollResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid poll response: %v", err)
+	}
+	return &response, nil
+}
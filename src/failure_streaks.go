@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// streakRetention is how long a streak is kept after the command last failed
+const streakRetention = 7 * 24 * time.Hour
+
+var streaksMu sync.Mutex
+
+// failureStreak counts consecutive failures of one command line
+type failureStreak struct {
+	Failures int       `json:"failures"`
+	Since    time.Time `json:"since"` // first failure of the streak
+	Last     time.Time `json:"last"`
+}
+
+// streakKey identifies the same command across runs: its command line, with the container it ran
+// in when there is one
+func streakKey(n *Notification) string {
+	key := sanitizeCommand(n.Command)
+	if n.ContainerName != "" {
+		key = n.ContainerName + ": " + key
+	}
+	return key
+}
+
+// streakThreshold returns how many consecutive failures of commandLine escalate its
+// notification, from the first matching streaks rule or streaks.count; 0 disables escalation
+func streakThreshold(commandLine string) int {
+	if globalConfig == nil {
+		return 0
+	}
+	for _, rule := range globalConfig.Streaks.Rules {
+		if matchCommandRule(rule.Commands, rule.Pattern, commandLine) != "" {
+			return rule.Count
+		}
+	}
+	return globalConfig.Streaks.Count
+}
+
+// recordStreak updates the streak of n's command in ~/.cmdbell/streaks.json, which wrapped
+// commands and the daemon share: a failure extends it, a success ends it. It returns the streak
+// including this run, 0 after a success.
+func recordStreak(n *Notification) (failureStreak, error) {
+	path, err := getDataPath("streaks.json")
+	if err != nil {
+		return failureStreak{}, err
+	}
+
+	streaksMu.Lock()
+	defer streaksMu.Unlock()
+
+	streaks := map[string]failureStreak{}
+	if data, err := os.ReadFile(path); err == nil {
+		if data, err = openData(data); err != nil {
+			return failureStreak{}, err
+		}
+		json.Unmarshal(data, &streaks)
+	}
+	for key, streak := range streaks {
+		if time.Since(streak.Last) > streakRetention {
+			delete(streaks, key)
+		}
+	}
+
+	key := streakKey(n)
+	streak := streaks[key]
+	if n.Success {
+		delete(streaks, key)
+		streak = failureStreak{}
+	} else {
+		if streak.Failures == 0 {
+			streak.Since = n.Time
+		}
+		streak.Failures++
+		streak.Last = n.Time
+		streaks[key] = streak
+	}
+
+	data, err := json.MarshalIndent(streaks, "", "  ")
+	if err == nil {
+		data, err = sealData(data)
+	}
+	if err != nil {
+		return streak, err
+	}
+	return streak, writeFileAtomic(path, data, 0600)
+}
+
+// escalateStreak tracks failure streaks of finished commands and turns the notification of a
+// command that keeps failing into an escalated one, such as "'npm test' failed 3 consecutive
+// times". Only runs that notify count, and events forwarded from other hosts were escalated there.
+func escalateStreak(n *Notification) {
+	if n.Command == "" || n.Running || n.Host != "" {
+		return
+	}
+	switch n.Source {
+	case "command", "container", "cron":
+	default:
+		return
+	}
+
+	threshold := streakThreshold(n.Command)
+	if threshold <= 0 {
+		return
+	}
+	streak, err := recordStreak(n)
+	if err != nil {
+		errorf("Failed to record failure streak: %v\n", err)
+		return
+	}
+	if streak.Failures < threshold {
+		return
+	}
+
+	// Shell hooks report the host's own commands with its hostname as container name
+	where := ""
+	if hostname, _ := os.Hostname(); n.ContainerName != "" && n.ContainerName != hostname {
+		where = fmt.Sprintf(" in '%s'", n.ContainerName)
+	}
+	n.Title += " - Failing repeatedly"
+	n.Message = fmt.Sprintf("'%s'%s failed %d consecutive times since %s, the last after %s",
+		displayCommand(n.Command), where, streak.Failures, streak.Since.Local().Format("15:04"), formatDuration(n.Duration))
+	n.Icon = "🚨"
+	n.Escalated = true
+	tracef("routed", "escalated, %q failed %d times in a row (streak threshold %d)", sanitizeCommand(n.Command), streak.Failures, threshold)
+}
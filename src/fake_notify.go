@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// fakeNotifyEnv names the file or FIFO that takes the place of desktop notifications. The
+// --fake-notify flag sets it, so a daemon started from the same command and the commands run
+// under CmdBell write there too.
+const fakeNotifyEnv = "CMDBELL_FAKE_NOTIFY"
+
+const (
+	defaultExpectTimeout = 5 * time.Second
+	expectPollInterval   = 100 * time.Millisecond
+)
+
+func fakeNotifyPath() string {
+	return os.Getenv(fakeNotifyEnv)
+}
+
+// setFakeNotify points the fake sink at path, made absolute so processes running elsewhere agree
+func setFakeNotify(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	os.Setenv(fakeNotifyEnv, path)
+}
+
+// writeFakeNotification appends the notification the desktop would have shown to the fake
+// sink, as one JSON line in the webhook payload format. A FIFO is written without waiting for
+// a reader, so a test that is not listening fails the delivery instead of hanging it.
+func writeFakeNotification(n *Notification, title, message string) error {
+	payload := newChannelPayload(n)
+	payload.Title = title
+	payload.Message = message
+	line, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	path := fakeNotifyPath()
+	flags := os.O_WRONLY | os.O_APPEND | os.O_CREATE
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		flags |= syscall.O_NONBLOCK
+	}
+	file, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return fmt.Errorf("fake notification sink: %v", err)
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// expectation is what `cmdbell expect` waits for in the fake sink
+type expectation struct {
+	title, message, command *regexp.Regexp
+	source                  string
+	success                 *bool
+	count                   int // exact number of matches; -1 for at least one
+}
+
+func (e expectation) matches(p ChannelPayload) bool {
+	return (e.title == nil || e.title.MatchString(p.Title)) &&
+		(e.message == nil || e.message.MatchString(p.Message)) &&
+		(e.command == nil || e.command.MatchString(p.Command)) &&
+		(e.source == "" || e.source == p.Source) &&
+		(e.success == nil || *e.success == p.Success)
+}
+
+// handleExpectCommand asserts that the fake sink received matching notifications, for
+// integration tests and scripts: it exits 0 once they arrive and 1 when the timeout passes first
+func handleExpectCommand() {
+	path := fakeNotifyPath()
+	timeout := defaultExpectTimeout
+	expect := expectation{count: -1}
+	jsonOutput := false
+	clearSink := false
+
+	args := os.Args[2:]
+	pattern := func(i int) *regexp.Regexp {
+		re, err := regexp.Compile(args[i])
+		if err != nil {
+			errorf("❌ Invalid pattern %q: %v\n", args[i], err)
+			os.Exit(1)
+		}
+		return re
+	}
+	for i := 0; i < len(args); i++ {
+		if i+1 < len(args) {
+			switch args[i] {
+			case "--file":
+				i++
+				path = args[i]
+				continue
+			case "--title":
+				i++
+				expect.title = pattern(i)
+				continue
+			case "--message":
+				i++
+				expect.message = pattern(i)
+				continue
+			case "--command":
+				i++
+				expect.command = pattern(i)
+				continue
+			case "--source":
+				i++
+				expect.source = args[i]
+				continue
+			case "--count":
+				i++
+				count, err := strconv.Atoi(args[i])
+				if err != nil || count < 0 {
+					errorf("❌ Invalid count %q, expected a number of notifications\n", args[i])
+					os.Exit(1)
+				}
+				expect.count = count
+				continue
+			case "--timeout":
+				i++
+				parsed, err := time.ParseDuration(args[i])
+				if err != nil || parsed < 0 {
+					errorf("❌ Invalid timeout %q, expected a duration such as 10s\n", args[i])
+					os.Exit(1)
+				}
+				timeout = parsed
+				continue
+			}
+		}
+		switch args[i] {
+		case "--success":
+			success := true
+			expect.success = &success
+		case "--failed":
+			success := false
+			expect.success = &success
+		case "--none":
+			expect.count = 0
+		case "--json":
+			jsonOutput = true
+		case "--clear":
+			clearSink = true
+		default:
+			printExpectUsage()
+			os.Exit(1)
+		}
+	}
+	if path == "" {
+		errorf("❌ No fake notification sink: pass --file or run with --fake-notify <path> (%s)\n", fakeNotifyEnv)
+		os.Exit(1)
+	}
+
+	if clearSink {
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			errorf("❌ Failed to clear %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	matched, err := awaitNotifications(path, expect, timeout)
+	if err != nil {
+		errorf("❌ Failed to read %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	for _, p := range matched {
+		if jsonOutput {
+			line, _ := json.Marshal(p)
+			fmt.Println(string(line))
+		} else {
+			fmt.Printf("🔔 %s: %s\n", p.Title, p.Message)
+		}
+	}
+
+	switch {
+	case expect.count < 0 && len(matched) == 0:
+		errorf("❌ No matching notification within %s\n", timeout)
+	case expect.count >= 0 && len(matched) != expect.count:
+		errorf("❌ Expected %d matching notifications, got %d within %s\n", expect.count, len(matched), timeout)
+	default:
+		return
+	}
+	os.Exit(1)
+}
+
+// awaitNotifications reads the sink until the expectation is settled: for "at least one" the
+// first match ends the wait, for an exact count a match beyond it does, and otherwise the
+// timeout. A file is followed like tail -f; a FIFO is read as notifications are written to it.
+func awaitNotifications(path string, expect expectation, timeout time.Duration) ([]ChannelPayload, error) {
+	flags := os.O_RDONLY
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		flags |= syscall.O_NONBLOCK
+	}
+	file, err := os.OpenFile(path, flags, 0)
+	if os.IsNotExist(err) {
+		// Nothing was delivered yet, the first notification creates it
+		if file, err = os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	deadline := time.Now().Add(timeout)
+	reader := bufio.NewReader(file)
+	var matched []ChannelPayload
+	var partial []byte
+	for {
+		file.SetReadDeadline(deadline)
+		chunk, err := reader.ReadBytes('\n')
+		partial = append(partial, chunk...)
+		if err == nil {
+			var payload ChannelPayload
+			if json.Unmarshal(partial, &payload) == nil && expect.matches(payload) {
+				matched = append(matched, payload)
+				if (expect.count < 0 && len(matched) > 0) || (expect.count >= 0 && len(matched) > expect.count) {
+					return matched, nil
+				}
+			}
+			partial = nil
+			continue
+		}
+		if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrDeadlineExceeded) && !errors.Is(err, syscall.EAGAIN) {
+			return matched, err
+		}
+		if !time.Now().Before(deadline) {
+			return matched, nil
+		}
+		time.Sleep(expectPollInterval)
+	}
+}
+
+func printExpectUsage() {
+	fmt.Println("Usage: cmdbell expect [--file <path>] [--title RE] [--message RE] [--command RE] [--source S]")
+	fmt.Println("                      [--success|--failed] [--count N|--none] [--timeout 5s] [--json]")
+	fmt.Println("       cmdbell expect [--file <path>] --clear")
+	fmt.Println()
+	fmt.Println("Waits for notifications written by --fake-notify <path> that match every filter given.")
+	fmt.Println("Without --count it succeeds on the first match; --count N and --none wait out the timeout")
+	fmt.Println("and fail as soon as there are more.")
+}
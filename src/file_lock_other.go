@@ -0,0 +1,37 @@
+//go:build !(linux || darwin)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// staleLock is how old a lock file must be before it is taken as left by a process that died
+const staleLock = 10 * time.Second
+
+// lockFile takes an exclusive lock on path's data shared by every cmdbell process, held by
+// creating path.lock, and returns the function releasing it
+func lockFile(path string) (func(), error) {
+	lock := path + ".lock"
+	deadline := time.Now().Add(2 * staleLock)
+	for {
+		file, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock %s: %v", path, err)
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for the lock on %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
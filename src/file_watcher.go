@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type watchedFile struct {
+	pending string // event waiting for the file to settle
+	timer   *time.Timer
+	changes int // counts changes, so a timer that fired before the latest one is ignored
+}
+
+type fileWatchRule struct {
+	config  FileWatchRule
+	pattern string
+	settle  time.Duration
+	files   map[string]*watchedFile
+}
+
+// settledFile is sent when a file has gone a rule's settle time without changing
+type settledFile struct {
+	rule    *fileWatchRule
+	path    string
+	changes int
+}
+
+// FileWatcher watches the directories of glob patterns with fsnotify and notifies when matching
+// files appear or change. Directories matching the pattern are watched as they are created, and
+// a removed one is watched for again from its nearest existing parent.
+type FileWatcher struct {
+	rules   []*fileWatchRule
+	watcher *fsnotify.Watcher
+	watched map[string]bool
+	settled chan settledFile
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewFileWatcher watches rules; interval is how long a file must go unchanged before it is
+// reported, for rules that set no settle of their own
+func NewFileWatcher(interval string, rules []FileWatchRule) (*FileWatcher, error) {
+	quiet, err := time.ParseDuration(interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file_watch interval: %v", err)
+	}
+	if quiet <= 0 {
+		return nil, fmt.Errorf("file_watch interval must be positive")
+	}
+
+	var watchRules []*fileWatchRule
+	for _, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("file watch rule %q has no pattern", rule.Name)
+		}
+
+		if rule.Name == "" {
+			rule.Name = rule.Pattern
+		}
+
+		pattern := filepath.Clean(expandHome(rule.Pattern))
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern for file watch rule %q: %v", rule.Name, err)
+		}
+		if strings.Contains(pattern, "**") {
+			return nil, fmt.Errorf("file watch rule %q uses **, which is not supported; match each directory level with *", rule.Name)
+		}
+
+		settle := quiet
+		if rule.Settle != "" {
+			settle, err = time.ParseDuration(rule.Settle)
+			if err != nil {
+				return nil, fmt.Errorf("invalid settle for file watch rule %q: %v", rule.Name, err)
+			}
+		}
+
+		watchRules = append(watchRules, &fileWatchRule{
+			config:  rule,
+			pattern: pattern,
+			settle:  settle,
+			files:   make(map[string]*watchedFile),
+		})
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &FileWatcher{
+		rules:   watchRules,
+		watcher: watcher,
+		watched: make(map[string]bool),
+		settled: make(chan settledFile),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+func (fw *FileWatcher) Start() {
+	// Files that already exist are not reported, only new activity
+	fw.refresh(true)
+
+	fw.wg.Add(1)
+	go func() {
+		defer fw.wg.Done()
+
+		for {
+			select {
+			case event, ok := <-fw.watcher.Events:
+				if !ok {
+					return
+				}
+				fw.handleEvent(event)
+			case err, ok := <-fw.watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("⚠️  File watcher error: %v", err)
+			case settled := <-fw.settled:
+				fw.report(settled)
+			case <-fw.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Printf("📂 File watcher started with %d rule(s)", len(fw.rules))
+}
+
+func (fw *FileWatcher) Stop() {
+	fw.cancel()
+	fw.watcher.Close()
+	fw.wg.Wait()
+	for _, rule := range fw.rules {
+		for _, file := range rule.files {
+			file.timer.Stop()
+		}
+	}
+	log.Println("🛑 File watcher stopped")
+}
+
+func (fw *FileWatcher) handleEvent(event fsnotify.Event) {
+	info, err := os.Stat(event.Name)
+	isDir := err == nil && info.IsDir()
+
+	// A directory that came or went may change what has to be watched
+	if event.Has(fsnotify.Create) && isDir || (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) && fw.watched[event.Name] {
+		fw.refresh(false)
+	}
+	if isDir {
+		return
+	}
+
+	var change string
+	switch {
+	case event.Has(fsnotify.Create):
+		change = "create"
+	case event.Has(fsnotify.Write):
+		change = "modify"
+	default:
+		return
+	}
+
+	for _, rule := range fw.rules {
+		if matched, _ := filepath.Match(rule.pattern, event.Name); matched {
+			fw.changed(rule, event.Name, change)
+		}
+	}
+}
+
+// changed records a change to a matching file and restarts its settle timer. A file that is
+// created and then written stays a creation.
+func (fw *FileWatcher) changed(rule *fileWatchRule, path, change string) {
+	file, ok := rule.files[path]
+	if !ok {
+		file = &watchedFile{pending: change}
+		rule.files[path] = file
+	} else {
+		file.timer.Stop()
+	}
+	file.changes++
+
+	settled := settledFile{rule: rule, path: path, changes: file.changes}
+	file.timer = time.AfterFunc(rule.settle, func() {
+		select {
+		case fw.settled <- settled:
+		case <-fw.ctx.Done():
+		}
+	})
+}
+
+// report notifies for a file once it has settled, even if it has been removed since
+func (fw *FileWatcher) report(settled settledFile) {
+	rule := settled.rule
+	file, ok := rule.files[settled.path]
+	if !ok || file.changes != settled.changes {
+		return
+	}
+	delete(rule.files, settled.path)
+
+	if rule.wants(file.pending) {
+		fw.notify(rule, settled.path, file.pending)
+	}
+}
+
+// refresh watches every directory the rules' patterns can match files in, and the nearest
+// existing parent of those that do not exist yet. Matching files in directories that were not
+// watched before are reported as created, since they may have appeared before the watch.
+func (fw *FileWatcher) refresh(baseline bool) {
+	wanted := make(map[string]bool)
+	for _, rule := range fw.rules {
+		for _, dir := range watchDirs(rule.pattern) {
+			wanted[dir] = true
+		}
+	}
+
+	for dir := range fw.watched {
+		if !wanted[dir] {
+			fw.watcher.Remove(dir)
+			delete(fw.watched, dir)
+		}
+	}
+
+	for dir := range wanted {
+		if fw.watched[dir] {
+			continue
+		}
+		if err := fw.watcher.Add(dir); err != nil {
+			log.Printf("Failed to watch %s: %v", dir, err)
+			continue
+		}
+		fw.watched[dir] = true
+
+		if baseline {
+			continue
+		}
+		for _, rule := range fw.rules {
+			matches, _ := filepath.Glob(filepath.Join(dir, filepath.Base(rule.pattern)))
+			for _, path := range matches {
+				info, err := os.Stat(path)
+				if err != nil || info.IsDir() {
+					continue
+				}
+				if matched, _ := filepath.Match(rule.pattern, path); matched {
+					fw.changed(rule, path, "create")
+				}
+			}
+		}
+	}
+}
+
+// watchDirs lists the existing directories to watch for pattern: those matching each level of
+// its directory below the part without wildcards, or the nearest existing parent of that part
+func watchDirs(pattern string) []string {
+	dir := filepath.Dir(pattern)
+	root := dir
+	for hasGlobMeta(root) {
+		root = filepath.Dir(root)
+	}
+
+	existing := root
+	for {
+		if info, err := os.Stat(existing); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return nil
+		}
+		existing = parent
+	}
+	if existing != root {
+		return []string{existing}
+	}
+
+	dirs := []string{root}
+	rest, _ := filepath.Rel(root, dir)
+	level := root
+	if rest != "." {
+		for _, part := range strings.Split(rest, string(filepath.Separator)) {
+			level = filepath.Join(level, part)
+			matches, _ := filepath.Glob(level)
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && info.IsDir() {
+					dirs = append(dirs, match)
+				}
+			}
+		}
+	}
+	return dirs
+}
+
+// hasGlobMeta reports whether path has characters filepath.Match treats specially
+func hasGlobMeta(path string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.ContainsAny(path, `*?[`)
+	}
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+func (fw *FileWatcher) notify(rule *fileWatchRule, path, event string) {
+	description := "was created"
+	if event == "modify" {
+		description = "was modified"
+	}
+
+	log.Printf("📂 File watch rule '%s' matched: %s %s", rule.config.Name, path, description)
+
+	if globalConfig != nil && !globalConfig.General.EnableNotify {
+		return
+	}
+	sendFileNotification(rule.config.Name, path, description)
+}
+
+func (rule *fileWatchRule) wants(event string) bool {
+	if len(rule.config.Events) == 0 {
+		return true
+	}
+	for _, e := range rule.config.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryEntry is a delivered notification as persisted by the history store
+type HistoryEntry struct {
+	ID              string    `json:"id"`
+	Time            time.Time `json:"time"`
+	Host            string    `json:"host"`
+	Source          string    `json:"source"`
+	Title           string    `json:"title"`
+	Message         string    `json:"message"`
+	Command         string    `json:"command,omitempty"`
+	Category        string    `json:"category,omitempty"`
+	ContainerName   string    `json:"container_name,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Success         bool      `json:"success"`
+	URL             string    `json:"url,omitempty"`
+	StartedAt       time.Time `json:"started_at,omitzero"`
+	User            string    `json:"user,omitempty"`
+	CorrelationID   string    `json:"correlation_id,omitempty"`
+	Terminal        Terminal  `json:"terminal,omitzero"`
+	// Local marks commands reported by this machine's own wrapper or shell hook, the only ones
+	// links and remote controls may run again
+	Local bool `json:"local,omitempty"`
+	// OriginID is the ID an entry merged from another machine's history had there; merged entries
+	// get IDs of their own, so a peer cannot pick the ID of a local event
+	OriginID string `json:"origin_id,omitempty"`
+}
+
+// HistoryFilter narrows a history query; zero values match everything
+type HistoryFilter struct {
+	Limit      int
+	Source     string
+	Host       string
+	Search     string
+	Category   string
+	FailedOnly bool
+	Since      time.Time
+	// CorrelationID matches events tagged with a caller's job ID, see correlation.go
+	CorrelationID string
+	// Terminal matches commands run in a terminal by its TTY, window ID or shell PID
+	Terminal string
+}
+
+// HistoryStore is the storage engine behind `cmdbell history`, selected by history.backend
+type HistoryStore interface {
+	Append(entry HistoryEntry) error
+	// Query returns matching entries, newest first
+	Query(filter HistoryFilter) ([]HistoryEntry, error)
+	// Merge adds entries not stored yet under new IDs, marked with their OriginID, and returns
+	// how many were added
+	Merge(entries []HistoryEntry) (int, error)
+}
+
+var (
+	historyOnce  sync.Once
+	historyStore HistoryStore
+)
+
+func NewHistoryStore(config *Config) (HistoryStore, error) {
+	path := expandHome(config.History.Path)
+	if path == "" {
+		var err error
+		path, err = getDataPath("history.jsonl")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := historyEncryptionKey(config.History.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.History.Backend {
+	case "jsonl", "":
+		store := NewJSONLHistoryStore(path)
+		store.key = key
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unsupported history backend: %s", config.History.Backend)
+	}
+}
+
+// getHistoryStore returns the configured store, or nil when history is disabled or unavailable
+func getHistoryStore() HistoryStore {
+	historyOnce.Do(func() {
+		if globalConfig == nil || !globalConfig.History.Enabled {
+			return
+		}
+
+		store, err := NewHistoryStore(globalConfig)
+		if err != nil {
+			log.Printf("⚠️  History not available: %v", err)
+			return
+		}
+		historyStore = store
+	})
+	return historyStore
+}
+
+func recordHistory(n *Notification) {
+	store := getHistoryStore()
+	if store == nil {
+		return
+	}
+
+	host := n.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	entry := HistoryEntry{
+		ID:              n.ID,
+		Time:            n.Time,
+		Host:            host,
+		Source:          n.Source,
+		Title:           n.Title,
+		Message:         n.RemoteMessage(),
+		Command:         sanitizeCommand(n.Command),
+		Category:        n.Category,
+		ContainerName:   n.ContainerName,
+		DurationSeconds: n.Duration.Seconds(),
+		Success:         n.Success,
+		URL:             n.URL,
+		StartedAt:       n.StartTime,
+		User:            n.User,
+		CorrelationID:   n.CorrelationID,
+		Terminal:        n.Terminal,
+		Local:           n.Local,
+	}
+
+	if err := store.Append(entry); err != nil {
+		errorf("Failed to record history: %v\n", err)
+	}
+}
+
+// eventIDPattern is what event IDs from other instances must look like to be kept, since they
+// end up in deep links and action URLs
+var eventIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func newEventID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (f HistoryFilter) Matches(entry HistoryEntry) bool {
+	if f.Source != "" && entry.Source != f.Source {
+		return false
+	}
+	if f.Host != "" && entry.Host != f.Host {
+		return false
+	}
+	if f.FailedOnly && entry.Success {
+		return false
+	}
+	if f.CorrelationID != "" && entry.CorrelationID != f.CorrelationID {
+		return false
+	}
+	if f.Terminal != "" && !entry.Terminal.Matches(f.Terminal) {
+		return false
+	}
+	if f.Category != "" && entryCategory(entry) != f.Category {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if f.Search != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(f.Search)) {
+		return false
+	}
+	return true
+}
+
+func handleHistoryCommand() {
+	filter := HistoryFilter{Limit: 20}
+	jsonOutput := false
+	format := ""
+
+	args := os.Args[2:]
+	if len(args) > 0 && args[0] == "sync" {
+		handleHistorySyncCommand()
+		return
+	}
+	if len(args) > 0 && args[0] == "encrypt" {
+		handleHistoryEncryptCommand()
+		return
+	}
+	if len(args) > 0 && args[0] == "stats" {
+		handleHistoryStatsCommand(args[1:])
+		return
+	}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--limit", "-n":
+			if i+1 < len(args) {
+				i++
+				limit, err := strconv.Atoi(args[i])
+				if err != nil {
+					fmt.Printf("Invalid limit: %s\n", args[i])
+					os.Exit(1)
+				}
+				filter.Limit = limit
+			}
+		case "--source":
+			if i+1 < len(args) {
+				i++
+				filter.Source = args[i]
+			}
+		case "--host":
+			if i+1 < len(args) {
+				i++
+				filter.Host = args[i]
+			}
+		case "--search":
+			if i+1 < len(args) {
+				i++
+				filter.Search = args[i]
+			}
+		case "--category":
+			if i+1 < len(args) {
+				i++
+				filter.Category = args[i]
+			}
+		case "--correlation-id":
+			if i+1 < len(args) {
+				i++
+				filter.CorrelationID = args[i]
+			}
+		case "--terminal":
+			if i+1 < len(args) {
+				i++
+				filter.Terminal = args[i]
+			}
+		case "--failed":
+			filter.FailedOnly = true
+		case "--json":
+			jsonOutput = true
+		case "--format":
+			if i+1 < len(args) {
+				i++
+				format = args[i]
+			}
+		default:
+			fmt.Println("Usage: cmdbell history [--limit N] [--source S] [--host H] [--search TEXT] [--category C] [--correlation-id ID] [--terminal TTY|WINDOW|PID] [--failed] [--json|--format alfred|raycast]")
+			fmt.Println("       cmdbell history stats [--source S] [--host H]")
+			fmt.Println("       cmdbell history sync")
+			fmt.Println("       cmdbell history encrypt")
+			os.Exit(1)
+		}
+	}
+
+	store := getHistoryStore()
+	if store == nil {
+		fmt.Println("History is disabled (history.enabled: false)")
+		os.Exit(1)
+	}
+
+	entries, err := store.Query(filter)
+	if err != nil {
+		fmt.Printf("Failed to read history: %v\n", err)
+		os.Exit(1)
+	}
+
+	if format != "" {
+		output, err := launcherOutput(format, entries)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(output); err != nil {
+			fmt.Printf("Failed to encode history: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			fmt.Printf("Failed to encode history: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No history yet")
+		return
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%s %s  %-12s %-9s %s\n", historyIcon(entry), entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Host, entry.Source, entry.Message)
+		if entry.URL != "" {
+			fmt.Printf("   %s\n", entry.URL)
+		}
+		if !entry.Terminal.IsZero() {
+			fmt.Printf("   in %s\n", entry.Terminal)
+		}
+	}
+}
+
+// historyIcon marks editor tasks apart from terminal commands so both can share one list
+func historyIcon(entry HistoryEntry) string {
+	switch {
+	case !entry.Success:
+		return "❌"
+	case entry.Source == "editor":
+		return "🛠️"
+	default:
+		return "✅"
+	}
+}
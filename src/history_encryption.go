@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// History encryption seals each JSONL line on its own with AES-256-GCM, so appends from
+// separate processes stay atomic. The other files that keep commands, decisions.jsonl,
+// outbox.jsonl, streaks.json, background.json and daemon-state.json, are sealed with the same
+// key, line by line or whole. history.encryption picks where the key comes from:
+//
+//	passphrase  derived with PBKDF2 from CMDBELL_HISTORY_PASSPHRASE and ~/.cmdbell/history.salt,
+//	            and cached in the OS keychain so each CLI process does not derive it again
+//	keychain    a random key kept in the OS keychain, see keychain.go
+const (
+	historyPassphraseEnv = "CMDBELL_HISTORY_PASSPHRASE"
+	historyKeyService    = "cmdbell-history"
+	historyKDFIterations = 600000
+
+	// derivedKeyAccount holds the passphrase key with a tag telling whether the passphrase changed
+	derivedKeyAccount = "passphrase-key"
+)
+
+// encryptedLinePrefix starts every line written by an encrypting store
+var encryptedLinePrefix = []byte(`{"cmdbell_encrypted"`)
+
+// historyKeys caches keys by mode; a process reads history and the other files with one key
+var historyKeys sync.Map
+
+// historyEncryptionKey returns the key for history.encryption, nil when it is off
+func historyEncryptionKey(mode string) ([]byte, error) {
+	if cached, ok := historyKeys.Load(mode); ok {
+		return cached.([]byte), nil
+	}
+
+	var key []byte
+	var err error
+	switch mode {
+	case "", "off":
+		return nil, nil
+	case "passphrase":
+		key, err = passphraseHistoryKey()
+	case "keychain":
+		key, err = keychainHistoryKey()
+	default:
+		return nil, fmt.Errorf("unsupported history encryption: %s", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	historyKeys.Store(mode, key)
+	return key, nil
+}
+
+// passphraseHistoryKey derives the key from CMDBELL_HISTORY_PASSPHRASE, or takes it from the
+// keychain when it was derived from the same passphrase before
+func passphraseHistoryKey() ([]byte, error) {
+	passphrase := os.Getenv(historyPassphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("history.encryption is passphrase but %s is not set", historyPassphraseEnv)
+	}
+	salt, err := historySalt()
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := keychainGet(historyKeyService, derivedKeyAccount)
+	if err == nil {
+		encoded, tag, _ := strings.Cut(cached, ".")
+		if key, err := parseEncryptionKey(encoded); err == nil && hmac.Equal([]byte(tag), []byte(passphraseTag(key, passphrase, salt))) {
+			return key, nil
+		}
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, historyKDFIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	// Without a keychain every process derives the key itself, which is slow but works
+	keychainSet(historyKeyService, derivedKeyAccount, base64.StdEncoding.EncodeToString(key)+"."+passphraseTag(key, passphrase, salt))
+	return key, nil
+}
+
+// passphraseTag binds a cached key to the passphrase and salt it was derived from
+func passphraseTag(key []byte, passphrase string, salt []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(salt)
+	mac.Write([]byte(passphrase))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sealData encrypts the contents of a file that keeps commands when history.encryption is on
+func sealData(data []byte) ([]byte, error) {
+	if globalConfig == nil {
+		return data, nil
+	}
+	key, err := historyEncryptionKey(globalConfig.History.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return data, nil
+	}
+	return encryptPayload(key, data)
+}
+
+// openData reverses sealData, passing through what was written before encryption was turned on
+func openData(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), encryptedLinePrefix) {
+		return data, nil
+	}
+	if globalConfig == nil {
+		return nil, errors.New("data is encrypted, set history.encryption to read it")
+	}
+	key, err := historyEncryptionKey(globalConfig.History.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.New("data is encrypted, set history.encryption to read it")
+	}
+	return decryptPayload(key, bytes.TrimSpace(data))
+}
+
+// historySalt reads the passphrase salt, creating it on first use; O_EXCL keeps two
+// processes starting at once from each writing their own
+func historySalt() ([]byte, error) {
+	path, err := getDataPath("history.salt")
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err == nil {
+		defer file.Close()
+		if _, err := file.Write(salt); err != nil {
+			return nil, fmt.Errorf("failed to write history salt: %v", err)
+		}
+		return salt, nil
+	}
+	if !os.IsExist(err) {
+		return nil, fmt.Errorf("failed to create history salt: %v", err)
+	}
+
+	salt, err = os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history salt: %v", err)
+	}
+	if len(salt) < 16 {
+		return nil, fmt.Errorf("history salt %s is damaged", path)
+	}
+	return salt, nil
+}
+
+// keychainHistoryKey fetches the history key from the OS keychain, storing a new one on first use
+func keychainHistoryKey() ([]byte, error) {
+	encoded, err := keychainGet(historyKeyService, "cmdbell")
+	if errors.Is(err, errSecretNotFound) {
+		if encoded, err = generateEncryptionKey(); err != nil {
+			return nil, fmt.Errorf("failed to generate history key: %v", err)
+		}
+		if err := keychainSet(historyKeyService, "cmdbell", encoded); err != nil {
+			return nil, fmt.Errorf("failed to store history key in the keychain: %v", err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return parseEncryptionKey(encoded)
+}
+
+// decodeHistoryLine turns one stored line back into JSON, decrypting it when sealed
+func (s *JSONLHistoryStore) decodeHistoryLine(line []byte) ([]byte, error) {
+	if !bytes.HasPrefix(line, encryptedLinePrefix) {
+		return line, nil
+	}
+	if s.key == nil {
+		return nil, errors.New("history is encrypted, set history.encryption to read it")
+	}
+	plaintext, err := decryptPayload(s.key, line)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt history (wrong passphrase or key?): %v", err)
+	}
+	return plaintext, nil
+}
+
+// EncryptExisting seals the lines written before encryption was turned on and returns how many
+func (s *JSONLHistoryStore) EncryptExisting() (int, error) {
+	if s.key == nil {
+		return 0, errors.New("history.encryption is off")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read history file: %v", err)
+	}
+
+	var out bytes.Buffer
+	sealed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) > 0 && !bytes.HasPrefix(line, encryptedLinePrefix) {
+			encrypted, err := encryptPayload(s.key, line)
+			if err != nil {
+				return 0, err
+			}
+			line = encrypted
+			sealed++
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read history file: %v", err)
+	}
+
+	if sealed == 0 {
+		return 0, nil
+	}
+	return sealed, writeFileAtomic(s.path, out.Bytes(), 0600)
+}
+
+// handleHistoryEncryptCommand encrypts history recorded before history.encryption was set
+func handleHistoryEncryptCommand() {
+	store, ok := getHistoryStore().(*JSONLHistoryStore)
+	if !ok || store == nil {
+		fmt.Println("History is disabled (history.enabled: false)")
+		os.Exit(1)
+	}
+
+	sealed, err := store.EncryptExisting()
+	if err != nil {
+		fmt.Printf("Failed to encrypt history: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("🔒 Encrypted %d history entries in %s\n", sealed, store.path)
+
+	files, err := encryptDataFiles()
+	if err != nil {
+		fmt.Printf("Failed to encrypt the other files that keep commands: %v\n", err)
+		os.Exit(1)
+	}
+	if files > 0 {
+		fmt.Printf("🔒 Encrypted %d other files that keep commands\n", files)
+	}
+}
+
+// encryptDataFiles seals the other files that keep commands, as written before history.encryption
+// was set, and returns how many it changed
+func encryptDataFiles() (int, error) {
+	changed := 0
+	for _, name := range []string{"decisions.jsonl", "outbox.jsonl", "streaks.json", "background.json", "daemon-state.json"} {
+		path, err := getDataPath(name)
+		if err != nil {
+			return changed, err
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return changed, err
+		}
+
+		// JSON Lines files are sealed line by line so appends stay atomic, the rest whole
+		var out bytes.Buffer
+		jsonLines := strings.HasSuffix(name, ".jsonl")
+		lines := [][]byte{data}
+		if jsonLines {
+			lines = bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+		}
+		sealed := false
+		for _, line := range lines {
+			if len(bytes.TrimSpace(line)) > 0 && !bytes.HasPrefix(bytes.TrimSpace(line), encryptedLinePrefix) {
+				if line, err = sealData(line); err != nil {
+					return changed, err
+				}
+				sealed = true
+			}
+			out.Write(line)
+			if jsonLines {
+				out.WriteByte('\n')
+			}
+		}
+		if !sealed {
+			continue
+		}
+		if err := writeFileAtomic(path, out.Bytes(), 0600); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
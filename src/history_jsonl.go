@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// JSONLHistoryStore keeps history as an append-only file of JSON lines
+type JSONLHistoryStore struct {
+	mu   sync.Mutex
+	path string
+	key  []byte // seals each line when history.encryption is on
+}
+
+func NewJSONLHistoryStore(path string) *JSONLHistoryStore {
+	return &JSONLHistoryStore{path: path}
+}
+
+func (s *JSONLHistoryStore) Append(entry HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appendLocked(entry)
+}
+
+// appendLocked writes one entry; callers hold s.mu
+func (s *JSONLHistoryStore) appendLocked(entry HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %v", err)
+	}
+	if s.key != nil {
+		if data, err = encryptPayload(s.key, data); err != nil {
+			return fmt.Errorf("failed to encrypt history entry: %v", err)
+		}
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %v", err)
+	}
+	defer file.Close()
+
+	// A single write per line keeps concurrent appends from separate processes intact
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history entry: %v", err)
+	}
+	return nil
+}
+
+func (s *JSONLHistoryStore) Query(filter HistoryFilter) ([]HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queryLocked(filter)
+}
+
+// queryLocked reads the matching entries; callers hold s.mu
+func (s *JSONLHistoryStore) queryLocked(filter HistoryFilter) ([]HistoryEntry, error) {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %v", err)
+	}
+	defer file.Close()
+
+	var matches []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line, err := s.decodeHistoryLine(scanner.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue // Skip lines damaged by crashes mid-write
+		}
+		if filter.Matches(entry) {
+			matches = append(matches, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %v", err)
+	}
+
+	// Newest first; merged entries from other machines are appended out of order
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Time.After(matches[j].Time)
+	})
+	if filter.Limit > 0 && len(matches) > filter.Limit {
+		matches = matches[:filter.Limit]
+	}
+	return matches, nil
+}
+
+// Merge holds the lock throughout, so concurrent merges of the same entries add them once
+func (s *JSONLHistoryStore) Merge(entries []HistoryEntry) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.queryLocked(HistoryFilter{})
+	if err != nil {
+		return 0, err
+	}
+
+	// An event is known by its ID here and by the ID it had where it happened
+	known := make(map[string]bool, 2*len(existing))
+	for _, entry := range existing {
+		known[entry.ID] = true
+		if entry.OriginID != "" {
+			known[entry.OriginID] = true
+		}
+	}
+
+	added := 0
+	for _, entry := range entries {
+		if entry.OriginID == "" {
+			entry.OriginID = entry.ID
+		}
+		if entry.OriginID == "" || known[entry.ID] || known[entry.OriginID] {
+			continue
+		}
+		known[entry.ID] = true
+		known[entry.OriginID] = true
+
+		entry.ID = newEventID()
+		entry.Local = false
+		if err := s.appendLocked(entry); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistorySyncBackend is a remote copy of the history that entries are exchanged with
+type HistorySyncBackend interface {
+	Fetch(ctx context.Context) ([]HistoryEntry, error)
+	Push(ctx context.Context, entries []HistoryEntry) error
+}
+
+func NewHistorySyncBackend(config *Config) (HistorySyncBackend, error) {
+	syncConfig := config.History.Sync
+	if syncConfig.URL == "" {
+		return nil, fmt.Errorf("history.sync.url is not set")
+	}
+
+	var err error
+	if syncConfig.Password, err = resolveSecret(syncConfig.Password); err != nil {
+		return nil, err
+	}
+	if syncConfig.Token, err = resolveSecret(syncConfig.Token); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch syncConfig.Type {
+	case "webdav":
+		return &webdavHistoryBackend{
+			url:      syncConfig.URL,
+			username: syncConfig.Username,
+			password: syncConfig.Password,
+			client:   client,
+		}, nil
+	case "daemon":
+		return &daemonHistoryBackend{
+			url:    strings.TrimRight(syncConfig.URL, "/") + "/history",
+			token:  syncConfig.Token,
+			client: client,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported history sync type: %q", syncConfig.Type)
+	}
+}
+
+// syncHistory merges the remote history into the local store and pushes the union back.
+// Entries are identified by the ID they had where they happened, so repeated or concurrent syncs
+// never duplicate events.
+func syncHistory(ctx context.Context, store HistoryStore, backend HistorySyncBackend) (pulled, pushed int, err error) {
+	remote, err := backend.Fetch(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch remote history: %v", err)
+	}
+
+	pulled, err = store.Merge(remote)
+	if err != nil {
+		return pulled, 0, fmt.Errorf("failed to merge remote history: %v", err)
+	}
+
+	local, err := store.Query(HistoryFilter{})
+	if err != nil {
+		return pulled, 0, err
+	}
+
+	remoteIDs := make(map[string]bool, 2*len(remote))
+	for _, entry := range remote {
+		remoteIDs[entry.ID] = true
+		if entry.OriginID != "" {
+			remoteIDs[entry.OriginID] = true
+		}
+	}
+	for _, entry := range local {
+		if !remoteIDs[entry.ID] && !remoteIDs[entry.OriginID] {
+			pushed++
+		}
+	}
+
+	if pushed > 0 {
+		// Oldest first so the remote copy stays append-ordered
+		sort.SliceStable(local, func(i, j int) bool {
+			return local[i].Time.Before(local[j].Time)
+		})
+		if err := backend.Push(ctx, local); err != nil {
+			return pulled, 0, fmt.Errorf("failed to push history: %v", err)
+		}
+	}
+
+	return pulled, pushed, nil
+}
+
+type webdavHistoryBackend struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func (b *webdavHistoryBackend) request(ctx context.Context, method string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if b.username != "" {
+		req.SetBasicAuth(b.username, b.password)
+	}
+	return b.client.Do(req)
+}
+
+func (b *webdavHistoryBackend) Fetch(ctx context.Context) ([]HistoryEntry, error) {
+	resp, err := b.request(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func (b *webdavHistoryBackend) Push(ctx context.Context, entries []HistoryEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	resp, err := b.request(ctx, http.MethodPut, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+type daemonHistoryBackend struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (b *daemonHistoryBackend) do(req *http.Request) (*http.Response, error) {
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func (b *daemonHistoryBackend) Fetch(ctx context.Context) ([]HistoryEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var entries []HistoryEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid history response: %v", err)
+	}
+	return entries, nil
+}
+
+func (b *daemonHistoryBackend) Push(ctx context.Context, entries []HistoryEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// HistorySyncer periodically syncs history from the daemon
+type HistorySyncer struct {
+	store    HistoryStore
+	backend  HistorySyncBackend
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewHistorySyncer(config *Config) (*HistorySyncer, error) {
+	interval, err := time.ParseDuration(config.History.Sync.Interval)
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("invalid history.sync.interval: %q", config.History.Sync.Interval)
+	}
+
+	store := getHistoryStore()
+	if store == nil {
+		return nil, fmt.Errorf("history is disabled")
+	}
+
+	backend, err := NewHistorySyncBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HistorySyncer{
+		store:    store,
+		backend:  backend,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+func (hs *HistorySyncer) Start() {
+	hs.wg.Add(1)
+	go func() {
+		defer hs.wg.Done()
+
+		ticker := time.NewTicker(hs.interval)
+		defer ticker.Stop()
+
+		for {
+			pulled, pushed, err := syncHistory(hs.ctx, hs.store, hs.backend)
+			if err != nil {
+				log.Printf("⚠️  History sync failed: %v", err)
+			} else if pulled > 0 || pushed > 0 {
+				log.Printf("🔄 History synced: %d pulled, %d pushed", pulled, pushed)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-hs.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Printf("🔄 History sync started (every %s)", hs.interval)
+}
+
+func (hs *HistorySyncer) Stop() {
+	hs.cancel()
+	hs.wg.Wait()
+	log.Println("🛑 History sync stopped")
+}
+
+func handleHistorySyncCommand() {
+	store := getHistoryStore()
+	if store == nil {
+		fmt.Println("History is disabled (history.enabled: false)")
+		os.Exit(1)
+	}
+
+	backend, err := NewHistorySyncBackend(globalConfig)
+	if err != nil {
+		fmt.Printf("History sync is not configured: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pulled, pushed, err := syncHistory(ctx, store, backend)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ History synced: %d pulled, %d pushed\n", pulled, pushed)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// HookStatus describes the CmdBell hook installed in one shell's config file
+type HookStatus struct {
+	Shell     string
+	Path      string
+	Installed bool
+	Version   int  // 0 for hooks written before version stamping
+	Current   bool // matches what this binary would generate with the current config
+}
+
+func (si *ShellIntegration) generateHook(shell string) string {
+	switch shell {
+	case "bash":
+		return si.generateBashHook()
+	case "zsh":
+		return si.generateZshHook()
+	case "fish":
+		return si.generateFishHook()
+	default:
+		return ""
+	}
+}
+
+// InstalledHooks inspects the shell config files for installed hooks and their versions
+func (si *ShellIntegration) InstalledHooks() []HookStatus {
+	var statuses []HookStatus
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		status := HookStatus{Shell: shell, Path: si.shellConfigPath(shell)}
+
+		if data, err := os.ReadFile(status.Path); err == nil {
+			if block, ok := extractHookBlock(string(data)); ok {
+				status.Installed = true
+				status.Version = hookBlockVersion(block)
+				status.Current = block == strings.Trim(si.generateHook(shell), "\n")
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func extractHookBlock(content string) (string, bool) {
+	startIdx := strings.Index(content, hookStartMarker)
+	if startIdx == -1 {
+		return "", false
+	}
+
+	endIdx := strings.Index(content[startIdx:], hookEndMarker)
+	if endIdx == -1 {
+		return "", false
+	}
+
+	return content[startIdx : startIdx+endIdx+len(hookEndMarker)], true
+}
+
+func hookBlockVersion(block string) int {
+	for _, line := range strings.Split(block, "\n") {
+		if strings.HasPrefix(line, hookVersionPrefix) {
+			version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, hookVersionPrefix)))
+			if err == nil {
+				return version
+			}
+		}
+	}
+	return 0
+}
+
+func handleUpgradeHooksCommand() {
+	integration, err := NewShellIntegration()
+	if err != nil {
+		fmt.Printf("Failed to create shell integration: %v\n", err)
+		os.Exit(1)
+	}
+
+	upgraded := 0
+	for _, status := range integration.InstalledHooks() {
+		if !status.Installed {
+			continue
+		}
+		if status.Current {
+			fmt.Printf("✅ %s hook is up to date (version %d)\n", status.Shell, status.Version)
+			continue
+		}
+
+		if err := integration.installForShell(status.Shell); err != nil {
+			fmt.Printf("❌ Failed to upgrade %s hook: %v\n", status.Shell, err)
+			os.Exit(1)
+		}
+		fmt.Printf("⬆️  Upgraded %s hook from version %d to %d\n", status.Shell, status.Version, HookVersion)
+		upgraded++
+	}
+
+	if upgraded > 0 {
+		fmt.Println("💡 Restart your shell or run 'source ~/.bashrc' (or equivalent) to activate")
+	}
+}
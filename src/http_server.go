@@ -1,16 +1,38 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"mime"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type HTTPServer struct {
-	server *http.Server
-	port   int
+	server       *http.Server
+	port         int // the port listened on once started, which port_fallback may have changed
+	portFallback bool
+	tlsServer    *http.Server // HTTPS listener for remote forwarders, nil without http.tls
+	tls          ServerTLSConfig
+	tokens       []APIToken
+	maxBodyBytes int64
+	corsOrigins  []string
+	idleTimeout  time.Duration
+	audit        *AuditLog
+	startedAt    time.Time
+	marks        *scriptMarks           // notify-start times waiting for notify-done
+	background   *backgroundJobs        // backgrounded shell jobs watched until they exit
+	components   func() map[string]bool // reports which daemon components are running, for /status
+	metrics      func() map[string]int  // internal counters reported by /status
+	jobs         func() []RunningJob    // work being timed, counted by /prompt
+	prompt       promptCache
 }
 
 type NotificationRequest struct {
@@ -19,31 +41,101 @@ type NotificationRequest struct {
 	Duration      string `json:"duration"`
 	Success       bool   `json:"success"`
 	StartTime     string `json:"start_time"`
+	CorrelationID string `json:"correlation_id"`
+	Terminal      Terminal `json:"terminal"`
 }
 
-func NewHTTPServer(port int) *HTTPServer {
+func NewHTTPServer(config *Config) *HTTPServer {
+	audit, err := NewAuditLog()
+	if err != nil {
+		log.Printf("⚠️  API audit log not available: %v", err)
+	}
+
+	idleTimeout, err := time.ParseDuration(config.HTTP.IdleTimeout)
+	if err != nil || idleTimeout <= 0 {
+		idleTimeout = 60 * time.Second
+	}
+
 	return &HTTPServer{
-		port: port,
+		port:         config.HTTP.Port,
+		portFallback: config.HTTP.PortFallback,
+		tls:          config.HTTP.TLS,
+		tokens:       config.HTTP.Tokens,
+		maxBodyBytes: config.HTTP.MaxBodyBytes,
+		corsOrigins:  config.HTTP.CORSOrigins,
+		idleTimeout:  idleTimeout,
+		audit:        audit,
+		startedAt:    time.Now(),
+		marks:        newScriptMarks(),
+		background:   newBackgroundJobs(),
 	}
 }
 
 func (hs *HTTPServer) Start() error {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/notify", hs.handleNotification)
+	mux.HandleFunc("/notify", hs.authorize("notify", hs.limitBody(hs.handleNotification)))
+	mux.HandleFunc("/events", hs.authorize("notify", hs.limitBody(hs.handleEvent)))
+	mux.HandleFunc("/tasks", hs.authorize("notify", hs.limitBody(hs.handleTask)))
+	mux.HandleFunc("/marks/start", hs.authorize("notify", hs.limitBody(hs.handleMarkStart)))
+	mux.HandleFunc("/marks/done", hs.authorize("notify", hs.limitBody(hs.handleMarkDone)))
+	mux.HandleFunc("/background", hs.authorize("notify", hs.limitBody(hs.handleBackground)))
+	mux.HandleFunc("/mutes", hs.authorize("notify", hs.limitBody(hs.handleMutes)))
+	mux.HandleFunc("/actions/", hs.authorize("notify", hs.limitBody(hs.handleAction)))
+	mux.HandleFunc("/slack", hs.limitBody(hs.handleSlack)) // signed by the Slack app instead
 	mux.HandleFunc("/health", hs.handleHealth)
+	mux.HandleFunc("/hub/identity", hs.handleHubIdentity)
+	mux.HandleFunc("/status", hs.handleStatus)
+	mux.HandleFunc("/prompt", hs.handlePrompt)
+	mux.HandleFunc("/jobs", hs.authorize("notify", hs.limitBody(hs.handleJobs)))
+	mux.HandleFunc("/openapi.json", hs.handleOpenAPI)
+	mux.HandleFunc("/history", hs.authorize("history", hs.limitBody(hs.handleHistory)))
+	mux.HandleFunc("/outputs/", hs.authorize("history", hs.handleOutput))
+	mux.HandleFunc("/poll", hs.authorize("history", hs.limitBody(hs.handlePoll)))
+
+	// With client certificates required over HTTPS, plain HTTP only serves this machine, or
+	// other machines could skip the certificate by using the plain port
+	bindHost := "0.0.0.0"
+	if hs.tls.Cert != "" && hs.tls.ClientCA != "" {
+		bindHost = "127.0.0.1"
+	}
+
+	// Bind before serving, so a taken port fails the start or falls back to a free one
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", bindHost, hs.port))
+	if err != nil && hs.portFallback {
+		log.Printf("⚠️  Port %d is not available (%v), listening on a free port instead", hs.port, err)
+		listener, err = net.Listen("tcp", bindHost+":0")
+	}
+	if err != nil {
+		if !hs.portFallback {
+			return fmt.Errorf("%v (set http.port_fallback to listen on a free port instead)", err)
+		}
+		return err
+	}
+	hs.port = listener.Addr().(*net.TCPAddr).Port
 
 	hs.server = &http.Server{
-		Addr:    fmt.Sprintf("0.0.0.0:%d", hs.port),
-		Handler: mux,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		Addr:    fmt.Sprintf("%s:%d", bindHost, hs.port),
+		Handler: hs.cors(mux),
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       hs.idleTimeout,
 	}
 
-	log.Printf("🌐 Starting HTTP server on 0.0.0.0:%d", hs.port)
+	log.Printf("🌐 Starting HTTP server on %s:%d", bindHost, hs.port)
+	
+	if hs.tls.Cert != "" {
+		if err := hs.startTLS(hs.server.Handler); err != nil {
+			listener.Close()
+			return fmt.Errorf("https: %v", err)
+		}
+	}
 	
+	go hs.background.run()
+
 	// Start server in goroutine to not block
 	go func() {
-		if err := hs.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := hs.server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Printf("HTTP server error: %v", err)
 		}
 	}()
@@ -57,7 +149,125 @@ func (hs *HTTPServer) Stop() error {
 	}
 
 	log.Println("🛑 Stopping HTTP server...")
-	return hs.server.Close()
+	hs.background.Stop()
+
+	// Let in-flight requests finish instead of dropping them
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := hs.server.Shutdown(ctx)
+	if err != nil {
+		hs.server.Close()
+	}
+	if hs.tlsServer != nil {
+		if err := hs.tlsServer.Shutdown(ctx); err != nil {
+			hs.tlsServer.Close()
+		}
+	}
+
+	// Requests hand notifications off to background deliveries; wait for those too
+	waitForDeliveries()
+	return err
+}
+
+// startTLS serves handler over HTTPS on http.tls.port, requiring client certificates when
+// http.tls.client_ca is set
+func (hs *HTTPServer) startTLS(handler http.Handler) error {
+	config, err := serverTLSConfig(hs.tls.Cert, hs.tls.Key, hs.tls.ClientCA)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", hs.tls.Port))
+	if err != nil {
+		return err
+	}
+
+	hs.tlsServer = &http.Server{
+		Handler:           handler,
+		TLSConfig:         config,
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       hs.idleTimeout,
+	}
+	if hs.tls.ClientCA != "" {
+		log.Printf("🔐 Starting HTTPS server on 0.0.0.0:%d, clients need a certificate from %s", hs.tls.Port, hs.tls.ClientCA)
+	} else {
+		log.Printf("🔐 Starting HTTPS server on 0.0.0.0:%d", hs.tls.Port)
+	}
+
+	go func() {
+		if err := hs.tlsServer.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTPS server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// fromLocalHook reports whether a /notify request is this machine's own shell hook, sent by a
+// local program and naming this host
+func fromLocalHook(r *http.Request, containerName string) bool {
+	hostname, _ := os.Hostname()
+	return fromLocalClient(r) && containerName == hostname
+}
+
+// limitBody rejects request bodies larger than http.max_body_bytes
+func (hs *HTTPServer) limitBody(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hs.maxBodyBytes > 0 {
+			if r.ContentLength > hs.maxBodyBytes {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, hs.maxBodyBytes)
+		}
+		next(w, r)
+	}
+}
+
+// cors adds CORS headers for origins listed in http.cors_origins and answers preflight requests
+func (hs *HTTPServer) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !hs.allowedOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-CmdBell-Token")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeDecodeError distinguishes bodies cut off by limitBody from malformed JSON
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var unsupported *schemaVersionError
+	if errors.As(err, &unsupported) {
+		http.Error(w, unsupported.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+}
+
+func (hs *HTTPServer) allowedOrigin(origin string) bool {
+	for _, allowed := range hs.corsOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
 }
 
 func (hs *HTTPServer) handleNotification(w http.ResponseWriter, r *http.Request) {
@@ -67,9 +277,9 @@ func (hs *HTTPServer) handleNotification(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req NotificationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodePayload(r.Body, "notification", &req); err != nil {
 		log.Printf("Invalid JSON payload: %v", err)
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		writeDecodeError(w, err)
 		return
 	}
 
@@ -92,6 +302,12 @@ func (hs *HTTPServer) handleNotification(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	correlationID, err := requestCorrelationID(r, req.CorrelationID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Set default container name if not provided
 	containerName := req.ContainerName
 	if containerName == "" {
@@ -99,23 +315,178 @@ func (hs *HTTPServer) handleNotification(w http.ResponseWriter, r *http.Request)
 	}
 
 	log.Printf("📨 Received notification: command='%s', container='%s', duration=%s, success=%t",
-		req.Command, containerName, duration, req.Success)
+		sanitizeCommand(req.Command), containerName, duration, req.Success)
+
+	// Hooks only pre-filter by the lowest threshold, so apply the command's own tier here
+	if !shouldNotifyCommand(req.Command, duration) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "success",
+			"message": "Below notification threshold",
+		})
+		return
+	}
 
 	// Send notification using existing function
-	sendContainerNotification(req.Command, containerName, duration, req.Success)
+	var startTime time.Time
+	if req.StartTime != "" {
+		if startTime, err = parseStartTime(req.StartTime); err != nil {
+			http.Error(w, "Invalid start_time format", http.StatusBadRequest)
+			return
+		}
+	}
+	n := containerNotification(req.Command, containerName, requestUser(r), startTime, duration, req.Success)
+	n.ID = newEventID()
+	n.CorrelationID = correlationID
+	n.Terminal = req.Terminal
+	n.Local = fromLocalHook(r, containerName)
+	deliverNotification(n)
 
 	// Send success response
 	w.Header().Set("Content-Type", "application/json")
-	response := map[string]interface{}{
+	response := withEventIDs(w, map[string]interface{}{
 		"status":  "success",
 		"message": "Notification sent",
-	}
+	}, n)
 	
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("Failed to encode response: %v", err)
 	}
 }
 
+// handleEvent accepts events forwarded by remote CmdBell instances when this daemon acts as a hub
+func (hs *HTTPServer) handleEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var payload ChannelPayload
+	if err := decodePayload(r.Body, "event", &payload); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if payload.Message == "" {
+		http.Error(w, "Missing required field: message", http.StatusBadRequest)
+		return
+	}
+
+	if payload.Host == "" {
+		payload.Host = r.RemoteAddr
+	}
+	correlationID, err := requestCorrelationID(r, payload.CorrelationID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("📨 Received event from %s: %s", payload.Host, payload.Message)
+	n := payload.toNotification()
+	if n.ID == "" {
+		n.ID = newEventID()
+	}
+	n.CorrelationID = correlationID
+	deliverNotification(n)
+
+	w.Header().Set("Content-Type", "application/json")
+	response := withEventIDs(w, map[string]interface{}{
+		"status":  "success",
+		"message": "Event delivered",
+	}, n)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+func (hs *HTTPServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	store := getHistoryStore()
+	if store == nil {
+		http.Error(w, "History is disabled", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		filter := HistoryFilter{
+			Source:        query.Get("source"),
+			Host:          query.Get("host"),
+			Search:        query.Get("search"),
+			Category:      query.Get("category"),
+			FailedOnly:    query.Get("failed") == "true",
+			CorrelationID: query.Get("correlation_id"),
+			Terminal:      query.Get("terminal"),
+		}
+		if limit, err := strconv.Atoi(query.Get("limit")); err == nil {
+			filter.Limit = limit
+		}
+
+		entries, err := store.Query(filter)
+		if err != nil {
+			log.Printf("Failed to read history: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if entries == nil {
+			entries = []HistoryEntry{}
+		}
+
+		// Launchers such as Alfred and Raycast get their list shape directly
+		var output interface{} = entries
+		if format := query.Get("format"); format != "" {
+			if output, err = launcherOutput(format, entries); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(output); err != nil {
+			log.Printf("Failed to encode history: %v", err)
+		}
+
+	case http.MethodPost:
+		// Merged entries end up in links and buttons, so only peers holding a token may add them
+		if !hs.tokenGrants(r, "history") {
+			log.Printf("🔒 Rejected history merge from %s without a token with the history scope", r.RemoteAddr)
+			http.Error(w, "Merging history needs a token with the history scope", http.StatusUnauthorized)
+			return
+		}
+		// A JSON content type cannot be sent cross-origin without a preflight
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			http.Error(w, "Expected Content-Type: application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		var entries []HistoryEntry
+		if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		added, err := store.Merge(entries)
+		if err != nil {
+			log.Printf("Failed to merge history: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("🔄 Merged %d history entries from %s", added, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json")
+		response := map[string]interface{}{
+			"status": "success",
+			"added":  added,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Printf("Failed to encode response: %v", err)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 func (hs *HTTPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -133,4 +504,55 @@ func (hs *HTTPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Failed to encode health response: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
+}
+
+func (hs *HTTPServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	components := map[string]bool{"http_server": true}
+	if hs.components != nil {
+		components = hs.components()
+	}
+
+	metrics := map[string]int{}
+	if hs.metrics != nil {
+		metrics = hs.metrics()
+	}
+
+	configPath, _ := getConfigPath()
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"status":                "running",
+		"config_path":           configPath,
+		"host":                  hostname,
+		"pid":                   os.Getpid(),
+		"started_at":            hs.startedAt,
+		"uptime_seconds":        int(time.Since(hs.startedAt).Seconds()),
+		"hook_version":          HookVersion,
+		"schema_version":        schemaVersion,
+		"oldest_schema_version": oldestSchemaVersion,
+		"components":            components,
+		"metrics":               metrics,
+		"unseen":                unseenCount(),
+		"profile":               activeProfile(),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode status response: %v", err)
+	}
+}
+
+func (hs *HTTPServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
 }
\ No newline at end of file
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/mdns"
+)
+
+// hubServiceType is the DNS-SD service hub daemons advertise on the LAN
+const hubServiceType = "_cmdbell._tcp"
+
+// HubAdvertiser announces this daemon as a hub over mDNS
+type HubAdvertiser struct {
+	service *mdns.MDNSService
+	server  *mdns.Server
+}
+
+func NewHubAdvertiser(name string, port int) (*HubAdvertiser, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %v", err)
+	}
+	if name == "" {
+		name = hostname
+	}
+
+	ips := lanAddresses()
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no LAN address to advertise")
+	}
+
+	txt := []string{"host=" + hostname}
+	service, err := mdns.NewMDNSService(hubInstanceName(name), hubServiceType, "", hubInstanceName(hostname)+".local.", port, ips, txt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mDNS service: %v", err)
+	}
+
+	return &HubAdvertiser{service: service}, nil
+}
+
+func (ha *HubAdvertiser) Start() error {
+	server, err := mdns.NewServer(&mdns.Config{Zone: ha.service, Logger: quietMDNSLogger()})
+	if err != nil {
+		return fmt.Errorf("failed to start mDNS responder: %v", err)
+	}
+	ha.server = server
+
+	log.Printf("📡 Advertising hub %q on port %d via mDNS", ha.service.Instance, ha.service.Port)
+	return nil
+}
+
+func (ha *HubAdvertiser) Stop() {
+	if ha.server != nil {
+		ha.server.Shutdown()
+	}
+	log.Println("🛑 Hub advertiser stopped")
+}
+
+// hubInstanceName keeps names to a single DNS label that survives a round trip through mDNS
+func hubInstanceName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '.' {
+			return '-'
+		}
+		return r
+	}, name)
+	return strings.Trim(name, "-")
+}
+
+// lanAddresses returns the non-loopback unicast addresses of interfaces that are up
+func lanAddresses() []net.IP {
+	var ips []net.IP
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ips
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+	return ips
+}
+
+func quietMDNSLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+// DiscoveredHub is a hub daemon found on the LAN
+type DiscoveredHub struct {
+	Name string `json:"name"`
+	Host string `json:"host"`
+	URL  string `json:"url"`
+}
+
+// discoverHubs browses the LAN for advertised hub daemons until the timeout expires
+func discoverHubs(ctx context.Context, timeout time.Duration) ([]DiscoveredHub, error) {
+	entries := make(chan *mdns.ServiceEntry, 16)
+	found := make(map[string]DiscoveredHub)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for entry := range entries {
+			hub, ok := newDiscoveredHub(entry)
+			if ok {
+				found[hub.Name] = hub
+			}
+		}
+	}()
+
+	params := mdns.DefaultParams(hubServiceType)
+	params.Entries = entries
+	params.Timeout = timeout
+	params.DisableIPv6 = true
+	params.Logger = quietMDNSLogger()
+
+	err := mdns.QueryContext(ctx, params)
+	close(entries)
+	<-done
+	if err != nil {
+		return nil, fmt.Errorf("mDNS query failed: %v", err)
+	}
+
+	hubs := make([]DiscoveredHub, 0, len(found))
+	for _, hub := range found {
+		hubs = append(hubs, hub)
+	}
+	sort.Slice(hubs, func(i, j int) bool { return hubs[i].Name < hubs[j].Name })
+	return hubs, nil
+}
+
+func newDiscoveredHub(entry *mdns.ServiceEntry) (DiscoveredHub, bool) {
+	if entry.AddrV4 == nil || entry.Port == 0 {
+		return DiscoveredHub{}, false
+	}
+
+	name := strings.TrimSuffix(entry.Name, "."+hubServiceType+".local.")
+	host := strings.TrimSuffix(entry.Host, ".local.")
+	for _, field := range entry.InfoFields {
+		if value, ok := strings.CutPrefix(field, "host="); ok {
+			host = value
+		}
+	}
+
+	return DiscoveredHub{
+		Name: name,
+		Host: host,
+		URL:  fmt.Sprintf("http://%s", net.JoinHostPort(entry.AddrV4.String(), fmt.Sprint(entry.Port))),
+	}, true
+}
+
+// PairedHub is the hub the user explicitly allowed events to be forwarded to
+type PairedHub struct {
+	Name     string    `json:"name"`
+	URL      string    `json:"url"`
+	PairedAt time.Time `json:"paired_at"`
+	// PublicKey is the hub's identity key as seen when pairing; a hub found at a new address
+	// must prove it holds the key before the channel token is sent there
+	PublicKey string `json:"public_key,omitempty"`
+}
+
+// hubIdentityContext is signed together with the caller's nonce, so identity signatures cannot
+// be passed off as anything else
+const hubIdentityContext = "cmdbell-hub-identity:"
+
+// hubIdentityKey returns the key this daemon proves its identity to paired machines with,
+// creating it on first use
+func hubIdentityKey() (ed25519.PrivateKey, error) {
+	path, err := getDataPath("hub_identity.key")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid hub identity key in %s", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, []byte(hex.EncodeToString(key.Seed())+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// hubIdentity is the answer to GET /hub/identity
+type hubIdentity struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+// handleHubIdentity signs the caller's nonce with the hub identity key
+func (hs *HTTPServer) handleHubIdentity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	nonce := r.URL.Query().Get("nonce")
+	if len(nonce) < 16 || len(nonce) > 128 {
+		http.Error(w, "Expected a nonce of 16 to 128 characters", http.StatusBadRequest)
+		return
+	}
+	key, err := hubIdentityKey()
+	if err != nil {
+		log.Printf("⚠️  Hub identity key not available: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hubIdentity{
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(hubIdentityContext+nonce))),
+	})
+}
+
+// fetchHubIdentity has the hub at url sign a fresh nonce and returns its public key once the
+// signature checks out. With pinned set, the key must be that one.
+func fetchHubIdentity(ctx context.Context, url, pinned string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	challenge := hex.EncodeToString(nonce)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(url, "/")+"/hub/identity?nonce="+challenge, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("identity check answered %s", resp.Status)
+	}
+
+	var identity hubIdentity
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&identity); err != nil {
+		return "", fmt.Errorf("invalid identity response: %v", err)
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(identity.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return "", errors.New("invalid identity key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(identity.Signature)
+	if err != nil || !ed25519.Verify(publicKey, []byte(hubIdentityContext+challenge), signature) {
+		return "", errors.New("identity signature does not match")
+	}
+	if pinned != "" && identity.PublicKey != pinned {
+		return "", errors.New("it holds a different identity key than the hub that was paired")
+	}
+	return identity.PublicKey, nil
+}
+
+func loadPairedHub() (*PairedHub, error) {
+	path, err := getDataPath("hub.json")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hub PairedHub
+	if err := json.Unmarshal(data, &hub); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &hub, nil
+}
+
+func savePairedHub(hub *PairedHub) error {
+	path, err := getDataPath("hub.json")
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(hub, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0600)
+}
+
+// rediscoverPairedHub looks the paired hub up again by name, so a hub whose IP changed keeps
+// working. Anything on the LAN can answer to the name, so the new address is only used once it
+// proves it holds the identity key pinned when pairing.
+func rediscoverPairedHub(ctx context.Context, hub *PairedHub) (*PairedHub, error) {
+	hubs, err := discoverHubs(ctx, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, found := range hubs {
+		if found.Name != hub.Name {
+			continue
+		}
+		if found.URL != hub.URL {
+			if hub.PublicKey == "" {
+				return nil, fmt.Errorf("paired hub %q now answers at %s, run 'cmdbell hub pair %s' to confirm it", hub.Name, found.URL, hub.Name)
+			}
+			if _, err := fetchHubIdentity(ctx, found.URL, hub.PublicKey); err != nil {
+				log.Printf("🔒 Not forwarding to %s, which answers to paired hub %q: %v", found.URL, hub.Name, err)
+				return nil, fmt.Errorf("%s claims to be paired hub %q but failed its identity check (%v); run 'cmdbell hub pair %s' if the hub was reinstalled", found.URL, hub.Name, err, hub.Name)
+			}
+			updated := *hub
+			updated.URL = found.URL
+			if err := savePairedHub(&updated); err != nil {
+				log.Printf("⚠️  Failed to save hub address: %v", err)
+			}
+			return &updated, nil
+		}
+		return hub, nil
+	}
+	return nil, fmt.Errorf("paired hub %q not found on the LAN", hub.Name)
+}
+
+func handleHubCommand() {
+	if len(os.Args) < 3 {
+		printHubUsage()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "discover":
+		handleHubDiscover()
+	case "pair":
+		handleHubPair()
+	case "unpair":
+		path, err := getDataPath("hub.json")
+		if err == nil {
+			err = os.Remove(path)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Printf("❌ Failed to unpair: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("✅ Hub unpaired")
+	case "status":
+		hub, err := loadPairedHub()
+		if err != nil {
+			fmt.Println("No hub paired. Run 'cmdbell hub discover' to find one.")
+			return
+		}
+		fmt.Printf("Paired hub: %s (%s), paired %s\n", hub.Name, hub.URL, hub.PairedAt.Local().Format("2006-01-02 15:04"))
+		if hub.PublicKey != "" {
+			fmt.Printf("Identity key: %s\n", hub.PublicKey)
+		}
+	default:
+		printHubUsage()
+		os.Exit(1)
+	}
+}
+
+func printHubUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  cmdbell hub discover [--timeout 3s] - List hub daemons advertised on the LAN")
+	fmt.Println("  cmdbell hub pair <name>             - Forward events to a discovered hub")
+	fmt.Println("  cmdbell hub unpair                  - Forget the paired hub")
+	fmt.Println("  cmdbell hub status                  - Show the paired hub")
+}
+
+func handleHubDiscover() {
+	timeout := 3 * time.Second
+	args := os.Args[3:]
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--timeout" && i+1 < len(args) {
+			i++
+			parsed, err := time.ParseDuration(args[i])
+			if err != nil {
+				fmt.Printf("Invalid --timeout: %v\n", err)
+				os.Exit(1)
+			}
+			timeout = parsed
+		}
+	}
+
+	fmt.Printf("🔍 Browsing for hubs for %s...\n", timeout)
+	hubs, err := discoverHubs(context.Background(), timeout)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(hubs) == 0 {
+		fmt.Println("No hubs found. Set hub.advertise: true in the hub's config and restart its daemon.")
+		return
+	}
+	for _, hub := range hubs {
+		fmt.Printf("  %-20s %-20s %s\n", hub.Name, hub.Host, hub.URL)
+	}
+	fmt.Println("\nRun 'cmdbell hub pair <name>' to forward events to one of them.")
+}
+
+func handleHubPair() {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: cmdbell hub pair <name>")
+		os.Exit(1)
+	}
+	name := os.Args[3]
+
+	hubs, err := discoverHubs(context.Background(), 3*time.Second)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, hub := range hubs {
+		if hub.Name != name {
+			continue
+		}
+		paired := &PairedHub{Name: hub.Name, URL: hub.URL, PairedAt: time.Now()}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		paired.PublicKey, err = fetchHubIdentity(ctx, hub.URL, "")
+		cancel()
+		if err != nil {
+			fmt.Printf("⚠️  The hub did not prove its identity (%v), so if its address changes you will need to pair again\n", err)
+		}
+		if err := savePairedHub(paired); err != nil {
+			fmt.Printf("❌ Failed to save pairing: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Paired with hub %s at %s\n", hub.Name, hub.URL)
+		if !hasHubChannel(globalConfig) {
+			fmt.Println("Add a channel to your config to start forwarding:")
+			fmt.Println("  channels:")
+			fmt.Println("    - type: hub")
+			fmt.Println("      token: <hub API token, if it requires one>")
+		}
+		return
+	}
+
+	fmt.Printf("❌ Hub %q not found on the LAN. Run 'cmdbell hub discover' to list hubs.\n", name)
+	os.Exit(1)
+}
+
+func hasHubChannel(config *Config) bool {
+	if config == nil {
+		return false
+	}
+	for _, channel := range config.Channels {
+		if channel.Type == "hub" {
+			return true
+		}
+	}
+	return false
+}
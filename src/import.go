@@ -0,0 +1,565 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// importSources are the tools `cmdbell import --from` understands, with the files each reads by default
+var importSources = map[string]func(files []string) (*importedConfig, error){
+	"noti":          importNoti,
+	"ntfy":          importNtfy,
+	"undistract-me": importUndistractMe,
+}
+
+// importedConfig is what could be translated from another tool's settings
+type importedConfig struct {
+	Files    []string // files that contributed settings
+	Settings importedSettings
+	Notes    []string // settings without a CmdBell equivalent
+}
+
+// importedSettings is the part of Config an import can fill, marshalled as a config snippet
+type importedSettings struct {
+	General struct {
+		MinDuration string `yaml:"min_duration,omitempty"`
+	} `yaml:"general,omitempty"`
+	Notification struct {
+		Sound bool `yaml:"sound,omitempty"`
+	} `yaml:"notification,omitempty"`
+	Thresholds struct {
+		Ignore []string `yaml:"ignore,omitempty"`
+	} `yaml:"thresholds,omitempty"`
+	Channels []importedChannel `yaml:"channels,omitempty"`
+}
+
+// importedChannel is a ChannelConfig with only the fields an import sets
+type importedChannel struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`
+	URL     string            `yaml:"url"`
+	Token   string            `yaml:"token,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Format  string            `yaml:"format,omitempty"`
+}
+
+func (ic *importedConfig) empty() bool {
+	s := ic.Settings
+	return s.General.MinDuration == "" && !s.Notification.Sound && len(s.Thresholds.Ignore) == 0 && len(s.Channels) == 0
+}
+
+func (ic *importedConfig) notef(format string, args ...interface{}) {
+	ic.Notes = append(ic.Notes, fmt.Sprintf(format, args...))
+}
+
+// shellRCFiles are the shell startup files wrappers are usually configured in
+func shellRCFiles() []string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, name := range []string{".bashrc", ".bash_profile", ".profile", ".zshenv", ".zshrc"} {
+		files = append(files, filepath.Join(homeDir, name))
+	}
+	return files
+}
+
+// toolConfigFiles returns where a tool keeps name, in the user config directory and in ~/.config,
+// which some tools use on macOS as well
+func toolConfigFiles(tool, name string) []string {
+	var files []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(dir, tool, name))
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		if path := filepath.Join(homeDir, ".config", tool, name); len(files) == 0 || files[0] != path {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+var shellAssignment = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// readShellVars collects variable assignments from shell startup files, the last one winning, and
+// reports which files mentioned any of markers, such as the line sourcing a tool
+func readShellVars(files []string, markers ...string) (map[string]string, []string) {
+	vars := map[string]string{}
+	var found []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		mentioned := false
+		for _, line := range strings.Split(string(data), "\n") {
+			for _, marker := range markers {
+				if strings.Contains(line, marker) && !strings.HasPrefix(strings.TrimSpace(line), "#") {
+					mentioned = true
+				}
+			}
+			if match := shellAssignment.FindStringSubmatch(line); match != nil {
+				vars[match[1]] = shellValue(match[2])
+			}
+		}
+		if mentioned {
+			found = append(found, file)
+		}
+	}
+	return vars, found
+}
+
+// shellValue unquotes the value of a shell assignment, dropping a trailing comment
+func shellValue(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	if quote := raw[0]; quote == '"' || quote == '\'' {
+		if end := strings.IndexByte(raw[1:], quote); end != -1 {
+			return raw[1 : end+1]
+		}
+		return raw[1:]
+	}
+	value, _, _ := strings.Cut(raw, " ")
+	return value
+}
+
+var leadingSeconds = regexp.MustCompile(`\d+`)
+
+// secondsSetting turns a count of seconds, such as "10" or ntfy's "-L 10", into a duration string
+func secondsSetting(value string) (string, bool) {
+	seconds, err := strconv.Atoi(leadingSeconds.FindString(value))
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%ds", seconds), true
+}
+
+// importUndistractMe reads LONG_RUNNING_COMMAND_TIMEOUT, LONG_RUNNING_IGNORE_LIST and
+// UDM_PLAY_SOUND from the shell startup files that load undistract-me
+func importUndistractMe(files []string) (*importedConfig, error) {
+	if len(files) == 0 {
+		files = shellRCFiles()
+	}
+	vars, found := readShellVars(files, "undistract-me", "long-running.bash", "LONG_RUNNING_")
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no undistract-me setup found in %s", strings.Join(files, ", "))
+	}
+
+	ic := &importedConfig{Files: found}
+	timeout := "10"
+	if value, ok := vars["LONG_RUNNING_COMMAND_TIMEOUT"]; ok {
+		timeout = value
+	}
+	if duration, ok := secondsSetting(timeout); ok {
+		ic.Settings.General.MinDuration = duration
+	} else {
+		ic.notef("LONG_RUNNING_COMMAND_TIMEOUT=%q is not a number of seconds", timeout)
+	}
+	ic.Settings.Thresholds.Ignore = strings.Fields(vars["LONG_RUNNING_IGNORE_LIST"])
+	if value := vars["UDM_PLAY_SOUND"]; value != "" && value != "0" {
+		ic.Settings.Notification.Sound = true
+	}
+	return ic, nil
+}
+
+// ntfyDefaultIgnore is the ignore list of ntfy's shell integration when AUTO_NTFY_DONE_IGNORE is unset
+var ntfyDefaultIgnore = []string{"emacs", "htop", "info", "less", "mail", "man", "meld", "most", "mutt", "nano", "screen", "ssh", "tail", "tmux", "top", "vi", "vim", "watch"}
+
+// importNtfy reads both tools called ntfy: the shell integration and ntfy.yml backends of the Python
+// ntfy, and the default server and credentials in client.yml of the ntfy.sh CLI
+func importNtfy(files []string) (*importedConfig, error) {
+	var rcFiles, configFiles []string
+	if len(files) == 0 {
+		rcFiles = shellRCFiles()
+		configFiles = append(toolConfigFiles("ntfy", "ntfy.yml"), toolConfigFiles("ntfy", "client.yml")...)
+	}
+	for _, file := range files {
+		if ext := filepath.Ext(file); ext == ".yml" || ext == ".yaml" {
+			configFiles = append(configFiles, file)
+		} else {
+			rcFiles = append(rcFiles, file)
+		}
+	}
+
+	ic := &importedConfig{}
+	vars, found := readShellVars(rcFiles, "ntfy shell-integration", "AUTO_NTFY_DONE_")
+	if len(found) > 0 {
+		ic.Files = append(ic.Files, found...)
+		longerThan := "-L 10"
+		if value, ok := vars["AUTO_NTFY_DONE_LONGER_THAN"]; ok {
+			longerThan = value
+		}
+		if duration, ok := secondsSetting(longerThan); ok {
+			ic.Settings.General.MinDuration = duration
+		}
+		ic.Settings.Thresholds.Ignore = ntfyDefaultIgnore
+		if value, ok := vars["AUTO_NTFY_DONE_IGNORE"]; ok {
+			ic.Settings.Thresholds.Ignore = strings.Fields(value)
+		}
+		if vars["AUTO_NTFY_DONE_UNFOCUSED_ONLY"] != "" {
+			ic.notef("AUTO_NTFY_DONE_UNFOCUSED_ONLY: CmdBell notifies whether or not the terminal has focus")
+		}
+	}
+
+	for _, file := range configFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+		ic.Files = append(ic.Files, file)
+		if filepath.Base(file) == "client.yml" {
+			importNtfyClient(ic, values)
+		} else {
+			importNtfyBackends(ic, values)
+		}
+	}
+
+	if len(ic.Files) == 0 {
+		return nil, fmt.Errorf("no ntfy setup found in %s", strings.Join(append(rcFiles, configFiles...), ", "))
+	}
+	return ic, nil
+}
+
+// ntfyDesktopBackends notify on this machine, which CmdBell's desktop notifications already do
+var ntfyDesktopBackends = map[string]bool{"default": true, "linux": true, "darwin": true, "win32": true, "notifycenter": true}
+
+func importNtfyBackends(ic *importedConfig, values map[string]interface{}) {
+	backends := stringList(values["backends"])
+	if len(backends) == 0 {
+		backends = []string{"default"}
+	}
+	for _, name := range backends {
+		section := stringMap(values[name])
+		backend := name
+		if value := section["backend"]; value != "" {
+			backend = value
+		}
+		switch {
+		case ntfyDesktopBackends[backend]:
+		case backend == "slack_webhook" && section["url"] != "":
+			ic.Settings.Channels = append(ic.Settings.Channels, importedChannel{Name: name, Type: "webhook", URL: section["url"], Format: formatMarkdown})
+		default:
+			ic.notef("ntfy backend %q: no CmdBell channel type, a webhook bridge could forward it", name)
+		}
+	}
+}
+
+func importNtfyClient(ic *importedConfig, values map[string]interface{}) {
+	host := strings.TrimSuffix(fmt.Sprint(valueOr(values["default-host"], "https://ntfy.sh")), "/")
+
+	var topics []string
+	if subscriptions, ok := values["subscribe"].([]interface{}); ok {
+		for _, subscription := range subscriptions {
+			if topic := stringMap(subscription)["topic"]; topic != "" {
+				topics = append(topics, topic)
+			}
+		}
+	}
+	if len(topics) == 0 {
+		ic.notef("ntfy client.yml names no topic; add a channel with type ntfy and url %s/<topic>", host)
+		return
+	}
+	if len(topics) > 1 {
+		ic.notef("ntfy client.yml subscribes to %d topics, only %q was imported", len(topics), topics[0])
+	}
+
+	channel := importedChannel{Name: "ntfy", Type: "ntfy", URL: host + "/" + strings.TrimPrefix(topics[0], host+"/")}
+	switch {
+	case values["default-token"] != nil:
+		channel.Token = fmt.Sprint(values["default-token"])
+	case values["default-user"] != nil:
+		credentials := fmt.Sprintf("%v:%v", values["default-user"], valueOr(values["default-password"], ""))
+		channel.Headers = map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))}
+	}
+	ic.Settings.Channels = append(ic.Settings.Channels, channel)
+}
+
+// notiWebhooks are noti services that post to an incoming webhook, with the key holding its URL
+// and the message format the webhook accepts
+var notiWebhooks = map[string]struct{ key, format string }{
+	"slack":      {"appurl", formatMarkdown},
+	"mattermost": {"incominghookuri", formatMarkdown},
+	"bearychat":  {"incominghookuri", formatShort},
+	"gchat":      {"appurl", formatShort},
+}
+
+// importNoti translates the services configured in noti.yaml
+func importNoti(files []string) (*importedConfig, error) {
+	if len(files) == 0 {
+		files = toolConfigFiles("noti", "noti.yaml")
+	}
+
+	ic := &importedConfig{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+		ic.Files = append(ic.Files, file)
+
+		services := make([]string, 0, len(values))
+		for service := range values {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+		for _, service := range services {
+			importNotiService(ic, strings.ToLower(service), stringMap(values[service]))
+		}
+	}
+
+	if len(ic.Files) == 0 {
+		return nil, fmt.Errorf("no noti config found in %s", strings.Join(files, ", "))
+	}
+	return ic, nil
+}
+
+func importNotiService(ic *importedConfig, service string, section map[string]string) {
+	if webhook, ok := notiWebhooks[service]; ok && section[webhook.key] != "" {
+		ic.Settings.Channels = append(ic.Settings.Channels, importedChannel{Name: service, Type: "webhook", URL: section[webhook.key], Format: webhook.format})
+		return
+	}
+
+	switch service {
+	case "nsuser", "banner":
+		if section["soundname"] != "" || section["soundnamefail"] != "" {
+			ic.Settings.Notification.Sound = true
+		}
+	case "ntfy":
+		if section["topic"] == "" {
+			ic.notef("noti ntfy service names no topic")
+			return
+		}
+		server := strings.TrimSuffix(valueOr(section["server"], "https://ntfy.sh").(string), "/")
+		ic.Settings.Channels = append(ic.Settings.Channels, importedChannel{Name: "ntfy", Type: "ntfy", URL: server + "/" + section["topic"], Token: section["token"]})
+	case "say", "espeak", "speechsynthesizer":
+		ic.notef("noti %s: spoken notifications have no CmdBell equivalent", service)
+	default:
+		ic.notef("noti %s: no CmdBell channel type, a webhook bridge could forward it", service)
+	}
+}
+
+// stringMap flattens a YAML mapping's scalar values, lowercasing keys since tools differ in case
+func stringMap(value interface{}) map[string]string {
+	result := map[string]string{}
+	values, _ := value.(map[string]interface{})
+	for key, v := range values {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}, nil:
+		default:
+			result[strings.ToLower(key)] = fmt.Sprint(v)
+		}
+	}
+	return result
+}
+
+func stringList(value interface{}) []string {
+	values, _ := value.([]interface{})
+	var result []string
+	for _, v := range values {
+		result = append(result, fmt.Sprint(v))
+	}
+	return result
+}
+
+func valueOr(value, fallback interface{}) interface{} {
+	if value == nil || value == "" {
+		return fallback
+	}
+	return value
+}
+
+// mergeImported adds imported settings to a config document: scalars are replaced, mappings are
+// merged and list entries are appended unless the list already has them, by name for channels
+func mergeImported(dst, src *yaml.Node) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			if existing := mappingValue(dst, key.Value); existing != nil {
+				mergeImported(existing, value)
+			} else {
+				dst.Content = append(dst.Content, key, value)
+			}
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		for _, item := range src.Content {
+			if !containsItem(dst, item) {
+				dst.Content = append(dst.Content, item)
+			}
+		}
+	default:
+		*dst = *src
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func containsItem(list, item *yaml.Node) bool {
+	for _, existing := range list.Content {
+		if item.Kind == yaml.ScalarNode && existing.Value == item.Value {
+			return true
+		}
+		if item.Kind == yaml.MappingNode && existing.Kind == yaml.MappingNode {
+			if name := mappingValue(item, "name"); name != nil {
+				if other := mappingValue(existing, "name"); other != nil && other.Value == name.Value {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// writeImported merges the imported settings into the config file, backing it up first the same
+// way shell startup files are, so `cmdbell restore-rc` can undo the import
+func writeImported(ic *importedConfig) (string, error) {
+	if err := ensureConfigDir(); err != nil {
+		return "", err
+	}
+	configPath, err := getConfigPath()
+	if err != nil {
+		return "", err
+	}
+	format := configFormat(configPath)
+
+	var root yaml.Node
+	if data, err := os.ReadFile(configPath); err == nil {
+		data, err = configToYAML(data, format)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s: %v", configPath, err)
+		}
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %v", configPath, err)
+		}
+	}
+
+	var imported yaml.Node
+	if err := imported.Encode(ic.Settings); err != nil {
+		return "", err
+	}
+	if len(root.Content) == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	mergeImported(root.Content[0], &imported)
+
+	data, err := yaml.Marshal(&root)
+	if err != nil {
+		return "", err
+	}
+	data, err = configFromYAML(data, format)
+	if err != nil {
+		return "", err
+	}
+
+	integration, err := NewShellIntegration()
+	if err != nil {
+		return "", err
+	}
+	return configPath, auditedConfigWrite("config.import", func() error {
+		return integration.writeShellConfig(configPath, data)
+	})
+}
+
+// handleImportCommand handles `cmdbell import --from noti|ntfy|undistract-me [--file path]... [--write]`
+func handleImportCommand() {
+	var from string
+	var files []string
+	write := false
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--from" && i+1 < len(args):
+			i++
+			from = args[i]
+		case strings.HasPrefix(args[i], "--from="):
+			from = strings.TrimPrefix(args[i], "--from=")
+		case args[i] == "--file" && i+1 < len(args):
+			i++
+			files = append(files, expandHome(args[i]))
+		case args[i] == "--write":
+			write = true
+		default:
+			from = ""
+			i = len(args)
+		}
+	}
+
+	importer, ok := importSources[from]
+	if !ok {
+		fmt.Println("Usage: cmdbell import --from noti|ntfy|undistract-me [--file <path>]... [--write]")
+		fmt.Println("Prints the CmdBell config translated from the tool's settings; --write merges it into your config")
+		os.Exit(1)
+	}
+
+	ic, err := importer(files)
+	if err != nil {
+		errorf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	if write {
+		if ic.empty() {
+			fmt.Printf("Nothing to import from %s\n", from)
+		} else {
+			configPath, err := writeImported(ic)
+			if err != nil {
+				errorf("❌ Failed to write config: %v\n", err)
+				os.Exit(1)
+			}
+			statusf("✅ Imported %s settings into %s\n", from, configPath)
+			statusf("💡 Run 'cmdbell --daemon restart' to apply them; 'cmdbell restore-rc' lists the backup taken first\n")
+		}
+		printImportNotes(ic)
+		return
+	}
+
+	fmt.Printf("# Imported from %s (%s)\n", from, strings.Join(ic.Files, ", "))
+	if ic.empty() {
+		fmt.Println("# Nothing to import")
+	} else {
+		data, err := yaml.Marshal(ic.Settings)
+		if err != nil {
+			errorf("❌ Failed to encode config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+	}
+	printImportNotes(ic)
+}
+
+func printImportNotes(ic *importedConfig) {
+	if len(ic.Notes) == 0 {
+		return
+	}
+	fmt.Println("# Not imported:")
+	for _, note := range ic.Notes {
+		fmt.Printf("#   - %s\n", note)
+	}
+}
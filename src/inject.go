@@ -0,0 +1,260 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 'cmdbell inject' puts the notify-only client into a running container and hooks the container's
+// interactive bash and zsh shells to report through it, so dev containers notify without image
+// changes. Everything lives in /etc/cmdbell plus one source line per system-wide shell rc file.
+const (
+	injectDir        = "/etc/cmdbell"
+	injectClientPath = injectDir + "/cmdbell-notify"
+	injectHookPath   = injectDir + "/hook.sh"
+)
+
+// injectRCFiles are the system-wide rc files interactive non-login shells read across distributions
+var injectRCFiles = []string{"/etc/bash.bashrc", "/etc/bashrc", "/etc/bash/bashrc", "/etc/zsh/zshrc", "/etc/zshrc"}
+
+func handleInjectCommand() {
+	args := os.Args[2:]
+	clientPath, token, remove := "", "", false
+	var containers []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--client":
+			if i+1 < len(args) {
+				i++
+				clientPath = args[i]
+			}
+		case "--token":
+			if i+1 < len(args) {
+				i++
+				token = args[i]
+			}
+		case "--remove":
+			remove = true
+		default:
+			containers = append(containers, args[i])
+		}
+	}
+	if len(containers) != 1 {
+		fmt.Println("Usage: cmdbell inject [--client <cmdbell-notify>] [--token <token>] <container>")
+		fmt.Println("       cmdbell inject --remove <container>")
+		os.Exit(1)
+	}
+	container := containers[0]
+
+	if remove {
+		if err := removeInjection(container); err != nil {
+			fmt.Printf("❌ Failed to remove CmdBell from %s: %v\n", container, err)
+			os.Exit(1)
+		}
+		fmt.Printf("🧹 Removed CmdBell from %s\n", container)
+		return
+	}
+
+	if err := injectContainer(container, clientPath, token); err != nil {
+		fmt.Printf("❌ Failed to inject CmdBell into %s: %v\n", container, err)
+		os.Exit(1)
+	}
+}
+
+func injectContainer(container, clientPath, token string) error {
+	arch, err := containerArch(container)
+	if err != nil {
+		return err
+	}
+
+	if clientPath == "" {
+		if clientPath, err = findNotifyClient(); err != nil {
+			return err
+		}
+	}
+	if err := checkClientBinary(clientPath, arch); err != nil {
+		return err
+	}
+
+	daemonURL, err := containerDaemonURL(container)
+	if err != nil {
+		return err
+	}
+
+	if _, err := containerShell(container, "mkdir -p "+injectDir, ""); err != nil {
+		return err
+	}
+	if output, err := exec.Command("docker", "cp", clientPath, container+":"+injectClientPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("docker cp: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	if _, err := containerShell(container, "chmod 755 "+injectClientPath+" && cat > "+injectHookPath, injectHook(daemonURL, token)); err != nil {
+		return err
+	}
+
+	wired, err := containerShell(container, injectWireScript(), "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Injected cmdbell-notify into %s, reporting to %s\n", container, daemonURL)
+	if wired = strings.TrimSpace(wired); wired == "" {
+		fmt.Printf("⚠️  No shell rc file found; source %s from the shell you use\n", injectHookPath)
+	} else {
+		fmt.Printf("🔗 Hooked %s\n", strings.ReplaceAll(wired, "\n", ", "))
+	}
+	fmt.Println("💡 New shells in the container notify when commands finish; an image rebuild removes this")
+	return nil
+}
+
+func removeInjection(container string) error {
+	script := fmt.Sprintf(`for rc in %s; do
+    [ -f "$rc" ] && sed -i '\#%s#d' "$rc"
+done
+rm -rf %s`, strings.Join(injectRCFiles, " "), injectHookPath, injectDir)
+	_, err := containerShell(container, script, "")
+	return err
+}
+
+// injectWireScript sources the hook from every rc file the image has, once, printing the files
+func injectWireScript() string {
+	sourceLine := fmt.Sprintf("[ -f %s ] && . %s # CmdBell", injectHookPath, injectHookPath)
+	return fmt.Sprintf(`for rc in %s; do
+    [ -f "$rc" ] || continue
+    grep -qF '%s' "$rc" || printf '\n%%s\n' '%s' >> "$rc"
+    echo "$rc"
+done`, strings.Join(injectRCFiles, " "), injectHookPath, sourceLine)
+}
+
+// containerShell runs a script as root inside the container, feeding it stdin
+func containerShell(container, script, stdin string) (string, error) {
+	cmd := exec.Command("docker", "exec", "-i", "-u", "0", container, "sh", "-c", script)
+	cmd.Stdin = strings.NewReader(stdin)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker exec: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// containerArch maps the container's uname -m onto the ELF machine the client must be built for
+func containerArch(container string) (elf.Machine, error) {
+	output, err := containerShell(container, "uname -m", "")
+	if err != nil {
+		return 0, err
+	}
+
+	switch machine := strings.TrimSpace(output); machine {
+	case "x86_64", "amd64":
+		return elf.EM_X86_64, nil
+	case "aarch64", "arm64":
+		return elf.EM_AARCH64, nil
+	case "armv7l", "armv6l":
+		return elf.EM_ARM, nil
+	default:
+		return 0, fmt.Errorf("unsupported container architecture %s", machine)
+	}
+}
+
+// findNotifyClient looks for cmdbell-notify next to this binary, then on PATH
+func findNotifyClient() (string, error) {
+	if self, err := os.Executable(); err == nil {
+		candidate := filepath.Join(filepath.Dir(self), "cmdbell-notify")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	if path, err := exec.LookPath("cmdbell-notify"); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("cmdbell-notify not found next to cmdbell or on PATH; build it with " +
+		"CGO_ENABLED=0 GOOS=linux go build ./cmd/cmdbell-notify and pass --client")
+}
+
+// checkClientBinary makes sure the client is a Linux executable the container can run
+func checkClientBinary(path string, arch elf.Machine) error {
+	file, err := elf.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s is not a Linux executable, pass --client with a GOOS=linux build: %v", path, err)
+	}
+	defer file.Close()
+
+	if file.Machine != arch {
+		return fmt.Errorf("%s is built for %s but the container runs %s", path, file.Machine, arch)
+	}
+	return nil
+}
+
+// containerDaemonURL finds how the container reaches this host's daemon: host.docker.internal
+// where Docker provides it, otherwise the gateway of the container's network
+func containerDaemonURL(container string) (string, error) {
+	port := daemonPort()
+	if _, err := containerShell(container, "getent hosts host.docker.internal || nslookup host.docker.internal", ""); err == nil {
+		return fmt.Sprintf("http://host.docker.internal:%d", port), nil
+	}
+
+	output, err := exec.Command("docker", "inspect", "-f", "{{range .NetworkSettings.Networks}}{{.Gateway}} {{end}}", container).Output()
+	if err != nil {
+		return "", fmt.Errorf("docker inspect: %v", err)
+	}
+	gateways := strings.Fields(string(output))
+	if len(gateways) == 0 {
+		return "", fmt.Errorf("cannot tell how %s reaches the host: no host.docker.internal and no network gateway", container)
+	}
+	return fmt.Sprintf("http://%s:%d", gateways[0], port), nil
+}
+
+// injectHook is the shell hook written into the container. It reports through cmdbell-notify,
+// so the image needs neither curl nor cmdbell.
+func injectHook(daemonURL, token string) string {
+	tokenLine := ""
+	if token != "" {
+		tokenLine = "export CMDBELL_TOKEN=" + shellQuote(token) + "\n"
+	}
+
+	return `# CmdBell hook injected by 'cmdbell inject'; remove with 'cmdbell inject --remove <container>'
+export CMDBELL_URL="${CMDBELL_URL:-` + daemonURL + `}"
+` + tokenLine + "\n" + containerHookFunctions()
+}
+
+// containerHookFunctions times interactive bash and zsh commands and reports them through the
+// client at injectClientPath, which reads CMDBELL_URL and CMDBELL_TOKEN
+func containerHookFunctions() string {
+	return `_cmdbell_preexec() {
+    _cmdbell_armed=
+    _cmdbell_start=$(date +%s)
+    _cmdbell_command="$1"
+}
+
+_cmdbell_precmd() {
+    local exit_code=$?
+    if [ -n "$_cmdbell_start" ]; then
+        local duration=$(( $(date +%s) - _cmdbell_start ))
+        if [ "$duration" -ge ` + strconv.Itoa(int(lowestThreshold().Seconds())) + ` ]; then
+            (` + injectClientPath + ` --retries 1 report "$_cmdbell_command" "$duration" "$exit_code" >/dev/null 2>&1 &)
+        fi
+        unset _cmdbell_start _cmdbell_command
+    fi
+    return $exit_code
+}
+
+case "$-" in
+    *i*)
+        if [ -n "$ZSH_VERSION" ]; then
+            autoload -Uz add-zsh-hook
+            add-zsh-hook preexec _cmdbell_preexec
+            add-zsh-hook precmd _cmdbell_precmd
+        elif [ -n "$BASH_VERSION" ]; then
+            # The DEBUG trap also fires for the prompt's own commands, so it only times the first
+            # command after the prompt has finished drawing
+            trap '[ -n "$_cmdbell_armed" ] && _cmdbell_preexec "$BASH_COMMAND"' DEBUG
+            PROMPT_COMMAND="_cmdbell_precmd${PROMPT_COMMAND:+; $PROMPT_COMMAND}; _cmdbell_armed=1"
+        fi
+        ;;
+esac
+`
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// jobBudget warns once when a job runs past its expected duration, without waiting for it to finish
+type jobBudget struct {
+	budget   time.Duration
+	timer    *time.Timer
+	exceeded atomic.Bool
+}
+
+// startJobBudget calls warn if the job is still running after budget; nil when there is no budget
+func startJobBudget(budget time.Duration, warn func()) *jobBudget {
+	if budget <= 0 {
+		return nil
+	}
+	b := &jobBudget{budget: budget}
+	b.timer = time.AfterFunc(budget, func() {
+		b.exceeded.Store(true)
+		warn()
+	})
+	return b
+}
+
+// Stop disarms the budget once the job has finished and reports whether it ran over
+func (b *jobBudget) Stop() bool {
+	if b == nil {
+		return false
+	}
+	b.timer.Stop()
+	return b.exceeded.Load()
+}
+
+// budgetNotification is the early warning for a job still running past its budget, such as
+// "Scheduled job 'nightly backup' is exceeding its 2h budget". It carries replaceKey so the
+// job's final notification takes its place on desktops that can.
+func budgetNotification(title, what, source, command, replaceKey string, budget time.Duration, started time.Time) *Notification {
+	return &Notification{
+		Title:      title,
+		Message:    fmt.Sprintf("%s is exceeding its %s budget and still running", what, formatDuration(budget)),
+		Icon:       "⏳",
+		Source:     source,
+		Command:    command,
+		Duration:   time.Since(started),
+		StartTime:  started,
+		ReplaceKey: replaceKey,
+		Running:    true,
+		TimedOut:   true,
+	}
+}
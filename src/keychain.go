@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Secrets such as webhook URLs and tokens can live in the OS keychain instead of config.yaml;
+// the config then names them, e.g. `token: keychain:slack-token`. They are stored under the
+// cmdbell service in the macOS keychain, the Secret Service (secret-tool) or the Windows
+// Credential Manager, and managed with 'cmdbell secret'.
+const (
+	secretRefPrefix = "keychain:"
+	secretService   = "cmdbell"
+)
+
+var errSecretNotFound = errors.New("not found in the keychain")
+
+// resolvedSecrets caches keychain lookups, which start a process each, for pollers and channels
+var resolvedSecrets sync.Map
+
+// resolveSecret returns a config value, looking it up in the keychain when it is a keychain: reference
+func resolveSecret(value string) (string, error) {
+	name, ok := strings.CutPrefix(value, secretRefPrefix)
+	if !ok {
+		return value, nil
+	}
+	if cached, ok := resolvedSecrets.Load(name); ok {
+		return cached.(string), nil
+	}
+
+	secret, err := keychainGet(secretService, name)
+	if err != nil {
+		return "", fmt.Errorf("secret %q: %v", name, err)
+	}
+	resolvedSecrets.Store(name, secret)
+	return secret, nil
+}
+
+// keychainGet reads a secret stored under service and account
+func keychainGet(service, account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	case "windows":
+		cmd = powershellVault(`try { $c = $v.Retrieve($env:CMDBELL_SERVICE, $env:CMDBELL_ACCOUNT) } catch { exit 44 }
+$c.RetrievePassword(); [Console]::Out.Write($c.Password)`, service, account)
+	default:
+		return "", fmt.Errorf("no keychain support on %s", runtime.GOOS)
+	}
+
+	output, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("keychain tool not found: %v", err)
+	}
+	// Each tool fails, or for secret-tool prints nothing, when there is no such item
+	secret := strings.TrimRight(string(output), "\r\n")
+	if err != nil || secret == "" {
+		return "", errSecretNotFound
+	}
+	return secret, nil
+}
+
+// keychainSet stores a secret under service and account, replacing any earlier value
+func keychainSet(service, account, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// security only takes the password as an argument, which any user can read in ps, so the
+		// command goes to its interactive mode on stdin instead; -U updates an existing item
+		if strings.ContainsAny(secret, "\r\n") {
+			return errors.New("secret spans several lines")
+		}
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			securityQuote(service), securityQuote(account), securityQuote(secret)))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label="+service+" "+account, "service", service, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	case "windows":
+		cmd = powershellVault(`try { $v.Remove($v.Retrieve($env:CMDBELL_SERVICE, $env:CMDBELL_ACCOUNT)) } catch {}
+$v.Add((New-Object Windows.Security.Credentials.PasswordCredential($env:CMDBELL_SERVICE, $env:CMDBELL_ACCOUNT, [Console]::In.ReadToEnd())))`, service, account)
+		cmd.Stdin = strings.NewReader(secret)
+	default:
+		return fmt.Errorf("no keychain support on %s", runtime.GOOS)
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(string(output)))
+	}
+	resolvedSecrets.Delete(account)
+	// security -i exits 0 when a command in it fails, so read the item back
+	if runtime.GOOS == "darwin" {
+		if stored, err := keychainGet(service, account); err != nil || stored != secret {
+			return fmt.Errorf("security did not store the secret: %s", strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// securityQuote quotes an argument for a command line given to `security -i`
+func securityQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// keychainDelete removes a secret stored under service and account
+func keychainDelete(service, account string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", service, "-a", account)
+	case "linux":
+		cmd = exec.Command("secret-tool", "clear", "service", service, "account", account)
+	case "windows":
+		cmd = powershellVault(`$v.Remove($v.Retrieve($env:CMDBELL_SERVICE, $env:CMDBELL_ACCOUNT))`, service, account)
+	default:
+		return fmt.Errorf("no keychain support on %s", runtime.GOOS)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(string(output)))
+	}
+	resolvedSecrets.Delete(account)
+	return nil
+}
+
+// powershellVault runs a script against the Windows PasswordVault, which the Credential Manager
+// shows under Web Credentials; names are passed in the environment rather than spliced into code
+func powershellVault(script, service, account string) *exec.Cmd {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+		`[void][Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime]
+$v = New-Object Windows.Security.Credentials.PasswordVault
+`+script)
+	cmd.Env = append(os.Environ(), "CMDBELL_SERVICE="+service, "CMDBELL_ACCOUNT="+account)
+	return cmd
+}
+
+// handleSecretCommand manages the secrets config values refer to as keychain:<name>
+func handleSecretCommand() {
+	if len(os.Args) != 4 || (os.Args[2] != "set" && os.Args[2] != "delete" && os.Args[2] != "check") {
+		fmt.Println("Usage: cmdbell secret set|delete|check <name>")
+		fmt.Println("Then refer to it in config.yaml, e.g. token: keychain:<name>")
+		os.Exit(1)
+	}
+
+	name := os.Args[3]
+	switch os.Args[2] {
+	case "set":
+		secret, err := readSecret(fmt.Sprintf("Value for %s: ", name))
+		if err != nil || secret == "" {
+			fmt.Println("No value given")
+			os.Exit(1)
+		}
+		if err := keychainSet(secretService, name, secret); err != nil {
+			fmt.Printf("Failed to store secret: %v\n", err)
+			os.Exit(1)
+		}
+		recordConfigChange(ConfigChange{Actor: cliActor(), Action: "secret.set", Target: "keychain", Summary: fmt.Sprintf("stored %s", name)})
+		fmt.Printf("🔑 Stored %s in the keychain; use it in config.yaml as %s%s\n", name, secretRefPrefix, name)
+
+	case "delete":
+		if err := keychainDelete(secretService, name); err != nil {
+			fmt.Printf("Failed to delete secret: %v\n", err)
+			os.Exit(1)
+		}
+		recordConfigChange(ConfigChange{Actor: cliActor(), Action: "secret.delete", Target: "keychain", Summary: fmt.Sprintf("deleted %s", name)})
+		fmt.Printf("🗑️  Deleted %s from the keychain\n", name)
+
+	case "check":
+		if _, err := keychainGet(secretService, name); err != nil {
+			fmt.Printf("❌ %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ %s is in the keychain\n", name)
+	}
+}
+
+// readSecret reads one line from stdin, without echoing it when stdin is a terminal
+func readSecret(prompt string) (string, error) {
+	info, err := os.Stdin.Stat()
+	terminal := err == nil && info.Mode()&os.ModeCharDevice != 0
+	if terminal {
+		fmt.Print(prompt)
+		if runtime.GOOS != "windows" {
+			stty := func(arg string) {
+				cmd := exec.Command("stty", arg)
+				cmd.Stdin = os.Stdin
+				cmd.Run()
+			}
+			stty("-echo")
+			defer func() {
+				stty("echo")
+				fmt.Println()
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Deep links handled by 'cmdbell open', so launchers can act on history entries:
+//
+//	cmdbell://rerun/<id>  runs the entry's command again in $SHELL from the home directory once
+//	                      confirmed, notifying like any wrapped command; only for commands this
+//	                      host's own wrapper or shell hooks reported
+//	cmdbell://ack/<id>    acknowledges the entry, clearing it from the unseen count
+//	cmdbell://open/<id>   opens the entry's URL, such as its saved output or pull request
+//	cmdbell://focus/<id>  brings the terminal tab or pane the entry's command ran in to the front
+//	cmdbell://action/<name>/<id>
+//	                      has the daemon run the configured action of that name for the entry
+//
+// 'cmdbell url-scheme install' registers the scheme, so the links also work from browsers,
+// Shortcuts and notification buttons.
+const deepLinkScheme = "cmdbell://"
+
+func deepLink(action, id string) string {
+	return deepLinkScheme + action + "/" + id
+}
+
+// alfredScriptFilter is the JSON an Alfred Script Filter reads: Enter opens Arg, ⌘-Enter the ack
+// link and ⌥-Enter, where there is one, the focus link
+type alfredScriptFilter struct {
+	Items []alfredItem `json:"items"`
+}
+
+type alfredItem struct {
+	UID      string               `json:"uid"`
+	Title    string               `json:"title"`
+	Subtitle string               `json:"subtitle"`
+	Arg      string               `json:"arg"`
+	Valid    bool                 `json:"valid"`
+	Mods     map[string]alfredMod `json:"mods,omitempty"`
+	Text     alfredText           `json:"text"`
+}
+
+type alfredMod struct {
+	Arg      string `json:"arg"`
+	Subtitle string `json:"subtitle"`
+	Valid    bool   `json:"valid"`
+}
+
+type alfredText struct {
+	Copy      string `json:"copy,omitempty"`
+	LargeType string `json:"largetype"`
+}
+
+// raycastItem carries the props of a Raycast List.Item plus the deep links for its actions
+type raycastItem struct {
+	ID           string             `json:"id"`
+	Title        string             `json:"title"`
+	Subtitle     string             `json:"subtitle"`
+	Icon         string             `json:"icon"`
+	Accessories  []raycastAccessory `json:"accessories"`
+	Command      string             `json:"command,omitempty"`
+	RerunURL     string             `json:"rerun_url,omitempty"`
+	FocusURL     string             `json:"focus_url,omitempty"`
+	AckURL       string             `json:"ack_url"`
+	Acknowledged bool               `json:"acknowledged"`
+}
+
+type raycastAccessory struct {
+	Text string `json:"text"`
+}
+
+// launcherOutput shapes history entries for a launcher, "alfred" or "raycast"
+func launcherOutput(format string, entries []HistoryEntry) (interface{}, error) {
+	acks := loadAcks()
+
+	switch format {
+	case "alfred":
+		filter := alfredScriptFilter{Items: []alfredItem{}}
+		for _, entry := range entries {
+			_, acknowledged := acks[entry.ID]
+			item := alfredItem{
+				UID:      entry.ID,
+				Title:    historyIcon(entry) + " " + entry.Message,
+				Subtitle: launcherSubtitle(entry, acknowledged),
+				Arg:      deepLink("ack", entry.ID),
+				Valid:    true,
+				Mods: map[string]alfredMod{
+					"cmd": {Arg: deepLink("ack", entry.ID), Subtitle: "Acknowledge", Valid: true},
+				},
+				Text: alfredText{Copy: entry.Command, LargeType: entry.Message},
+			}
+			if rerunnable(entry) == nil {
+				item.Arg = deepLink("rerun", entry.ID)
+				item.Subtitle += " · ↵ to re-run"
+			}
+			if focusable(entry.Host, entry.Terminal) == nil {
+				item.Mods["alt"] = alfredMod{Arg: deepLink("focus", entry.ID), Subtitle: "Go to " + entry.Terminal.String(), Valid: true}
+			}
+			filter.Items = append(filter.Items, item)
+		}
+		return filter, nil
+
+	case "raycast":
+		items := []raycastItem{}
+		for _, entry := range entries {
+			_, acknowledged := acks[entry.ID]
+			item := raycastItem{
+				ID:           entry.ID,
+				Title:        entry.Message,
+				Subtitle:     entry.Host,
+				Icon:         historyIcon(entry),
+				Accessories:  []raycastAccessory{{Text: entry.Source}, {Text: entry.Time.Local().Format("Jan 2 15:04")}},
+				Command:      entry.Command,
+				AckURL:       deepLink("ack", entry.ID),
+				Acknowledged: acknowledged,
+			}
+			if rerunnable(entry) == nil {
+				item.RerunURL = deepLink("rerun", entry.ID)
+			}
+			if focusable(entry.Host, entry.Terminal) == nil {
+				item.FocusURL = deepLink("focus", entry.ID)
+			}
+			items = append(items, item)
+		}
+		return items, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported format %q (expected alfred or raycast)", format)
+	}
+}
+
+func launcherSubtitle(entry HistoryEntry, acknowledged bool) string {
+	subtitle := fmt.Sprintf("%s · %s · %s", entry.Host, entry.Source, entry.Time.Local().Format("Jan 2 15:04"))
+	if acknowledged {
+		subtitle += " · acknowledged"
+	}
+	return subtitle
+}
+
+// rerunnable reports why an entry's command cannot be run again here, or nil when it can
+func rerunnable(entry HistoryEntry) error {
+	if entry.Command == "" {
+		return errors.New("the entry has no command")
+	}
+
+	if entry.OriginID != "" {
+		return errors.New("the entry was merged from another machine's history")
+	}
+	hostname, _ := os.Hostname()
+	if entry.Host != hostname {
+		return fmt.Errorf("the command ran on %s", entry.Host)
+	}
+
+	// Anyone who can reach the API can report a command, so only run what this machine's own
+	// wrapper or shell hook recorded
+	if !entry.Local {
+		return fmt.Errorf("%s events not reported by this machine's wrapper or shell hooks cannot be re-run", entry.Source)
+	}
+
+	if strings.Contains(entry.Command, "***") || strings.Contains(entry.Command, "sha256:") {
+		return errors.New("secrets in the command were redacted in history")
+	}
+	return nil
+}
+
+// handleOpenCommand follows a cmdbell:// deep link
+func handleOpenCommand() {
+	// --yes is for callers that had the rerun confirmed already, such as chat buttons
+	args := os.Args[2:]
+	confirmed := len(args) == 2 && args[0] == "--yes"
+	if confirmed {
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		fmt.Println("Usage: cmdbell open [--yes] cmdbell://rerun/<id> | cmdbell://ack/<id> | cmdbell://open/<id> | cmdbell://focus/<id> | cmdbell://action/<name>/<id>")
+		os.Exit(1)
+	}
+	link := args[0]
+
+	rest, ok := strings.CutPrefix(link, deepLinkScheme)
+	action, id, _ := strings.Cut(strings.TrimSuffix(rest, "/"), "/")
+	if !ok || id == "" {
+		fmt.Printf("Invalid link %q, expected cmdbell://<rerun|ack|open|focus>/<id> or cmdbell://action/<name>/<id>\n", link)
+		os.Exit(1)
+	}
+
+	switch action {
+	case "ack":
+		if _, err := acknowledgeEvents([]string{id}); err != nil {
+			fmt.Printf("Failed to acknowledge %s: %v\n", id, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Acknowledged %s\n", id)
+
+	case "rerun":
+		entry, err := findHistoryEntry(id)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		if err := rerunnable(entry); err != nil {
+			fmt.Printf("❌ Cannot re-run %s: %v\n", id, err)
+			os.Exit(1)
+		}
+		if !confirmed {
+			if err := confirmRerun(entry.Command); err != nil {
+				fmt.Printf("❌ Not re-running %s: %v\n", id, err)
+				os.Exit(1)
+			}
+		}
+		home, _ := os.UserHomeDir()
+		os.Exit(rerunCommand(entry.Command, home))
+
+	case "open":
+		entry, err := findHistoryEntry(id)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		if entry.URL == "" {
+			fmt.Printf("❌ %s has no URL to open\n", id)
+			os.Exit(1)
+		}
+		if err := openTarget(entry.URL); err != nil {
+			fmt.Printf("❌ Failed to open %s: %v\n", entry.URL, err)
+			os.Exit(1)
+		}
+
+	case "focus":
+		entry, err := findHistoryEntry(id)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := runAction(ctx, ActionConfig{Name: "focus", Focus: true}, entry); err != nil {
+			fmt.Printf("❌ Cannot focus the terminal of %s: %v\n", id, err)
+			os.Exit(1)
+		}
+
+	case "action":
+		name, eventID, _ := strings.Cut(id, "/")
+		if eventID == "" {
+			fmt.Printf("Invalid link %q, expected cmdbell://action/<name>/<id>\n", link)
+			os.Exit(1)
+		}
+		if err := followActionLink(name, eventID); err != nil {
+			fmt.Printf("❌ Action '%s' failed: %v\n", name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("⚡ Running action '%s' for %s\n", name, eventID)
+
+	default:
+		fmt.Printf("Unknown link action %q, expected rerun, ack, open, focus or action\n", action)
+		os.Exit(1)
+	}
+}
+
+// confirmRerun shows the command a link would run and asks whether to run it, in the terminal
+// when there is one and otherwise in a dialog, since links open from browsers and launchers
+func confirmRerun(command string) error {
+	question := "Re-run this command?\n\n" + command
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		fmt.Printf("%s\n\nRun it? [y/N]: ", question)
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer := strings.ToLower(strings.TrimSpace(line)); answer != "y" && answer != "yes" {
+			return errors.New("cancelled")
+		}
+		return nil
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf(`display dialog "%s" with title "CmdBell" buttons {"Cancel", "Run"} default button "Cancel" cancel button "Cancel"`, escapeAppleScript(question)))
+	case "windows":
+		// The command goes through the environment, so nothing in it is parsed as PowerShell
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+			`Add-Type -AssemblyName System.Windows.Forms; if ([System.Windows.Forms.MessageBox]::Show($env:CMDBELL_QUESTION, 'CmdBell', 'OKCancel', 'Question', 'Button2') -ne 'OK') { exit 1 }`)
+		cmd.Env = append(os.Environ(), "CMDBELL_QUESTION="+question)
+	default:
+		if path, err := exec.LookPath("zenity"); err == nil {
+			cmd = exec.Command(path, "--question", "--no-markup", "--title", "CmdBell", "--ok-label", "Run", "--cancel-label", "Cancel", "--text", question)
+		} else if path, err := exec.LookPath("kdialog"); err == nil {
+			cmd = exec.Command(path, "--title", "CmdBell", "--warningcontinuecancel", question)
+		} else {
+			return errors.New("no terminal or dialog (zenity or kdialog) to confirm it in")
+		}
+	}
+	if err := cmd.Run(); err != nil {
+		return errors.New("cancelled")
+	}
+	return nil
+}
+
+func findHistoryEntry(id string) (HistoryEntry, error) {
+	store := getHistoryStore()
+	if store == nil {
+		return HistoryEntry{}, errors.New("history is disabled (history.enabled: false)")
+	}
+
+	entries, err := store.Query(HistoryFilter{})
+	if err != nil {
+		return HistoryEntry{}, fmt.Errorf("failed to read history: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return HistoryEntry{}, fmt.Errorf("no history entry %s", id)
+}
+
+// rerunnableDetached reports why rerunDetached cannot run an entry's command, or nil when it
+// can. A system mode daemon runs as root, so it only runs commands as the user who ran them.
+func rerunnableDetached(entry HistoryEntry) error {
+	if err := rerunnable(entry); err != nil {
+		return err
+	}
+	if !systemMode() {
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		return errors.New("system mode only re-runs commands on Linux, with runuser")
+	}
+	if entry.User == "" {
+		return errors.New("system mode only re-runs commands as the user who ran them, and the entry has none")
+	}
+	if _, err := user.Lookup(entry.User); err != nil {
+		return fmt.Errorf("unknown user %s: %v", entry.User, err)
+	}
+	return nil
+}
+
+// rerunDetached runs an entry's command again the way cmdbell://rerun links do, in a process of
+// its own, for remote controls such as Slack buttons, which confirm the rerun themselves. done
+// is called once it finishes.
+func rerunDetached(entry HistoryEntry, done func(took time.Duration, err error)) error {
+	if err := rerunnableDetached(entry); err != nil {
+		return fmt.Errorf("cannot re-run %s: %v", entry.ID, err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot find the cmdbell binary: %v", err)
+	}
+
+	cmd := exec.Command(executable, "open", "--yes", deepLink("rerun", entry.ID))
+	if systemMode() {
+		// The entry is in root's history, so the user's login shell runs the command line itself
+		cmd = exec.Command("runuser", "-l", entry.User, "-c", shellQuote(executable)+" -c "+shellQuote(entry.Command))
+	}
+	startTime := time.Now()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start the rerun: %v", err)
+	}
+	go func() {
+		err := cmd.Wait()
+		done(time.Since(startTime), err)
+	}()
+	return nil
+}
+
+// rerunCommand runs a command line through the user's shell from dir, or the current directory
+// when empty. Launchers pass the home directory, since they start from / and history does not
+// record the working directory.
+func rerunCommand(commandLine, dir string) int {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	fmt.Printf("Re-running: %s\n", commandLine)
+
+	startTime := time.Now()
+	cmd := exec.Command(shell, "-c", commandLine)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	duration := time.Since(startTime)
+
+	if shouldNotifyCommand(commandLine, duration) {
+		sendCommandNotification(commandLine, "", startTime, duration, err == nil)
+		waitForDeliveries()
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	default:
+		fmt.Printf("Failed to run command: %v\n", err)
+		return 1
+	}
+}
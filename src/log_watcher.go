@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLogLineLength keeps notifications readable when a matching line is a stack trace or JSON blob
+const maxLogLineLength = 200
+
+type logWatchRule struct {
+	config   LogWatchRule
+	pattern  *regexp.Regexp
+	cooldown time.Duration
+}
+
+func (r *logWatchRule) matchesContainer(name string) bool {
+	if r.config.Container == "" {
+		return true
+	}
+	matched, _ := filepath.Match(r.config.Container, name)
+	return matched
+}
+
+// LogWatcher follows the logs of running containers and notifies when a line matches a rule.
+// Containers are discovered by polling `docker ps`, so restarted containers are picked up again.
+type LogWatcher struct {
+	rules    []*logWatchRule
+	interval time.Duration
+	started  time.Time
+
+	mu        sync.Mutex
+	following map[string]bool
+	since     map[string]time.Time // where to resume each container's logs after it stops
+	lastFired map[string]time.Time // by rule and container, for cooldowns
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewLogWatcher(interval string, rules []LogWatchRule) (*LogWatcher, error) {
+	pollInterval, err := time.ParseDuration(interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log_watch interval: %v", err)
+	}
+	if pollInterval <= 0 {
+		return nil, fmt.Errorf("log_watch interval must be positive")
+	}
+
+	var watchRules []*logWatchRule
+	for _, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("log watch rule %q has no pattern", rule.Name)
+		}
+
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for log watch rule %q: %v", rule.Name, err)
+		}
+		if _, err := filepath.Match(rule.Container, ""); err != nil {
+			return nil, fmt.Errorf("invalid container for log watch rule %q: %v", rule.Name, err)
+		}
+
+		cooldown := time.Minute
+		if rule.Cooldown != "" {
+			cooldown, err = time.ParseDuration(rule.Cooldown)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cooldown for log watch rule %q: %v", rule.Name, err)
+			}
+		}
+
+		if rule.Name == "" {
+			rule.Name = rule.Pattern
+		}
+
+		watchRules = append(watchRules, &logWatchRule{
+			config:   rule,
+			pattern:  pattern,
+			cooldown: cooldown,
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LogWatcher{
+		rules:     watchRules,
+		interval:  pollInterval,
+		following: make(map[string]bool),
+		since:     make(map[string]time.Time),
+		lastFired: make(map[string]time.Time),
+		ctx:       ctx,
+		cancel:    cancel,
+	}, nil
+}
+
+func (lw *LogWatcher) Start() {
+	// Only lines logged from now on are matched
+	lw.started = time.Now()
+	lw.discover()
+
+	lw.wg.Add(1)
+	go func() {
+		defer lw.wg.Done()
+
+		ticker := time.NewTicker(lw.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				lw.discover()
+			case <-lw.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Printf("📜 Log watcher started with %d rule(s)", len(lw.rules))
+}
+
+func (lw *LogWatcher) Stop() {
+	lw.cancel()
+	lw.wg.Wait()
+	log.Println("🛑 Log watcher stopped")
+}
+
+// discover starts following running containers that any rule applies to
+func (lw *LogWatcher) discover() {
+	output, err := exec.CommandContext(lw.ctx, "docker", "ps", "--format", "{{.Names}}").Output()
+	if err != nil {
+		if lw.ctx.Err() == nil {
+			log.Printf("Failed to list containers for log watching: %v", err)
+		}
+		return
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || !lw.watched(name) {
+			continue
+		}
+
+		lw.mu.Lock()
+		following := lw.following[name]
+		lw.following[name] = true
+		lw.mu.Unlock()
+
+		if !following {
+			lw.wg.Add(1)
+			go lw.follow(name)
+		}
+	}
+}
+
+func (lw *LogWatcher) watched(name string) bool {
+	for _, rule := range lw.rules {
+		if rule.matchesContainer(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// follow streams a container's logs until it stops, resuming where it left off when it is discovered again
+func (lw *LogWatcher) follow(name string) {
+	defer lw.wg.Done()
+
+	lw.mu.Lock()
+	since, ok := lw.since[name]
+	if !ok {
+		since = lw.started
+	}
+	lw.mu.Unlock()
+
+	tracef("received", "following logs of %s since %s", name, since.Format(time.RFC3339))
+	writer := &lineWriter{onLine: func(line string, at time.Time) {
+		lw.handleLine(name, line, at)
+	}}
+	cmd := exec.CommandContext(lw.ctx, "docker", "logs", "--follow", "--since", fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()), name)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Run(); err != nil && lw.ctx.Err() == nil {
+		log.Printf("Stopped following logs of %s: %v", name, err)
+	}
+
+	lw.mu.Lock()
+	lw.since[name] = time.Now()
+	delete(lw.following, name)
+	lw.mu.Unlock()
+}
+
+func (lw *LogWatcher) handleLine(container, line string, at time.Time) {
+	for _, rule := range lw.rules {
+		if !rule.matchesContainer(container) || !rule.pattern.MatchString(line) {
+			continue
+		}
+
+		key := rule.config.Name + "/" + container
+		lw.mu.Lock()
+		last, fired := lw.lastFired[key]
+		cooling := fired && at.Sub(last) < rule.cooldown
+		if !cooling {
+			lw.lastFired[key] = at
+		}
+		lw.mu.Unlock()
+
+		if cooling {
+			tracef("filtered", "log rule %q matched in %s during its cooldown", rule.config.Name, container)
+			continue
+		}
+
+		text := strings.TrimSpace(line)
+		if runes := []rune(text); len(runes) > maxLogLineLength {
+			text = string(runes[:maxLogLineLength]) + "…"
+		}
+		sendLogNotification(rule.config.Name, container, text, !rule.config.Failure)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -14,16 +15,24 @@ import (
 var globalConfig *Config
 
 func main() {
+	parseGlobalFlags()
+
 	// Load configuration first
 	config, err := LoadConfig()
 	if err != nil {
-		fmt.Printf("Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		// The daemon applies general.config_errors to the config itself
+		if len(os.Args) < 2 || os.Args[1] != "--daemon" {
+			fmt.Printf("Failed to load configuration: %v\n", err)
+			os.Exit(1)
+		}
+		defaultConfig := getDefaultConfig()
+		config = &defaultConfig
 	}
 	globalConfig = config
+	syncHookThreshold()
 
-	// Auto-install shell integration in container environments
-	if isRunningInContainer() {
+	// Auto-install shell integration in container environments, but not from crontab where output becomes mail
+	if isRunningInContainer() && !(len(os.Args) > 1 && os.Args[1] == "cron") {
 		autoInstallShellIntegration()
 	}
 
@@ -43,22 +52,154 @@ func main() {
 		handleShellUninstall()
 	case "--notify":
 		handleNotifyCommand()
+	case "-c", "--shell":
+		handleShellCommand()
+	case "run":
+		handleRunCommand()
+	case "bench":
+		handleBenchCommand()
+	case "alias":
+		handleAliasCommand()
+	case "decrypt":
+		handleDecryptCommand()
+	case "config":
+		handleConfigCommand()
+	case "import":
+		handleImportCommand()
+	case "setup":
+		handleSetupCommand()
+	case "restore-rc":
+		handleRestoreRCCommand()
+	case "history":
+		handleHistoryCommand()
+	case "doctor":
+		handleDoctorCommand()
+	case "upgrade-hooks":
+		handleUpgradeHooksCommand()
+	case "hub":
+		handleHubCommand()
+	case "pair":
+		handlePairCommand()
+	case "docker":
+		handleDockerCommand()
+	case "cron":
+		handleCronCommand()
+	case "explain":
+		handleExplainCommand()
+	case "copy":
+		handleCopyCommand()
+	case "secret":
+		handleSecretCommand()
+	case "cert":
+		handleCertCommand()
+	case "background":
+		handleBackgroundCommand()
+	case "mute":
+		handleMuteCommand()
+	case "unmute":
+		handleUnmuteCommand()
+	case "mutes":
+		handleMutesCommand()
+	case "ack":
+		handleAckCommand()
+	case "status":
+		handleStatusCommand()
+	case "prompt":
+		handlePromptCommand()
+	case "top":
+		handleTopCommand()
+	case "rerun":
+		handleRerunCommand()
+	case "inject":
+		handleInjectCommand()
+	case "generate":
+		handleGenerateCommand()
+	case "open":
+		handleOpenCommand()
+	case "url-scheme":
+		handleURLSchemeCommand()
+	case "start":
+		handleStartCommand()
+	case "done":
+		handleDoneCommand()
+	case "notify-start":
+		handleNotifyStartCommand()
+	case "notify-done":
+		handleNotifyDoneCommand()
+	case "expect":
+		handleExpectCommand()
 	default:
-		executeCommand()
+		executeCommand(os.Args[1:], runOptions{})
 	}
 }
 
 func printUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("  cmdbell <command> [args...]     - Execute command with notification")
+	fmt.Println("  cmdbell run [--log-output] [--] <command> [args...] - Same as above, optionally saving output to ~/.cmdbell/outputs")
+	fmt.Println("  cmdbell run --timeout 30m [--on-timeout kill|notify] -- <command> - Kill the command, or only notify, once it runs too long")
+	fmt.Println("  cmdbell run --retries 3 [--retry-delay 30s] [--retry-on-exit 1,75] -- <command> - Run a failing command again")
+	fmt.Println("  cmdbell -c '<cmd> && <cmd> | <cmd>' - Run a command line through $SHELL and notify for the whole of it")
+	fmt.Println("  cmdbell bench [-n N] -- <command> - Run a command N times and notify with min/median/max")
+	fmt.Println("  cmdbell alias [--shell <sh>] <cmd>... - Print shell functions wrapping commands")
+	fmt.Println("  cmdbell docker [compose] exec ... - Run docker exec and notify with its exit code")
+	fmt.Println("  cmdbell inject [--client <path>] <container> - Hook a running container's shells up to this daemon")
+	fmt.Println("  cmdbell generate devcontainer-feature [--dir <dir>] - Write a dev container feature that does the same at build time")
+	fmt.Println("  cmdbell cron [--budget 2h] -- <command> [args...] - Run a crontab entry, notifying channels on failure or long runs")
+	fmt.Println("  cmdbell start <token>           - Mark the start of work in a cron job, Makefile or CI step")
+	fmt.Println("  cmdbell done <token> [exit]     - Notify that the work finished, timed by the daemon")
+	fmt.Println("  cmdbell notify-start [--label L]  - Mark the start of a script, for notify-done")
+	fmt.Println("  cmdbell notify-done [--label L] [-e $?] - Notify that the script finished, timed from notify-start")
 	fmt.Println("  cmdbell --monitor               - Start Docker container monitoring")
 	fmt.Println("  cmdbell --daemon start          - Start daemon mode")
 	fmt.Println("  cmdbell --daemon stop           - Stop daemon")
-	fmt.Println("  cmdbell --daemon status         - Check daemon status")
+	fmt.Println("  cmdbell --daemon status [--json] - Show uptime, components, channel health and recent errors")
 	fmt.Println("  cmdbell --daemon restart        - Restart daemon")
 	fmt.Println("  cmdbell --install               - Install shell integration")
 	fmt.Println("  cmdbell --uninstall             - Remove shell integration")
+	fmt.Println("  cmdbell setup                   - Interactive first-run configuration")
+	fmt.Println("  cmdbell history [--limit N] [--failed] [--json] - Show recent notifications")
+	fmt.Println("  cmdbell history --format alfred|raycast - Recent notifications as launcher list items")
+	fmt.Println("  cmdbell history encrypt         - Encrypt history recorded before history.encryption was set")
+	fmt.Println("  cmdbell rerun <id> | --pick [query] - Run a command from history again, chosen by ID or in a fuzzy picker")
+	fmt.Println("  cmdbell open cmdbell://rerun/<id>|ack/<id>|open/<id>|focus/<id> - Re-run once confirmed, acknowledge or open a history entry, or go to its terminal")
+	fmt.Println("  cmdbell open cmdbell://action/<name>/<id> - Run a configured action for a history entry")
+	fmt.Println("  cmdbell url-scheme [install|uninstall|status] - Register cmdbell:// links with the desktop")
+	fmt.Println("  cmdbell status [--count|--prompt|--json] - List notifications not acknowledged yet, e.g. for a prompt badge")
+	fmt.Println("  cmdbell ack [<id>...]           - Mark notifications as seen, all unseen ones by default")
+	fmt.Println("  cmdbell prompt [--json]         - Unseen and running counts from the daemon for PS1 or starship")
+	fmt.Println("  cmdbell top [--interval 1s] [--once] - Live view of running jobs with ETAs, keys to mute, stop and acknowledge")
+	fmt.Println("  cmdbell doctor                  - Check configuration, hooks and daemon health")
+	fmt.Println("  cmdbell doctor --explain <cmd>  - Show which notification threshold applies to a command")
+	fmt.Println("  cmdbell explain --last|<id>     - Trace why an event did or did not notify")
+	fmt.Println("  cmdbell mute <cmd> [--for 2h]   - Silence a command or prefix for a while")
+	fmt.Println("  cmdbell unmute <id|cmd>         - Lift a mute early")
+	fmt.Println("  cmdbell mutes [--json]          - List active mutes")
+	fmt.Println("  cmdbell copy --last-failed      - Copy the last failed command to the clipboard")
+	fmt.Println("  cmdbell upgrade-hooks           - Rewrite installed shell hooks with the current template")
+	fmt.Println("  cmdbell hub discover|pair|status - Find and pair with a hub daemon on the LAN")
+	fmt.Println("  cmdbell cert ca|server|client    - Mint certificates for mutual TLS between forwarders and a hub")
+	fmt.Println("  cmdbell pair [--name N] [--ntfy] - Show a QR code for connecting a mobile app")
+	fmt.Println("  cmdbell restore-rc [<backup>|--latest] - Restore a shell config backup")
+	fmt.Println("  cmdbell config validate         - Check the config file for typos and invalid values")
+	fmt.Println("  cmdbell config profiles         - List config profiles and hosts sections, marking those in use")
+	fmt.Println("  cmdbell config history [--limit N] [--json] - Show who changed the config, mutes, paired devices and secrets, with diffs")
+	fmt.Println("  cmdbell import --from noti|ntfy|undistract-me [--write] - Translate another wrapper's settings into CmdBell config")
+	fmt.Println("  cmdbell decrypt [--key <k>|--new-key] - Decrypt an encrypted channel payload from stdin")
+	fmt.Println("  cmdbell secret set|delete|check <name> - Keep a token in the OS keychain, used as keychain:<name>")
+	fmt.Println("  cmdbell expect [--title RE] [--message RE] [--failed] [--count N|--none] [--timeout 5s] - Assert on notifications captured by --fake-notify")
 	fmt.Println("  cmdbell --notify <cmd> <dur> <exit> - Internal: send notification")
+	fmt.Println("  cmdbell background <pid> <start> <cmd> - Internal: notify when a backgrounded job exits")
+	fmt.Println()
+	fmt.Println("Global flags, before the command:")
+	fmt.Println("  --verbose    - Trace every event through the pipeline (or set CMDBELL_DEBUG=1)")
+	fmt.Println("  --dry-run    - Log what channels would receive instead of sending (or set CMDBELL_DRY_RUN=1)")
+	fmt.Println("  --quiet, -q  - No status messages on stderr around the command (or set CMDBELL_QUIET=1)")
+	fmt.Println("  --profile P  - Use the config's profiles.P overlay, e.g. work or home (or set CMDBELL_PROFILE=P)")
+	fmt.Println("  --fake-notify PATH - Write desktop notifications as JSON lines to a file or FIFO, for tests (or set CMDBELL_FAKE_NOTIFY=PATH)")
+	fmt.Println("  --correlation-id ID - Tag the events this command sends with your job's ID (or set CMDBELL_CORRELATION_ID=ID)")
+	fmt.Println()
+	fmt.Println("CmdBell's own messages go to stderr; NO_COLOR or TERM=dumb drops their emoji.")
 }
 
 func handleDaemonCommands() {
@@ -86,7 +227,7 @@ func handleDaemonCommands() {
 		}
 
 	case "status":
-		daemon.Status()
+		daemon.Status(len(os.Args) > 3 && os.Args[3] == "--json")
 
 	case "restart":
 		daemon.Stop() // Ignore error if not running
@@ -105,32 +246,219 @@ func handleDaemonCommands() {
 	}
 }
 
-func executeCommand() {
-	command := os.Args[1]
-	args := os.Args[2:]
+func handleRunCommand() {
+	opts, args, err := parseRunFlags(os.Args[2:])
+	if err != nil {
+		errorf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	if len(args) > 0 && (args[0] == "-c" || args[0] == "--shell") {
+		if len(args) != 2 {
+			fmt.Println("Usage: cmdbell run [options] -c '<command line>'")
+			os.Exit(1)
+		}
+		executeShellCommand(args[1], opts)
+		return
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
 
-	fmt.Printf("Executing: %s %s\n", command, strings.Join(args, " "))
+	if len(args) == 0 {
+		fmt.Println("Usage: cmdbell run [options] [--] <command> [args...]")
+		fmt.Println("       cmdbell run [options] -c '<command line>'")
+		fmt.Println("Options:")
+		fmt.Println("  --log-output              Save output to ~/.cmdbell/outputs and link it from the notification")
+		fmt.Println("  --timeout <duration>      Stop the command once it runs this long")
+		fmt.Println("  --on-timeout kill|notify  Kill it at the timeout (default) or only notify, with the progress")
+		fmt.Println("                            of rsync, pip, cargo and docker pull where they print it")
+		fmt.Println("  --retries <n>             Run a failed command up to n more times")
+		fmt.Println("  --retry-delay <duration>  Wait between attempts")
+		fmt.Println("  --retry-on-exit <codes>   Only retry these exit codes, e.g. 1,75")
+		os.Exit(1)
+	}
+
+	executeCommand(args, opts)
+}
+
+// executeCommand runs a wrapped command; with opts.LogOutput its output is also saved under
+// ~/.cmdbell/outputs and the notification links to it
+func executeCommand(argv []string, opts runOptions) {
+	// A lone argument such as "make && make install" is a shell command line, not a binary name
+	if len(argv) == 1 && looksLikeShellCommand(argv[0]) {
+		executeShellCommand(argv[0], opts)
+		return
+	}
+	statusf("Executing: %s\n", strings.Join(argv, " "))
+	runWrapped(argv, argv[0], strings.Join(argv, " "), opts)
+}
+
+// runWrapped runs argv and notifies about it as command, applying thresholds to commandLine
+func runWrapped(argv []string, command, commandLine string, opts runOptions) {
+	args := argv[1:]
+
+	// Only one layer notifies when cmdbell wrappers and shell hooks are nested
+	notify := true
+	switch {
+	case insideWrapper():
+		tracef("filtered", "%s runs inside another cmdbell wrapper, which notifies instead", command)
+		notify = false
+	case hookStartTime() != "" && !wrapperWinsOverHook():
+		tracef("filtered", "%s is timed by a shell hook, which notifies instead (general.nested: hook)", command)
+		notify = false
+	case hookStartTime() != "":
+		if err := suppressHookNotification(hookStartTime()); err != nil {
+			statusf("⚠️  The shell hook may notify as well: %v\n", err)
+		}
+	}
+
+	// A long job that would run out of disk or memory is better not started
+	guardResources(command, notify)
 
 	startTime := time.Now()
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+
+	var outputLog *os.File
+	outputTail := &tailBuffer{limit: cronOutputLimit}
+	if opts.LogOutput {
+		var err error
+		// Shell command lines are named after their first word rather than the shell
+		name := argv[0]
+		if command != argv[0] {
+			name = strings.Fields(command)[0]
+		}
+		if outputLog, err = createOutputLog(name); err != nil {
+			statusf("⚠️  Not saving output: %v\n", err)
+		} else {
+			// The end of the output also goes to channels that attach it
+			stdout = io.MultiWriter(stdout, outputLog, outputTail)
+			stderr = io.MultiWriter(stderr, outputLog, outputTail)
+		}
+	}
+
+	// Known VM and cloud tools get their output parsed for box/image names and step durations
+	var parsers []io.Writer
+	enricher := newCommandEnricher(argv[0], args)
+	if enricher != nil && enricher.Output() != nil {
+		parsers = append(parsers, enricher.Output())
+	}
 
-	err := cmd.Run()
+	// Known tools also have their progress followed, for the "still running" notification
+	progress := newProgressMeter(argv[0], args)
+	if progress != nil {
+		progress.applyEnv()
+		parsers = append(parsers, progress.Output())
+	}
+
+	// On a terminal the parsers read a recording, so the tool keeps the terminal; see recordedCommand
+	var observe io.Writer
+	if len(parsers) > 0 {
+		observe = io.MultiWriter(parsers...)
+		if !isTerminal(os.Stdout) {
+			stdout = io.MultiWriter(stdout, observe)
+			stderr = io.MultiWriter(stderr, observe)
+			observe = nil
+		}
+	}
+
+	// Flaky commands are run again under --retries; the notification covers every attempt
+	var err error
+	var timedOut bool
+	var exitCodes []int
+	for attempt := 1; ; attempt++ {
+		timedOut, err = runAttempt(argv, stdout, stderr, observe, opts, command, notify, progress)
+		exitCodes = append(exitCodes, exitCode(err))
+		if !opts.shouldRetry(err, timedOut, attempt) {
+			break
+		}
+		statusf("🔁 %s exited with %d, retrying in %s (attempt %d of %d)\n",
+			displayCommand(command), exitCode(err), opts.RetryDelay, attempt+1, opts.Retries+1)
+		time.Sleep(opts.RetryDelay)
+	}
 	duration := time.Since(startTime)
 
-	if globalConfig != nil && duration >= globalConfig.General.MinDurationTime && globalConfig.General.EnableNotify {
-		sendNotification(command, duration, err == nil)
+	if outputLog != nil {
+		outputLog.Close()
+		statusf("📝 Output saved to %s\n", outputLog.Name())
+	}
+
+	// A command killed at its deadline always notifies, whatever its threshold
+	killed := timedOut && opts.OnTimeout == "kill"
+	if notify && ((killed && globalConfig != nil && globalConfig.General.EnableNotify) || shouldNotifyCommand(commandLine, duration)) {
+		detail := ""
+		if enricher != nil {
+			detail = enricher.Summary()
+		}
+		if summary := retrySummary(exitCodes); summary != "" {
+			if detail != "" {
+				detail += "; "
+			}
+			detail += summary
+		}
+		status := "completed"
+		if killed {
+			status = "timed out"
+		} else if sig := exitSignal(err); sig != "" {
+			status = "terminated by " + sig
+		} else if err != nil {
+			status = "failed"
+		}
+		n := finishedNotification(command, status, detail, startTime, duration, err == nil)
+		n.Local = true
+		if killed {
+			n.Icon = "⏱️"
+			n.TimedOut = true
+		}
+		if timedOut {
+			// Replaces the "still running" toast from --on-timeout notify
+			n.ReplaceKey = runReplaceKey()
+		}
+		if outputLog != nil {
+			n.URL = outputLogLink(outputLog.Name())
+			n.Output = outputTail.String()
+		}
+		deliverNotification(n)
+		waitForDeliveries()
 	}
 
+	if killed {
+		os.Exit(timeoutExitCode)
+	}
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// runAttempt runs argv once in its own process group, which signals to cmdbell are forwarded
+// to, and reports whether it hit its --timeout
+func runAttempt(argv []string, stdout, stderr, observe io.Writer, opts runOptions, command string, notify bool, progress *progressMeter) (bool, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	var recording *terminalRecording
+	if observe != nil {
+		if recorded, r := recordedCommand(argv, observe); recorded != nil {
+			cmd, recording = recorded, r
+		}
+	}
+	cmd.Env = append(os.Environ(), wrappedEnv+"=1")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	group := newProcessGroup(cmd)
+	err := group.Start()
+	if recording != nil {
+		recording.started()
+		defer recording.finish()
+	}
+	if err != nil {
+		return false, err
+	}
+	deadline := startDeadline(opts, group, command, time.Now(), notify, progress)
+	err = group.Wait()
+	return deadline.Stop(), err
+}
+
 func startDockerMonitoring() {
-	monitor, err := NewDockerMonitor()
+	monitor, err := NewDockerMonitor(globalConfig)
 	if err != nil {
 		fmt.Printf("Failed to create Docker monitor: %v\n", err)
 		os.Exit(1)
@@ -178,7 +506,7 @@ func handleShellUninstall() {
 
 func handleNotifyCommand() {
 	if len(os.Args) < 5 {
-		fmt.Println("Usage: cmdbell --notify <command> <duration_seconds> <exit_code>")
+		fmt.Println("Usage: cmdbell --notify <command> <duration_seconds> <exit_code> [start_time]")
 		os.Exit(1)
 	}
 
@@ -192,8 +520,22 @@ func handleNotifyCommand() {
 		os.Exit(1)
 	}
 
+	if !shouldNotifyCommand(command, duration) {
+		return
+	}
+
+	// Hooks pass the Unix start time so overnight runs report when they actually started
+	var startTime time.Time
+	if len(os.Args) > 5 {
+		if startTime, err = parseStartTime(os.Args[5]); err != nil {
+			fmt.Printf("Invalid start time: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	success := exitCodeStr == "0"
-	sendNotification(command, duration, success)
+	sendCommandNotification(command, "", startTime, duration, success)
+	waitForDeliveries()
 }
 
 // isRunningInContainer checks if the current process is running inside a Docker container
@@ -260,7 +602,7 @@ func isShellIntegrationInstalled() bool {
 		configPath := filepath.Join(homeDir, config)
 		if data, err := os.ReadFile(configPath); err == nil {
 			contents := string(data)
-			if strings.Contains(contents, "CmdBell shell integration - START") {
+			if strings.Contains(contents, hookStartMarker) {
 				return true
 			}
 		}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Format profiles decide how much of an event a destination shows. Each channel picks one with
+// `format`, defaulting to what suits its type; the desktop follows notification.format.
+const (
+	formatShort    = "short"    // the one-line message
+	formatLong     = "long"     // the message followed by host, duration, command and link lines
+	formatMarkdown = "markdown" // ntfy markdown, or Slack blocks with fields for webhooks
+	formatJSON     = "json"     // the full ChannelPayload document
+)
+
+// channelFormats lists the profiles each channel type supports, its default first
+var channelFormats = map[string][]string{
+	"webhook":  {formatJSON, formatMarkdown, formatShort, formatLong},
+	"ntfy":     {formatLong, formatShort, formatMarkdown},
+	"hub":      {formatJSON}, // read by another daemon
+	"queue":    {formatJSON}, // fetched by hubs
+	"telegram": {formatLong, formatShort},
+}
+
+// resolveChannelFormat returns the channel's profile, checking that its type supports it
+func resolveChannelFormat(config ChannelConfig) (string, error) {
+	formats := channelFormats[config.Type]
+	if len(formats) == 0 {
+		return "", nil
+	}
+	if config.Format == "" {
+		return formats[0], nil
+	}
+	if !containsString(formats, config.Format) {
+		return "", fmt.Errorf("%s channels do not support format %q (expected %s)", config.Type, config.Format, strings.Join(formats, ", "))
+	}
+	return config.Format, nil
+}
+
+// messageField is one labelled detail of an event, such as its host or duration
+type messageField struct {
+	Name  string
+	Value string
+}
+
+// messageFields collects the details the long and markdown profiles show under the message;
+// command is passed in already sanitized or shortened for where it is going
+func messageFields(n *Notification, command string) []messageField {
+	host := n.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	fields := []messageField{{"Host", host}}
+	if n.Duration > 0 {
+		fields = append(fields, messageField{"Duration", formatDuration(n.Duration)})
+	}
+	if command != "" {
+		fields = append(fields, messageField{"Command", command})
+	}
+	if n.Category != "" {
+		fields = append(fields, messageField{"Category", n.Category})
+	}
+	if n.ContainerName != "" && n.ContainerName != host {
+		fields = append(fields, messageField{"Container", n.ContainerName})
+	}
+	if n.Service != "" {
+		fields = append(fields, messageField{"Service", n.Service})
+	}
+	if n.User != "" {
+		fields = append(fields, messageField{"User", n.User})
+	}
+	if n.URL != "" {
+		fields = append(fields, messageField{"Link", n.URL})
+	}
+	return fields
+}
+
+// longText is the message followed by one "Name: value" line per detail
+func longText(message string, fields []messageField) string {
+	var b strings.Builder
+	b.WriteString(message)
+	b.WriteString("\n")
+	for _, field := range fields {
+		fmt.Fprintf(&b, "\n%s: %s", field.Name, field.Value)
+	}
+	return b.String()
+}
+
+// markdownText renders the message and its details for ntfy, which shows Markdown when asked to
+func markdownText(message string, fields []messageField) string {
+	var b strings.Builder
+	b.WriteString(message)
+	b.WriteString("\n")
+	for _, field := range fields {
+		value := field.Value
+		if field.Name == "Command" {
+			value = "`" + strings.ReplaceAll(value, "`", "'") + "`"
+		}
+		fmt.Fprintf(&b, "\n- **%s:** %s", field.Name, value)
+	}
+	return b.String()
+}
+
+// slackBody is a Slack incoming webhook message: the text as the fallback, a fields section with
+// the details, any attached output and, for channels of an interactive Slack app, the buttons;
+// Mattermost and Rocket.Chat read the text and ignore the blocks
+func slackBody(n *Notification, output string, interactive bool) map[string]interface{} {
+	message := n.RemoteMessage()
+	fields := []map[string]string{}
+	for _, field := range messageFields(n, sanitizeCommand(n.Command)) {
+		value := field.Value
+		if field.Name == "Command" {
+			value = "`" + strings.ReplaceAll(value, "`", "'") + "`"
+		}
+		fields = append(fields, map[string]string{"type": "mrkdwn", "text": "*" + field.Name + "*\n" + value})
+	}
+
+	text := message
+	if n.Icon != "" {
+		text = n.Icon + " " + message
+	}
+	blocks := []map[string]interface{}{
+		{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": "*" + strings.TrimSpace(n.Title) + "*\n" + text}},
+	}
+	if len(fields) > 0 {
+		blocks = append(blocks, map[string]interface{}{"type": "section", "fields": fields})
+	}
+	if output != "" {
+		blocks = append(blocks, map[string]interface{}{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": codeBlock(truncateOutput(output, slackTextLimit))}})
+	}
+	if buttons := slackButtons(n); interactive && buttons != nil {
+		blocks = append(blocks, buttons)
+	}
+	return map[string]interface{}{"text": text, "blocks": blocks}
+}
+
+// slackTextLimit leaves room for the code fences within Slack's 3000 character section text
+const slackTextLimit = 2900
+
+// codeBlock fences output for chat messages, empty when there is none
+func codeBlock(output string) string {
+	if output == "" {
+		return ""
+	}
+	return "\n```\n" + strings.ReplaceAll(strings.TrimRight(output, "\n"), "```", "` ` `") + "\n```"
+}
+
+// desktopMessage is the text of a native notification under notification.format
+func desktopMessage(n *Notification) string {
+	if globalConfig != nil && globalConfig.Notification.Format == formatLong {
+		return longText(n.Message, messageFields(n, displayCommand(n.Command)))
+	}
+	// Desktop notifications cannot link anywhere, so the link is shown for copying
+	if n.URL != "" {
+		return n.Message + "\n" + n.URL
+	}
+	return n.Message
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mutual TLS lets forwarders on untrusted networks reach a hub without a VPN: the hub serves
+// HTTPS on http.tls.port and only accepts clients with a certificate from its CA, and each
+// forwarder checks the hub's certificate against the same CA. `cmdbell cert` mints the CA and
+// certificates into ~/.cmdbell/certs.
+const (
+	defaultTLSPort = 59722
+
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 825 * 24 * time.Hour // the longest validity clients accept for server certificates
+)
+
+// certNamePattern restricts client certificate names, which become file names and audit log clients
+var certNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// serverTLSConfig loads the hub's certificate and, with a client CA, requires clients to present
+// a certificate it signed
+func serverTLSConfig(certFile, keyFile, clientCA string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(expandHome(certFile), expandHome(keyFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCA != "" {
+		pool, err := loadCertPool(clientCA)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// tlsTransport returns a transport that verifies servers against ca and presents the client
+// certificate cert, or nil when none of them is set and the default transport will do
+func tlsTransport(ca, certFile, keyFile string) (http.RoundTripper, error) {
+	if ca == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if ca != "" {
+		pool, err := loadCertPool(ca)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("tls_cert and tls_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(expandHome(certFile), expandHome(keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return transport, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// certClient names the client behind a request authenticated by its certificate, empty otherwise
+func certClient(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return "cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+func certsDir() (string, error) {
+	path, err := getDataPath("certs")
+	if err != nil {
+		return "", err
+	}
+	return path, os.MkdirAll(path, 0700)
+}
+
+// handleCertCommand handles `cmdbell cert ca|server|client`
+func handleCertCommand() {
+	if len(os.Args) < 3 {
+		printCertUsage()
+		os.Exit(1)
+	}
+	dir, err := certsDir()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "ca":
+		if err := createCA(dir); err != nil {
+			fmt.Printf("❌ Failed to create CA: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Created the CA in %s\n", dir)
+		fmt.Println("   Next: 'cmdbell cert server' on the hub and 'cmdbell cert client <name>' for each forwarder")
+	case "server":
+		hosts, days, err := parseCertArgs(os.Args[3:], true)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			printCertUsage()
+			os.Exit(1)
+		}
+		if len(hosts) == 0 {
+			hosts = defaultCertHosts()
+		}
+		if err := issueCert(dir, "server", hosts, days, x509.ExtKeyUsageServerAuth); err != nil {
+			fmt.Printf("❌ Failed to issue the server certificate: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Issued the hub certificate for %s\n\n", strings.Join(hosts, ", "))
+		fmt.Println("Add to the hub's config:")
+		fmt.Println("  http:")
+		fmt.Println("    tls:")
+		fmt.Printf("      port: %d\n", defaultTLSPort)
+		fmt.Printf("      cert: %s\n", filepath.Join(dir, "server.crt"))
+		fmt.Printf("      key: %s\n", filepath.Join(dir, "server.key"))
+		fmt.Printf("      client_ca: %s\n", filepath.Join(dir, "ca.crt"))
+		fmt.Println("\nWith client_ca set, plain HTTP only listens on localhost, so other machines, containers")
+		fmt.Println("included, reach the hub over HTTPS with a client certificate.")
+	case "client":
+		if len(os.Args) < 4 || !certNamePattern.MatchString(os.Args[3]) || os.Args[3] == "ca" || os.Args[3] == "server" {
+			fmt.Println("Client names use letters, digits, '.', '-' and '_', and cannot be 'ca' or 'server'")
+			printCertUsage()
+			os.Exit(1)
+		}
+		name := os.Args[3]
+		_, days, err := parseCertArgs(os.Args[4:], false)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			printCertUsage()
+			os.Exit(1)
+		}
+		if err := issueCert(dir, name, nil, days, x509.ExtKeyUsageClientAuth); err != nil {
+			fmt.Printf("❌ Failed to issue the client certificate: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Issued a client certificate for %s\n\n", name)
+		fmt.Printf("Copy ca.crt, %s.crt and %s.key from %s to ~/.cmdbell/certs on the forwarder, then add:\n", name, name, dir)
+		fmt.Println("  channels:")
+		fmt.Println("    - type: hub")
+		fmt.Printf("      url: https://<hub>:%d\n", defaultTLSPort)
+		fmt.Println("      tls_ca: ~/.cmdbell/certs/ca.crt")
+		fmt.Printf("      tls_cert: ~/.cmdbell/certs/%s.crt\n", name)
+		fmt.Printf("      tls_key: ~/.cmdbell/certs/%s.key\n", name)
+	default:
+		printCertUsage()
+		os.Exit(1)
+	}
+}
+
+func printCertUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  cmdbell cert ca                                  - Create the CA for hubs and forwarders")
+	fmt.Println("  cmdbell cert server [--host <name>]... [--days N] - Issue the hub's certificate for http.tls")
+	fmt.Println("  cmdbell cert client <name> [--days N]            - Issue a forwarder's client certificate")
+}
+
+// parseCertArgs reads --host (when hosts are allowed) and --days
+func parseCertArgs(args []string, allowHosts bool) ([]string, int, error) {
+	var hosts []string
+	days := int(certValidity.Hours() / 24)
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return nil, 0, fmt.Errorf("%s needs a value", args[i])
+		}
+		switch {
+		case args[i] == "--host" && allowHosts:
+			hosts = append(hosts, args[i+1])
+		case args[i] == "--days":
+			parsed, err := strconv.Atoi(args[i+1])
+			if err != nil || parsed <= 0 {
+				return nil, 0, fmt.Errorf("invalid --days %q", args[i+1])
+			}
+			days = parsed
+		default:
+			return nil, 0, fmt.Errorf("unknown option: %s", args[i])
+		}
+		i++
+	}
+	return hosts, days, nil
+}
+
+// defaultCertHosts is what forwarders may call the hub: its hostname, localhost and LAN addresses
+func defaultCertHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append([]string{hostname}, hosts...)
+	}
+	for _, ip := range lanAddresses() {
+		hosts = append(hosts, ip.String())
+	}
+	return hosts
+}
+
+func createCA(dir string) error {
+	certPath, keyPath := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	if _, err := os.Stat(keyPath); err == nil {
+		return fmt.Errorf("%s already exists; remove it to start over, which invalidates every certificate it issued", keyPath)
+	}
+
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "CmdBell CA " + hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	return writeCert(certPath, keyPath, template, template, key, key)
+}
+
+// issueCert signs a certificate for name with the CA, valid for hosts when it is a server's
+func issueCert(dir, name string, hosts []string, days int, usage x509.ExtKeyUsage) error {
+	caCert, caKey, err := loadCA(dir)
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(time.Duration(days) * 24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	return writeCert(filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key"), template, caCert, key, caKey)
+}
+
+func loadCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	pair, err := tls.LoadX509KeyPair(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("no CA yet, run 'cmdbell cert ca' first")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported CA key type")
+	}
+	return cert, key, nil
+}
+
+// writeCert signs template for key with the parent's signer and writes the certificate and the
+// private key as PEM
+func writeCert(certPath, keyPath string, template, parent *x509.Certificate, key, signer *ecdsa.PrivateKey) error {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	template.SerialNumber = serial
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return writeFileAtomic(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
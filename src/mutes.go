@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+)
+
+// defaultMuteDuration is how long `cmdbell mute` silences a command without --for
+const defaultMuteDuration = time.Hour
+
+var mutesMu sync.Mutex
+
+// Mute silences commands matching Pattern until it expires. Patterns match like the commands of a
+// thresholds rule: the command name or a prefix such as "npm run dev".
+type Mute struct {
+	ID      string    `json:"id"`
+	Pattern string    `json:"pattern"`
+	Created time.Time `json:"created"`
+	Until   time.Time `json:"until"`
+}
+
+// loadMutes returns the mutes in ~/.cmdbell/mutes.json that have not expired, soonest to expire
+// first. The file is read on every call so wrapped commands see mutes added through the daemon.
+func loadMutes() []Mute {
+	path, err := getDataPath("mutes.json")
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var mutes []Mute
+	json.Unmarshal(data, &mutes)
+	return activeMutes(mutes)
+}
+
+func activeMutes(mutes []Mute) []Mute {
+	now := time.Now()
+	active := []Mute{}
+	for _, mute := range mutes {
+		if mute.Until.After(now) {
+			active = append(active, mute)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Until.Before(active[j].Until) })
+	return active
+}
+
+func saveMutes(mutes []Mute) error {
+	path, err := getDataPath("mutes.json")
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(mutes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// addMute silences pattern for duration; muting a pattern again replaces its expiry
+func addMute(pattern string, duration time.Duration) (Mute, error) {
+	mutesMu.Lock()
+	defer mutesMu.Unlock()
+
+	mute := Mute{ID: newEventID()[:8], Pattern: pattern, Created: time.Now(), Until: time.Now().Add(duration)}
+	mutes := []Mute{mute}
+	for _, existing := range loadMutes() {
+		if existing.Pattern != pattern {
+			mutes = append(mutes, existing)
+		}
+	}
+	return mute, saveMutes(activeMutes(mutes))
+}
+
+// parseMuteArgs reads the words of a remote mute command, such as "make test 2h": a trailing
+// duration is how long, defaultMuteDuration without one
+func parseMuteArgs(words []string) (string, time.Duration) {
+	if len(words) > 1 {
+		if parsed, err := time.ParseDuration(words[len(words)-1]); err == nil && parsed > 0 {
+			return strings.Join(words[:len(words)-1], " "), parsed
+		}
+	}
+	return strings.Join(words, " "), defaultMuteDuration
+}
+
+// removeMute lifts the mute with the given ID or pattern
+func removeMute(idOrPattern string) (bool, error) {
+	mutesMu.Lock()
+	defer mutesMu.Unlock()
+
+	mutes := loadMutes()
+	kept := []Mute{}
+	for _, mute := range mutes {
+		if mute.ID != idOrPattern && mute.Pattern != idOrPattern {
+			kept = append(kept, mute)
+		}
+	}
+	if len(kept) == len(mutes) {
+		return false, nil
+	}
+	return true, saveMutes(kept)
+}
+
+// muteChange and unmuteChange describe mute changes for `cmdbell config history`
+func muteChange(actor string, mute Mute) ConfigChange {
+	return ConfigChange{
+		Actor:   actor,
+		Action:  "mute.add",
+		Target:  "mutes",
+		Summary: fmt.Sprintf("muted '%s' until %s (id %s)", mute.Pattern, mute.Until.Local().Format("2006-01-02 15:04"), mute.ID),
+	}
+}
+
+func unmuteChange(actor, idOrPattern string) ConfigChange {
+	return ConfigChange{Actor: actor, Action: "mute.remove", Target: "mutes", Summary: fmt.Sprintf("unmuted '%s'", idOrPattern)}
+}
+
+// mutedBy returns the mute silencing commandLine, or nil
+func mutedBy(commandLine string) *Mute {
+	if commandLine == "" {
+		return nil
+	}
+	for _, mute := range loadMutes() {
+		if matchCommandRule([]string{mute.Pattern}, "", commandLine) != "" {
+			return &mute
+		}
+	}
+	return nil
+}
+
+// suppressMuted records a muted notification for `cmdbell explain` and reports whether it was muted
+func suppressMuted(n *Notification) bool {
+	mute := mutedBy(n.Command)
+	if mute == nil {
+		return false
+	}
+	appendDecision(Decision{
+		ID:              newEventID(),
+		Time:            time.Now(),
+		Source:          n.Source,
+		Command:         sanitizeCommand(n.Command),
+		Message:         n.RemoteMessage(),
+		DurationSeconds: n.Duration.Seconds(),
+		Success:         n.Success,
+		Outcome:         fmt.Sprintf("muted by %q until %s", mute.Pattern, mute.Until.Local().Format("15:04")),
+	})
+	tracef("filtered", "dropped, %q is muted until %s", sanitizeCommand(n.Command), mute.Until.Local().Format("15:04"))
+	return true
+}
+
+func (hs *HTTPServer) handleMutes(w http.ResponseWriter, r *http.Request) {
+	var response interface{}
+	switch r.Method {
+	case http.MethodGet:
+		response = loadMutes()
+
+	case http.MethodPost:
+		var req client.MuteRequest
+		if err := decodePayload(r.Body, "mute", &req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		duration := defaultMuteDuration
+		if req.Duration != "" {
+			parsed, err := time.ParseDuration(req.Duration)
+			if err != nil || parsed <= 0 {
+				http.Error(w, fmt.Sprintf("Invalid duration %q", req.Duration), http.StatusBadRequest)
+				return
+			}
+			duration = parsed
+		}
+		if strings.TrimSpace(req.Pattern) == "" {
+			http.Error(w, "pattern is required", http.StatusBadRequest)
+			return
+		}
+		mute, err := addMute(strings.TrimSpace(req.Pattern), duration)
+		if err != nil {
+			log.Printf("Failed to save mute: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("🔇 Muted '%s' until %s", mute.Pattern, mute.Until.Local().Format("15:04"))
+		recordConfigChange(muteChange(apiActor(r), mute))
+		response = mute
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		removed, err := removeMute(id)
+		if err != nil {
+			log.Printf("Failed to save mutes: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if id == "" || !removed {
+			http.Error(w, fmt.Sprintf("No mute %q", id), http.StatusNotFound)
+			return
+		}
+		log.Printf("🔔 Unmuted '%s'", id)
+		recordConfigChange(unmuteChange(apiActor(r), id))
+		response = map[string]interface{}{"status": "success", "id": id}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// handleMuteCommand handles `cmdbell mute <pattern> [--for 2h]`
+func handleMuteCommand() {
+	args := os.Args[2:]
+	duration := defaultMuteDuration
+	var pattern []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--for" && i+1 < len(args) {
+			i++
+			parsed, err := time.ParseDuration(args[i])
+			if err != nil || parsed <= 0 {
+				errorf("❌ Invalid duration %q, expected a value such as 30m or 2h\n", args[i])
+				os.Exit(1)
+			}
+			duration = parsed
+			continue
+		}
+		pattern = append(pattern, args[i])
+	}
+	if len(pattern) == 0 {
+		fmt.Println("Usage: cmdbell mute <command or prefix> [--for 2h]")
+		fmt.Println("Example: cmdbell mute \"npm run dev\" --for 2h")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	mute, err := localDaemonClient().Mute(ctx, client.MuteRequest{Pattern: strings.Join(pattern, " "), Duration: duration.String()})
+	if err != nil {
+		// The daemon reads the same file, so a mute saved directly still applies
+		var apiErr *client.APIError
+		if errors.As(err, &apiErr) {
+			errorf("❌ Failed to mute: %v\n", err)
+			os.Exit(1)
+		}
+		local, err := addMute(strings.Join(pattern, " "), duration)
+		if err != nil {
+			errorf("❌ Failed to mute: %v\n", err)
+			os.Exit(1)
+		}
+		recordConfigChange(muteChange(cliActor(), local))
+		mute = &client.Mute{ID: local.ID, Pattern: local.Pattern, Created: local.Created, Until: local.Until}
+	}
+	fmt.Printf("🔇 Muted '%s' until %s (id %s)\n", mute.Pattern, mute.Until.Local().Format("2006-01-02 15:04"), mute.ID)
+}
+
+// handleUnmuteCommand handles `cmdbell unmute <id or pattern>`
+func handleUnmuteCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: cmdbell unmute <id or pattern>")
+		os.Exit(1)
+	}
+	target := strings.Join(os.Args[2:], " ")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := localDaemonClient().Unmute(ctx, target)
+	var apiErr *client.APIError
+	switch {
+	case err == nil:
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		fmt.Printf("No mute matches '%s'\n", target)
+		os.Exit(1)
+	case errors.As(err, &apiErr):
+		errorf("❌ Failed to unmute: %v\n", err)
+		os.Exit(1)
+	default:
+		removed, err := removeMute(target)
+		if err != nil {
+			errorf("❌ Failed to unmute: %v\n", err)
+			os.Exit(1)
+		}
+		if !removed {
+			fmt.Printf("No mute matches '%s'\n", target)
+			os.Exit(1)
+		}
+		recordConfigChange(unmuteChange(cliActor(), target))
+	}
+	fmt.Printf("🔔 Unmuted '%s'\n", target)
+}
+
+// handleMutesCommand handles `cmdbell mutes [--json]`
+func handleMutesCommand() {
+	mutes := loadMutes()
+	if len(os.Args) > 2 && os.Args[2] == "--json" {
+		data, _ := json.MarshalIndent(mutes, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	if len(mutes) == 0 {
+		fmt.Println("No commands are muted")
+		return
+	}
+	for _, mute := range mutes {
+		fmt.Printf("🔇 %s  %-30s until %s (%s left)\n", mute.ID, mute.Pattern,
+			mute.Until.Local().Format("2006-01-02 15:04"), formatDuration(time.Until(mute.Until)))
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A command can be seen by more than one cmdbell layer. 'cmdbell run' exports CMDBELL_WRAPPED to
+// the command it runs, so wrappers and shell hooks underneath it stay quiet. Shell hooks export
+// CMDBELL_START_TIME before each command, so a wrapper typed at a hooked prompt knows the hook will
+// report it too; general.nested picks which of the two notifies, and when the wrapper wins it leaves
+// a marker in ~/.cmdbell/nested that the hook removes instead of notifying.
+const wrappedEnv = "CMDBELL_WRAPPED"
+
+// insideWrapper reports whether this process was started by another 'cmdbell run'
+func insideWrapper() bool {
+	return os.Getenv(wrappedEnv) != ""
+}
+
+// hookStartTime is the start time exported by a shell hook timing this process, empty without one
+func hookStartTime() string {
+	return os.Getenv("CMDBELL_START_TIME")
+}
+
+// wrapperWinsOverHook reports whether general.nested lets the wrapper notify instead of the shell hook
+func wrapperWinsOverHook() bool {
+	return globalConfig == nil || globalConfig.General.Nested != "hook"
+}
+
+// suppressHookNotification leaves the marker that tells the shell hook timing this command to stay quiet
+func suppressHookNotification(startTime string) error {
+	if startTime == "" || strings.ContainsAny(startTime, `/\`) {
+		return fmt.Errorf("invalid hook start time %q", startTime)
+	}
+
+	dir, err := getDataPath("nested")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create nested marker directory: %v", err)
+	}
+	pruneNestedMarkers(dir)
+	return os.WriteFile(filepath.Join(dir, startTime), nil, 0600)
+}
+
+// pruneNestedMarkers removes markers no hook picked up, e.g. after the shell was closed mid-command
+func pruneNestedMarkers(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > 24*time.Hour {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+// 'cmdbell docker exec' and 'cmdbell docker compose' time what the daemon's docker monitor also
+// sees in the event stream. Execs carry no labels and their environment is not in the events, so
+// the wrapper leaves a marker in ~/.cmdbell/wrapped-docker naming the exec, or the compose project,
+// and the monitor leaves the notification to the wrapper when it finds one.
+const wrappedDockerDir = "wrapped-docker"
+
+// wrappedExecWindow is how long after the wrapper starts docker a marker can match its exec
+const wrappedExecWindow = time.Minute
+
+type wrappedDocker struct {
+	Target  string `json:"target,omitempty"` // container name or ID, or the service for compose
+	Compose bool   `json:"compose,omitempty"`
+	Command string `json:"command,omitempty"`
+	Project string `json:"project,omitempty"` // set instead for a compose command such as up
+}
+
+// markWrappedDocker leaves a marker for a docker command about to start and returns its path,
+// which the wrapper removes once docker exits
+func markWrappedDocker(marker wrappedDocker) (string, error) {
+	dir, err := getDataPath(wrappedDockerDir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create wrapped docker directory: %v", err)
+	}
+	pruneNestedMarkers(dir)
+
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, newEventID()+".json")
+	return path, os.WriteFile(path, data, 0600)
+}
+
+// findWrappedDocker returns the path of the first marker match accepts, empty without one
+func findWrappedDocker(maxAge time.Duration, match func(wrappedDocker) bool) string {
+	dir, err := getDataPath(wrappedDockerDir)
+	if err != nil {
+		return ""
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) > maxAge {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var marker wrappedDocker
+		if json.Unmarshal(data, &marker) == nil && match(marker) {
+			return path
+		}
+	}
+	return ""
+}
+
+// takeWrappedExec reports whether a wrapper left a marker for the exec a docker event is about,
+// removing it so each marker accounts for one exec
+func takeWrappedExec(event DockerEvent) bool {
+	attributes := event.Actor.Attributes
+	path := findWrappedDocker(wrappedExecWindow, func(marker wrappedDocker) bool {
+		if marker.Command == "" || marker.Command != event.actionDetail() {
+			return false
+		}
+		if marker.Compose {
+			return marker.Target == attributes["com.docker.compose.service"]
+		}
+		return marker.Target == attributes["name"] ||
+			(len(marker.Target) >= 4 && strings.HasPrefix(event.ID, marker.Target))
+	})
+	return path != "" && os.Remove(path) == nil
+}
+
+// composeProjectWrapped reports whether a wrapper is running a compose command for project. The
+// marker stays until the command exits, however long a build before the bring-up takes.
+func composeProjectWrapped(project string) bool {
+	return findWrappedDocker(24*time.Hour, func(marker wrappedDocker) bool {
+		return marker.Project == project
+	}) != ""
+}
@@ -1,96 +1,697 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Notification is a single event delivered to the desktop and any configured channels
+type Notification struct {
+	ID            string // history entry ID, assigned on delivery
+	CorrelationID string // caller's ID for the job behind the event, see correlation.go
+	Title         string
+	Message       string
+	Icon          string
+	Source        string // "command", "container", "schedule", "file", "endpoint", "log", "editor" or "cron"
+	Command       string
+	Category      string // "build", "test", "deploy", "package-manager", "data" or a categories rule's; classified on delivery
+	ContainerName string
+	Service       string // docker compose service, when known
+	Duration      time.Duration
+	Success       bool
+	Time          time.Time
+	Host          string // origin host for events forwarded to a hub, empty when local
+	URL           string // jump-back link, such as a vscode:// URL to the task's workspace
+	Output        string // captured command output, attached by channels that support it
+	RemoteOnly    bool   // skip the console and desktop, e.g. for cron jobs without a GUI session
+	StartTime     time.Time // when the work started; derived from Duration when zero
+	User          string // in system mode, the user whose session and channels receive the event
+	ReplaceKey    string // desktop notifications with the same key replace each other instead of stacking
+	Running       bool   // an update on work that has not finished yet, such as a timeout warning
+	TimedOut      bool   // the work ran past its timeout or budget, whether or not it was stopped
+	Escalated     bool   // the command keeps failing, see streaks; delivered with critical urgency
+	Terminal      Terminal // the terminal a command ran in, when known
+	Local         bool     // reported by this machine's own wrapper or shell hook, which makes the command re-runnable
+}
+
+// outcome classifies the event for channels' notify_on: "timeout", "success" or "failure"
+func (n *Notification) outcome() string {
+	switch {
+	case n.TimedOut:
+		return "timeout"
+	case n.Success:
+		return "success"
+	default:
+		return "failure"
+	}
+}
+
 func sendNotification(command string, duration time.Duration, success bool) {
+	sendCommandNotification(command, "", time.Time{}, duration, success)
+}
+
+// sendCommandNotification reports a finished command; startTime is zero when only the duration is known.
+// detail, such as what a VM tool built, is appended to the message.
+func sendCommandNotification(command, detail string, startTime time.Time, duration time.Duration, success bool) {
+	n := commandNotification(command, detail, startTime, duration, success)
+	n.Local = true
+	deliverNotification(n)
+}
+
+// commandNotification builds the notification for a finished command, for callers that add to it
+func commandNotification(command, detail string, startTime time.Time, duration time.Duration, success bool) *Notification {
 	status := "completed"
-	icon := "✅"
 	if !success {
 		status = "failed"
+	}
+	return finishedNotification(command, status, detail, startTime, duration, success)
+}
+
+// finishedNotification is commandNotification with how the command ended spelled out, such as
+// "terminated by SIGKILL"
+func finishedNotification(command, status, detail string, startTime time.Time, duration time.Duration, success bool) *Notification {
+	icon := "✅"
+	if !success {
 		icon = "❌"
 	}
 
-	title := "CmdBell"
 	message := fmt.Sprintf("Command '%s' %s after %s",
-		command, status, duration.Round(time.Second))
+		displayCommand(command), status, formatDuration(duration))
+	if detail != "" {
+		message += ": " + detail
+	}
 
-	// Always show console output as fallback
-	fmt.Printf("\n🔔 %s: %s\n", title, message)
+	return &Notification{
+		Title:    "CmdBell",
+		Message:  message,
+		Icon:     icon,
+		Source:    "command",
+		Command:   command,
+		Duration:  duration,
+		Success:   success,
+		StartTime: startTime,
+	}
+}
 
-	// Send native OS notification
-	err := sendNativeNotification(title, message, icon)
-	if err != nil {
-		fmt.Printf("Failed to send native notification: %v\n", err)
+func sendContainerNotification(command, containerName, user string, startTime time.Time, duration time.Duration, success bool) {
+	deliverNotification(containerNotification(command, containerName, user, startTime, duration, success))
+}
+
+// containerNotification builds the notification for a command reported by a container's hook
+func containerNotification(command, containerName, user string, startTime time.Time, duration time.Duration, success bool) *Notification {
+	status := "completed"
+	icon := "✅"
+	if !success {
+		status = "failed"
+		icon = "❌"
 	}
+
+	return &Notification{
+		Title: "CmdBell - Container",
+		Message: fmt.Sprintf("Command '%s' in '%s' %s after %s",
+			displayCommand(command), containerName, status, formatDuration(duration)),
+		Icon:          icon,
+		Source:        "container",
+		Command:       command,
+		ContainerName: containerName,
+		Duration:      duration,
+		Success:       success,
+		StartTime:     startTime,
+		User:          user,
+	}
+}
+
+// parseStartTime accepts the start time hooks send, Unix seconds such as 1760621520.123 or RFC 3339
+func parseStartTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	// BSD date has no %N, which leaves "1760621520.N"
+	if whole, _, found := strings.Cut(value, "."); found {
+		if seconds, err := strconv.ParseInt(whole, 10, 64); err == nil {
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Parse(time.RFC3339, value)
 }
 
-func sendContainerNotification(command, containerName string, duration time.Duration, success bool) {
+// timeSpan describes when work started and finished, e.g. "started 14:02, finished 14:49",
+// adding the day when it ran overnight; it is empty when both fall in the same minute
+func timeSpan(start, finish time.Time) string {
+	start, finish = start.Local(), finish.Local()
+	layout := "15:04"
+	if start.YearDay() != finish.YearDay() || start.Year() != finish.Year() {
+		layout = "Mon 15:04"
+	}
+
+	started, finished := start.Format(layout), finish.Format(layout)
+	if started == finished {
+		return ""
+	}
+	return fmt.Sprintf("started %s, finished %s", started, finished)
+}
+
+// sendExecNotification reports a `cmdbell docker exec` run, whose exit code is known exactly
+func sendExecNotification(command, containerName, service string, duration time.Duration, exitCode int) {
 	status := "completed"
+	icon := "✅"
+	if exitCode != 0 {
+		status = fmt.Sprintf("failed with exit code %d", exitCode)
+		icon = "❌"
+	}
+
+	target := fmt.Sprintf("'%s'", containerName)
+	if service != "" && service != containerName {
+		target = fmt.Sprintf("'%s' (service '%s')", containerName, service)
+	}
+
+	deliverNotification(&Notification{
+		Title: "CmdBell - Container",
+		Message: fmt.Sprintf("Command '%s' in %s %s after %s",
+			displayCommand(command), target, status, formatDuration(duration)),
+		Icon:          icon,
+		Source:        "container",
+		Command:       command,
+		ContainerName: containerName,
+		Service:       service,
+		Duration:      duration,
+		Success:       exitCode == 0,
+	})
+}
+
+func sendComposeNotification(project, outcome, breakdown string, duration time.Duration, success bool) {
 	icon := "✅"
+	if !success {
+		icon = "❌"
+	}
+
+	deliverNotification(&Notification{
+		Title: "CmdBell - Compose",
+		Message: fmt.Sprintf("Compose project '%s' %s after %s: %s",
+			project, outcome, formatDuration(duration), breakdown),
+		Icon:          icon,
+		Source:        "compose",
+		ContainerName: project,
+		Duration:      duration,
+		Success:       success,
+		TimedOut:      outcome == "timed out",
+	})
+}
+
+// scheduleNotification reports a finished scheduled job, for the scheduler to deliver
+func scheduleNotification(name, reason string, duration time.Duration) *Notification {
+	return &Notification{
+		Title: "CmdBell - Schedule",
+		Message: fmt.Sprintf("Scheduled job '%s' %s after %s",
+			name, reason, formatDuration(duration)),
+		Icon:     "⏰",
+		Source:   "schedule",
+		Duration: duration,
+	}
+}
+
+func sendFileNotification(ruleName, path, event string) {
+	deliverNotification(&Notification{
+		Title:   "CmdBell - File",
+		Message: fmt.Sprintf("File '%s' %s (%s)", path, event, ruleName),
+		Icon:    "📄",
+		Source:  "file",
+		Success: true,
+	})
+}
+
+// sendLogNotification reports a container log line that matched a log_watch rule
+func sendLogNotification(ruleName, containerName, line string, success bool) {
+	icon := "📜"
+	if !success {
+		icon = "❌"
+	}
+
+	deliverNotification(&Notification{
+		Title:         "CmdBell - Logs",
+		Message:       fmt.Sprintf("%s in '%s': %s", ruleName, containerName, line),
+		Icon:          icon,
+		Source:        "log",
+		ContainerName: containerName,
+		Success:       success,
+	})
+}
+
+func sendEndpointNotification(name, target string, up bool) {
+	status := "is now accepting connections"
+	icon := "🟢"
+	if !up {
+		status = "is no longer reachable"
+		icon = "🔴"
+	}
+
+	// Recovery replaces the outage toast rather than leaving both on screen
+	deliverNotification(&Notification{
+		Title:      "CmdBell - Endpoint",
+		Message:    fmt.Sprintf("%s (%s) %s", name, target, status),
+		Icon:       icon,
+		Source:     "endpoint",
+		Success:    up,
+		ReplaceKey: "endpoint-" + name,
+	})
+}
+
+// sendTaskNotification reports a task run by an editor such as VS Code, linking back to it when url is set
+func sendTaskNotification(editor, task, workspace, url string, duration time.Duration, success bool) {
+	deliverNotification(taskNotification(editor, task, workspace, url, duration, success))
+}
+
+// taskNotification builds the notification for a task finished by an editor task runner
+func taskNotification(editor, task, workspace, url string, duration time.Duration, success bool) *Notification {
+	status := "completed"
+	icon := "🛠️"
 	if !success {
 		status = "failed"
 		icon = "❌"
 	}
 
-	title := "CmdBell - Container"
-	message := fmt.Sprintf("Command '%s' in '%s' %s after %s",
-		command, containerName, status, duration.Round(time.Second))
+	message := fmt.Sprintf("Task '%s' %s after %s", task, status, formatDuration(duration))
+	if workspace != "" {
+		message += fmt.Sprintf(" in %s", workspace)
+	}
+
+	return &Notification{
+		Title:    "CmdBell - " + editor,
+		Message:  message,
+		Icon:     icon,
+		Source:   "editor",
+		Command:  task,
+		Duration: duration,
+		Success:  success,
+		URL:      url,
+	}
+}
+
+// sendCronNotification reports a cron job to remote channels only, with its output attached, and
+// returns the notification's ID
+func sendCronNotification(command string, duration time.Duration, exitCode int, output string) string {
+	status := "completed"
+	icon := "✅"
+	if exitCode != 0 {
+		status = fmt.Sprintf("failed with exit code %d", exitCode)
+		icon = "❌"
+	}
+
+	n := &Notification{
+		Title:      "CmdBell - Cron",
+		Message:    fmt.Sprintf("Cron job '%s' %s after %s", displayCommand(command), status, formatDuration(duration)),
+		Icon:       icon,
+		Source:     "cron",
+		Command:    command,
+		Duration:   duration,
+		Success:    exitCode == 0,
+		Output:     output,
+		RemoteOnly: true,
+		Local:      true,
+	}
+	deliverNotification(n)
+	return n.ID
+}
+
+// pendingDeliveries tracks notifications still being delivered in the background
+var pendingDeliveries sync.WaitGroup
+
+// notificationBus queues notifications in the daemon, whose HTTP handlers and monitors hand them
+// off instead of waiting for them to be delivered; nil in other commands
+var notificationBus atomic.Pointer[eventBus[*Notification]]
+
+// startNotificationBus has deliverNotification queue notifications for one delivery goroutine
+func startNotificationBus() {
+	notificationBus.Store(newEventBus("Notification", deliverNow))
+}
+
+// deliverNotification prints the message to the console and dispatches the native OS
+// notification and every remote channel concurrently, each bounded by notification.timeout.
+// It returns immediately; short-lived CLI paths call waitForDeliveries before exiting.
+func deliverNotification(n *Notification) {
+	// Queued notifications are identified and timed up front, so one saved in the daemon state
+	// and restored is neither lost nor delivered twice
+	if n.ID == "" {
+		n.ID = newEventID()
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	bus := notificationBus.Load()
+	if bus != nil && bus.Publish(n) {
+		return
+	}
+	// Without the daemon's bus this is the CLI, run from the terminal of the command it reports
+	if bus == nil && n.Source == "command" && n.Host == "" && n.Terminal.IsZero() {
+		n.Terminal = currentTerminal()
+	}
+	deliverNow(n)
+}
+
+// deliverNow delivers a notification from the calling goroutine
+func deliverNow(n *Notification) {
+	if suppressMuted(n) || suppressCooldown(n) {
+		return
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	if n.ID == "" {
+		n.ID = newEventID()
+	}
+	if id := envCorrelationID(); id != "" && n.CorrelationID == "" && n.Host == "" {
+		n.CorrelationID = id
+	}
+	if n.StartTime.IsZero() && n.Duration > 0 {
+		n.StartTime = n.Time.Add(-n.Duration)
+	}
+	escalateStreak(n)
+	// Commands are tagged with their category, whose emoji replaces the plain success mark
+	if n.Category == "" && n.Command != "" {
+		n.Category = classifyCommand(n.Command)
+	}
+	if icon := categoryIcon(n.Category); icon != "" && n.Icon == "✅" {
+		n.Icon = icon
+	}
+	// Forwarded events already carry the span, in the origin's time zone
+	if !n.StartTime.IsZero() && n.Host == "" {
+		if span := timeSpan(n.StartTime, n.StartTime.Add(n.Duration)); span != "" {
+			n.Message += " (" + span + ")"
+		}
+	}
 
 	// Always show console output as fallback
-	fmt.Printf("\n🔔 %s: %s\n", title, message)
+	if !n.RemoteOnly {
+		statusf("\n🔔 %s: %s\n", n.Title, n.Message)
+		if n.URL != "" {
+			statusf("   %s\n", n.URL)
+		}
+	}
 
-	// Send native OS notification
-	err := sendNativeNotification(title, message, icon)
-	if err != nil {
-		fmt.Printf("Failed to send native notification: %v\n", err)
+	recordHistory(n)
+	activeDBusService.publishEvent(n)
+
+	timeout := notificationTimeout()
+
+	trace := newDeliveredDecision(n)
+	tracef("routed", "%s event %s: %s", n.Source, n.ID, n.RemoteMessage())
+
+	// Send native OS notification, offering to copy failed commands for editing and re-running
+	if !n.RemoteOnly {
+		// Work still running has no final command line to copy, and its toast stays replaceable
+		copyText := ""
+		if !n.Success && !n.Running {
+			copyText = n.Command
+		}
+		message := desktopMessage(n)
+		if fakeNotifyPath() != "" {
+			// Integration tests read what the desktop would have shown back with cmdbell expect
+			dispatch(trace, "fake notification", "desktop", timeout, func(ctx context.Context) error {
+				return writeFakeNotification(n, n.Title, message)
+			})
+		} else if n.User != "" && systemMode() {
+			dispatch(trace, "native notification to "+n.User, "desktop "+n.User, timeout, func(ctx context.Context) error {
+				return sendUserNativeNotification(ctx, n.User, n.Title, message)
+			})
+		} else {
+			style := desktopStyle(n)
+			dispatch(trace, "native notification", "desktop", timeout, func(ctx context.Context) error {
+				return sendNativeNotification(ctx, n.Title, message, n.Icon, copyText, n.ID, style)
+			})
+		}
+	}
+
+	for _, channel := range getChannels() {
+		// Events received from other machines are never forwarded to a hub again
+		if n.Host != "" && forwardsToHub(channel) {
+			trace.record("channel "+channel.Name(), "skipped", fmt.Errorf("event came from %s", n.Host))
+			tracef("routed", "%s skips channel %s, the event came from %s", n.ID, channel.Name(), n.Host)
+			continue
+		}
+		if !channelAccepts(channel, n) {
+			trace.record("channel "+channel.Name(), "skipped", fmt.Errorf("channel belongs to another user"))
+			continue
+		}
+		if !channelNotifiesOn(channel, n) {
+			trace.record("channel "+channel.Name(), "skipped", fmt.Errorf("notify_on leaves out %s", n.outcome()))
+			tracef("routed", "%s skips channel %s, its notify_on leaves out %s", n.ID, channel.Name(), n.outcome())
+			continue
+		}
+		if dryRun, ok := channel.(*dryRunChannel); ok {
+			dryRun.Send(context.Background(), n)
+			trace.record("channel "+channel.Name(), "skipped", fmt.Errorf("dry run"))
+			continue
+		}
+
+		channelPoolFor(channel).submit(trace, n, timeout)
 	}
+
+	// Write the trace once every delivery has reported back
+	pendingDeliveries.Add(1)
+	go func() {
+		defer pendingDeliveries.Done()
+		trace.wg.Wait()
+		appendDecision(trace.decision)
+	}()
 }
 
-func sendNativeNotification(title, message, icon string) error {
+func dispatch(trace *decisionTrace, description, target string, timeout time.Duration, send func(ctx context.Context) error) {
+	pendingDeliveries.Add(1)
+	trace.wg.Add(1)
+	deliverySlots <- struct{}{}
+	go func() {
+		defer pendingDeliveries.Done()
+		defer trace.wg.Done()
+		defer func() { <-deliverySlots }()
+		runDelivery(trace, description, target, timeout, send)
+	}()
+}
+
+// runDelivery makes one send and records its outcome in the decision trace
+func runDelivery(trace *decisionTrace, description, target string, timeout time.Duration, send func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	started := time.Now()
+	if err := send(ctx); err != nil {
+		errorf("Failed to send %s: %v\n", description, err)
+		trace.recordSend(target, started, err)
+		tracef("delivered", "%s to %s failed: %v", trace.decision.ID, target, err)
+		return err
+	}
+	trace.recordSend(target, started, nil)
+	tracef("delivered", "%s to %s", trace.decision.ID, target)
+	return nil
+}
+
+// forwardsToHub reports whether a channel sends events on to a hub, directly or through the outbox
+func forwardsToHub(channel Channel) bool {
+	switch channel.(type) {
+	case *hubChannel, *queueChannel:
+		return true
+	}
+	return false
+}
+
+// waitForDeliveries blocks until background deliveries have finished or timed out
+func waitForDeliveries() {
+	pendingDeliveries.Wait()
+}
+
+func notificationTimeout() time.Duration {
+	if globalConfig != nil && globalConfig.Notification.Timeout != "" {
+		if timeout, err := time.ParseDuration(globalConfig.Notification.Timeout); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+	return 10 * time.Second
+}
+
+// RemoteMessage returns the message with the command sanitized for leaving this machine
+func (n *Notification) RemoteMessage() string {
+	if n.Command == "" {
+		return n.Message
+	}
+	// Messages carry the shortened command, whose secrets must be hidden all the same
+	message := strings.ReplaceAll(n.Message, n.Command, sanitizeCommand(n.Command))
+	return strings.ReplaceAll(message, displayCommand(n.Command), displayCommand(sanitizeCommand(n.Command)))
+}
+
+// sendNativeNotification shows a desktop notification; a non-empty copyText adds a
+// "Copy command" action where the platform's notifier supports one, and clicking it also
+// acknowledges eventID. style sets the sound and urgency where the notifier has them.
+func sendNativeNotification(ctx context.Context, title, message, icon, copyText, eventID string, style notificationStyle) error {
 	switch runtime.GOOS {
 	case "darwin":
-		return sendMacOSNotification(title, message, icon)
+		return sendMacOSNotification(ctx, title, message, icon, copyText, eventID, style)
 	case "linux":
-		return sendLinuxNotification(title, message, icon)
+		return sendLinuxNotification(ctx, title, message, icon, copyText, eventID, style)
 	case "windows":
-		return sendWindowsNotification(title, message, icon)
+		return sendWindowsNotification(ctx, title, message, icon, copyText, eventID, style)
 	default:
 		return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
 	}
 }
 
-func sendMacOSNotification(title, message, icon string) error {
+func sendMacOSNotification(ctx context.Context, title, message, icon, copyText, eventID string, style notificationStyle) error {
+	if style.Alert {
+		if err := startMacOSAlert(title, message, icon, copyText, eventID, style); err == nil {
+			return nil
+		}
+	}
+
+	// osascript notifications cannot run anything when clicked or replace earlier ones, terminal-notifier can
+	if copyText != "" || style.ReplaceKey != "" {
+		if _, err := exec.LookPath("terminal-notifier"); err == nil {
+			args := []string{"-title", title, "-subtitle", icon}
+			if copyText != "" {
+				onClick := "printf '%s' " + shellQuote(copyText) + " | pbcopy"
+				if ack := clickAckCommand(eventID); ack != nil {
+					onClick += "; " + shellQuote(ack[0]) + " " + strings.Join(ack[1:], " ") + " >/dev/null"
+				}
+				args = append(args, "-message", message+"\nClick to copy the command", "-execute", onClick)
+			} else {
+				args = append(args, "-message", message)
+			}
+			if style.ReplaceKey != "" {
+				args = append(args, "-group", "cmdbell-"+style.ReplaceKey)
+			}
+			if style.Sound != "" {
+				args = append(args, "-sound", style.Sound)
+			}
+			return exec.CommandContext(ctx, "terminal-notifier", args...).Run()
+		}
+	}
+
 	script := fmt.Sprintf(`display notification "%s" with title "%s" subtitle "%s"`,
 		escapeAppleScript(message), escapeAppleScript(title), icon)
+	if style.Sound != "" {
+		script += fmt.Sprintf(` sound name "%s"`, escapeAppleScript(style.Sound))
+	}
 
-	cmd := exec.Command("osascript", "-e", script)
+	cmd := exec.CommandContext(ctx, "osascript", "-e", script)
 	return cmd.Run()
 }
 
-func sendLinuxNotification(title, message, icon string) error {
+// startMacOSAlert shows a notification that stays until dismissed. alerter posts a Notification
+// Center alert, whatever the banner style set for CmdBell in System Settings; without it an
+// osascript alert dialog stands in. Both block until answered, so they run detached, and
+// answering acknowledges the event. Action buttons need alerter.
+func startMacOSAlert(title, message, icon, copyText, eventID string, style notificationStyle) error {
+	ack := clickAckCommand(eventID)
+
+	if _, err := exec.LookPath("alerter"); err == nil {
+		script := `answer=$(alerter "$@")
+case "$answer" in ""|@TIMEOUT) exit 0 ;; esac
+[ -n "$CMDBELL_ACK_EXE" ] && "$CMDBELL_ACK_EXE" open "$CMDBELL_ACK_LINK" >/dev/null 2>&1
+[ "$answer" = "Copy command" ] && printf '%s' "$CMDBELL_COPY" | pbcopy
+name=$(printf '%s\n' "$CMDBELL_BUTTONS" | awk -F '\t' -v answer="$answer" '$1 == answer { print $2; exit }')
+[ -n "$name" ] && [ -n "$CMDBELL_ACK_EXE" ] && "$CMDBELL_ACK_EXE" open "` + deepLinkScheme + `action/$name/$CMDBELL_EVENT" >/dev/null 2>&1
+exit 0`
+		args := []string{"-c", script, "cmdbell", "-title", title, "-subtitle", icon, "-message", message, "-closeLabel", "Dismiss"}
+		var labels, buttons []string
+		if copyText != "" {
+			labels = append(labels, "Copy command")
+		}
+		for _, button := range style.Buttons {
+			labels = append(labels, button.Button)
+			buttons = append(buttons, button.Button+"\t"+button.Name)
+		}
+		if len(labels) > 0 {
+			args = append(args, "-actions", strings.Join(labels, ","))
+		}
+		if style.ReplaceKey != "" {
+			args = append(args, "-group", "cmdbell-"+style.ReplaceKey)
+		}
+		if style.Sound != "" {
+			args = append(args, "-sound", style.Sound)
+		}
+		cmd := exec.Command("sh", args...)
+		cmd.Env = append(os.Environ(), "CMDBELL_COPY="+copyText, "CMDBELL_BUTTONS="+strings.Join(buttons, "\n"), "CMDBELL_EVENT="+eventID)
+		if ack != nil {
+			cmd.Env = append(cmd.Env, "CMDBELL_ACK_EXE="+ack[0], "CMDBELL_ACK_LINK="+ack[2])
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		go cmd.Wait()
+		return nil
+	}
+
+	kind, buttons := "informational", `{"OK"}`
+	if copyText != "" {
+		kind, buttons = "critical", `{"Copy command", "OK"}`
+	}
+	script := fmt.Sprintf(`set answer to display alert "%s" message "%s" as %s buttons %s default button "OK"
+if button returned of answer is "Copy command" then set the clipboard to "%s"`,
+		escapeAppleScript(icon+" "+title), escapeAppleScript(message), kind, buttons, escapeAppleScript(copyText))
+	if ack != nil {
+		script += fmt.Sprintf("\ndo shell script \"%s\"", escapeAppleScript(shellQuote(ack[0])+" "+strings.Join(ack[1:], " ")+" >/dev/null"))
+	}
+	cmd := exec.Command("osascript", "-e", script)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+func sendLinuxNotification(ctx context.Context, title, message, icon, copyText, eventID string, style notificationStyle) error {
 	// Check if we're in a headless environment
-	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+	if !guiSession() {
 		return fmt.Errorf("no GUI environment detected (headless mode)")
 	}
 
+	// Sandboxed terminals usually cannot reach the notification server, the portal is their way out
+	if sandboxed() {
+		if err := sendPortalNotification(ctx, title, message, eventID, style); err == nil {
+			return nil
+		}
+	}
+
+	if (copyText != "" || len(style.Buttons) > 0) && notifySendSupportsActions() {
+		if err := startLinuxActionNotification(title, message, copyText, eventID, style); err == nil {
+			return nil
+		}
+	}
+
 	// Try notify-send first (most common)
 	if _, err := exec.LookPath("notify-send"); err == nil {
-		cmd := exec.Command("notify-send", title, message, "--icon=info")
-		if err := cmd.Run(); err == nil {
+		args := append(notifySendStyle(style), title, message, "--icon=info")
+		if id := replaceID(style.ReplaceKey); id != "" {
+			args = append(args, "--replace-id="+id)
+		}
+		if style.ReplaceKey != "" && notifySendSupportsActions() {
+			// The server assigns the ID, which the next notification with the key replaces
+			cmd := exec.CommandContext(ctx, "notify-send", append(args, "--print-id")...)
+			if output, err := cmd.Output(); err == nil {
+				setReplaceID(style.ReplaceKey, strings.TrimSpace(string(output)))
+				return nil
+			}
+		} else if err := exec.CommandContext(ctx, "notify-send", args...).Run(); err == nil {
+			return nil
+		}
+	}
+
+	// Without notify-send, the portal still reaches the desktop's own notifications, which suit
+	// Wayland sessions better than kdialog and zenity popups
+	if !sandboxed() {
+		if err := sendPortalNotification(ctx, title, message, eventID, style); err == nil {
 			return nil
 		}
 	}
 
 	// Fallback to kdialog (KDE)
 	if _, err := exec.LookPath("kdialog"); err == nil {
-		cmd := exec.Command("kdialog", "--passivepopup", fmt.Sprintf("%s\n%s", title, message), "5")
+		cmd := exec.CommandContext(ctx, "kdialog", "--passivepopup", fmt.Sprintf("%s\n%s", title, message), "5")
 		if err := cmd.Run(); err == nil {
 			return nil
 		}
@@ -98,7 +699,7 @@ func sendLinuxNotification(title, message, icon string) error {
 
 	// Fallback to zenity (GNOME)
 	if _, err := exec.LookPath("zenity"); err == nil {
-		cmd := exec.Command("zenity", "--info", "--text", fmt.Sprintf("%s\n%s", title, message), "--timeout=5")
+		cmd := exec.CommandContext(ctx, "zenity", "--info", "--text", fmt.Sprintf("%s\n%s", title, message), "--timeout=5")
 		if err := cmd.Run(); err == nil {
 			return nil
 		}
@@ -107,22 +708,150 @@ func sendLinuxNotification(title, message, icon string) error {
 	return fmt.Errorf("no working notification tool found or GUI not available")
 }
 
-func sendWindowsNotification(title, message, icon string) error {
+var (
+	notifySendActionsOnce sync.Once
+	notifySendActions     bool
+)
+
+// notifySendSupportsActions reports whether notify-send is new enough (libnotify 0.7.10) for
+// --action, and with it --print-id and --replace-id
+func notifySendSupportsActions() bool {
+	notifySendActionsOnce.Do(func() {
+		output, err := exec.Command("notify-send", "--help").CombinedOutput()
+		notifySendActions = err == nil && strings.Contains(string(output), "--action")
+	})
+	return notifySendActions
+}
+
+// startLinuxActionNotification shows a notification with buttons: "Copy command" and the configured
+// action buttons. notify-send blocks until the notification is closed, so it runs detached in the
+// background instead of holding up delivery, and outlives short-lived CLI processes. Any button
+// also acknowledges the event.
+func startLinuxActionNotification(title, message, copyText, eventID string, style notificationStyle) error {
+	var clipboard []string
+	if copyText != "" {
+		tool, err := clipboardCommand()
+		if err != nil && len(style.Buttons) == 0 {
+			return err
+		}
+		clipboard = tool
+	}
+
+	script := `answer=$(notify-send "$@")
+[ -n "$answer" ] || exit 0
+[ -n "$CMDBELL_EXE" ] && "$CMDBELL_EXE" open "$CMDBELL_ACK_LINK" >/dev/null 2>&1
+case "$answer" in
+copy) printf '%s' "$CMDBELL_COPY" | eval "$CMDBELL_CLIPBOARD" ;;
+action:*) [ -n "$CMDBELL_EXE" ] && "$CMDBELL_EXE" open "` + deepLinkScheme + `action/${answer#action:}/$CMDBELL_EVENT" >/dev/null 2>&1 ;;
+esac
+exit 0`
+	args := append([]string{"-c", script, "cmdbell"}, notifySendStyle(style)...)
+	args = append(args, "--icon=info", "--wait")
+	if len(clipboard) > 0 {
+		args = append(args, "--action=copy=Copy command")
+	}
+	for _, button := range style.Buttons {
+		args = append(args, "--action=action:"+button.Name+"="+button.Button)
+	}
+	if id := replaceID(style.ReplaceKey); id != "" {
+		args = append(args, "--replace-id="+id)
+	}
+	args = append(args, title, message)
+
+	quoted := make([]string, len(clipboard))
+	for i, arg := range clipboard {
+		quoted[i] = shellQuote(arg)
+	}
+	cmd := exec.Command("sh", args...)
+	cmd.Env = append(os.Environ(), "CMDBELL_COPY="+copyText, "CMDBELL_CLIPBOARD="+strings.Join(quoted, " "), "CMDBELL_EVENT="+eventID)
+	if ack := clickAckCommand(eventID); ack != nil {
+		cmd.Env = append(cmd.Env, "CMDBELL_EXE="+ack[0], "CMDBELL_ACK_LINK="+ack[2])
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// notifySendStyle returns notify-send's urgency and, with a sound, its sound-name hint
+func notifySendStyle(style notificationStyle) []string {
+	options := []string{"--urgency=" + style.Urgency}
+	if style.Sound != "" {
+		options = append(options, "--hint=string:sound-name:"+style.Sound)
+	}
+	return options
+}
+
+var (
+	replaceIDsMu sync.Mutex
+	replaceIDs   = map[string]string{}
+)
+
+// replaceID returns the notification server's ID for the last notification sent with key
+func replaceID(key string) string {
+	if key == "" {
+		return ""
+	}
+	replaceIDsMu.Lock()
+	defer replaceIDsMu.Unlock()
+	return replaceIDs[key]
+}
+
+func setReplaceID(key, id string) {
+	replaceIDsMu.Lock()
+	defer replaceIDsMu.Unlock()
+	replaceIDs[key] = id
+}
+
+// windowsSounds are the system sounds PowerShell can play
+var windowsSounds = map[string]bool{"Asterisk": true, "Beep": true, "Exclamation": true, "Hand": true, "Question": true}
+
+func sendWindowsNotification(ctx context.Context, title, message, icon, copyText, eventID string, style notificationStyle) error {
+	// Toasts stay in Action Center; balloon tips are the fallback where WinRT is unavailable
+	if err := sendWindowsToast(ctx, title, message, icon, copyText, eventID, style); err == nil {
+		return nil
+	}
+
+	// Clicking the balloon copies the command and acknowledges the event; events need the
+	// message loop pumped while it shows. Balloon tips cannot replace each other, so
+	// style.ReplaceKey has no effect here.
+	onClick, wait := "", "Start-Sleep -Seconds 6;"
+	if copyText != "" {
+		message += "\nClick to copy the command"
+		ackScript := ""
+		if ack := clickAckCommand(eventID); ack != nil {
+			ackScript = fmt.Sprintf(" & '%s' open '%s' | Out-Null;", strings.ReplaceAll(ack[0], "'", "''"), ack[2])
+		}
+		onClick = fmt.Sprintf("$balloon.add_BalloonTipClicked({ Set-Clipboard -Value '%s';%s });", strings.ReplaceAll(copyText, "'", "''"), ackScript)
+		wait = "for ($i = 0; $i -lt 60; $i++) { [System.Windows.Forms.Application]::DoEvents(); Start-Sleep -Milliseconds 100 };"
+	}
+
+	tipIcon, sound := "Info", ""
+	if style.Urgency == "critical" {
+		tipIcon = "Warning"
+	}
+	if windowsSounds[style.Sound] {
+		sound = fmt.Sprintf("[System.Media.SystemSounds]::%s.Play();", style.Sound)
+	}
+
 	// Use PowerShell to show Windows toast notification
 	script := fmt.Sprintf(`
 		Add-Type -AssemblyName System.Windows.Forms;
 		$balloon = New-Object System.Windows.Forms.NotifyIcon;
 		$balloon.Icon = [System.Drawing.SystemIcons]::Information;
-		$balloon.BalloonTipIcon = "Info";
+		$balloon.BalloonTipIcon = "%s";
 		$balloon.BalloonTipText = "%s";
 		$balloon.BalloonTipTitle = "%s";
 		$balloon.Visible = $true;
+		%s
 		$balloon.ShowBalloonTip(5000);
-		Start-Sleep -Seconds 6;
+		%s
+		%s
 		$balloon.Dispose();
-	`, escapeWindowsString(message), escapeWindowsString(title))
+	`, tipIcon, escapeWindowsString(message), escapeWindowsString(title), onClick, sound, wait)
 
-	cmd := exec.Command("powershell", "-Command", script)
+	cmd := exec.CommandContext(ctx, "powershell", "-Command", script)
 	return cmd.Run()
 }
 
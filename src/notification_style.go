@@ -0,0 +1,106 @@
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+// notificationStyle is how loudly a desktop notification asks for attention
+type notificationStyle struct {
+	Bucket  string // the notification.buckets entry it came from, if any
+	Sound   string // the platform's name for the sound, "" for the notifier's default
+	Urgency string // "low", "normal" or "critical"
+
+	ReplaceKey string // the Notification's ReplaceKey, for notifiers that can update a toast in place
+	Alert      bool   // stay on screen until dismissed (macOS)
+	Quiet      bool   // within notification.quiet_hours: no sound, and no popup where that can be asked for
+
+	Buttons []ActionConfig // configured actions offered as buttons, following cmdbell://action links
+}
+
+// desktopStyle is styleFor with everything else native notifiers need to know about n
+func desktopStyle(n *Notification) notificationStyle {
+	style := styleFor(n)
+	style.ReplaceKey = n.ReplaceKey
+	style.Alert = alertStyle(n)
+	style.Buttons = notificationButtons(n)
+	if n.Escalated {
+		style.Urgency = "critical"
+	}
+	if inQuietHours(time.Now()) {
+		// Low urgency keeps GNOME and KDE from showing a banner
+		style.Quiet = true
+		style.Sound = ""
+		style.Urgency = "low"
+	}
+	return style
+}
+
+// soundNames maps the portable sound names used in notification.buckets to each platform's own.
+// Any other name is passed to the notifier as it is.
+var soundNames = map[string]map[string]string{
+	"ping":  {"darwin": "Tink", "linux": "message-new-instant", "windows": "Asterisk"},
+	"chime": {"darwin": "Glass", "linux": "complete", "windows": "Exclamation"},
+	"alarm": {"darwin": "Sosumi", "linux": "alarm-clock-elapsed", "windows": "Hand"},
+}
+
+// styleFor picks the duration bucket a notification falls in: the one with the highest min
+// the event's duration reaches. Events without a duration use the notifier's defaults.
+func styleFor(n *Notification) notificationStyle {
+	style := notificationStyle{Urgency: "normal"}
+	if globalConfig == nil || n.Duration <= 0 {
+		return style
+	}
+
+	var bucket *DurationBucket
+	var bucketMin time.Duration
+	for i, candidate := range globalConfig.Notification.Buckets {
+		min, err := time.ParseDuration(candidate.Min)
+		if err != nil || n.Duration < min {
+			continue
+		}
+		if bucket == nil || min >= bucketMin {
+			bucket, bucketMin = &globalConfig.Notification.Buckets[i], min
+		}
+	}
+	if bucket == nil {
+		return style
+	}
+
+	style.Bucket = bucket.Name
+	if bucket.Urgency != "" {
+		style.Urgency = bucket.Urgency
+	}
+	if globalConfig.Notification.Sound {
+		style.Sound = bucket.Sound
+		if names, ok := soundNames[bucket.Sound]; ok {
+			style.Sound = names[runtime.GOOS]
+		}
+	}
+	return style
+}
+
+// ntfyPriority maps an urgency onto ntfy's 1 to 5 priority scale
+func ntfyPriority(urgency string) string {
+	switch urgency {
+	case "low":
+		return "2"
+	case "critical":
+		return "5"
+	}
+	return "3"
+}
+
+// alertStyle reports whether a notification should stay on screen until dismissed, from
+// notification.macos_style and, for failures, notification.macos_failure_style. Updates on work
+// still running are always banners.
+func alertStyle(n *Notification) bool {
+	if globalConfig == nil || n.Running {
+		return false
+	}
+	style := globalConfig.Notification.MacOSStyle
+	if !n.Success && globalConfig.Notification.MacOSFailureStyle != "" {
+		style = globalConfig.Notification.MacOSFailureStyle
+	}
+	return style == "alert"
+}
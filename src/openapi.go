@@ -0,0 +1,9 @@
+package main
+
+import _ "embed"
+
+// openAPISpec documents the daemon HTTP API and is served at /openapi.json.
+// Keep it and the client package in sync with the handlers in http_server.go.
+//
+//go:embed openapi.json
+var openAPISpec []byte
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// outputLogDir is where `cmdbell run --log-output` keeps the output of wrapped commands
+func outputLogDir() (string, error) {
+	dir, err := getDataPath("outputs")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %v", err)
+	}
+	return dir, nil
+}
+
+// createOutputLog opens a timestamped file for a command's output, e.g. 20261016-020000-make.log
+func createOutputLog(command string) (*os.File, error) {
+	dir, err := outputLogDir()
+	if err != nil {
+		return nil, err
+	}
+
+	name := unsafeFileChars.ReplaceAllString(filepath.Base(command), "_")
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.log", time.Now().Format("20060102-150405"), name))
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+}
+
+// outputLogLink returns where a notification should point for a saved output: the daemon's
+// /outputs/ URL when it is running without tokens, which a browser could not present, otherwise the file
+func outputLogLink(path string) string {
+	if globalConfig != nil && globalConfig.HTTP.Enabled && len(globalConfig.HTTP.Tokens) == 0 && NewDaemon().IsRunning() {
+		return fmt.Sprintf("http://localhost:%d/outputs/%s", daemonPort(), filepath.Base(path))
+	}
+	return "file://" + path
+}
+
+// handleOutput serves a saved command output from ~/.cmdbell/outputs
+func (hs *HTTPServer) handleOutput(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/outputs/")
+	if name == "" || name != filepath.Base(name) || !strings.HasSuffix(name, ".log") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	dir, err := outputLogDir()
+	if err != nil {
+		log.Printf("Failed to open output directory: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, filepath.Join(dir, name))
+}
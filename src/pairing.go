@@ -0,0 +1,296 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// PairedDevice is an API token issued to a companion app through `cmdbell pair`
+type PairedDevice struct {
+	Name     string    `json:"name"`
+	Token    string    `json:"token"`
+	Scopes   []string  `json:"scopes"`
+	PairedAt time.Time `json:"paired_at"`
+}
+
+func (d PairedDevice) apiToken() APIToken {
+	return APIToken{Name: d.Name, Token: d.Token, Scopes: d.Scopes}
+}
+
+func loadPairedDevices() ([]PairedDevice, error) {
+	path, err := getDataPath("devices.json")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []PairedDevice
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return devices, nil
+}
+
+func savePairedDevices(devices []PairedDevice) error {
+	path, err := getDataPath("devices.json")
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(devices, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0600)
+}
+
+func generateDeviceToken() (string, error) {
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// pairingURL encodes everything a companion app needs to talk to this daemon
+func pairingURL(serverURL, name, token string) string {
+	query := url.Values{}
+	query.Set("url", serverURL)
+	query.Set("name", name)
+	query.Set("token", token)
+	return "cmdbell://pair?" + query.Encode()
+}
+
+// ntfySubscriptionURL turns an ntfy topic URL into the deep link the ntfy app subscribes with
+func ntfySubscriptionURL(topicURL string) (string, error) {
+	parsed, err := url.Parse(topicURL)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("invalid ntfy url: %s", topicURL)
+	}
+	return "ntfy://" + parsed.Host + parsed.Path, nil
+}
+
+// defaultServerURL guesses the address other devices on the LAN reach this daemon at
+func defaultServerURL(port int) string {
+	host := "localhost"
+	for _, ip := range lanAddresses() {
+		if ip.To4() != nil {
+			host = ip.String()
+			break
+		}
+	}
+	return fmt.Sprintf("http://%s", net.JoinHostPort(host, fmt.Sprint(port)))
+}
+
+func printQRCode(content string, invert bool) error {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("failed to generate QR code: %v", err)
+	}
+	fmt.Print(code.ToSmallString(invert))
+	return nil
+}
+
+func handlePairCommand() {
+	hostname, _ := os.Hostname()
+	name := "mobile"
+	serverURL := ""
+	scopes := []string{"notify", "history"}
+	useNtfy := false
+	invert := false
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--name":
+			if i+1 < len(args) {
+				i++
+				name = args[i]
+			}
+		case "--url":
+			if i+1 < len(args) {
+				i++
+				serverURL = args[i]
+			}
+		case "--scopes":
+			if i+1 < len(args) {
+				i++
+				scopes = strings.Split(args[i], ",")
+			}
+		case "--ntfy":
+			useNtfy = true
+		case "--invert":
+			invert = true
+		case "--list":
+			listPairedDevices()
+			return
+		case "--revoke":
+			if i+1 >= len(args) {
+				fmt.Println("Usage: cmdbell pair --revoke <name>")
+				os.Exit(1)
+			}
+			revokePairedDevice(args[i+1])
+			return
+		default:
+			fmt.Printf("Unknown option: %s\n", args[i])
+			printPairUsage()
+			os.Exit(1)
+		}
+	}
+
+	if useNtfy {
+		pairNtfy(invert)
+		return
+	}
+
+	for _, scope := range scopes {
+		if !containsString([]string{"notify", "history", "admin"}, scope) {
+			fmt.Printf("Invalid scope %q (expected notify, history or admin)\n", scope)
+			os.Exit(1)
+		}
+	}
+
+	devices, err := loadPairedDevices()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	for _, device := range devices {
+		if device.Name == name {
+			fmt.Printf("A device named %q is already paired. Revoke it first or choose another --name.\n", name)
+			os.Exit(1)
+		}
+	}
+
+	token, err := generateDeviceToken()
+	if err != nil {
+		fmt.Printf("❌ Failed to generate token: %v\n", err)
+		os.Exit(1)
+	}
+
+	devices = append(devices, PairedDevice{
+		Name:     name,
+		Token:    token,
+		Scopes:   scopes,
+		PairedAt: time.Now(),
+	})
+	if err := savePairedDevices(devices); err != nil {
+		fmt.Printf("❌ Failed to save device: %v\n", err)
+		os.Exit(1)
+	}
+	recordConfigChange(ConfigChange{
+		Actor:   cliActor(),
+		Action:  "device.pair",
+		Target:  "devices",
+		Summary: fmt.Sprintf("paired '%s' with scopes %s", name, strings.Join(scopes, ",")),
+	})
+
+	if serverURL == "" {
+		serverURL = defaultServerURL(daemonPort())
+	}
+
+	fmt.Printf("📱 Scan to connect %q to CmdBell on %s:\n\n", name, hostname)
+	if err := printQRCode(pairingURL(serverURL, name, token), invert); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nURL:    %s\nToken:  %s\nScopes: %s\n", serverURL, token, strings.Join(scopes, ","))
+	if len(globalConfig.HTTP.Tokens) == 0 {
+		fmt.Println("\n🔒 Requests from other machines now need a token, such as this one. This machine's")
+		fmt.Println("   hooks and CLI can still reach the API over localhost without one; set http.tokens")
+		fmt.Println("   to require tokens there too.")
+	}
+}
+
+func pairNtfy(invert bool) {
+	for _, channel := range globalConfig.Channels {
+		if channel.Type != "ntfy" {
+			continue
+		}
+
+		subscription, err := ntfySubscriptionURL(channel.URL)
+		if err != nil {
+			fmt.Printf("❌ Channel %q: %v\n", channel.Name, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("📱 Scan with the ntfy app to subscribe to %s:\n\n", channel.URL)
+		if err := printQRCode(subscription, invert); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Println("No ntfy channel is configured. Add one under channels: with type: ntfy.")
+	os.Exit(1)
+}
+
+func listPairedDevices() {
+	devices, err := loadPairedDevices()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	if len(devices) == 0 {
+		fmt.Println("No paired devices")
+		return
+	}
+	for _, device := range devices {
+		fmt.Printf("  %-20s %-20s paired %s\n", device.Name, strings.Join(device.Scopes, ","), device.PairedAt.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+func revokePairedDevice(name string) {
+	devices, err := loadPairedDevices()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	kept := devices[:0]
+	for _, device := range devices {
+		if device.Name != name {
+			kept = append(kept, device)
+		}
+	}
+	if len(kept) == len(devices) {
+		fmt.Printf("No paired device named %q\n", name)
+		os.Exit(1)
+	}
+
+	if err := savePairedDevices(kept); err != nil {
+		fmt.Printf("❌ Failed to save devices: %v\n", err)
+		os.Exit(1)
+	}
+	recordConfigChange(ConfigChange{Actor: cliActor(), Action: "device.revoke", Target: "devices", Summary: fmt.Sprintf("revoked '%s'", name)})
+	fmt.Printf("✅ Revoked %s\n", name)
+	if len(kept) == 0 && len(globalConfig.HTTP.Tokens) == 0 {
+		fmt.Println("⚠️  No devices are paired and no http.tokens are configured, so the API accepts")
+		fmt.Println("   requests without a token from anywhere on the network again.")
+	}
+}
+
+func printPairUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  cmdbell pair [--name N] [--url URL] [--scopes notify,history] [--invert]")
+	fmt.Println("  cmdbell pair --ntfy              - QR code subscribing the ntfy app to the ntfy channel")
+	fmt.Println("  cmdbell pair --list              - List paired devices")
+	fmt.Println("  cmdbell pair --revoke <name>     - Revoke a paired device's token")
+}
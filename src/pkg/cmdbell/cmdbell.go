@@ -0,0 +1,166 @@
+// Package cmdbell sends CmdBell notifications from Go programs.
+//
+// Notifications are handed to the CmdBell daemon, which owns dispatch: it applies the user's
+// rules (thresholds, mutes, quiet hours, categories) and delivers to the desktop and every
+// configured channel, so a tool using this package notifies exactly like a command run under
+// the cmdbell CLI:
+//
+//	start := time.Now()
+//	err := release()
+//	cmdbell.Notify(ctx, cmdbell.Notification{
+//		Command:  "release v1.4.0",
+//		Duration: time.Since(start),
+//		Success:  err == nil,
+//	})
+//
+// A Notification with a Message is delivered as written instead of being judged as a finished
+// command, as an event from its Host like those a hub receives. The daemon is found through
+// CMDBELL_URL and CMDBELL_TOKEN, or else the daemon running on this machine; New reaches any
+// other daemon or hub.
+//
+// Rules is the threshold logic the daemon itself runs, for tools that decide locally whether a
+// command is worth reporting before sending it. Delivery to the desktop and channels is not part
+// of this package: it depends on the daemon's config, credentials and state, so a notification
+// always goes through a daemon.
+package cmdbell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+)
+
+const (
+	// DefaultRetries is how often a Notifier retries while the daemon is unreachable or overloaded
+	DefaultRetries = 4
+
+	attemptTimeout = 10 * time.Second
+	firstBackoff   = time.Second
+)
+
+// Notification is a finished command, or a message, to notify about
+type Notification struct {
+	// Command that finished; with Duration and Success, the daemon decides whether it is worth a
+	// notification by the user's thresholds
+	Command   string
+	Duration  time.Duration
+	Success   bool
+	StartedAt time.Time // defaults to Duration before now
+
+	// Message, when set, is delivered as is, under Title
+	Title   string
+	Message string
+	Icon    string
+	Source  string // what sent the event, shown in history; defaults to the daemon's choice
+	URL     string // jump-back link opened from the notification
+
+	Host          string // machine or container the command ran on; defaults to the hostname
+	CorrelationID string // the caller's ID for the job behind the event
+}
+
+// Notifier sends notifications to one daemon or hub
+type Notifier struct {
+	Client  *client.Client
+	Retries int // attempts after the first while the daemon is unreachable
+}
+
+// New returns a Notifier for the daemon at url with token. An empty url or token is taken
+// from CMDBELL_URL or CMDBELL_TOKEN, and the url falls back to the daemon on this machine.
+func New(url, token string) *Notifier {
+	if url == "" {
+		url = os.Getenv("CMDBELL_URL")
+	}
+	if url == "" {
+		url = client.LocalURL()
+	}
+	if token == "" {
+		token = os.Getenv("CMDBELL_TOKEN")
+	}
+	return &Notifier{Client: client.New(url, token), Retries: DefaultRetries}
+}
+
+// Notify sends n to the daemon found from the environment
+func Notify(ctx context.Context, n Notification) error {
+	return New("", "").Notify(ctx, n)
+}
+
+// Notify sends n, retrying with exponential backoff while the daemon is unreachable or
+// overloaded. Requests the daemon rejects, such as with a bad token, are not retried.
+func (nt *Notifier) Notify(ctx context.Context, n Notification) error {
+	if n.Command == "" && n.Message == "" {
+		return errors.New("cmdbell: a notification needs a command or a message")
+	}
+	if n.Host == "" {
+		n.Host, _ = os.Hostname()
+	}
+	if n.StartedAt.IsZero() && n.Duration > 0 {
+		n.StartedAt = time.Now().Add(-n.Duration)
+	}
+
+	backoff := firstBackoff
+	for attempt := 0; ; attempt++ {
+		err := nt.send(ctx, n)
+		if err == nil || attempt >= nt.Retries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (nt *Notifier) send(ctx context.Context, n Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, attemptTimeout)
+	defer cancel()
+
+	if n.Message != "" {
+		return nt.Client.PostEvent(ctx, client.Event{
+			CorrelationID:   n.CorrelationID,
+			Title:           n.Title,
+			Message:         n.Message,
+			Icon:            n.Icon,
+			Source:          n.Source,
+			Command:         n.Command,
+			DurationSeconds: n.Duration.Seconds(),
+			Success:         n.Success,
+			Host:            n.Host,
+			URL:             n.URL,
+			StartedAt:       n.StartedAt,
+			Time:            time.Now(),
+		})
+	}
+
+	request := client.NotificationRequest{
+		Command:       n.Command,
+		ContainerName: n.Host,
+		Duration:      fmt.Sprintf("%.0fs", n.Duration.Seconds()),
+		Success:       n.Success,
+		CorrelationID: n.CorrelationID,
+	}
+	if !n.StartedAt.IsZero() {
+		request.StartTime = fmt.Sprintf("%d.%09d", n.StartedAt.Unix(), n.StartedAt.Nanosecond())
+	}
+	return nt.Client.Notify(ctx, request)
+}
+
+// retryable reports whether a send failed before the daemon took the notification. A timeout or
+// a connection dropped mid-request may follow a delivery, and retrying it would notify twice.
+func retryable(err error) bool {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusServiceUnavailable
+	}
+	var opErr *net.OpError
+	return (errors.As(err, &opErr) && opErr.Op == "dial") || errors.Is(err, syscall.ECONNREFUSED)
+}
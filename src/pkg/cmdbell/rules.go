@@ -0,0 +1,141 @@
+package cmdbell
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMinDuration is the threshold for commands when no config sets general.min_duration
+const DefaultMinDuration = 15 * time.Second
+
+// Rules decides which finished commands are worth a notification, the way the daemon applies
+// general.min_duration and the thresholds section of its config
+type Rules struct {
+	MinDuration time.Duration     // threshold for commands no rule matches
+	Tiers       map[string]string // tier name to duration, such as "slow": "5m"
+	Thresholds  []ThresholdRule   // the first rule matching a command picks its tier
+	Ignore      []string          // command names or prefixes that never notify, such as "vim"
+}
+
+// ThresholdRule assigns commands to a tier
+type ThresholdRule struct {
+	Tier     string
+	Commands []string // command names or prefixes such as "npm run build"
+	Pattern  string   // regex matched against the whole command line
+}
+
+// Threshold is the minimum duration before a command notifies, and why it applies
+type Threshold struct {
+	Duration time.Duration
+	Tier     string // empty when MinDuration applies
+	Reason   string
+}
+
+// Threshold returns the minimum duration before commandLine notifies: the tier of the first
+// matching rule, otherwise MinDuration
+func (r *Rules) Threshold(commandLine string) Threshold {
+	fallback := Threshold{Duration: r.MinDuration, Reason: "general.min_duration"}
+
+	for i, rule := range r.Thresholds {
+		match := MatchCommand(rule.Commands, rule.Pattern, commandLine)
+		if match == "" {
+			continue
+		}
+
+		value, ok := r.Tiers[rule.Tier]
+		if !ok {
+			fallback.Reason = fmt.Sprintf("general.min_duration, rule %d names unknown tier %q", i+1, rule.Tier)
+			return fallback
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			fallback.Reason = fmt.Sprintf("general.min_duration, tier %q has invalid duration %q", rule.Tier, value)
+			return fallback
+		}
+		return Threshold{
+			Duration: duration,
+			Tier:     rule.Tier,
+			Reason:   fmt.Sprintf("thresholds rule %d matched %s", i+1, match),
+		}
+	}
+	return fallback
+}
+
+// Ignored describes the Ignore entry matching commandLine, or returns "" when none does
+func (r *Rules) Ignored(commandLine string) string {
+	return MatchCommand(r.Ignore, "", commandLine)
+}
+
+// ShouldNotify reports whether a run of commandLine that took duration is worth a notification
+func (r *Rules) ShouldNotify(commandLine string, duration time.Duration) bool {
+	return r.Ignored(commandLine) == "" && duration >= r.Threshold(commandLine).Duration
+}
+
+// Lowest is the shortest threshold any command can have, below which nothing notifies
+func (r *Rules) Lowest() time.Duration {
+	lowest := r.MinDuration
+	for _, rule := range r.Thresholds {
+		if duration, err := time.ParseDuration(r.Tiers[rule.Tier]); err == nil && duration < lowest {
+			lowest = duration
+		}
+	}
+	return lowest
+}
+
+// MatchCommand matches commandLine against command names or prefixes, such as "npm run build",
+// and a regex over the whole line, describing what matched or returning "" when nothing did
+func MatchCommand(commands []string, pattern, commandLine string) string {
+	commandLine = strings.TrimSpace(commandLine)
+	fields := strings.Fields(commandLine)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := filepath.Base(fields[0])
+	normalized := strings.Join(append([]string{name}, fields[1:]...), " ")
+
+	for _, command := range commands {
+		if normalized == command || strings.HasPrefix(normalized, command+" ") {
+			return fmt.Sprintf("command %q", command)
+		}
+	}
+
+	if pattern != "" {
+		if re, err := compilePattern(pattern); err == nil && re.MatchString(commandLine) {
+			return fmt.Sprintf("pattern %q", pattern)
+		}
+	}
+	return ""
+}
+
+// patterns holds each rule regex compiled, so matching a command does not compile it again
+var patterns sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// Compile compiles the rules' patterns up front, as a config is loaded, and reports the first
+// invalid one; a rule with an invalid pattern still matches by its commands
+func (r *Rules) Compile() error {
+	for i, rule := range r.Thresholds {
+		if rule.Pattern == "" {
+			continue
+		}
+		if _, err := compilePattern(rule.Pattern); err != nil {
+			return fmt.Errorf("thresholds rule %d has an invalid pattern: %w", i+1, err)
+		}
+	}
+	return nil
+}
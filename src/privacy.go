@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Built-in secret patterns. When a pattern has a "secret" group only that part is redacted.
+var defaultRedactPatterns = []string{
+	`(?i)--?(?:password|passwd|pass|token|secret|api[-_]?key|access[-_]?key|auth)[= ](?P<secret>\S+)`,
+	`(?i)\b[A-Z0-9_]*(?:PASSWORD|PASSWD|TOKEN|SECRET|API_KEY|ACCESS_KEY)[A-Z0-9_]*=(?P<secret>\S+)`,
+	`(?i)bearer\s+(?P<secret>[A-Za-z0-9._~+/=-]{8,})`,
+	`://[^/\s:@]+:(?P<secret>[^@\s]+)@`,
+	`\bAKIA[0-9A-Z]{16}\b`,
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`,
+}
+
+type CommandRedactor struct {
+	patterns       []*regexp.Regexp
+	mode           string
+	storeArguments bool
+}
+
+var (
+	redactorOnce    sync.Once
+	defaultRedactor *CommandRedactor
+)
+
+func NewCommandRedactor(config *Config) *CommandRedactor {
+	redactor := &CommandRedactor{
+		mode:           "mask",
+		storeArguments: true,
+	}
+
+	patterns := defaultRedactPatterns
+	if config != nil {
+		if config.History.Redact != "" {
+			redactor.mode = config.History.Redact
+		}
+		redactor.storeArguments = config.History.StoreArguments
+		patterns = append(patterns[:len(patterns):len(patterns)], config.History.RedactPatterns...)
+	}
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("⚠️  Ignoring invalid redact pattern %q: %v", pattern, err)
+			continue
+		}
+		redactor.patterns = append(redactor.patterns, re)
+	}
+
+	return redactor
+}
+
+// sanitizeCommand applies the configured privacy settings to a command line
+// before it is persisted or sent anywhere outside the desktop notification
+func sanitizeCommand(command string) string {
+	redactorOnce.Do(func() {
+		defaultRedactor = NewCommandRedactor(globalConfig)
+	})
+	return defaultRedactor.Sanitize(command)
+}
+
+func (r *CommandRedactor) Sanitize(command string) string {
+	if !r.storeArguments {
+		return binaryName(command)
+	}
+	if r.mode == "off" {
+		return command
+	}
+
+	for _, re := range r.patterns {
+		command = r.redactMatches(re, command)
+	}
+	return command
+}
+
+func (r *CommandRedactor) redactMatches(re *regexp.Regexp, command string) string {
+	secretGroup := re.SubexpIndex("secret")
+
+	var b strings.Builder
+	last := 0
+	for _, match := range re.FindAllStringSubmatchIndex(command, -1) {
+		start, end := match[0], match[1]
+		if secretGroup > 0 && match[2*secretGroup] >= 0 {
+			start, end = match[2*secretGroup], match[2*secretGroup+1]
+		}
+
+		b.WriteString(command[last:start])
+		b.WriteString(r.replacement(command[start:end]))
+		last = end
+	}
+	b.WriteString(command[last:])
+
+	return b.String()
+}
+
+func (r *CommandRedactor) replacement(secret string) string {
+	if r.mode == "hash" {
+		sum := sha256.Sum256([]byte(secret))
+		return "sha256:" + hex.EncodeToString(sum[:4])
+	}
+	return "***"
+}
+
+// binaryName returns the first word of a command line, skipping VAR=value prefixes
+func binaryName(command string) string {
+	for _, field := range strings.Fields(command) {
+		if !strings.Contains(field, "=") {
+			return field
+		}
+	}
+	return ""
+}
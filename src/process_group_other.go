@@ -0,0 +1,36 @@
+//go:build !(linux || darwin)
+
+package main
+
+import "os/exec"
+
+// processGroup runs the command directly where cmdbell does not manage process groups
+type processGroup struct {
+	cmd *exec.Cmd
+}
+
+func newProcessGroup(cmd *exec.Cmd) *processGroup {
+	return &processGroup{cmd: cmd}
+}
+
+func (g *processGroup) Start() error {
+	return g.cmd.Start()
+}
+
+func (g *processGroup) Wait() error {
+	return g.cmd.Wait()
+}
+
+// Terminate kills the command; there is no group to ask to exit first
+func (g *processGroup) Terminate() {
+	g.cmd.Process.Kill()
+}
+
+func (g *processGroup) Kill() {
+	g.cmd.Process.Kill()
+}
+
+// exitSignal always returns "", as commands here do not report being killed by a signal
+func exitSignal(err error) string {
+	return ""
+}
@@ -0,0 +1,176 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// pPID is waitid's P_PID id type, the same on Linux and macOS
+const pPID = 1
+
+// processGroup runs a wrapped command in a process group of its own, so signals reach everything
+// it starts and not only its first process. When cmdbell holds the terminal, the group gets the
+// foreground so the command can still read from it and Ctrl-C reaches the whole group directly.
+type processGroup struct {
+	cmd     *exec.Cmd
+	tty     *os.File // the controlling terminal, when the group was given it
+	signals chan os.Signal
+}
+
+func newProcessGroup(cmd *exec.Cmd) *processGroup {
+	g := &processGroup{cmd: cmd}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		if foregroundGroup(tty) == syscall.Getpgrp() {
+			g.tty = tty
+			cmd.SysProcAttr.Foreground = true
+			cmd.SysProcAttr.Ctty = int(tty.Fd())
+		} else {
+			tty.Close()
+		}
+	}
+	return g
+}
+
+// Start starts the command; SIGINT, SIGTERM and SIGHUP are forwarded to its group from then on
+func (g *processGroup) Start() error {
+	g.signals = make(chan os.Signal, 4)
+	signal.Notify(g.signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGCHLD)
+
+	if err := g.cmd.Start(); err != nil {
+		g.release()
+		return err
+	}
+	return nil
+}
+
+// Wait forwards signals to the group until the command exits
+func (g *processGroup) Wait() error {
+	defer g.release()
+	pgid := g.cmd.Process.Pid
+
+	done := make(chan error, 1)
+	go func() { done <- g.cmd.Wait() }()
+
+	for {
+		select {
+		case err := <-done:
+			if g.tty != nil {
+				setForegroundGroup(g.tty, syscall.Getpgrp())
+			}
+			return err
+		case sig := <-g.signals:
+			if sig != syscall.SIGCHLD {
+				syscall.Kill(-pgid, sig.(syscall.Signal))
+			} else if g.tty != nil && childStopped(pgid) {
+				g.suspend(pgid)
+			}
+		}
+	}
+}
+
+// Terminate asks every process in the group to exit
+func (g *processGroup) Terminate() {
+	syscall.Kill(-g.cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// Kill kills whatever is left of the group
+func (g *processGroup) Kill() {
+	syscall.Kill(-g.cmd.Process.Pid, syscall.SIGKILL)
+}
+
+func (g *processGroup) release() {
+	signal.Stop(g.signals)
+	if g.tty != nil {
+		g.tty.Close()
+	}
+}
+
+// suspend follows the command when Ctrl-Z stops it: cmdbell takes the terminal back and stops
+// too, so the shell sees the job stop, then hands the terminal back and continues the command
+// once the job is resumed
+func (g *processGroup) suspend(pgid int) {
+	setForegroundGroup(g.tty, syscall.Getpgrp())
+
+	// The stop reaches cmdbell's threads asynchronously, so wait for the SIGCONT that resumes the job
+	resumed := make(chan os.Signal, 1)
+	signal.Notify(resumed, syscall.SIGCONT)
+	defer signal.Stop(resumed)
+	syscall.Kill(0, syscall.SIGTSTP)
+	<-resumed
+
+	setForegroundGroup(g.tty, pgid)
+	syscall.Kill(-pgid, syscall.SIGCONT)
+}
+
+// foregroundGroup returns the terminal's foreground process group, or -1
+func foregroundGroup(tty *os.File) int {
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return -1
+	}
+	return int(pgrp)
+}
+
+func setForegroundGroup(tty *os.File, pgid int) {
+	// A background process changing the foreground group gets SIGTTOU, which would stop cmdbell
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	pgrp := int32(pgid)
+	syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&pgrp)))
+}
+
+// childStopped reports whether pid has stopped since last asked. Only stops are consumed; the
+// exit is left for cmd.Wait to collect.
+func childStopped(pid int) bool {
+	// si_signo comes first in siginfo_t and stays zero when there was nothing to report
+	var info [128]byte
+	_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid), uintptr(unsafe.Pointer(&info[0])),
+		syscall.WSTOPPED|syscall.WNOHANG, 0, 0)
+	return errno == 0 && binary.NativeEndian.Uint32(info[:4]) != 0
+}
+
+// exitSignal returns the name of the signal that killed a command, or "" when it exited
+func exitSignal(err error) string {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return ""
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+	if name, ok := signalNames[status.Signal()]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d", int(status.Signal()))
+}
+
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGTRAP: "SIGTRAP",
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGUSR2: "SIGUSR2",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// rsync --progress: "    32,768 100%   31.25MB/s    0:00:00 (xfr#1, to-chk=2/4)"
+	rsyncCheckPattern = regexp.MustCompile(`(?:to|ir)-chk=(\d+)/(\d+)`)
+	// rsync --info=progress2, whose percentage covers the whole transfer
+	rsyncPercentPattern = regexp.MustCompile(`\s(\d{1,3})%\s`)
+	// pip: "━━━━━━━━━━━━╸━━━━━━━ 3.2/10.0 MB 1.2 MB/s eta 0:00:05"
+	pipSizePattern = regexp.MustCompile(`([\d.]+)/([\d.]+) ([kMG]B)\b`)
+	// cargo: "    Building [=======>           ] 120/364: serde, tokio"
+	cargoBuildPattern = regexp.MustCompile(`Building \[[^\]]*\] (\d+)/(\d+)`)
+	// docker and podman pull without a terminal: "4f4fb700ef54: Pull complete"
+	layerPattern = regexp.MustCompile(`^([0-9a-f]{12}): (Pulling fs layer|Waiting|Downloading|Verifying Checksum|Download complete|Extracting|Pull complete|Already exists)`)
+)
+
+// progressMeter follows the progress a known tool prints while it runs, so notifications about
+// work still running can say how far along it is, such as "73% complete"
+type progressMeter struct {
+	writer  *lineWriter
+	parse   func(line string) (percent float64, ok bool)
+	env     []string // variables that make the tool print progress into a pipe, unless already set
+	percent float64
+	known   bool
+}
+
+// newProgressMeter returns a meter for tools whose progress output is recognised, or nil
+func newProgressMeter(command string, args []string) *progressMeter {
+	m := &progressMeter{}
+	switch filepath.Base(command) {
+	case "rsync":
+		overall := containsString(args, "--info=progress2")
+		m.parse = func(line string) (float64, bool) {
+			if match := rsyncCheckPattern.FindStringSubmatch(line); match != nil {
+				left, _ := strconv.Atoi(match[1])
+				total, _ := strconv.Atoi(match[2])
+				return fraction(total-left, total)
+			}
+			if match := rsyncPercentPattern.FindStringSubmatch(line); overall && match != nil {
+				percent, _ := strconv.ParseFloat(match[1], 64)
+				return percent, true
+			}
+			return 0, false
+		}
+	case "pip", "pip3":
+		if !firstArgs(args, "install") && !firstArgs(args, "download") {
+			return nil
+		}
+		m.parse = func(line string) (float64, bool) {
+			match := pipSizePattern.FindStringSubmatch(line)
+			if match == nil {
+				return 0, false
+			}
+			done, _ := strconv.ParseFloat(match[1], 64)
+			total, _ := strconv.ParseFloat(match[2], 64)
+			if total <= 0 {
+				return 0, false
+			}
+			return 100 * done / total, true
+		}
+	case "cargo":
+		// cargo only draws its progress bar for a terminal unless told to
+		m.env = []string{"CARGO_TERM_PROGRESS_WHEN=always", "CARGO_TERM_PROGRESS_WIDTH=100"}
+		m.parse = func(line string) (float64, bool) {
+			match := cargoBuildPattern.FindStringSubmatch(line)
+			if match == nil {
+				return 0, false
+			}
+			done, _ := strconv.Atoi(match[1])
+			total, _ := strconv.Atoi(match[2])
+			return fraction(done, total)
+		}
+	case "docker", "podman":
+		if !firstArgs(args, "pull") && !firstArgs(args, "image", "pull") {
+			return nil
+		}
+		// Without a terminal there are no byte counts, only each layer's state
+		layers := map[string]bool{}
+		m.parse = func(line string) (float64, bool) {
+			match := layerPattern.FindStringSubmatch(line)
+			if match == nil {
+				return 0, false
+			}
+			layers[match[1]] = layers[match[1]] || match[2] == "Pull complete" || match[2] == "Already exists"
+			done := 0
+			for _, complete := range layers {
+				if complete {
+					done++
+				}
+			}
+			return fraction(done, len(layers))
+		}
+	default:
+		return nil
+	}
+
+	m.writer = &lineWriter{onLine: m.handleLine, redraws: true}
+	return m
+}
+
+func fraction(done, total int) (float64, bool) {
+	if total <= 0 || done < 0 {
+		return 0, false
+	}
+	return 100 * float64(done) / float64(total), true
+}
+
+func (m *progressMeter) Output() *lineWriter {
+	return m.writer
+}
+
+// applyEnv sets the meter's variables for the command about to run, keeping the user's own
+func (m *progressMeter) applyEnv() {
+	for _, variable := range m.env {
+		name, value, _ := strings.Cut(variable, "=")
+		if _, set := os.LookupEnv(name); !set {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func (m *progressMeter) handleLine(line string, _ time.Time) {
+	if percent, ok := m.parse(strings.TrimSpace(line)); ok {
+		m.percent = min(percent, 100)
+		m.known = true
+	}
+}
+
+// Summary returns how far along the command is, such as "73% complete", or "" before it has
+// reported any progress
+func (m *progressMeter) Summary() string {
+	if m == nil {
+		return ""
+	}
+	m.writer.mu.Lock()
+	defer m.writer.mu.Unlock()
+
+	if !m.known {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%% complete", m.percent)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+)
+
+// promptTimeout is how long `cmdbell prompt` waits for the daemon before printing nothing, so a
+// stuck daemon never holds up the shell
+const promptTimeout = 50 * time.Millisecond
+
+// promptCacheTTL bounds how stale /prompt can be. Every open shell asks after every command, so
+// the daemon reads history at most once per TTL instead.
+const promptCacheTTL = time.Second
+
+// promptCache holds the last /prompt answer
+type promptCache struct {
+	mu     sync.Mutex
+	status client.PromptStatus
+	at     time.Time
+}
+
+func (c *promptCache) get(jobs func() []RunningJob) client.PromptStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.at) < promptCacheTTL {
+		return c.status
+	}
+	c.status = client.PromptStatus{Unseen: unseenCount()}
+	if jobs != nil {
+		c.status.Running = len(jobs())
+	}
+	c.at = time.Now()
+	return c.status
+}
+
+func (hs *HTTPServer) handlePrompt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(hs.prompt.get(hs.jobs)); err != nil {
+		log.Printf("Failed to encode prompt response: %v", err)
+	}
+}
+
+// handlePromptCommand handles `cmdbell prompt [--json]`, printing a short status such as
+// "🔔 2 ⏳ 1" for PS1, RPROMPT or a starship custom module. It prints nothing when there is
+// nothing to show or the daemon does not answer in time.
+func handlePromptCommand() {
+	jsonOutput := false
+	for _, arg := range os.Args[2:] {
+		if arg != "--json" {
+			printPromptUsage()
+			os.Exit(1)
+		}
+		jsonOutput = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), promptTimeout)
+	defer cancel()
+	status, err := localDaemonClient().Prompt(ctx)
+	if err != nil {
+		if jsonOutput {
+			fmt.Println("{}")
+		}
+		return
+	}
+
+	if jsonOutput {
+		data, _ := json.Marshal(status)
+		fmt.Println(string(data))
+		return
+	}
+	if segment := promptSegment(*status); segment != "" {
+		fmt.Println(segment)
+	}
+}
+
+// promptSegment renders the counts worth showing, in words for plain output
+func promptSegment(status client.PromptStatus) string {
+	var parts []string
+	if status.Unseen > 0 {
+		if plainOutput() {
+			parts = append(parts, fmt.Sprintf("%d unseen", status.Unseen))
+		} else {
+			parts = append(parts, fmt.Sprintf("🔔 %d", status.Unseen))
+		}
+	}
+	if status.Running > 0 {
+		if plainOutput() {
+			parts = append(parts, fmt.Sprintf("%d running", status.Running))
+		} else {
+			parts = append(parts, fmt.Sprintf("⏳ %d", status.Running))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func printPromptUsage() {
+	fmt.Println("Usage: cmdbell prompt [--json]")
+	fmt.Println()
+	fmt.Println("Prints unseen notifications and running jobs, e.g. \"🔔 2 ⏳ 1\", or nothing.")
+	fmt.Println()
+	fmt.Println("zsh:")
+	fmt.Println("  setopt PROMPT_SUBST; RPROMPT='$(cmdbell prompt)'")
+	fmt.Println("bash:")
+	fmt.Println("  PS1='$(cmdbell prompt) '\"$PS1\"")
+	fmt.Println("starship (~/.config/starship.toml):")
+	fmt.Println("  [custom.cmdbell]")
+	fmt.Println("  command = \"cmdbell prompt\"")
+	fmt.Println("  when = true")
+	fmt.Println("  shell = [\"sh\"]")
+}
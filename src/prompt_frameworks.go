@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// promptFramework describes a prompt/integration framework that also hooks command execution
+type promptFramework struct {
+	name    string
+	shells  []string
+	files   []string // paths relative to the home directory whose presence indicates the framework
+	markers []string // strings in the shell config that indicate the framework
+	note    string
+}
+
+var promptFrameworks = []promptFramework{
+	{
+		name:    "oh-my-zsh",
+		shells:  []string{"zsh"},
+		files:   []string{".oh-my-zsh"},
+		markers: []string{"oh-my-zsh.sh"},
+		note:    "hooks are registered with add-zsh-hook and run alongside its own",
+	},
+	{
+		name:    "powerlevel10k",
+		shells:  []string{"zsh"},
+		files:   []string{".p10k.zsh"},
+		markers: []string{"powerlevel10k", "p10k-instant-prompt"},
+		note:    "hooks are registered with add-zsh-hook and print nothing during startup, so instant prompt is unaffected",
+	},
+	{
+		name:    "starship",
+		shells:  []string{"bash", "zsh", "fish"},
+		markers: []string{"starship init"},
+		note:    "its existing DEBUG trap and PROMPT_COMMAND are chained rather than replaced",
+	},
+	{
+		name:    "bash-preexec",
+		shells:  []string{"bash"},
+		files:   []string{".bash-preexec.sh"},
+		markers: []string{"bash-preexec"},
+		note:    "hooks are added to preexec_functions/precmd_functions; keep it sourced before the CmdBell block",
+	},
+	{
+		name:    "iTerm2 shell integration",
+		shells:  []string{"bash", "zsh", "fish"},
+		files:   []string{".iterm2_shell_integration.bash", ".iterm2_shell_integration.zsh", ".iterm2_shell_integration.fish"},
+		markers: []string{"iterm2_shell_integration"},
+		note:    "it bundles bash-preexec for bash, which CmdBell registers with; keep it sourced before the CmdBell block",
+	},
+}
+
+// detectPromptFrameworks reports frameworks found for a shell and how CmdBell composes with them
+func (si *ShellIntegration) detectPromptFrameworks(shell string) []string {
+	rcContent := ""
+	if data, err := os.ReadFile(si.shellConfigPath(shell)); err == nil {
+		rcContent = si.removeExistingHook(string(data), hookStartMarker, hookEndMarker)
+	}
+
+	var messages []string
+	for _, framework := range promptFrameworks {
+		if !containsString(framework.shells, shell) {
+			continue
+		}
+		if si.frameworkPresent(framework, rcContent) {
+			messages = append(messages, fmt.Sprintf("%s detected for %s: %s", framework.name, shell, framework.note))
+		}
+	}
+
+	if shell == "bash" && strings.Contains(rcContent, "trap ") && strings.Contains(rcContent, "DEBUG") {
+		messages = append(messages, "another DEBUG trap is set in .bashrc: CmdBell chains it, but traps set after the CmdBell block will replace CmdBell's")
+	}
+
+	return messages
+}
+
+func (si *ShellIntegration) frameworkPresent(framework promptFramework, rcContent string) bool {
+	for _, marker := range framework.markers {
+		if strings.Contains(rcContent, marker) {
+			return true
+		}
+	}
+	for _, file := range framework.files {
+		if _, err := os.Stat(filepath.Join(si.homeDir, file)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (si *ShellIntegration) shellConfigPath(shell string) string {
+	switch shell {
+	case "bash":
+		return filepath.Join(si.homeDir, ".bashrc")
+	case "zsh":
+		return filepath.Join(si.homeDir, ".zshrc")
+	case "fish":
+		return filepath.Join(si.homeDir, ".config", "fish", "config.fish")
+	default:
+		return ""
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package main
+
+import "time"
+
+// clockLayout is how notification.quiet_hours times are written
+const clockLayout = "15:04"
+
+// inQuietHours reports whether now falls within notification.quiet_hours. An end before the
+// start spans midnight, so 22:00 to 07:00 covers the night.
+func inQuietHours(now time.Time) bool {
+	if globalConfig == nil {
+		return false
+	}
+	quiet := globalConfig.Notification.QuietHours
+	start, err := time.Parse(clockLayout, quiet.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(clockLayout, quiet.End)
+	if err != nil || start.Equal(end) {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	from, to := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if from < to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const rcBackupTimeFormat = "20060102-150405"
+
+// writeShellConfig replaces a shell config file atomically. Symlinks are followed so the
+// link itself survives, the original permissions are kept and the previous contents are
+// saved under ~/.cmdbell/backups first.
+func (si *ShellIntegration) writeShellConfig(configPath string, content []byte) error {
+	target := configPath
+	if resolved, err := filepath.EvalSymlinks(configPath); err == nil {
+		target = resolved
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+
+		existing, err := os.ReadFile(target)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", target, err)
+		}
+		if string(existing) == string(content) {
+			return nil
+		}
+		if err := si.backupShellConfig(configPath, existing, mode); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(target, content, mode)
+}
+
+func (si *ShellIntegration) backupShellConfig(configPath string, content []byte, mode os.FileMode) error {
+	backupDir, err := getDataPath("backups")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	name := si.backupName(configPath) + "." + time.Now().Format(rcBackupTimeFormat)
+	if err := os.WriteFile(filepath.Join(backupDir, name), content, mode); err != nil {
+		return fmt.Errorf("failed to back up %s: %v", configPath, err)
+	}
+	return nil
+}
+
+// backupName encodes a config path relative to the home directory into a flat file name
+func (si *ShellIntegration) backupName(configPath string) string {
+	rel, err := filepath.Rel(si.homeDir, configPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = configPath
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "__")
+}
+
+// backupSource decodes a backup file name back into the config path it was taken from
+func (si *ShellIntegration) backupSource(backupName string) (string, bool) {
+	dot := strings.LastIndex(backupName, ".")
+	if dot == -1 {
+		return "", false
+	}
+	if _, err := time.Parse(rcBackupTimeFormat, backupName[dot+1:]); err != nil {
+		return "", false
+	}
+
+	rel := filepath.FromSlash(strings.ReplaceAll(backupName[:dot], "__", "/"))
+	if filepath.IsAbs(rel) {
+		return rel, true
+	}
+	return filepath.Join(si.homeDir, rel), true
+}
+
+func writeFileAtomic(path string, content []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".cmdbell-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %v", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+	return nil
+}
+
+// ListBackups returns backup file names, newest first
+func (si *ShellIntegration) ListBackups() ([]string, error) {
+	backupDir, err := getDataPath("backups")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(backupDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if _, ok := si.backupSource(entry.Name()); ok && !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return names[i][strings.LastIndex(names[i], ".")+1:] > names[j][strings.LastIndex(names[j], ".")+1:]
+	})
+	return names, nil
+}
+
+// RestoreBackup writes a backup back to the config file it was taken from
+func (si *ShellIntegration) RestoreBackup(backupName string) (string, error) {
+	source, ok := si.backupSource(backupName)
+	if !ok {
+		return "", fmt.Errorf("not a CmdBell backup: %s", backupName)
+	}
+
+	backupDir, err := getDataPath("backups")
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(filepath.Join(backupDir, backupName))
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %v", err)
+	}
+
+	if configPath, err := getConfigPath(); err == nil && source == configPath {
+		return source, auditedConfigWrite("config.restore", func() error {
+			return si.writeShellConfig(source, content)
+		})
+	}
+	return source, si.writeShellConfig(source, content)
+}
+
+func handleRestoreRCCommand() {
+	integration, err := NewShellIntegration()
+	if err != nil {
+		fmt.Printf("Failed to create shell integration: %v\n", err)
+		os.Exit(1)
+	}
+
+	backups, err := integration.ListBackups()
+	if err != nil {
+		fmt.Printf("Failed to list backups: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(os.Args) < 3 {
+		if len(backups) == 0 {
+			fmt.Println("No shell config backups found")
+			return
+		}
+
+		fmt.Println("Available backups (newest first):")
+		for _, name := range backups {
+			source, _ := integration.backupSource(name)
+			fmt.Printf("  %s  ->  %s\n", name, source)
+		}
+		fmt.Println("\nUsage: cmdbell restore-rc <backup> | --latest")
+		return
+	}
+
+	var toRestore []string
+	if os.Args[2] == "--latest" {
+		seen := make(map[string]bool)
+		for _, name := range backups {
+			source, _ := integration.backupSource(name)
+			if !seen[source] {
+				seen[source] = true
+				toRestore = append(toRestore, name)
+			}
+		}
+	} else {
+		toRestore = []string{os.Args[2]}
+	}
+
+	for _, name := range toRestore {
+		source, err := integration.RestoreBackup(name)
+		if err != nil {
+			fmt.Printf("❌ Failed to restore %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Restored %s from %s\n", source, name)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"unicode"
+)
+
+const (
+	// maxRerunCandidates caps how many distinct commands completion and the picker offer
+	maxRerunCandidates = 200
+	rerunPickerRows    = 15
+)
+
+// handleRerunCommand runs a command from history again in the current directory, picked by
+// history ID, from a fuzzy picker, or for shell completion listed with --complete
+func handleRerunCommand() {
+	args := os.Args[2:]
+	switch {
+	case len(args) >= 1 && args[0] == "--complete":
+		prefix := ""
+		if len(args) > 1 {
+			prefix = args[1]
+		}
+		printRerunCompletions(prefix)
+		return
+	case len(args) >= 1 && args[0] == "--pick":
+		pickAndRerun(strings.Join(args[1:], " "))
+		return
+	case len(args) != 1 || strings.HasPrefix(args[0], "-"):
+		printRerunUsage()
+		os.Exit(1)
+	}
+
+	entry, err := findHistoryEntry(args[0])
+	if err != nil {
+		// Not an ID: treat it as what to look for in the picker
+		pickAndRerun(args[0])
+		return
+	}
+	if err := rerunnable(entry); err != nil {
+		errorf("❌ Cannot re-run %s: %v\n", entry.ID, err)
+		os.Exit(1)
+	}
+	os.Exit(rerunCommand(entry.Command, ""))
+}
+
+// rerunCandidates returns the latest history entry of each command that can be run again here,
+// newest first
+func rerunCandidates() ([]HistoryEntry, error) {
+	store := getHistoryStore()
+	if store == nil {
+		return nil, fmt.Errorf("history is disabled (history.enabled: false)")
+	}
+	entries, err := store.Query(HistoryFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+
+	var candidates []HistoryEntry
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if seen[entry.Command] || rerunnable(entry) != nil {
+			continue
+		}
+		seen[entry.Command] = true
+		candidates = append(candidates, entry)
+		if len(candidates) == maxRerunCandidates {
+			break
+		}
+	}
+	return candidates, nil
+}
+
+// printRerunCompletions prints "<id>\t<command>" for each candidate whose ID starts with prefix
+// or whose command contains it. Fish shows the command as the description as is; the zsh hook
+// turns the tab into _describe's colon and the bash one keeps the ID.
+func printRerunCompletions(prefix string) {
+	candidates, err := rerunCandidates()
+	if err != nil {
+		return
+	}
+	for _, entry := range candidates {
+		if strings.HasPrefix(entry.ID, prefix) || strings.Contains(entry.Command, prefix) {
+			fmt.Printf("%s\t%s\n", entry.ID, strings.ReplaceAll(entry.Command, "\n", " "))
+		}
+	}
+}
+
+// fuzzyScore matches query against text as a case-insensitive subsequence. Lower scores are
+// better matches: each character skipped between matched ones costs a point, and so does
+// starting the match late.
+func fuzzyScore(query, text string) (int, bool) {
+	queryRunes := []rune(strings.ToLower(query))
+	score, next, last := 0, 0, -1
+	for i, r := range []rune(strings.ToLower(text)) {
+		if next == len(queryRunes) {
+			break
+		}
+		if r != queryRunes[next] {
+			continue
+		}
+		if last >= 0 {
+			score += i - last - 1
+		} else {
+			score += min(i, 10)
+		}
+		last = i
+		next++
+	}
+	return score, next == len(queryRunes)
+}
+
+// rerunPicker is the state of `cmdbell rerun --pick` between keys
+type rerunPicker struct {
+	candidates []HistoryEntry
+	query      string
+	matches    []HistoryEntry
+	selected   int
+}
+
+func (p *rerunPicker) filter() {
+	type scored struct {
+		entry HistoryEntry
+		score int
+	}
+	var found []scored
+	for _, entry := range p.candidates {
+		if score, ok := fuzzyScore(p.query, entry.Command); ok {
+			found = append(found, scored{entry, score})
+		}
+	}
+	// Stable, so equally good matches stay newest first
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score < found[j].score })
+
+	p.matches = p.matches[:0]
+	for _, match := range found {
+		p.matches = append(p.matches, match.entry)
+	}
+	p.selected = max(0, min(p.selected, len(p.matches)-1))
+}
+
+func (p *rerunPicker) render() {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&b, "Re-run: %s▏\n\n", p.query)
+	if len(p.matches) == 0 {
+		b.WriteString("  No matching commands\n")
+	}
+
+	// Keep the selection in view when it is past the first screenful
+	first := max(0, p.selected-rerunPickerRows+1)
+	for i := first; i < len(p.matches) && i < first+rerunPickerRows; i++ {
+		entry := p.matches[i]
+		command := strings.ReplaceAll(entry.Command, "\n", " ")
+		if runes := []rune(command); len(runes) > topNameWidth {
+			command = string(runes[:topNameWidth-1]) + "…"
+		}
+		line := fmt.Sprintf("%s %-*s  %s", historyIcon(entry), topNameWidth, command, entry.Time.Local().Format("Jan 2 15:04"))
+		switch {
+		case i != p.selected:
+			fmt.Fprintf(&b, "  %s\n", line)
+		case plainOutput():
+			fmt.Fprintf(&b, "> %s\n", line)
+		default:
+			fmt.Fprintf(&b, "\x1b[7m> %s\x1b[0m\n", line)
+		}
+	}
+	fmt.Fprintf(&b, "\n%d of %d commands · type to filter · ↑/↓ select · enter run · esc cancel\n", len(p.matches), len(p.candidates))
+	fmt.Print(b.String())
+}
+
+// pick lets the user choose a command, returning false when they cancel. Keys are read here
+// rather than by a goroutine, which would go on taking input from the command run next.
+func (p *rerunPicker) pick() (HistoryEntry, bool) {
+	restore := enterFullScreen()
+	defer restore()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		restore()
+		os.Exit(130)
+	}()
+
+	p.filter()
+	for {
+		p.render()
+		key, err := readKey()
+		if err != nil {
+			return HistoryEntry{}, false
+		}
+		switch key {
+		case "esc":
+			return HistoryEntry{}, false
+		case "enter":
+			if len(p.matches) > 0 {
+				return p.matches[p.selected], true
+			}
+		case "up":
+			p.selected = max(0, p.selected-1)
+		case "down":
+			p.selected = min(max(0, len(p.matches)-1), p.selected+1)
+		case "backspace":
+			if runes := []rune(p.query); len(runes) > 0 {
+				p.query = string(runes[:len(runes)-1])
+				p.filter()
+			}
+		default:
+			if !strings.HasPrefix(key, "\x1b") && strings.IndexFunc(key, unicode.IsControl) < 0 {
+				p.query += key
+				p.selected = 0
+				p.filter()
+			}
+		}
+	}
+}
+
+// pickAndRerun offers the commands matching query in a picker and runs the chosen one. Without
+// a terminal to pick in, only a query matching a single command runs.
+func pickAndRerun(query string) {
+	candidates, err := rerunCandidates()
+	if err != nil {
+		errorf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	picker := &rerunPicker{candidates: candidates, query: query}
+
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		picker.filter()
+		switch len(picker.matches) {
+		case 0:
+			errorf("❌ No command in history matches '%s'\n", query)
+		case 1:
+			os.Exit(rerunCommand(picker.matches[0].Command, ""))
+		default:
+			errorf("❌ %d commands in history match '%s', pick one in a terminal or by ID\n", len(picker.matches), query)
+		}
+		os.Exit(1)
+	}
+
+	entry, ok := picker.pick()
+	if !ok {
+		return
+	}
+	os.Exit(rerunCommand(entry.Command, ""))
+}
+
+func printRerunUsage() {
+	fmt.Println("Usage: cmdbell rerun <id> | --pick [query] | <query>")
+	fmt.Println()
+	fmt.Println("Runs a command from history again in the current directory, notifying like any")
+	fmt.Println("wrapped command. --pick, or a query that is not a history ID, chooses the command in")
+	fmt.Println("a fuzzy picker. The shell hooks complete 'cmdbell rerun <TAB>' with recent commands.")
+}
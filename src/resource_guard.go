@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errResourceUnsupported is returned where cmdbell cannot measure free disk or memory
+var errResourceUnsupported = errors.New("not supported on this platform")
+
+// sizeUnits are the suffixes parseSize accepts, in powers of 1024 as df -h and free -h count
+var sizeUnits = []struct {
+	suffix     string
+	multiplier uint64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// parseSize parses sizes such as 5GB, 512M or 1.5GiB; a bare number is in bytes
+func parseSize(value string) (uint64, error) {
+	number := strings.ToUpper(strings.TrimSpace(value))
+	number = strings.TrimSuffix(strings.TrimSuffix(number, "B"), "I")
+
+	multiplier := uint64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSuffix(number, unit.suffix), unit.multiplier
+			break
+		}
+	}
+
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid size %q (use values like 512MB, 5GB)", value)
+	}
+	return uint64(parsed * float64(multiplier)), nil
+}
+
+// formatSize prints a byte count the way parseSize reads it
+func formatSize(bytes uint64) string {
+	for _, unit := range sizeUnits {
+		if bytes >= unit.multiplier {
+			return fmt.Sprintf("%.1f %sB", float64(bytes)/float64(unit.multiplier), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
+
+// checkResources compares free disk space and available memory with guard.min_disk and
+// guard.min_memory, returning what falls short
+func checkResources() []string {
+	if globalConfig == nil {
+		return nil
+	}
+	guard := globalConfig.Guard
+	var problems []string
+
+	if guard.MinDisk != "" {
+		minimum, err := parseSize(guard.MinDisk)
+		if err != nil {
+			statusf("⚠️  Ignoring guard.min_disk: %v\n", err)
+		} else {
+			paths := guard.Paths
+			if len(paths) == 0 {
+				cwd, _ := os.Getwd()
+				paths = []string{cwd}
+			}
+			for _, path := range paths {
+				free, err := freeDiskSpace(path)
+				if err != nil {
+					statusf("⚠️  Cannot check free space on %s: %v\n", path, err)
+					continue
+				}
+				if free < minimum {
+					problems = append(problems, fmt.Sprintf("only %s free on %s (guard.min_disk is %s)", formatSize(free), path, formatSize(minimum)))
+				}
+			}
+		}
+	}
+
+	if guard.MinMemory != "" {
+		minimum, err := parseSize(guard.MinMemory)
+		if err != nil {
+			statusf("⚠️  Ignoring guard.min_memory: %v\n", err)
+		} else if available, err := availableMemory(); err != nil {
+			statusf("⚠️  Cannot check available memory: %v\n", err)
+		} else if available < minimum {
+			problems = append(problems, fmt.Sprintf("only %s of memory available (guard.min_memory is %s)", formatSize(available), formatSize(minimum)))
+		}
+	}
+	return problems
+}
+
+// guardResources runs the resource check before a wrapped command starts. With guard.action
+// "abort" a shortfall stops cmdbell before the command runs and notifies; otherwise it warns.
+func guardResources(command string, notify bool) {
+	problems := checkResources()
+	if len(problems) == 0 {
+		return
+	}
+
+	if globalConfig.Guard.Action != "abort" {
+		for _, problem := range problems {
+			statusf("⚠️  %s\n", problem)
+		}
+		return
+	}
+
+	for _, problem := range problems {
+		errorf("🛑 Not starting %s: %s\n", displayCommand(command), problem)
+	}
+	if notify && globalConfig.General.EnableNotify {
+		deliverNotification(&Notification{
+			Title:     "CmdBell",
+			Message:   fmt.Sprintf("Command '%s' not started: %s", displayCommand(command), strings.Join(problems, "; ")),
+			Icon:      "🛑",
+			Source:    "command",
+			Command:   command,
+			Success:   false,
+			StartTime: time.Now(),
+			Local:     true,
+		})
+		waitForDeliveries()
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin)
+
+package main
+
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, errResourceUnsupported
+}
+
+func availableMemory() (uint64, error) {
+	return 0, errResourceUnsupported
+}
@@ -0,0 +1,81 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// freeDiskSpace returns the bytes available to unprivileged users on path's filesystem
+func freeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// availableMemory returns how much memory new work can use without swapping
+func availableMemory() (uint64, error) {
+	if available, err := memAvailable(); err == nil {
+		return available, nil
+	}
+	return vmStatAvailable()
+}
+
+// memAvailable reads MemAvailable from /proc/meminfo (Linux)
+func memAvailable() (uint64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kb * 1024, nil
+		}
+	}
+	return 0, fmt.Errorf("no MemAvailable in /proc/meminfo")
+}
+
+var (
+	vmStatPageSize = regexp.MustCompile(`page size of (\d+) bytes`)
+	vmStatPages    = regexp.MustCompile(`^Pages (free|inactive|speculative|purgeable):\s+(\d+)\.`)
+)
+
+// vmStatAvailable adds up free and reclaimable pages from vm_stat (macOS)
+func vmStatAvailable() (uint64, error) {
+	output, err := exec.Command("vm_stat").Output()
+	if err != nil {
+		return 0, err
+	}
+
+	match := vmStatPageSize.FindSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("unrecognized vm_stat output")
+	}
+	pageSize, _ := strconv.ParseUint(string(match[1]), 10, 64)
+
+	var pages uint64
+	for _, line := range strings.Split(string(output), "\n") {
+		if match := vmStatPages.FindStringSubmatch(line); match != nil {
+			count, _ := strconv.ParseUint(match[2], 10, 64)
+			pages += count
+		}
+	}
+	return pages * pageSize, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// timeoutKillGrace is how long a timed-out command has to exit after SIGTERM before it is killed
+const timeoutKillGrace = 10 * time.Second
+
+// timeoutExitCode is what cmdbell exits with when it kills a timed-out command, as timeout(1) does
+const timeoutExitCode = 124
+
+// runOptions are the `cmdbell run` flags that shape how a wrapped command runs
+type runOptions struct {
+	LogOutput bool
+	Timeout   time.Duration // zero for no deadline
+	OnTimeout string        // "kill" or "notify"
+
+	Retries     int           // how many times a failed command is run again
+	RetryDelay  time.Duration // wait between attempts
+	RetryOnExit []int         // exit codes worth retrying; empty retries any failure
+}
+
+// parseRunFlags reads the flags before the command and returns the rest of args
+func parseRunFlags(args []string) (runOptions, []string, error) {
+	opts := runOptions{OnTimeout: "kill"}
+	for len(args) > 0 {
+		flag, value, hasValue := strings.Cut(args[0], "=")
+		takeValue := func() (string, error) {
+			if hasValue {
+				args = args[1:]
+				return value, nil
+			}
+			if len(args) < 2 {
+				return "", fmt.Errorf("%s needs a value", flag)
+			}
+			value := args[1]
+			args = args[2:]
+			return value, nil
+		}
+
+		switch flag {
+		case "--log-output":
+			opts.LogOutput = true
+			args = args[1:]
+		case "--timeout":
+			value, err := takeValue()
+			if err != nil {
+				return opts, nil, err
+			}
+			if opts.Timeout, err = time.ParseDuration(value); err != nil || opts.Timeout <= 0 {
+				return opts, nil, fmt.Errorf("invalid timeout %q, expected a duration such as 30m", value)
+			}
+		case "--on-timeout":
+			value, err := takeValue()
+			if err != nil {
+				return opts, nil, err
+			}
+			if value != "kill" && value != "notify" {
+				return opts, nil, fmt.Errorf("invalid --on-timeout %q, expected kill or notify", value)
+			}
+			opts.OnTimeout = value
+		case "--retries":
+			value, err := takeValue()
+			if err != nil {
+				return opts, nil, err
+			}
+			if opts.Retries, err = strconv.Atoi(value); err != nil || opts.Retries < 0 {
+				return opts, nil, fmt.Errorf("invalid --retries %q, expected a number", value)
+			}
+		case "--retry-delay":
+			value, err := takeValue()
+			if err != nil {
+				return opts, nil, err
+			}
+			if opts.RetryDelay, err = time.ParseDuration(value); err != nil || opts.RetryDelay < 0 {
+				return opts, nil, fmt.Errorf("invalid --retry-delay %q, expected a duration such as 30s", value)
+			}
+		case "--retry-on-exit":
+			value, err := takeValue()
+			if err != nil {
+				return opts, nil, err
+			}
+			for _, field := range strings.Split(value, ",") {
+				code, err := strconv.Atoi(strings.TrimSpace(field))
+				if err != nil {
+					return opts, nil, fmt.Errorf("invalid --retry-on-exit %q, expected exit codes such as 1,75", value)
+				}
+				opts.RetryOnExit = append(opts.RetryOnExit, code)
+			}
+		default:
+			return opts, args, nil
+		}
+	}
+	return opts, args, nil
+}
+
+// shouldRetry reports whether a failed attempt is run again. Commands that were killed, by a
+// signal or at their deadline, are not.
+func (opts runOptions) shouldRetry(err error, timedOut bool, attempt int) bool {
+	if err == nil || timedOut || attempt > opts.Retries || exitSignal(err) != "" {
+		return false
+	}
+	code := exitCode(err)
+	if code < 0 {
+		return false
+	}
+	return len(opts.RetryOnExit) == 0 || slices.Contains(opts.RetryOnExit, code)
+}
+
+// exitCode returns a command's exit code, or -1 when it did not start or exit normally
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// retrySummary describes the attempts of a retried command, or returns "" for a single attempt
+func retrySummary(exitCodes []int) string {
+	if len(exitCodes) < 2 {
+		return ""
+	}
+	if exitCodes[len(exitCodes)-1] == 0 {
+		return fmt.Sprintf("succeeded on attempt %d", len(exitCodes))
+	}
+	codes := make([]string, len(exitCodes))
+	for i, code := range exitCodes {
+		codes[i] = strconv.Itoa(code)
+	}
+	return fmt.Sprintf("%d attempts, exit codes %s", len(exitCodes), strings.Join(codes, ", "))
+}
+
+// commandDeadline enforces a wrapped command's --timeout once it has started
+type commandDeadline struct {
+	timer    *time.Timer
+	expired  atomic.Bool
+	opts     runOptions
+	group    *processGroup
+	command  string
+	started  time.Time
+	notifies bool
+	progress *progressMeter // nil unless the command's progress output is recognised
+}
+
+// startDeadline arms the timeout, or returns nil when the command has none. With "kill" the
+// group gets SIGTERM at the deadline and SIGKILL after a grace period; with "notify" it keeps
+// running and only a timed-out notification goes out.
+func startDeadline(opts runOptions, group *processGroup, command string, started time.Time, notifies bool, progress *progressMeter) *commandDeadline {
+	if opts.Timeout <= 0 {
+		return nil
+	}
+	d := &commandDeadline{opts: opts, group: group, command: command, started: started, notifies: notifies, progress: progress}
+	d.timer = time.AfterFunc(opts.Timeout, d.expire)
+	return d
+}
+
+func (d *commandDeadline) expire() {
+	d.expired.Store(true)
+	if d.opts.OnTimeout == "notify" {
+		detail := "still running"
+		if summary := d.progress.Summary(); summary != "" {
+			detail += ", " + summary
+		}
+		statusf("⏱️  %s is still running after its %s timeout%s\n", displayCommand(d.command), formatDuration(d.opts.Timeout), strings.TrimPrefix(detail, "still running"))
+		if d.notifies && globalConfig != nil && globalConfig.General.EnableNotify {
+			n := finishedNotification(d.command, "timed out", detail, d.started, time.Since(d.started), false)
+			n.Icon = "⏱️"
+			n.ReplaceKey = runReplaceKey()
+			n.Running = true
+			n.TimedOut = true
+			n.Local = true
+			deliverNotification(n)
+		}
+		return
+	}
+
+	statusf("⏱️  %s timed out after %s, stopping it\n", displayCommand(d.command), formatDuration(d.opts.Timeout))
+	d.group.Terminate()
+	time.AfterFunc(timeoutKillGrace, d.group.Kill)
+}
+
+// Stop disarms the deadline once the command has exited and reports whether it had expired. A
+// killed command's leftover processes are killed with it.
+func (d *commandDeadline) Stop() bool {
+	if d == nil {
+		return false
+	}
+	d.timer.Stop()
+	if !d.expired.Load() {
+		return false
+	}
+	if d.opts.OnTimeout == "kill" {
+		d.group.Kill()
+	}
+	return true
+}
+
+// runReplaceKey ties a wrapped command's "still running" toast to its final one, which replaces it
+func runReplaceKey() string {
+	return fmt.Sprintf("run-%d", os.Getpid())
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type scheduledJob struct {
+	config     ScheduleConfig
+	interval   time.Duration
+	budget     time.Duration
+	lastOutput [sha256.Size]byte
+	hasOutput  bool
+}
+
+type Scheduler struct {
+	jobs   []*scheduledJob
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(schedules []ScheduleConfig) (*Scheduler, error) {
+	var jobs []*scheduledJob
+	for _, schedule := range schedules {
+		if schedule.Command == "" {
+			return nil, fmt.Errorf("schedule %q has no command", schedule.Name)
+		}
+
+		interval, err := time.ParseDuration(schedule.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval for schedule %q: %v", schedule.Name, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("interval for schedule %q must be positive", schedule.Name)
+		}
+
+		var budget time.Duration
+		if schedule.Budget != "" {
+			budget, err = time.ParseDuration(schedule.Budget)
+			if err != nil {
+				return nil, fmt.Errorf("invalid budget for schedule %q: %v", schedule.Name, err)
+			}
+		}
+
+		if schedule.Name == "" {
+			schedule.Name = schedule.Command
+		}
+
+		jobs = append(jobs, &scheduledJob{
+			config:   schedule,
+			interval: interval,
+			budget:   budget,
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		jobs:   jobs,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.runJob(job)
+	}
+
+	log.Printf("⏰ Scheduler started with %d job(s)", len(s.jobs))
+}
+
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	log.Println("🛑 Scheduler stopped")
+}
+
+func (s *Scheduler) runJob(job *scheduledJob) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	// Run once immediately so the first output becomes the baseline
+	s.execute(job)
+
+	for {
+		select {
+		case <-ticker.C:
+			s.execute(job)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Scheduler) execute(job *scheduledJob) {
+	cmd := shellCommand(s.ctx, job.config.Command)
+	cmd.Dir = expandHome(job.config.Dir)
+
+	startTime := time.Now()
+	budget := startJobBudget(job.budget, func() {
+		log.Printf("⏳ Scheduled job '%s' is past its %s budget", job.config.Name, formatDuration(job.budget))
+		if globalConfig == nil || globalConfig.General.EnableNotify {
+			deliverNotification(budgetNotification("CmdBell - Schedule", fmt.Sprintf("Scheduled job '%s'", job.config.Name),
+				"schedule", "", job.replaceKey(), job.budget, startTime))
+		}
+	})
+	output, err := cmd.CombinedOutput()
+	duration := time.Since(startTime)
+	overBudget := budget.Stop()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	sum := sha256.Sum256(output)
+	changed := job.hasOutput && sum != job.lastOutput
+	job.lastOutput = sum
+	job.hasOutput = true
+
+	log.Printf("⏰ Scheduled job '%s' finished in %s (error: %v, output changed: %t)",
+		job.config.Name, duration.Round(time.Second), err, changed)
+
+	if globalConfig != nil && !globalConfig.General.EnableNotify {
+		return
+	}
+
+	var reason string
+	switch {
+	case err != nil && job.notifyOn("failure"):
+		reason = fmt.Sprintf("failed (%v)", err)
+	case changed && job.notifyOn("change"):
+		reason = "output changed"
+	case overBudget:
+		reason = fmt.Sprintf("finished over its %s budget", formatDuration(job.budget))
+	default:
+		return
+	}
+
+	n := scheduleNotification(job.config.Name, reason, duration)
+	if overBudget {
+		n.ReplaceKey = job.replaceKey()
+	}
+	deliverNotification(n)
+}
+
+// replaceKey ties a run's budget warning to its final notification
+func (job *scheduledJob) replaceKey() string {
+	return "schedule-" + job.config.Name
+}
+
+func (job *scheduledJob) notifyOn(trigger string) bool {
+	if len(job.config.NotifyOn) == 0 {
+		return true
+	}
+	for _, t := range job.config.NotifyOn {
+		if t == trigger {
+			return true
+		}
+	}
+	return false
+}
+
+// shellCommand builds a command that runs the given string through the platform shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// schemaVersion is the version of the JSON payloads CmdBell exchanges with integrations: the
+// request bodies of the HTTP API and the events posted to webhooks and hubs, each carrying it
+// as schema_version. Adding a field does not change it; renaming or removing one, or changing
+// what it means, does, together with an entry in schemaUpgrades translating the previous
+// version.
+const schemaVersion = 1
+
+// oldestSchemaVersion is the oldest version the daemon still accepts. Payloads without
+// schema_version predate versioning and are version 1.
+const oldestSchemaVersion = max(1, schemaVersion-1)
+
+// schemaUpgrade rewrites a payload of one version into the next, in place
+type schemaUpgrade func(payload map[string]json.RawMessage) error
+
+// schemaUpgrades holds, per payload kind, the upgrade from each version to the next, keyed by
+// the version it upgrades from. A kind without an entry for a version did not change in it.
+var schemaUpgrades = map[string]map[int]schemaUpgrade{}
+
+// schemaVersionError rejects a payload of a version the daemon cannot translate
+type schemaVersionError struct {
+	version int
+}
+
+func (e *schemaVersionError) Error() string {
+	return fmt.Sprintf("Unsupported schema_version %d: this daemon accepts %s", e.version, supportedSchemaVersions())
+}
+
+func supportedSchemaVersions() string {
+	if oldestSchemaVersion == schemaVersion {
+		return fmt.Sprintf("version %d", schemaVersion)
+	}
+	return fmt.Sprintf("versions %d to %d", oldestSchemaVersion, schemaVersion)
+}
+
+// decodePayload decodes a JSON object of the given kind into v, first translating it from the
+// schema_version it declares to the current one
+func decodePayload(r io.Reader, kind string, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	version := 1
+	if raw, ok := payload["schema_version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return fmt.Errorf("schema_version: %v", err)
+		}
+	}
+	if version < oldestSchemaVersion || version > schemaVersion {
+		return &schemaVersionError{version: version}
+	}
+
+	if version < schemaVersion {
+		for from := version; from < schemaVersion; from++ {
+			if upgrade := schemaUpgrades[kind][from]; upgrade != nil {
+				if err := upgrade(payload); err != nil {
+					return fmt.Errorf("schema_version %d: %v", from, err)
+				}
+			}
+		}
+		payload["schema_version"] = json.RawMessage(fmt.Sprint(schemaVersion))
+		if data, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+)
+
+const (
+	// defaultMarkLabel pairs notify-start and notify-done when no --label is given
+	defaultMarkLabel = "script"
+	// markMaxAge drops starts that were never finished, e.g. a cron job that was killed
+	markMaxAge = 7 * 24 * time.Hour
+)
+
+// MarkRequest starts or finishes a labelled run for start/done and notify-start/notify-done
+type MarkRequest struct {
+	Label    string `json:"label"`
+	ExitCode int    `json:"exit_code"` // only used when finishing
+	// CorrelationID tags the finished run's notification, see correlation.go
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// scriptMarks holds the start time of each labelled run until done reports it. Marks live in
+// the daemon so separate `cmdbell` invocations can be paired, and are saved to
+// ~/.cmdbell/marks.json so long cron jobs and CI steps survive a daemon restart.
+type scriptMarks struct {
+	mu     sync.Mutex
+	path   string
+	starts map[string]time.Time
+}
+
+func newScriptMarks() *scriptMarks {
+	m := &scriptMarks{starts: make(map[string]time.Time)}
+	path, err := getDataPath("marks.json")
+	if err != nil {
+		return m
+	}
+	m.path = path
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &m.starts)
+	}
+	return m
+}
+
+func (m *scriptMarks) start(label string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for name, startedAt := range m.starts {
+		if now.Sub(startedAt) > markMaxAge {
+			delete(m.starts, name)
+		}
+	}
+	m.starts[label] = now
+	m.save()
+}
+
+func (m *scriptMarks) finish(label string) (time.Duration, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	startedAt, ok := m.starts[label]
+	if !ok {
+		return 0, false
+	}
+	delete(m.starts, label)
+	m.save()
+	return time.Since(startedAt), true
+}
+
+// running lists the labels still waiting for notify-done
+func (m *scriptMarks) running() []RunningJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]RunningJob, 0, len(m.starts))
+	for label, startedAt := range m.starts {
+		jobs = append(jobs, RunningJob{Source: "mark", Name: label, Started: startedAt})
+	}
+	return jobs
+}
+
+// save writes the marks to disk; callers hold m.mu
+func (m *scriptMarks) save() {
+	if m.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(m.starts, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(m.path, data, 0600); err != nil {
+		log.Printf("Failed to save marks: %v", err)
+	}
+}
+
+func (hs *HTTPServer) handleMarkStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MarkRequest
+	if err := decodePayload(r.Body, "mark", &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.Label == "" {
+		req.Label = defaultMarkLabel
+	}
+
+	hs.marks.start(req.Label)
+	log.Printf("⏱️  Started '%s'", req.Label)
+	activeDBusService.jobsChanged()
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"status": "success",
+		"label":  req.Label,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+func (hs *HTTPServer) handleMarkDone(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MarkRequest
+	if err := decodePayload(r.Body, "mark", &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.Label == "" {
+		req.Label = defaultMarkLabel
+	}
+
+	correlationID, err := requestCorrelationID(r, req.CorrelationID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	duration, ok := hs.marks.finish(req.Label)
+	if !ok {
+		http.Error(w, fmt.Sprintf("No notify-start for label %q", req.Label), http.StatusNotFound)
+		return
+	}
+	activeDBusService.jobsChanged()
+
+	log.Printf("⏱️  Finished '%s' after %s (exit code %d)", req.Label, duration.Round(time.Second), req.ExitCode)
+
+	// Same rules as the shell hook: runs shorter than min_duration stay quiet
+	notified := globalConfig == nil || (globalConfig.General.EnableNotify && duration >= globalConfig.General.MinDurationTime)
+	response := map[string]interface{}{
+		"status":           "success",
+		"label":            req.Label,
+		"duration_seconds": duration.Seconds(),
+		"notified":         notified,
+	}
+	if notified {
+		n := commandNotification(req.Label, "", time.Time{}, duration, req.ExitCode == 0)
+		n.ID = newEventID()
+		n.CorrelationID = correlationID
+		deliverNotification(n)
+		withEventIDs(w, response, n)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// localDaemonClient talks to this machine's daemon, using a configured token with the notify scope if any
+func localDaemonClient() *client.Client {
+	port, token := daemonPort(), ""
+	if globalConfig != nil {
+		for _, t := range globalConfig.HTTP.Tokens {
+			if t.hasScope("notify") {
+				token = t.Token
+				break
+			}
+		}
+	}
+	if env := os.Getenv("CMDBELL_TOKEN"); env != "" {
+		token = env
+	}
+	c := client.New(fmt.Sprintf("http://localhost:%d", port), token)
+	c.CorrelationID = envCorrelationID()
+	return c
+}
+
+// handleStartCommand handles `cmdbell start <token>`, bracketing work in cron jobs, Makefiles or CI steps
+func handleStartCommand() {
+	if len(os.Args) != 3 || os.Args[2] == "" {
+		printStartDoneUsage()
+		os.Exit(1)
+	}
+	startMark(os.Args[2])
+}
+
+// handleDoneCommand handles `cmdbell done <token> [exit]` and exits with the given exit code
+func handleDoneCommand() {
+	if len(os.Args) < 3 || len(os.Args) > 4 || os.Args[2] == "" {
+		printStartDoneUsage()
+		os.Exit(1)
+	}
+
+	exitCode := 0
+	if len(os.Args) == 4 {
+		code, err := strconv.Atoi(os.Args[3])
+		if err != nil {
+			fmt.Printf("❌ Invalid exit code: %s\n", os.Args[3])
+			os.Exit(1)
+		}
+		exitCode = code
+	}
+	finishMark(os.Args[2], exitCode)
+}
+
+func printStartDoneUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  cmdbell start <token>")
+	fmt.Println("  cmdbell done <token> [exit_code]")
+	fmt.Println()
+	fmt.Println("Example (crontab):")
+	fmt.Println("  cmdbell start backup; ./backup.sh; cmdbell done backup $?")
+}
+
+// handleNotifyStartCommand records when a script starts, for a later notify-done with the same label
+func handleNotifyStartCommand() {
+	label := defaultMarkLabel
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--label", "-l":
+			if i+1 < len(args) {
+				i++
+				label = args[i]
+			}
+		default:
+			printNotifyMarkUsage()
+			os.Exit(1)
+		}
+	}
+
+	startMark(label)
+}
+
+// handleNotifyDoneCommand notifies that a script finished, e.g. `python train.py; cmdbell notify-done -e $?`
+func handleNotifyDoneCommand() {
+	label := defaultMarkLabel
+	exitCode := 0
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--label", "-l":
+			if i+1 < len(args) {
+				i++
+				label = args[i]
+			}
+		case "--exit-code", "-e":
+			if i+1 < len(args) {
+				i++
+				code, err := strconv.Atoi(args[i])
+				if err != nil {
+					fmt.Printf("❌ Invalid exit code: %s\n", args[i])
+					os.Exit(1)
+				}
+				exitCode = code
+			}
+		default:
+			printNotifyMarkUsage()
+			os.Exit(1)
+		}
+	}
+
+	finishMark(label, exitCode)
+}
+
+func startMark(label string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Never fail the caller's chain because the daemon is down; done reports it instead
+	if err := localDaemonClient().StartMark(ctx, label); err != nil {
+		fmt.Printf("⚠️  Could not reach the daemon, '%s' will not be timed: %v\n", label, err)
+	}
+}
+
+// finishMark asks the daemon to time and notify the run, then exits with exitCode
+// so `...; cmdbell done <token> $?` keeps the caller's status
+func finishMark(label string, exitCode int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := localDaemonClient().FinishMark(ctx, label, exitCode); err != nil {
+		var apiErr *client.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			fmt.Printf("⚠️  Nothing was started as '%s'\n", label)
+		} else {
+			fmt.Printf("⚠️  Could not reach the daemon: %v\n", err)
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func printNotifyMarkUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  cmdbell notify-start [--label <label>]")
+	fmt.Println("  cmdbell notify-done [--label <label>] [-e <exit_code>]")
+	fmt.Println()
+	fmt.Println("Example:")
+	fmt.Println("  cmdbell notify-start --label \"training run\"; python train.py; cmdbell notify-done --label \"training run\" -e $?")
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// setupWizard walks through first-run configuration on the terminal
+type setupWizard struct {
+	reader *bufio.Reader
+	config Config
+}
+
+func handleSetupCommand() {
+	wizard := &setupWizard{
+		reader: bufio.NewReader(os.Stdin),
+		config: getDefaultConfig(),
+	}
+	// The wizard edits and saves the file as written; globalConfig has this host's sections and
+	// the profile applied, which would otherwise be saved into the base config
+	if base, err := LoadBaseConfig(); err == nil {
+		wizard.config = *base
+	}
+
+	if err := wizard.Run(); err != nil {
+		fmt.Printf("Setup failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (w *setupWizard) Run() error {
+	fmt.Println("🔔 Welcome to CmdBell setup! Press Enter to keep the value in brackets.")
+	fmt.Println()
+
+	w.askMinDuration()
+	w.askDesktopNotifications()
+	w.askChannels()
+
+	w.config.Docker.Monitor = w.askYesNo("Monitor Docker containers for long-running exec commands?", w.config.Docker.Monitor)
+	w.config.Daemon.Autostart = w.askYesNo("Start the daemon automatically when a shell opens?", w.config.Daemon.Autostart)
+
+	if err := SaveConfig(&w.config); err != nil {
+		return err
+	}
+	runtimeConfig := w.config
+	if err := applyOverlays(&runtimeConfig); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	globalConfig = &runtimeConfig
+
+	configPath, _ := getConfigPath()
+	fmt.Printf("\n💾 Configuration saved to %s\n\n", configPath)
+
+	return w.askShells()
+}
+
+func (w *setupWizard) askMinDuration() {
+	for {
+		value := w.ask("Minimum command duration before notifying", w.config.General.MinDuration)
+		duration, err := time.ParseDuration(value)
+		if err == nil && duration >= 0 {
+			w.config.General.MinDuration = value
+			w.config.General.MinDurationTime = duration
+			return
+		}
+		fmt.Println("⚠️  Please enter a duration like 15s, 2m or 1h")
+	}
+}
+
+func (w *setupWizard) askDesktopNotifications() {
+	w.config.General.EnableNotify = w.askYesNo("Enable notifications?", w.config.General.EnableNotify)
+	if !w.config.General.EnableNotify {
+		return
+	}
+
+	if w.askYesNo("Send a test desktop notification now?", true) {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout())
+		defer cancel()
+
+		if err := sendNativeNotification(ctx, "CmdBell", "Test notification from cmdbell setup", "🔔", "", "", notificationStyle{Urgency: "normal"}); err != nil {
+			fmt.Printf("⚠️  Desktop notification failed: %v\n", err)
+		} else {
+			fmt.Println("✅ Desktop notification sent")
+		}
+	}
+}
+
+func (w *setupWizard) askChannels() {
+	for w.askYesNo("Add a remote notification channel (webhook, ntfy)?", false) {
+		channelType := w.ask("Channel type (webhook/ntfy)", "ntfy")
+		if channelType != "webhook" && channelType != "ntfy" {
+			fmt.Println("⚠️  Unsupported channel type")
+			continue
+		}
+
+		channelConfig := ChannelConfig{
+			Name: w.ask("Channel name", channelType),
+			Type: channelType,
+			URL:  w.ask("URL (for ntfy include the topic, e.g. https://ntfy.sh/my-topic)", ""),
+		}
+
+		channel, err := NewChannel(channelConfig)
+		if err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+			continue
+		}
+
+		if w.askYesNo("Send a test notification to this channel?", true) {
+			ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout())
+			err := channel.Send(ctx, &Notification{
+				Title:   "CmdBell",
+				Message: "Test notification from cmdbell setup",
+				Icon:    "🔔",
+				Source:  "test",
+				Success: true,
+				Time:    time.Now(),
+			})
+			cancel()
+			if err != nil {
+				fmt.Printf("⚠️  Test failed: %v\n", err)
+				if !w.askYesNo("Keep this channel anyway?", false) {
+					continue
+				}
+			} else {
+				fmt.Println("✅ Test notification sent")
+			}
+		}
+
+		w.config.Channels = append(w.config.Channels, channelConfig)
+	}
+}
+
+func (w *setupWizard) askShells() error {
+	integration, err := NewShellIntegration()
+	if err != nil {
+		return err
+	}
+
+	currentShell := filepath.Base(os.Getenv("SHELL"))
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		if !w.askYesNo(fmt.Sprintf("Install shell integration for %s?", shell), shell == currentShell) {
+			continue
+		}
+
+		if err := integration.installForShell(shell); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to install for %s: %v\n", shell, err)
+		} else {
+			fmt.Printf("✅ Installed for %s\n", shell)
+		}
+	}
+
+	fmt.Println("\n🎉 Setup complete!")
+	fmt.Println("💡 Restart your shell or run 'source ~/.bashrc' (or equivalent) to activate")
+	return nil
+}
+
+func (w *setupWizard) ask(prompt, defaultValue string) string {
+	if defaultValue != "" {
+		fmt.Printf("%s [%s]: ", prompt, defaultValue)
+	} else {
+		fmt.Printf("%s: ", prompt)
+	}
+
+	line, _ := w.reader.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return defaultValue
+	}
+	return line
+}
+
+func (w *setupWizard) askYesNo(prompt string, defaultValue bool) bool {
+	hint := "y/N"
+	if defaultValue {
+		hint = "Y/n"
+	}
+
+	for {
+		answer := strings.ToLower(w.ask(fmt.Sprintf("%s (%s)", prompt, hint), ""))
+		switch answer {
+		case "":
+			return defaultValue
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
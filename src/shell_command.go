@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// shellOperators mark an argument as a command line for the shell rather than a program name
+const shellOperators = "|&;<>()$`*?\"'"
+
+// handleShellCommand handles `cmdbell -c '<command line>'`
+func handleShellCommand() {
+	if len(os.Args) != 3 {
+		fmt.Println("Usage: cmdbell -c '<command line>'")
+		fmt.Println("Example: cmdbell -c 'make && make install'")
+		os.Exit(1)
+	}
+	executeShellCommand(os.Args[2], runOptions{})
+}
+
+// executeShellCommand runs a command line through the user's shell, timing and reporting the whole
+// pipeline as one command
+func executeShellCommand(commandLine string, opts runOptions) {
+	if strings.TrimSpace(commandLine) == "" {
+		errorf("❌ Empty command line\n")
+		os.Exit(1)
+	}
+
+	statusf("Executing: %s\n", commandLine)
+	runWrapped(shellArgv(commandLine), commandLine, commandLine, opts)
+}
+
+// shellArgv runs commandLine with $SHELL, or the platform's shell when it is unset
+func shellArgv(commandLine string) []string {
+	if runtime.GOOS == "windows" {
+		if shell := os.Getenv("SHELL"); shell != "" {
+			// Git Bash and MSYS set SHELL
+			return []string{shell, "-c", commandLine}
+		}
+		comspec := os.Getenv("ComSpec")
+		if comspec == "" {
+			comspec = "cmd.exe"
+		}
+		return []string{comspec, "/C", commandLine}
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	return []string{shell, "-c", commandLine}
+}
+
+// looksLikeShellCommand reports whether a lone argument is a command line, such as
+// "make && make install", rather than the name or path of a program
+func looksLikeShellCommand(arg string) bool {
+	if !strings.ContainsAny(arg, " \t"+shellOperators) {
+		return false
+	}
+	_, err := exec.LookPath(arg)
+	return err != nil
+}
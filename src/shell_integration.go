@@ -4,12 +4,25 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+const (
+	hookStartMarker   = "# CmdBell shell integration - START"
+	hookEndMarker     = "# CmdBell shell integration - END"
+	hookVersionPrefix = "# CmdBell hook version: "
+
+	// HookVersion is bumped whenever the generated hook templates change
+	HookVersion = 13
+)
+
 type ShellIntegration struct {
 	executablePath string
 	homeDir        string
+	autoWrap       []string
+	autostart      bool
+	port           int // the configured daemon port, used until the daemon records another
 }
 
 func NewShellIntegration() (*ShellIntegration, error) {
@@ -23,9 +36,26 @@ func NewShellIntegration() (*ShellIntegration, error) {
 		return nil, fmt.Errorf("failed to get home directory: %v", err)
 	}
 
+	var autoWrap []string
+	var autostart bool
+	port := 59721
+	if globalConfig != nil {
+		autostart = globalConfig.Daemon.Autostart
+		port = globalConfig.HTTP.Port
+		for _, command := range globalConfig.General.AutoWrap {
+			if !isValidCommandName(command) {
+				return nil, fmt.Errorf("invalid auto_wrap command name: %q", command)
+			}
+			autoWrap = append(autoWrap, command)
+		}
+	}
+
 	return &ShellIntegration{
 		executablePath: executablePath,
 		homeDir:        homeDir,
+		autoWrap:       autoWrap,
+		autostart:      autostart,
+		port:           port,
 	}, nil
 }
 
@@ -65,6 +95,10 @@ func (si *ShellIntegration) Uninstall() error {
 }
 
 func (si *ShellIntegration) installForShell(shell string) error {
+	for _, warning := range si.detectPromptFrameworks(shell) {
+		fmt.Printf("ℹ️  %s\n", warning)
+	}
+
 	switch shell {
 	case "bash":
 		return si.installBash()
@@ -119,19 +153,38 @@ func (si *ShellIntegration) installFish() error {
 func (si *ShellIntegration) generateBashHook() string {
 	return `
 # CmdBell shell integration - START
-_cmdbell_preexec() {
-    export CMDBELL_START_TIME=$(date +%s.%N)
+` + si.versionStamp() + si.terminalDetection("bash") + `_cmdbell_preexec() {
+` + si.autoWrapFilter("bash") + `    export CMDBELL_START_TIME=$(date +%s.%N)
     export CMDBELL_COMMAND="$1"
 }
 
 _cmdbell_precmd() {
+    local exit_code=$?
+
+    # A command line that left a job in the background: have the daemon watch its PID
+    if [[ -n "$!" ]] && [[ "$!" != "$_cmdbell_last_bg" ]]; then
+        _cmdbell_last_bg=$!
+        if [[ -n "$CMDBELL_COMMAND" ]] && [[ "$CMDBELL_COMMAND" != cmdbell\ * ]] && command -v cmdbell >/dev/null 2>&1; then
+            (cmdbell background "$_cmdbell_last_bg" "$CMDBELL_START_TIME" "$CMDBELL_COMMAND" >/dev/null 2>&1 &)
+        fi
+    fi
+
     if [[ -n "$CMDBELL_START_TIME" ]] && [[ -n "$CMDBELL_COMMAND" ]]; then
+        # A cmdbell wrapper reports this command, or runs the shell this hook lives in
+        if [[ -n "$CMDBELL_WRAPPED" ]] || [[ -e "$HOME/.cmdbell/nested/$CMDBELL_START_TIME" ]]; then
+            rm -f "$HOME/.cmdbell/nested/$CMDBELL_START_TIME"
+            unset CMDBELL_START_TIME
+            unset CMDBELL_COMMAND
+            return
+        fi
+        
         local end_time=$(date +%s.%N)
         local duration=$(echo "$end_time - $CMDBELL_START_TIME" | bc -l)
         local duration_int=$(printf "%.0f" "$duration")
         
-        if [[ $duration_int -ge 15 ]]; then
-            local exit_code=$?
+        local min_seconds=0
+        [[ -r "$HOME/.cmdbell/` + hookThresholdFile + `" ]] && read -r min_seconds < "$HOME/.cmdbell/` + hookThresholdFile + `"
+        if [[ $duration_int -ge $min_seconds ]]; then
             local success="true"
             [[ $exit_code -ne 0 ]] && success="false"
             
@@ -150,16 +203,25 @@ _cmdbell_precmd() {
                 fi
             fi
             
+            # Authenticate when the daemon requires API tokens
+            local auth_header=()
+            [[ -n "$CMDBELL_TOKEN" ]] && auth_header=(-H "Authorization: Bearer $CMDBELL_TOKEN")
+            # Tag the event with the job ID set by 'cmdbell --correlation-id' or the environment
+            [[ -n "$CMDBELL_CORRELATION_ID" ]] && auth_header+=(-H "X-Correlation-ID: $CMDBELL_CORRELATION_ID")
+            
             # Send HTTP notification
-            local payload='{"command":"'"$CMDBELL_COMMAND"'","container_name":"'"${HOSTNAME:-unknown}"'","duration":"'"${duration_int}s"'","success":'"$success"'}'
+            local payload='{"command":"'"$CMDBELL_COMMAND"'","container_name":"'"${HOSTNAME:-unknown}"'","duration":"'"${duration_int}s"'","success":'"$success"',"start_time":"'"$CMDBELL_START_TIME"'",` + si.terminalPayload("bash") + `}'
+            
+            # The daemon records the port it listens on when it had to fall back to a free one
+            local port=$(sed -n 's/.*"port": *\([0-9][0-9]*\).*/\1/p' "$HOME/.cmdbell/runtime.json" 2>/dev/null)
             
             # Try HTTP first, fallback to local notification
-            if ! curl -s -X POST "http://$host_ip:59721/notify" \
-                -H "Content-Type: application/json" \
+            if ! curl -sf -X POST "http://$host_ip:${port:-` + si.portString() + `}/notify" \
+                -H "Content-Type: application/json" "${auth_header[@]}" \
                 -d "$payload" >/dev/null 2>&1; then
                 # HTTP failed, try local fallback if cmdbell binary exists
                 if command -v cmdbell >/dev/null 2>&1; then
-                    cmdbell --notify "$CMDBELL_COMMAND" "$duration_int" "$exit_code" &
+                    cmdbell --notify "$CMDBELL_COMMAND" "$duration_int" "$exit_code" "$CMDBELL_START_TIME" &
                 fi
             fi
         fi
@@ -169,31 +231,68 @@ _cmdbell_precmd() {
     fi
 }
 
+# Chain any DEBUG trap installed earlier (e.g. by starship) instead of replacing it
+_cmdbell_install_debug_trap() {
+    eval "set -- $(trap -p DEBUG)"
+    if [[ "${3:-}" != *_cmdbell_preexec* ]]; then
+        _cmdbell_prev_debug_trap="${3:-}"
+    fi
+    # The trap also fires for PROMPT_COMMAND, so it only times the first command after the prompt
+    trap '[[ -n "$_cmdbell_armed" ]] && { _cmdbell_armed=; _cmdbell_preexec "$BASH_COMMAND"; }; [[ -n "$_cmdbell_prev_debug_trap" ]] && eval "$_cmdbell_prev_debug_trap"' DEBUG
+}
+
 # Set up hooks for bash
 if [[ -n "$PS1" ]]; then
-    trap '_cmdbell_preexec "$BASH_COMMAND"' DEBUG
-    PROMPT_COMMAND="_cmdbell_precmd${PROMPT_COMMAND:+; $PROMPT_COMMAND}"
+    if [[ -n "${bash_preexec_imported:-}${__bp_imported:-}" ]]; then
+        # bash-preexec (also bundled by iTerm2) owns the DEBUG trap, register through it
+        [[ " ${preexec_functions[*]} " == *" _cmdbell_preexec "* ]] || preexec_functions+=(_cmdbell_preexec)
+        [[ " ${precmd_functions[*]} " == *" _cmdbell_precmd "* ]] || precmd_functions+=(_cmdbell_precmd)
+    else
+        _cmdbell_install_debug_trap
+        if [[ "$PROMPT_COMMAND" != *_cmdbell_precmd* ]]; then
+            PROMPT_COMMAND="_cmdbell_precmd${PROMPT_COMMAND:+; $PROMPT_COMMAND}; _cmdbell_armed=1"
+        fi
+    fi
 fi
-# CmdBell shell integration - END
+` + si.completionSnippet("bash") + si.autostartSnippet("bash") + `# CmdBell shell integration - END
 `
 }
 
 func (si *ShellIntegration) generateZshHook() string {
 	return `
 # CmdBell shell integration - START
-_cmdbell_preexec() {
-    export CMDBELL_START_TIME=$(date +%s.%N)
+` + si.versionStamp() + si.terminalDetection("zsh") + `_cmdbell_preexec() {
+` + si.autoWrapFilter("bash") + `    export CMDBELL_START_TIME=$(date +%s.%N)
     export CMDBELL_COMMAND="$1"
 }
 
 _cmdbell_precmd() {
+    local exit_code=$?
+
+    # A command line that left a job in the background: have the daemon watch its PID
+    if [[ -n "$!" ]] && [[ "$!" != "$_cmdbell_last_bg" ]]; then
+        _cmdbell_last_bg=$!
+        if [[ -n "$CMDBELL_COMMAND" ]] && [[ "$CMDBELL_COMMAND" != cmdbell\ * ]] && command -v cmdbell >/dev/null 2>&1; then
+            (cmdbell background "$_cmdbell_last_bg" "$CMDBELL_START_TIME" "$CMDBELL_COMMAND" >/dev/null 2>&1 &)
+        fi
+    fi
+
     if [[ -n "$CMDBELL_START_TIME" ]] && [[ -n "$CMDBELL_COMMAND" ]]; then
+        # A cmdbell wrapper reports this command, or runs the shell this hook lives in
+        if [[ -n "$CMDBELL_WRAPPED" ]] || [[ -e "$HOME/.cmdbell/nested/$CMDBELL_START_TIME" ]]; then
+            rm -f "$HOME/.cmdbell/nested/$CMDBELL_START_TIME"
+            unset CMDBELL_START_TIME
+            unset CMDBELL_COMMAND
+            return
+        fi
+        
         local end_time=$(date +%s.%N)
         local duration=$(echo "$end_time - $CMDBELL_START_TIME" | bc -l 2>/dev/null || echo "0")
         local duration_int=$(printf "%.0f" "$duration")
         
-        if [[ $duration_int -ge 15 ]]; then
-            local exit_code=$?
+        local min_seconds=0
+        [[ -r "$HOME/.cmdbell/` + hookThresholdFile + `" ]] && read -r min_seconds < "$HOME/.cmdbell/` + hookThresholdFile + `"
+        if [[ $duration_int -ge $min_seconds ]]; then
             local success="true"
             [[ $exit_code -ne 0 ]] && success="false"
             
@@ -212,16 +311,25 @@ _cmdbell_precmd() {
                 fi
             fi
             
+            # Authenticate when the daemon requires API tokens
+            local auth_header=()
+            [[ -n "$CMDBELL_TOKEN" ]] && auth_header=(-H "Authorization: Bearer $CMDBELL_TOKEN")
+            # Tag the event with the job ID set by 'cmdbell --correlation-id' or the environment
+            [[ -n "$CMDBELL_CORRELATION_ID" ]] && auth_header+=(-H "X-Correlation-ID: $CMDBELL_CORRELATION_ID")
+            
             # Send HTTP notification
-            local payload='{"command":"'"$CMDBELL_COMMAND"'","container_name":"'"${HOSTNAME:-unknown}"'","duration":"'"${duration_int}s"'","success":'"$success"'}'
+            local payload='{"command":"'"$CMDBELL_COMMAND"'","container_name":"'"${HOSTNAME:-unknown}"'","duration":"'"${duration_int}s"'","success":'"$success"',"start_time":"'"$CMDBELL_START_TIME"'",` + si.terminalPayload("zsh") + `}'
+            
+            # The daemon records the port it listens on when it had to fall back to a free one
+            local port=$(sed -n 's/.*"port": *\([0-9][0-9]*\).*/\1/p' "$HOME/.cmdbell/runtime.json" 2>/dev/null)
             
             # Try HTTP first, fallback to local notification
-            if ! curl -s -X POST "http://$host_ip:59721/notify" \
-                -H "Content-Type: application/json" \
+            if ! curl -sf -X POST "http://$host_ip:${port:-` + si.portString() + `}/notify" \
+                -H "Content-Type: application/json" "${auth_header[@]}" \
                 -d "$payload" >/dev/null 2>&1; then
                 # HTTP failed, try local fallback if cmdbell binary exists
                 if command -v cmdbell >/dev/null 2>&1; then
-                    cmdbell --notify "$CMDBELL_COMMAND" "$duration_int" "$exit_code" &
+                    cmdbell --notify "$CMDBELL_COMMAND" "$duration_int" "$exit_code" "$CMDBELL_START_TIME" &
                 fi
             fi
         fi
@@ -237,26 +345,48 @@ if [[ -n "$PS1" ]]; then
     add-zsh-hook preexec _cmdbell_preexec
     add-zsh-hook precmd _cmdbell_precmd
 fi
-# CmdBell shell integration - END
+` + si.completionSnippet("zsh") + si.autostartSnippet("zsh") + `# CmdBell shell integration - END
 `
 }
 
 func (si *ShellIntegration) generateFishHook() string {
 	return `
 # CmdBell shell integration - START
-function _cmdbell_preexec --on-event fish_preexec
-    set -gx CMDBELL_START_TIME (date +%s.%N)
+` + si.versionStamp() + si.terminalDetection("fish") + `function _cmdbell_preexec --on-event fish_preexec
+` + si.autoWrapFilter("fish") + `    set -gx CMDBELL_START_TIME (date +%s.%N)
     set -gx CMDBELL_COMMAND "$argv"
 end
 
 function _cmdbell_postcmd --on-event fish_postexec
+    set -l exit_code $status
+
+    # A command line that left a job in the background: have the daemon watch its PID
+    if set -q last_pid; and test "$last_pid" != "$_cmdbell_last_bg"
+        set -g _cmdbell_last_bg $last_pid
+        if test -n "$CMDBELL_COMMAND"; and not string match -q 'cmdbell *' -- "$CMDBELL_COMMAND"; and command -v cmdbell >/dev/null 2>&1
+            cmdbell background "$_cmdbell_last_bg" "$CMDBELL_START_TIME" "$CMDBELL_COMMAND" >/dev/null 2>&1 &
+            disown 2>/dev/null
+        end
+    end
+
     if test -n "$CMDBELL_START_TIME"; and test -n "$CMDBELL_COMMAND"
+        # A cmdbell wrapper reports this command, or runs the shell this hook lives in
+        if test -n "$CMDBELL_WRAPPED"; or test -e "$HOME/.cmdbell/nested/$CMDBELL_START_TIME"
+            rm -f "$HOME/.cmdbell/nested/$CMDBELL_START_TIME"
+            set -e CMDBELL_START_TIME
+            set -e CMDBELL_COMMAND
+            return
+        end
+
         set end_time (date +%s.%N)
         set duration (math "$end_time - $CMDBELL_START_TIME")
         set duration_int (printf "%.0f" "$duration")
         
-        if test $duration_int -ge 15
-            set exit_code $status
+        set -l min_seconds 0
+        if test -r "$HOME/.cmdbell/` + hookThresholdFile + `"
+            read min_seconds < "$HOME/.cmdbell/` + hookThresholdFile + `"
+        end
+        if test $duration_int -ge $min_seconds
             set success "true"
             if test $exit_code -ne 0
                 set success "false"
@@ -277,16 +407,30 @@ function _cmdbell_postcmd --on-event fish_postexec
                 end
             end
             
+            # Authenticate when the daemon requires API tokens
+            set auth_header
+            if test -n "$CMDBELL_TOKEN"
+                set auth_header -H "Authorization: Bearer $CMDBELL_TOKEN"
+            end
+            # Tag the event with the job ID set by 'cmdbell --correlation-id' or the environment
+            if test -n "$CMDBELL_CORRELATION_ID"
+                set -a auth_header -H "X-Correlation-ID: $CMDBELL_CORRELATION_ID"
+            end
+            
             # Send HTTP notification
-            set payload '{"command":"'"$CMDBELL_COMMAND"'","container_name":"'(hostname)'","duration":"'"$duration_int"'s","success":'"$success"'}'
+            set payload '{"command":"'"$CMDBELL_COMMAND"'","container_name":"'(hostname)'","duration":"'"$duration_int"'s","success":'"$success"',"start_time":"'"$CMDBELL_START_TIME"'",` + si.terminalPayload("fish") + `}'
+            
+            # The daemon records the port it listens on when it had to fall back to a free one
+            set port (sed -n 's/.*"port": *\([0-9][0-9]*\).*/\1/p' "$HOME/.cmdbell/runtime.json" 2>/dev/null)
+            test -n "$port"; or set port ` + si.portString() + `
             
             # Try HTTP first, fallback to local notification
-            if not curl -s -X POST "http://$host_ip:59721/notify" \
-                -H "Content-Type: application/json" \
+            if not curl -sf -X POST "http://$host_ip:$port/notify" \
+                -H "Content-Type: application/json" $auth_header \
                 -d "$payload" >/dev/null 2>&1
                 # HTTP failed, try local fallback if cmdbell binary exists
                 if command -v cmdbell >/dev/null 2>&1
-                    cmdbell --notify "$CMDBELL_COMMAND" "$duration_int" "$exit_code" &
+                    cmdbell --notify "$CMDBELL_COMMAND" "$duration_int" "$exit_code" "$CMDBELL_START_TIME" &
                 end
             end
         end
@@ -295,27 +439,153 @@ function _cmdbell_postcmd --on-event fish_postexec
         set -e CMDBELL_COMMAND
     end
 end
-# CmdBell shell integration - END
+` + si.completionSnippet("fish") + si.autostartSnippet("fish") + `# CmdBell shell integration - END
 `
 }
 
-func (si *ShellIntegration) addToShellConfig(configPath, hookContent string) error {
-	startMarker := "# CmdBell shell integration - START"
-	endMarker := "# CmdBell shell integration - END"
+// autoWrapFilter returns a preexec snippet that skips commands not in the auto_wrap list
+func (si *ShellIntegration) autoWrapFilter(shell string) string {
+	if len(si.autoWrap) == 0 {
+		return ""
+	}
+
+	if shell == "fish" {
+		return `    switch (string split -m 1 ' ' -- "$argv")[1]
+        case ` + strings.Join(si.autoWrap, " ") + `
+        case '*'
+            return
+    end
+`
+	}
+
+	return `    case "${1%% *}" in
+        ` + strings.Join(si.autoWrap, "|") + `) ;;
+        *) return ;;
+    esac
+`
+}
 
+// completionSnippet returns shell code completing 'cmdbell rerun <TAB>' with the history IDs of
+// recent commands, which zsh and fish list with the command beside each
+func (si *ShellIntegration) completionSnippet(shell string) string {
+	switch shell {
+	case "fish":
+		return `
+# Complete 'cmdbell rerun' with recent commands from history
+complete -c cmdbell -n '__fish_seen_subcommand_from rerun' -f -a '(cmdbell rerun --complete 2>/dev/null)'
+`
+	case "zsh":
+		return `
+# Complete 'cmdbell rerun' with recent commands from history
+_cmdbell_complete() {
+    if (( CURRENT == 3 )) && [[ "${words[2]}" == rerun ]]; then
+        local -a entries
+        entries=(${${(f)"$(cmdbell rerun --complete 2>/dev/null)"}/$'\t'/:})
+        _describe 'command' entries
+    else
+        _files
+    fi
+}
+(( $+functions[compdef] )) && compdef _cmdbell_complete cmdbell
+`
+	default:
+		return `
+# Complete 'cmdbell rerun' with recent commands from history, by ID or a word of the command
+_cmdbell_complete() {
+    if [[ "${COMP_WORDS[1]}" == rerun ]] && (( COMP_CWORD == 2 )); then
+        local IFS=$'\n'
+        COMPREPLY=($(cmdbell rerun --complete "${COMP_WORDS[2]}" 2>/dev/null | cut -f1))
+    fi
+}
+complete -o default -F _cmdbell_complete cmdbell
+`
+	}
+}
+
+// autostartSnippet returns shell code that starts the daemon in the background when it is not running
+func (si *ShellIntegration) autostartSnippet(shell string) string {
+	if !si.autostart {
+		return ""
+	}
+
+	if shell == "fish" {
+		return `
+# Start the CmdBell daemon if it is not running
+if not kill -0 (cat "$HOME/.cmdbell.pid" 2>/dev/null) 2>/dev/null
+    nohup ` + shellQuote(si.executablePath) + ` --daemon start >/dev/null 2>&1 &
+    disown
+end
+`
+	}
+
+	return `
+# Start the CmdBell daemon if it is not running
+if ! kill -0 "$(cat "$HOME/.cmdbell.pid" 2>/dev/null)" 2>/dev/null; then
+    (nohup ` + shellQuote(si.executablePath) + ` --daemon start >/dev/null 2>&1 &)
+fi
+`
+}
+
+// hookThresholdFile in ~/.cmdbell holds the lowest threshold in whole seconds, the hooks'
+// pre-filter, read on each command so threshold changes apply without reinstalling the hooks.
+// The daemon still applies each command's own threshold tier.
+const hookThresholdFile = "hook-threshold"
+
+// syncHookThreshold writes the config's lowest threshold for the hooks when it changed
+func syncHookThreshold() {
+	path, err := getDataPath(hookThresholdFile)
+	if err != nil {
+		return
+	}
+	value := strconv.Itoa(int(lowestThreshold().Seconds())) + "\n"
+	if current, err := os.ReadFile(path); err == nil && string(current) == value {
+		return
+	}
+	if err := writeFileAtomic(path, []byte(value), 0644); err != nil {
+		errorf("⚠️  Failed to update the shell hook threshold: %v\n", err)
+	}
+}
+
+// portString is the daemon port hooks use when ~/.cmdbell/runtime.json does not name one
+func (si *ShellIntegration) portString() string {
+	return strconv.Itoa(si.port)
+}
+
+func (si *ShellIntegration) versionStamp() string {
+	return fmt.Sprintf("%s%d\n", hookVersionPrefix, HookVersion)
+}
+
+func (si *ShellIntegration) addToShellConfig(configPath, hookContent string) error {
 	// Read existing config
 	var existingContent string
 	if content, err := os.ReadFile(configPath); err == nil {
 		existingContent = string(content)
 	}
 
-	// Remove existing hook if present
-	cleanContent := si.removeExistingHook(existingContent, startMarker, endMarker)
+	// Replace an existing hook in place so its position relative to other frameworks is kept
+	if newContent, ok := si.replaceExistingHook(existingContent, hookContent); ok {
+		return si.writeShellConfig(configPath, []byte(newContent))
+	}
 
 	// Add new hook
-	newContent := cleanContent + "\n" + hookContent + "\n"
+	newContent := existingContent + "\n" + hookContent + "\n"
 
-	return os.WriteFile(configPath, []byte(newContent), 0644)
+	return si.writeShellConfig(configPath, []byte(newContent))
+}
+
+func (si *ShellIntegration) replaceExistingHook(content, hookContent string) (string, bool) {
+	startIdx := strings.Index(content, hookStartMarker)
+	if startIdx == -1 {
+		return content, false
+	}
+
+	endIdx := strings.Index(content[startIdx:], hookEndMarker)
+	if endIdx == -1 {
+		return content, false
+	}
+	endIdx += startIdx + len(hookEndMarker)
+
+	return content[:startIdx] + strings.Trim(hookContent, "\n") + content[endIdx:], true
 }
 
 func (si *ShellIntegration) removeExistingHook(content, startMarker, endMarker string) string {
@@ -360,9 +630,6 @@ func (si *ShellIntegration) uninstallFish() error {
 }
 
 func (si *ShellIntegration) removeFromShellConfig(configPath string) error {
-	startMarker := "# CmdBell shell integration - START"
-	endMarker := "# CmdBell shell integration - END"
-
 	content, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -371,7 +638,7 @@ func (si *ShellIntegration) removeFromShellConfig(configPath string) error {
 		return fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	cleanContent := si.removeExistingHook(string(content), startMarker, endMarker)
+	cleanContent := si.removeExistingHook(string(content), hookStartMarker, hookEndMarker)
 
-	return os.WriteFile(configPath, []byte(cleanContent), 0644)
+	return si.writeShellConfig(configPath, []byte(cleanContent))
 }
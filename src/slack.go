@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Slack interactivity closes the loop on webhook channels in the markdown format: with the Slack
+// app's signing secret set as slack_signing_secret, failure messages get Rerun and Mute 1h
+// buttons. Pointing the app's interactivity and slash command request URL at the daemon's /slack
+// endpoint, through whatever tunnel makes it reachable from Slack, lets them and
+// `/cmdbell rerun <id>` or `/cmdbell mute <command> [2h]` act on this machine. Slack cannot send
+// API tokens, so requests are authenticated by their signature instead.
+const (
+	slackActionRerun = "cmdbell_rerun"
+	slackActionMute  = "cmdbell_mute"
+
+	// slackMaxSkew is how old a signed request may be, so captured ones cannot be replayed later
+	slackMaxSkew = 5 * time.Minute
+)
+
+var slackHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// slackButtons returns the actions block offered on a failure message, or nil. The buttons name
+// the event by its history ID, so there are none while history is disabled.
+func slackButtons(n *Notification) map[string]interface{} {
+	if n.Success || n.Running || n.ID == "" || n.Command == "" || getHistoryStore() == nil {
+		return nil
+	}
+
+	button := func(label, actionID string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":      "button",
+			"text":      map[string]string{"type": "plain_text", "text": label},
+			"action_id": actionID,
+			"value":     n.ID,
+		}
+	}
+	var elements []map[string]interface{}
+	host := n.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	if rerunnableDetached(HistoryEntry{Command: n.Command, Host: host, Source: n.Source, User: n.User, Local: n.Local}) == nil {
+		// Slack asks before sending the click, showing the exact command that will run
+		rerun := button("Rerun", slackActionRerun)
+		rerun["confirm"] = map[string]interface{}{
+			"title":   map[string]string{"type": "plain_text", "text": "Re-run this command?"},
+			"text":    map[string]string{"type": "mrkdwn", "text": slackCode(n.Command) + " on " + host},
+			"confirm": map[string]string{"type": "plain_text", "text": "Run"},
+			"deny":    map[string]string{"type": "plain_text", "text": "Cancel"},
+		}
+		elements = append(elements, rerun)
+	}
+	elements = append(elements, button("Mute 1h", slackActionMute))
+	return map[string]interface{}{"type": "actions", "elements": elements}
+}
+
+// slackSigningSecrets returns the signing secrets of the channels posting to Slack apps
+func slackSigningSecrets() []string {
+	if globalConfig == nil {
+		return nil
+	}
+	var secrets []string
+	for _, channel := range globalConfig.Channels {
+		if channel.SlackSigningSecret == "" {
+			continue
+		}
+		secret, err := resolveSecret(channel.SlackSigningSecret)
+		if err != nil {
+			log.Printf("⚠️  Channel '%s': %v", channel.Name, err)
+			continue
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets
+}
+
+// verifySlackSignature checks a request was signed by one of the Slack apps within slackMaxSkew,
+// following https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackSignature(header http.Header, body []byte, secrets []string, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing request timestamp")
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return fmt.Errorf("request timestamp is %s off", formatDuration(skew.Abs()))
+	}
+
+	signature := []byte(header.Get("X-Slack-Signature"))
+	for _, secret := range secrets {
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+		if hmac.Equal(signature, []byte("v0="+hex.EncodeToString(mac.Sum(nil)))) {
+			return nil
+		}
+	}
+	return errors.New("signature does not match")
+}
+
+// slackInteraction is the part of a block_actions payload the buttons need
+type slackInteraction struct {
+	Type        string `json:"type"`
+	ResponseURL string `json:"response_url"`
+	User        struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// handleSlack answers button clicks and slash commands from Slack apps
+func (hs *HTTPServer) handleSlack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	secrets := slackSigningSecrets()
+	if len(secrets) == 0 {
+		http.Error(w, "No channel sets slack_signing_secret", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	err = verifySlackSignature(r.Header, body, secrets, time.Now())
+	hs.audit.Record(AuditEntry{
+		Time:       time.Now(),
+		Client:     "slack",
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Scope:      "slack",
+		Allowed:    err == nil,
+	})
+	if err != nil {
+		log.Printf("🔒 Rejected Slack request from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Invalid form body", http.StatusBadRequest)
+		return
+	}
+
+	// Slash commands are answered in the response, to the channel unless only the user needs
+	// to see it; button clicks through their response URL
+	if form.Get("command") != "" {
+		text, ok := slackSlashCommand(form)
+		responseType := "in_channel"
+		if !ok {
+			responseType = "ephemeral"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"response_type": responseType, "text": text})
+		return
+	}
+
+	var interaction slackInteraction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &interaction); err != nil || interaction.Type != "block_actions" {
+		http.Error(w, "Expected a block_actions payload or a slash command", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	user := "<@" + interaction.User.ID + ">"
+	for _, action := range interaction.Actions {
+		var reply string
+		var err error
+		switch action.ActionID {
+		case slackActionRerun:
+			reply, err = slackRerun(action.Value, user, interaction.ResponseURL)
+		case slackActionMute:
+			var entry HistoryEntry
+			if entry, err = findHistoryEntry(action.Value); err == nil {
+				reply, err = slackMute(entry.Command, defaultMuteDuration, user)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			reply = "❌ " + err.Error()
+		}
+		go postSlackResponse(interaction.ResponseURL, reply)
+	}
+}
+
+// slackSlashCommand runs `/cmdbell rerun <id>` or `/cmdbell mute <command> [duration]`,
+// reporting false with its usage or error
+func slackSlashCommand(form url.Values) (string, bool) {
+	user := "<@" + form.Get("user_id") + ">"
+	fields := strings.Fields(form.Get("text"))
+	usage := fmt.Sprintf("Usage: `%s rerun <id>` or `%s mute <command> [2h]`", form.Get("command"), form.Get("command"))
+	if len(fields) < 2 {
+		return usage, false
+	}
+
+	var reply string
+	var err error
+	switch fields[0] {
+	case "rerun":
+		reply, err = slackRerun(fields[1], user, form.Get("response_url"))
+	case "mute":
+		pattern, duration := parseMuteArgs(fields[1:])
+		reply, err = slackMute(pattern, duration, user)
+	default:
+		return usage, false
+	}
+	if err != nil {
+		return "❌ " + err.Error(), false
+	}
+	return reply, true
+}
+
+// slackRerun runs an event's command again the way cmdbell://rerun links do, and posts how it
+// went to responseURL once it finishes
+func slackRerun(id, user, responseURL string) (string, error) {
+	entry, err := findHistoryEntry(id)
+	if err != nil {
+		return "", err
+	}
+
+	command := slackCode(entry.Command)
+	err = rerunDetached(entry, func(took time.Duration, err error) {
+		reply := fmt.Sprintf("✅ %s succeeded on the rerun after %s", command, formatDuration(took))
+		if err != nil {
+			reply = fmt.Sprintf("❌ %s failed again after %s (%v)", command, formatDuration(took), err)
+		}
+		postSlackResponse(responseURL, reply)
+	})
+	if err != nil {
+		return "", err
+	}
+	log.Printf("🔁 Re-running '%s' for Slack user %s", entry.Command, user)
+	return fmt.Sprintf("🔁 %s is re-running %s on %s", user, command, entry.Host), nil
+}
+
+// slackMute silences a command the way `cmdbell mute` does
+func slackMute(pattern string, duration time.Duration, user string) (string, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return "", errors.New("nothing to mute")
+	}
+	mute, err := addMute(strings.TrimSpace(pattern), duration)
+	if err != nil {
+		return "", fmt.Errorf("failed to save mute: %v", err)
+	}
+	log.Printf("🔇 Muted '%s' until %s for Slack user %s", mute.Pattern, mute.Until.Local().Format("15:04"), user)
+	recordConfigChange(muteChange("Slack user "+user, mute))
+	return fmt.Sprintf("🔇 %s muted %s until %s", user, slackCode(mute.Pattern), mute.Until.Local().Format("15:04")), nil
+}
+
+// slackCode shows a command as inline code, escaped the way Slack's mrkdwn needs
+func slackCode(command string) string {
+	command = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "`", "'").Replace(command)
+	return "`" + command + "`"
+}
+
+// postSlackResponse posts a message to the conversation a button or slash command came from
+func postSlackResponse(responseURL, text string) {
+	if !strings.HasPrefix(responseURL, "https://hooks.slack.com/") {
+		log.Printf("⚠️  Not answering Slack at unexpected response URL %q", responseURL)
+		return
+	}
+	body, err := json.Marshal(map[string]interface{}{"response_type": "in_channel", "replace_original": false, "text": text})
+	if err != nil {
+		return
+	}
+	resp, err := slackHTTPClient.Post(responseURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("❌ Failed to answer Slack: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("❌ Failed to answer Slack: unexpected status %s", resp.Status)
+	}
+}
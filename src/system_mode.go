@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// System mode runs one daemon for a shared machine, usually as root: Docker is monitored once and
+// each event goes to the user who started the work. The user comes from the system.user_label
+// container label, the home directory a compose project lives in, or the API token's user.
+
+func systemMode() bool {
+	return globalConfig != nil && globalConfig.System.Enabled
+}
+
+// execUser works out which user a docker exec belongs to from its container's attributes
+func execUser(attributes map[string]string) string {
+	if !systemMode() {
+		return ""
+	}
+
+	if name := attributes[globalConfig.System.UserLabel]; name != "" {
+		return name
+	}
+	if dir := attributes["com.docker.compose.project.working_dir"]; dir != "" {
+		if rest, ok := strings.CutPrefix(dir, "/home/"); ok {
+			name, _, _ := strings.Cut(rest, "/")
+			return name
+		}
+	}
+	return ""
+}
+
+type requestUserKey struct{}
+
+// withRequestUser records the user of the API token that authorized a request
+func withRequestUser(r *http.Request, name string) *http.Request {
+	if name == "" {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestUserKey{}, name))
+}
+
+// requestUser returns the user a request was made for in system mode, empty otherwise
+func requestUser(r *http.Request) string {
+	if !systemMode() {
+		return ""
+	}
+	name, _ := r.Context().Value(requestUserKey{}).(string)
+	return name
+}
+
+// userSessionBus returns the session bus of a logged-in user, which systemd places at /run/user/<uid>/bus
+func userSessionBus(name string) (string, error) {
+	account, err := user.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join("/run/user", account.Uid, "bus")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("%s has no session bus, not logged in?", name)
+	}
+	return "unix:path=" + path, nil
+}
+
+// sendUserNativeNotification shows a desktop notification in another user's session; the daemon must run as root
+func sendUserNativeNotification(ctx context.Context, name, title, message string) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("per-user desktop notifications are only supported on Linux")
+	}
+
+	bus, err := userSessionBus(name)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "runuser", "-u", name, "--",
+		"env", "DBUS_SESSION_BUS_ADDRESS="+bus, "notify-send", "--icon=info", title, message)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// channelAccepts reports whether a channel takes an event; channels with a user only receive that user's events
+func channelAccepts(channel Channel, n *Notification) bool {
+	channelConfig, ok := channelConfigNamed(channel.Name())
+	return !ok || channelConfig.User == "" || channelConfig.User == n.User
+}
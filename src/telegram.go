@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Telegram channels send through a bot: token is the bot token from @BotFather and chat_id the
+// chat it writes to. With commands: true the daemon also long-polls the bot for /status, /mute,
+// /history and /rerun sent from chat_id or allowed_chats, a remote control from the phone. In
+// those chats only chat_id's owner and allowed_users are listened to, and reruns wait for them
+// to confirm the exact command with a button.
+const (
+	telegramAPI = "https://api.telegram.org"
+	// telegramTextLimit leaves room for the message above attached output within Telegram's
+	// 4096 characters
+	telegramTextLimit = 3000
+	// telegramPollWait is how long getUpdates waits for a message before returning empty
+	telegramPollWait = 30 * time.Second
+	// telegramMaxAge skips commands sent long before the daemon saw them, such as ones left
+	// unconfirmed by a daemon that stopped
+	telegramMaxAge = 2 * time.Minute
+	// telegramHistoryMax caps /history, whose default is telegramHistoryDefault
+	telegramHistoryMax     = 20
+	telegramHistoryDefault = 5
+	// telegramConfirmWindow is how long a /rerun waits for its Run button
+	telegramConfirmWindow = 2 * time.Minute
+)
+
+const telegramUsage = `CmdBell commands:
+/status - running jobs, unseen notifications and mutes
+/history [n] - the last notifications
+/mute <command> [2h] - silence a command, for 1h by default
+/rerun last|<id> - run the last command, or a history entry, again once you press Run`
+
+// telegramCall calls a Bot API method and decodes its result into result when not nil. Errors
+// never include the request URL, which holds the bot token.
+func telegramCall(ctx context.Context, client *http.Client, config ChannelConfig, method string, params, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", method, err)
+	}
+	url := strings.TrimRight(config.URL, "/") + "/bot" + config.Token + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %v", method, strings.ReplaceAll(err.Error(), config.Token, "<token>"))
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("%s: unexpected response (%s)", method, resp.Status)
+	}
+	if !reply.OK {
+		return fmt.Errorf("%s: %s", method, reply.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
+
+type telegramChannel struct {
+	httpChannel
+}
+
+func (c *telegramChannel) Send(ctx context.Context, n *Notification) error {
+	body, err := c.encryptedBody(n)
+	if err != nil {
+		return err
+	}
+
+	// Encrypted events go as the envelope, for a client holding the key to open
+	text := string(body)
+	if body == nil {
+		text = n.RemoteMessage()
+		if c.config.Format == formatLong {
+			text = longText(text, messageFields(n, sanitizeCommand(n.Command)))
+		}
+		if output := attachedOutput(c.attachments(n)); output != "" {
+			text += "\n\n" + truncateOutput(output, telegramTextLimit)
+		}
+	}
+	return telegramCall(ctx, c.client, c.config, "sendMessage", map[string]interface{}{"chat_id": c.config.ChatID, "text": text, "disable_web_page_preview": true}, nil)
+}
+
+// telegramMessage is the part of a Telegram message commands need
+type telegramMessage struct {
+	MessageID int64  `json:"message_id"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text"`
+	From      struct {
+		ID int64 `json:"id"`
+	} `json:"from"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+// telegramUpdate is the part of a getUpdates result commands and their buttons need
+type telegramUpdate struct {
+	UpdateID      int64            `json:"update_id"`
+	Message       *telegramMessage `json:"message"`
+	CallbackQuery *struct {
+		ID   string `json:"id"`
+		Data string `json:"data"`
+		From struct {
+			ID int64 `json:"id"`
+		} `json:"from"`
+		Message *telegramMessage `json:"message"`
+	} `json:"callback_query"`
+}
+
+// telegramRerun is a /rerun waiting for the user who sent it to press Run
+type telegramRerun struct {
+	entry   HistoryEntry
+	user    string
+	expires time.Time
+}
+
+// telegramCommandChannels returns the telegram channels taking commands, with secrets resolved
+func telegramCommandChannels(configs []ChannelConfig) []ChannelConfig {
+	var bots []ChannelConfig
+	for _, config := range configs {
+		if config.Type != "telegram" || !config.Commands {
+			continue
+		}
+		if err := resolveChannelSecrets(&config); err != nil {
+			log.Printf("⚠️  Telegram channel '%s': %v", config.Name, err)
+			continue
+		}
+		if config.URL == "" {
+			config.URL = telegramAPI
+		}
+		bots = append(bots, config)
+	}
+	return bots
+}
+
+// TelegramBot answers the commands sent to telegram channels' bots from their allowed chats
+type TelegramBot struct {
+	channels []ChannelConfig
+	jobs     func() []RunningJob
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending map[string]telegramRerun // by the token in the buttons' callback data
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewTelegramBot(channels []ChannelConfig, jobs func() []RunningJob) (*TelegramBot, error) {
+	for _, channel := range channels {
+		if channel.Token == "" || channel.ChatID == "" {
+			return nil, fmt.Errorf("telegram channel %q needs token and chat_id", channel.Name)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TelegramBot{
+		channels: channels,
+		jobs:     jobs,
+		client:   &http.Client{Timeout: telegramPollWait + 15*time.Second},
+		pending:  map[string]telegramRerun{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+func (tb *TelegramBot) Start() {
+	for _, channel := range tb.channels {
+		tb.wg.Add(1)
+		go tb.run(channel)
+		log.Printf("🤖 Taking commands from Telegram channel '%s'", channel.Name)
+	}
+}
+
+func (tb *TelegramBot) Stop() {
+	tb.cancel()
+	tb.wg.Wait()
+	log.Println("🛑 Telegram bot stopped")
+}
+
+func (tb *TelegramBot) run(channel ChannelConfig) {
+	defer tb.wg.Done()
+
+	var offset int64
+	backoff := time.Second
+	for tb.ctx.Err() == nil {
+		var updates []telegramUpdate
+		params := map[string]interface{}{"offset": offset, "timeout": int(telegramPollWait.Seconds()), "allowed_updates": []string{"message", "callback_query"}}
+		if err := telegramCall(tb.ctx, tb.client, channel, "getUpdates", params, &updates); err != nil {
+			if tb.ctx.Err() != nil {
+				return
+			}
+			log.Printf("⚠️  Telegram channel '%s': %v (retrying in %s)", channel.Name, err, backoff)
+			select {
+			case <-tb.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+
+		// Asking from past an update confirms it, so Telegram does not send it again
+		for _, update := range updates {
+			offset = max(offset, update.UpdateID+1)
+			tb.handle(channel, update)
+		}
+	}
+}
+
+// allowedTelegramSender reports why a chat and the user writing in it may control the daemon. In a private
+// chat with the bot, chat_id is its owner's user ID; anyone else needs to be in allowed_users,
+// so members of an allowed group cannot run commands just by being there.
+func allowedTelegramSender(channel ChannelConfig, chat, user string) error {
+	if chat != channel.ChatID && !slices.Contains(channel.AllowedChats, chat) {
+		return fmt.Errorf("chat %s is not allowed", chat)
+	}
+	if user != channel.ChatID && !slices.Contains(channel.AllowedUsers, user) {
+		return fmt.Errorf("user %s is not in allowed_users", user)
+	}
+	return nil
+}
+
+// send calls a Bot API method that writes to a chat, logging rather than returning failures
+func (tb *TelegramBot) send(channel ChannelConfig, method string, params map[string]interface{}) {
+	ctx, cancel := context.WithTimeout(tb.ctx, 10*time.Second)
+	defer cancel()
+	if err := telegramCall(ctx, tb.client, channel, method, params, nil); err != nil {
+		log.Printf("❌ Failed to answer Telegram chat %v: %v", params["chat_id"], err)
+	}
+}
+
+func (tb *TelegramBot) handle(channel ChannelConfig, update telegramUpdate) {
+	if update.CallbackQuery != nil {
+		tb.handleButton(channel, update)
+		return
+	}
+	message := update.Message
+	if message == nil || !strings.HasPrefix(message.Text, "/") {
+		return
+	}
+	chat := strconv.FormatInt(message.Chat.ID, 10)
+	user := strconv.FormatInt(message.From.ID, 10)
+	if err := allowedTelegramSender(channel, chat, user); err != nil {
+		log.Printf("🔒 Ignored Telegram command on channel '%s': %v", channel.Name, err)
+		return
+	}
+	if sent := time.Unix(message.Date, 0); time.Since(sent) > telegramMaxAge {
+		log.Printf("⏭️  Skipped Telegram command %q sent at %s", message.Text, sent.Local().Format("15:04"))
+		return
+	}
+
+	fields := strings.Fields(message.Text)
+	// In groups commands may be addressed to the bot, as in /status@cmdbell_bot
+	command, _, _ := strings.Cut(fields[0], "@")
+	reply, err := tb.command(command, fields[1:], chat, user)
+	if err != nil {
+		reply = map[string]interface{}{"text": "❌ " + err.Error()}
+	}
+	reply["chat_id"] = message.Chat.ID
+	reply["disable_web_page_preview"] = true
+	tb.send(channel, "sendMessage", reply)
+}
+
+// command runs one bot command and returns the sendMessage parameters of its answer
+func (tb *TelegramBot) command(command string, args []string, chat, user string) (map[string]interface{}, error) {
+	if command != "/rerun" {
+		text, err := tb.textCommand(command, args, chat)
+		return map[string]interface{}{"text": text}, err
+	}
+
+	// Reruns only ask here; the Run button below the exact command starts them
+	entry, err := telegramRerunEntry(args)
+	if err != nil {
+		return nil, err
+	}
+	token := newEventID()
+	tb.mu.Lock()
+	for key, waiting := range tb.pending {
+		if time.Now().After(waiting.expires) {
+			delete(tb.pending, key)
+		}
+	}
+	tb.pending[token] = telegramRerun{entry: entry, user: user, expires: time.Now().Add(telegramConfirmWindow)}
+	tb.mu.Unlock()
+
+	buttons := [][]map[string]string{{
+		{"text": "▶️ Run", "callback_data": "rerun:" + token},
+		{"text": "Cancel", "callback_data": "cancel:" + token},
+	}}
+	text := fmt.Sprintf("Re-run this command on %s?\n\n%s", entry.Host, entry.Command)
+	return map[string]interface{}{"text": text, "reply_markup": map[string]interface{}{"inline_keyboard": buttons}}, nil
+}
+
+// handleButton answers the Run and Cancel buttons under a /rerun. Only the user who asked may
+// press them, within telegramConfirmWindow.
+func (tb *TelegramBot) handleButton(channel ChannelConfig, update telegramUpdate) {
+	query := update.CallbackQuery
+	tb.send(channel, "answerCallbackQuery", map[string]interface{}{"callback_query_id": query.ID})
+	if query.Message == nil {
+		return
+	}
+	chat := strconv.FormatInt(query.Message.Chat.ID, 10)
+	user := strconv.FormatInt(query.From.ID, 10)
+	if err := allowedTelegramSender(channel, chat, user); err != nil {
+		log.Printf("🔒 Ignored Telegram button on channel '%s': %v", channel.Name, err)
+		return
+	}
+
+	action, token, _ := strings.Cut(query.Data, ":")
+	tb.mu.Lock()
+	waiting, ok := tb.pending[token]
+	if ok && waiting.user == user {
+		delete(tb.pending, token)
+	}
+	tb.mu.Unlock()
+
+	edit := func(text string) {
+		tb.send(channel, "editMessageText", map[string]interface{}{"chat_id": query.Message.Chat.ID, "message_id": query.Message.MessageID, "text": text})
+	}
+	reply := func(text string) {
+		tb.send(channel, "sendMessage", map[string]interface{}{"chat_id": query.Message.Chat.ID, "text": text, "disable_web_page_preview": true})
+	}
+	switch {
+	case !ok || time.Now().After(waiting.expires):
+		edit("⌛ This rerun expired or already ran, send /rerun again")
+		return
+	case waiting.user != user:
+		log.Printf("🔒 Ignored Telegram button from user %s on a rerun user %s asked for", user, waiting.user)
+		return
+	case action != "rerun":
+		edit(fmt.Sprintf("Not re-running '%s'", waiting.entry.Command))
+		return
+	}
+
+	// Answered before starting, so a quick command's outcome cannot arrive first
+	entry := waiting.entry
+	edit(fmt.Sprintf("🔁 Re-running '%s' on %s", entry.Command, entry.Host))
+	err := rerunDetached(entry, func(took time.Duration, err error) {
+		if err != nil {
+			reply(fmt.Sprintf("❌ '%s' failed again after %s (%v)", entry.Command, formatDuration(took), err))
+			return
+		}
+		reply(fmt.Sprintf("✅ '%s' succeeded on the rerun after %s", entry.Command, formatDuration(took)))
+	})
+	if err != nil {
+		reply("❌ " + err.Error())
+		return
+	}
+	log.Printf("🔁 Re-running '%s' for Telegram user %s", entry.Command, user)
+}
+
+// telegramRerunEntry returns the history entry /rerun last|<id> names, if it can be re-run
+func telegramRerunEntry(args []string) (HistoryEntry, error) {
+	if len(args) != 1 {
+		return HistoryEntry{}, errors.New("usage: /rerun last|<id>")
+	}
+
+	var entry HistoryEntry
+	if args[0] == "last" {
+		candidates, err := rerunCandidates()
+		if err != nil {
+			return HistoryEntry{}, err
+		}
+		if len(candidates) == 0 {
+			return HistoryEntry{}, errors.New("no command in history can be re-run here")
+		}
+		entry = candidates[0]
+	} else {
+		var err error
+		if entry, err = findHistoryEntry(args[0]); err != nil {
+			return HistoryEntry{}, err
+		}
+	}
+	if err := rerunnableDetached(entry); err != nil {
+		return HistoryEntry{}, fmt.Errorf("cannot re-run %s: %v", entry.ID, err)
+	}
+	return entry, nil
+}
+
+// textCommand runs one bot command and returns its answer as text
+func (tb *TelegramBot) textCommand(command string, args []string, chat string) (string, error) {
+	switch command {
+	case "/status":
+		return tb.status(), nil
+
+	case "/history":
+		limit := telegramHistoryDefault
+		if len(args) > 0 {
+			parsed, err := strconv.Atoi(args[0])
+			if err != nil || parsed < 1 {
+				return "", fmt.Errorf("expected a number of notifications, not %q", args[0])
+			}
+			limit = min(parsed, telegramHistoryMax)
+		}
+		return telegramHistory(limit)
+
+	case "/mute":
+		pattern, duration := parseMuteArgs(args)
+		if pattern == "" {
+			return "", errors.New("usage: /mute <command> [2h]")
+		}
+		mute, err := addMute(pattern, duration)
+		if err != nil {
+			return "", fmt.Errorf("failed to save mute: %v", err)
+		}
+		log.Printf("🔇 Muted '%s' until %s from Telegram chat %s", mute.Pattern, mute.Until.Local().Format("15:04"), chat)
+		recordConfigChange(muteChange("Telegram chat "+chat, mute))
+		return fmt.Sprintf("🔇 Muted '%s' until %s", mute.Pattern, mute.Until.Local().Format("15:04")), nil
+
+	default:
+		return telegramUsage, nil
+	}
+}
+
+// status summarizes the daemon like `cmdbell prompt` and `cmdbell mutes` together
+func (tb *TelegramBot) status() string {
+	host, _ := os.Hostname()
+	jobs := tb.jobs()
+	mutes := activeMutes(loadMutes())
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔔 CmdBell on %s: %d running, %d unseen, %d muted", host, len(jobs), unseenCount(), len(mutes))
+	for _, job := range jobs {
+		fmt.Fprintf(&b, "\n▶️ %s (%s, %s)", job.Name, job.Source, formatDuration(time.Since(job.Started)))
+	}
+	for _, mute := range mutes {
+		fmt.Fprintf(&b, "\n🔇 %s until %s", mute.Pattern, mute.Until.Local().Format("15:04"))
+	}
+	return b.String()
+}
+
+// telegramHistory lists the latest notifications, with the IDs /rerun takes
+func telegramHistory(limit int) (string, error) {
+	store := getHistoryStore()
+	if store == nil {
+		return "", errors.New("history is disabled (history.enabled: false)")
+	}
+	entries, err := store.Query(HistoryFilter{Limit: limit})
+	if err != nil {
+		return "", fmt.Errorf("failed to read history: %v", err)
+	}
+	if len(entries) == 0 {
+		return "No notifications yet", nil
+	}
+
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = fmt.Sprintf("%s %s %s [%s]", historyIcon(entry), entry.Time.Local().Format("Jan 2 15:04"), entry.Message, entry.ID)
+	}
+	return strings.Join(lines, "\n"), nil
+}
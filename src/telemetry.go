@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Each decision is exported as one OTLP trace: a root span for the event from when CmdBell
+// received it until its last delivery reported back, a span for the rules that decided its
+// outcome, and a span per notifier or channel send. Spans go to the collector as OTLP/HTTP
+// JSON, so no SDK is needed.
+const (
+	telemetryTimeout = 5 * time.Second
+
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusOK    = 1
+	spanStatusError = 2
+)
+
+// telemetryBus queues exports in the daemon, so a slow collector never holds up delivery; CLI
+// invocations export directly while they wait for their deliveries
+var telemetryBus atomic.Pointer[eventBus[Decision]]
+
+func startTelemetryBus() {
+	if telemetryEndpoint() != "" {
+		telemetryBus.Store(newEventBus("Telemetry", sendDecisionTrace))
+	}
+}
+
+func telemetryEndpoint() string {
+	if globalConfig == nil {
+		return ""
+	}
+	return strings.TrimRight(globalConfig.Telemetry.OTLPEndpoint, "/")
+}
+
+// exportDecision sends a decision's trace to the configured OTLP collector, if any
+func exportDecision(decision Decision) {
+	if telemetryEndpoint() == "" {
+		return
+	}
+	if bus := telemetryBus.Load(); bus != nil && bus.Publish(decision) {
+		return
+	}
+	sendDecisionTrace(decision)
+}
+
+func sendDecisionTrace(decision Decision) {
+	body, err := json.Marshal(otlpRequest(decision))
+	if err != nil {
+		return
+	}
+	if err := postTelemetry(body); err != nil {
+		log.Printf("⚠️  Failed to export trace for event %s: %v", decision.ID, err)
+	}
+}
+
+func postTelemetry(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, telemetryEndpoint()+"/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range globalConfig.Telemetry.Headers {
+		resolved, err := resolveSecret(value)
+		if err != nil {
+			return fmt.Errorf("header %s: %v", name, err)
+		}
+		req.Header.Set(name, resolved)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpRequest builds the ExportTraceServiceRequest for one decision
+func otlpRequest(decision Decision) map[string]any {
+	traceID := randomHex(16)
+	root := otlpSpan{
+		TraceID: traceID,
+		SpanID:  randomHex(8),
+		Name:    "cmdbell.event " + decision.Source,
+		Kind:    spanKindInternal,
+		Attributes: attributes(
+			"cmdbell.event_id", decision.ID,
+			"cmdbell.source", decision.Source,
+			"cmdbell.command", decision.Command,
+			"cmdbell.success", decision.Success,
+			"cmdbell.duration_seconds", decision.DurationSeconds,
+			"cmdbell.outcome", decision.Outcome,
+		),
+		Status: otlpStatus{Code: spanStatusOK},
+	}
+
+	rules := otlpSpan{
+		TraceID:      traceID,
+		SpanID:       randomHex(8),
+		ParentSpanID: root.SpanID,
+		Name:         "cmdbell.rules",
+		Kind:         spanKindInternal,
+		Start:        unixNano(decision.Time),
+		End:          unixNano(decision.Time),
+		Attributes: attributes(
+			"cmdbell.outcome", decision.Outcome,
+			"cmdbell.threshold", decision.Threshold,
+			"cmdbell.tier", decision.Tier,
+			"cmdbell.threshold_reason", decision.Reason,
+		),
+	}
+	spans := []otlpSpan{rules}
+
+	end := decision.Time
+	failed := 0
+	for _, delivery := range decision.Deliveries {
+		started, finished := delivery.Started, delivery.Started.Add(time.Duration(delivery.Seconds*float64(time.Second)))
+		if started.IsZero() {
+			started, finished = decision.Time, decision.Time
+		}
+		if finished.After(end) {
+			end = finished
+		}
+
+		span := otlpSpan{
+			TraceID:      traceID,
+			SpanID:       randomHex(8),
+			ParentSpanID: root.SpanID,
+			Name:         "cmdbell.deliver " + delivery.Target,
+			Kind:         spanKindClient,
+			Start:        unixNano(started),
+			End:          unixNano(finished),
+			Attributes:   attributes("cmdbell.target", delivery.Target, "cmdbell.delivery_status", delivery.Status, "cmdbell.delivery_error", delivery.Error),
+		}
+		switch delivery.Status {
+		case "ok":
+			span.Status.Code = spanStatusOK
+		case "failed":
+			span.Status = otlpStatus{Code: spanStatusError, Message: delivery.Error}
+			failed++
+		}
+		spans = append(spans, span)
+	}
+	root.Start, root.End = unixNano(decision.Time), unixNano(end)
+	if failed > 0 {
+		root.Status = otlpStatus{Code: spanStatusError, Message: fmt.Sprintf("%d of %d deliveries failed", failed, len(decision.Deliveries))}
+	}
+
+	serviceName := "cmdbell"
+	if globalConfig != nil && globalConfig.Telemetry.ServiceName != "" {
+		serviceName = globalConfig.Telemetry.ServiceName
+	}
+	hostname, _ := os.Hostname()
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": attributes("service.name", serviceName, "host.name", hostname)},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "cmdbell"},
+				"spans": append([]otlpSpan{root}, spans...),
+			}},
+		}},
+	}
+}
+
+// attributes turns key/value pairs into OTLP attributes, leaving out empty strings
+func attributes(pairs ...any) []otlpAttribute {
+	var result []otlpAttribute
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key := pairs[i].(string)
+		switch value := pairs[i+1].(type) {
+		case string:
+			if value != "" {
+				result = append(result, otlpAttribute{key, map[string]any{"stringValue": value}})
+			}
+		case bool:
+			result = append(result, otlpAttribute{key, map[string]any{"boolValue": value}})
+		case float64:
+			result = append(result, otlpAttribute{key, map[string]any{"doubleValue": value}})
+		}
+	}
+	return result
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func randomHex(bytes int) string {
+	id := make([]byte, bytes)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
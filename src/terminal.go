@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Terminal identifies the terminal a command ran in: the shell that ran it, its TTY and the
+// emulator window, tab or pane the shell lives in. Events carry it so history can tell which
+// terminal a command ran in, and so work scoped to a terminal can target the right one.
+type Terminal struct {
+	ShellPID int    `json:"shell_pid,omitempty"`
+	TTY      string `json:"tty,omitempty"`
+	Emulator string `json:"emulator,omitempty"` // e.g. kitty, iTerm2, vscode or tmux
+	Window   string `json:"window,omitempty"`   // the emulator's ID for the window, tab or pane
+	Control  string `json:"control,omitempty"`  // where the emulator takes remote control, e.g. kitty's socket
+}
+
+func (t Terminal) IsZero() bool {
+	return t == Terminal{}
+}
+
+func (t Terminal) String() string {
+	var parts []string
+	if t.Emulator != "" {
+		emulator := t.Emulator
+		if t.Window != "" {
+			emulator += " " + t.Window
+		}
+		parts = append(parts, emulator)
+	}
+	if t.TTY != "" {
+		parts = append(parts, t.TTY)
+	}
+	if t.ShellPID > 0 {
+		parts = append(parts, fmt.Sprintf("shell %d", t.ShellPID))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Matches reports whether query names this terminal: its TTY, with or without /dev/, its
+// window ID or its shell's PID
+func (t Terminal) Matches(query string) bool {
+	switch {
+	case query == "":
+		return true
+	case t.TTY != "" && (query == t.TTY || "/dev/"+query == t.TTY):
+		return true
+	case t.Window != "" && query == t.Window:
+		return true
+	default:
+		return t.ShellPID > 0 && query == strconv.Itoa(t.ShellPID)
+	}
+}
+
+// terminalMarkers are the variables terminal emulators and multiplexers export to the shells
+// they start, each holding the ID of the window, tab or pane, and for those with remote control
+// the variable with its address. The first one set names the terminal; tmux comes first since
+// a pane inherits the variables of the emulator it was started from.
+var terminalMarkers = []struct{ env, emulator, control string }{
+	{"TMUX_PANE", "tmux", "TMUX"},
+	{"KITTY_WINDOW_ID", "kitty", "KITTY_LISTEN_ON"},
+	{"WEZTERM_PANE", "WezTerm", "WEZTERM_UNIX_SOCKET"},
+	{"ALACRITTY_WINDOW_ID", "Alacritty", ""},
+	{"ITERM_SESSION_ID", "iTerm2", ""},
+	{"WT_SESSION", "Windows Terminal", ""},
+	{"KONSOLE_DBUS_WINDOW", "Konsole", ""},
+	{"GNOME_TERMINAL_SCREEN", "GNOME Terminal", ""},
+	{"TILIX_ID", "Tilix", ""},
+	{"TERMINATOR_UUID", "Terminator", ""},
+}
+
+// terminalEmulator identifies the emulator from the environment. Without a marker it falls
+// back to TERM_PROGRAM (Apple_Terminal, vscode, ghostty...) or JetBrains' TERMINAL_EMULATOR,
+// and to the X11 window ID that xterm and VTE terminals export.
+func terminalEmulator(getenv func(string) string) (emulator, window, control string) {
+	for _, marker := range terminalMarkers {
+		if id := getenv(marker.env); id != "" {
+			if marker.control != "" {
+				// $TMUX is the server socket followed by its PID and session
+				control, _, _ = strings.Cut(getenv(marker.control), ",")
+			}
+			return marker.emulator, id, control
+		}
+	}
+	emulator = getenv("TERM_PROGRAM")
+	if emulator == "" {
+		emulator = getenv("TERMINAL_EMULATOR")
+	}
+	return emulator, getenv("WINDOWID"), ""
+}
+
+// currentTerminal identifies the terminal of a command run under the CLI, whose parent is the
+// shell it was typed in. Outside a terminal, such as under cron, it is zero.
+func currentTerminal() Terminal {
+	t := Terminal{TTY: ttyName()}
+	t.Emulator, t.Window, t.Control = terminalEmulator(os.Getenv)
+	if t.TTY == "" && t.Emulator == "" {
+		return Terminal{}
+	}
+	t.ShellPID = os.Getppid()
+	return t
+}
+
+// ttyName returns the terminal device of the standard streams where /proc shows it, or ""
+func ttyName() string {
+	for fd := 0; fd <= 2; fd++ {
+		target, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+		if err == nil && (strings.HasPrefix(target, "/dev/pts/") || strings.HasPrefix(target, "/dev/tty")) {
+			return target
+		}
+	}
+	return ""
+}
+
+// terminalDetection is the hook code run once when the shell starts, setting _cmdbell_tty,
+// _cmdbell_terminal, _cmdbell_window and _cmdbell_control the way terminalEmulator and ttyName
+// would
+func (si *ShellIntegration) terminalDetection(shell string) string {
+	var b strings.Builder
+	b.WriteString("# Identify the terminal once, sent with each event\n")
+	if shell == "fish" {
+		b.WriteString("set -g _cmdbell_tty (tty 2>/dev/null); or set -g _cmdbell_tty \"\"\n")
+		b.WriteString("set -g _cmdbell_control \"\"\n")
+		for i, marker := range terminalMarkers {
+			keyword := "else if"
+			if i == 0 {
+				keyword = "if"
+			}
+			fmt.Fprintf(&b, "%s test -n \"$%s\"\n    set -g _cmdbell_terminal %q; set -g _cmdbell_window \"$%s\"\n", keyword, marker.env, marker.emulator, marker.env)
+			if marker.control != "" {
+				fmt.Fprintf(&b, "    set -g _cmdbell_control (string split -f1 , -- \"$%s\")\n", marker.control)
+			}
+		}
+		b.WriteString("else\n    set -g _cmdbell_terminal \"$TERM_PROGRAM\"; test -n \"$_cmdbell_terminal\"; or set -g _cmdbell_terminal \"$TERMINAL_EMULATOR\"\n")
+		b.WriteString("    set -g _cmdbell_window \"$WINDOWID\"\nend\n\n")
+		return b.String()
+	}
+
+	b.WriteString("_cmdbell_tty=$(tty 2>/dev/null) || _cmdbell_tty=\"\"\n")
+	b.WriteString("_cmdbell_control=\"\"\n")
+	for i, marker := range terminalMarkers {
+		keyword := "elif"
+		if i == 0 {
+			keyword = "if"
+		}
+		fmt.Fprintf(&b, "%s [[ -n \"$%s\" ]]; then\n    _cmdbell_terminal=%q _cmdbell_window=\"$%s\"", keyword, marker.env, marker.emulator, marker.env)
+		if marker.control != "" {
+			fmt.Fprintf(&b, " _cmdbell_control=\"${%s%%%%,*}\"", marker.control)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("else\n    _cmdbell_terminal=\"${TERM_PROGRAM:-$TERMINAL_EMULATOR}\" _cmdbell_window=\"$WINDOWID\"\nfi\n\n")
+	return b.String()
+}
+
+// terminalPayload is the hook's JSON for the terminal of the event, spliced into its payload
+func (si *ShellIntegration) terminalPayload(shell string) string {
+	pid := `'"$$"'`
+	if shell == "fish" {
+		pid = `'"$fish_pid"'`
+	}
+	return `"terminal":{"shell_pid":` + pid + `,"tty":"'"$_cmdbell_tty"'","emulator":"'"$_cmdbell_terminal"'","window":"'"$_cmdbell_window"'","control":"'"$_cmdbell_control"'"}`
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// iTermFocusScript selects the iTerm2 session with the unique ID given as its argument, along
+// with its tab and window
+const iTermFocusScript = `on run argv
+	tell application "iTerm2"
+		repeat with w in windows
+			repeat with t in tabs of w
+				repeat with s in sessions of t
+					if unique id of s is item 1 of argv then
+						select w
+						select t
+						select s
+						activate
+						return
+					end if
+				end repeat
+			end repeat
+		end repeat
+	end tell
+	error "no iTerm2 session " & item 1 of argv
+end run`
+
+// terminalFocusers build the command that brings a terminal's window, tab or pane to the front,
+// per emulator, through the emulator's own remote control
+var terminalFocusers = map[string]func(ctx context.Context, t Terminal) *exec.Cmd{
+	// Needs allow_remote_control and listen_on in kitty.conf for the socket recorded
+	"kitty": func(ctx context.Context, t Terminal) *exec.Cmd {
+		args := []string{"@"}
+		if t.Control != "" {
+			args = append(args, "--to", t.Control)
+		}
+		return exec.CommandContext(ctx, "kitty", append(args, "focus-window", "--match", "id:"+t.Window)...)
+	},
+	"WezTerm": func(ctx context.Context, t Terminal) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, "wezterm", "cli", "activate-pane", "--pane-id", t.Window)
+		if t.Control != "" {
+			cmd.Env = append(os.Environ(), "WEZTERM_UNIX_SOCKET="+t.Control)
+		}
+		return cmd
+	},
+	"tmux": func(ctx context.Context, t Terminal) *exec.Cmd {
+		var args []string
+		if t.Control != "" {
+			args = append(args, "-S", t.Control)
+		}
+		args = append(args, "select-window", "-t", t.Window, ";", "select-pane", "-t", t.Window)
+		return exec.CommandContext(ctx, "tmux", args...)
+	},
+	// ITERM_SESSION_ID is the session's position, such as w0t1p0, then its unique ID
+	"iTerm2": func(ctx context.Context, t Terminal) *exec.Cmd {
+		_, id, _ := strings.Cut(t.Window, ":")
+		return exec.CommandContext(ctx, "osascript", "-e", iTermFocusScript, id)
+	},
+}
+
+// focusable reports why the terminal of an event cannot be focused from here, or nil when it can
+func focusable(host string, t Terminal) error {
+	if hostname, _ := os.Hostname(); host != "" && host != hostname {
+		return fmt.Errorf("the command ran on %s", host)
+	}
+	if t.Emulator == "" || t.Window == "" {
+		return errors.New("the terminal it ran in is unknown")
+	}
+	if _, ok := terminalFocusers[t.Emulator]; !ok {
+		return fmt.Errorf("%s windows cannot be focused, only kitty, WezTerm, tmux and iTerm2 ones", t.Emulator)
+	}
+	return nil
+}
+
+// focusCommand returns the command focusing the terminal a history entry's command ran in
+func focusCommand(ctx context.Context, entry HistoryEntry) (*exec.Cmd, error) {
+	if err := focusable(entry.Host, entry.Terminal); err != nil {
+		return nil, err
+	}
+	return terminalFocusers[entry.Terminal.Emulator](ctx, entry.Terminal), nil
+}
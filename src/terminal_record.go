@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// recordingDrain bounds the wait for the last of a recording once the command exits, as anything
+// it left running in the background may hold the pipe open
+const recordingDrain = time.Second
+
+// terminalRecording copies what a command run under script(1) prints to a parser
+type terminalRecording struct {
+	reader *os.File
+	writer *os.File
+	done   chan struct{}
+}
+
+// recordedCommand returns a command running argv under script(1), which gives it the terminal as
+// usual and writes a copy of what it prints to a pipe read into observe. Teeing the output instead
+// would leave the tool writing to a pipe, losing its colours, progress bars and prompts. It returns
+// nil where script is not available; argv then runs without its output parsed.
+func recordedCommand(argv []string, observe io.Writer) (*exec.Cmd, *terminalRecording) {
+	script, err := exec.LookPath("script")
+	if err != nil {
+		return nil, nil
+	}
+
+	// The typescript goes to the pipe the command gets as fd 3
+	var args []string
+	switch runtime.GOOS {
+	case "linux":
+		quoted := make([]string, len(argv))
+		for i, arg := range argv {
+			quoted[i] = shellQuote(arg)
+		}
+		args = []string{"-q", "-e", "-f", "-c", strings.Join(quoted, " "), "/dev/fd/3"}
+	case "darwin", "freebsd":
+		args = append([]string{"-q", "-F", "/dev/fd/3"}, argv...)
+	default:
+		return nil, nil
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, nil
+	}
+	recording := &terminalRecording{reader: reader, writer: writer, done: make(chan struct{})}
+	go func() {
+		defer close(recording.done)
+		io.Copy(observe, reader)
+	}()
+
+	cmd := exec.Command(script, args...)
+	cmd.ExtraFiles = []*os.File{writer}
+	return cmd, recording
+}
+
+// started closes this process's end of the pipe once the command has it, or failed to start
+func (r *terminalRecording) started() {
+	r.writer.Close()
+}
+
+// finish waits for the rest of the recording after the command exits
+func (r *terminalRecording) finish() {
+	select {
+	case <-r.done:
+	case <-time.After(recordingDrain):
+	}
+	r.reader.Close()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/pkg/cmdbell"
+)
+
+// thresholdResolution explains which minimum duration applies to a command and why
+type thresholdResolution = cmdbell.Threshold
+
+// thresholdRules returns the loaded config's thresholds as the rules pkg/cmdbell applies
+func thresholdRules() *cmdbell.Rules {
+	return configRules(globalConfig)
+}
+
+// configRules returns a config's thresholds as the rules pkg/cmdbell applies
+func configRules(config *Config) *cmdbell.Rules {
+	rules := &cmdbell.Rules{
+		MinDuration: config.General.MinDurationTime,
+		Tiers:       config.Thresholds.Tiers,
+		Ignore:      config.Thresholds.Ignore,
+	}
+	for _, rule := range config.Thresholds.Rules {
+		rules.Thresholds = append(rules.Thresholds, cmdbell.ThresholdRule{
+			Tier:     rule.Tier,
+			Commands: rule.Commands,
+			Pattern:  rule.Pattern,
+		})
+	}
+	return rules
+}
+
+// resolveThreshold returns the minimum duration before commandLine notifies:
+// the tier of the first matching thresholds rule, otherwise general.min_duration
+func resolveThreshold(commandLine string) thresholdResolution {
+	if globalConfig == nil {
+		return thresholdResolution{Duration: cmdbell.DefaultMinDuration, Reason: "built-in default"}
+	}
+	return thresholdRules().Threshold(commandLine)
+}
+
+// matchThresholdRule describes what in the rule matched commandLine, or returns "" when nothing did
+func matchThresholdRule(rule ThresholdRule, commandLine string) string {
+	return matchCommandRule(rule.Commands, rule.Pattern, commandLine)
+}
+
+// matchCommandRule matches commandLine against command names or prefixes and a regex over the
+// whole line, as thresholds rules do, describing what matched or returning "" when nothing did
+func matchCommandRule(commands []string, pattern, commandLine string) string {
+	return cmdbell.MatchCommand(commands, pattern, commandLine)
+}
+
+// ignoredBy describes the thresholds.ignore entry matching commandLine, or returns "" when none does
+func ignoredBy(commandLine string) string {
+	if globalConfig == nil {
+		return ""
+	}
+	return thresholdRules().Ignored(commandLine)
+}
+
+// shouldNotifyCommand applies enable_notify, thresholds.ignore and the command's threshold tier,
+// recording runs that stay quiet so `cmdbell explain` can say why
+func shouldNotifyCommand(commandLine string, duration time.Duration) bool {
+	if globalConfig == nil {
+		return false
+	}
+
+	resolution := resolveThreshold(commandLine)
+	ignored := ignoredBy(commandLine)
+	tracef("received", "command %q finished after %s", sanitizeCommand(commandLine), duration.Round(time.Millisecond))
+	switch {
+	case !globalConfig.General.EnableNotify:
+		recordSuppressed(commandLine, duration, resolution, "notifications disabled")
+		tracef("filtered", "dropped, notifications are disabled")
+		return false
+	case ignored != "":
+		recordSuppressed(commandLine, duration, resolution, "ignored, thresholds.ignore matched "+ignored)
+		tracef("filtered", "dropped, thresholds.ignore matched %s", ignored)
+		return false
+	case duration < resolution.Duration:
+		recordSuppressed(commandLine, duration, resolution, "below threshold")
+		tracef("filtered", "dropped, %s is below the %s threshold (%s)", duration.Round(time.Millisecond), resolution.Duration, resolution.Reason)
+		return false
+	}
+	tracef("filtered", "passed the %s threshold (%s)", resolution.Duration, resolution.Reason)
+	return true
+}
+
+// lowestThreshold is the shortest threshold any command can have, which shell hooks use
+// to skip commands that could never notify before calling the daemon
+func lowestThreshold() time.Duration {
+	if globalConfig == nil {
+		return cmdbell.DefaultMinDuration
+	}
+	return thresholdRules().Lowest()
+}
+
+// explainThreshold prints the threshold resolved for commandLine, for `cmdbell doctor --explain`
+func explainThreshold(commandLine string) {
+	resolution := resolveThreshold(commandLine)
+	ignored := ignoredBy(commandLine)
+
+	fmt.Printf("Command:   %s\n", commandLine)
+	if resolution.Tier != "" {
+		fmt.Printf("Tier:      %s\n", resolution.Tier)
+	}
+	fmt.Printf("Threshold: %s\n", resolution.Duration)
+	fmt.Printf("Because:   %s\n", resolution.Reason)
+
+	switch {
+	case globalConfig != nil && !globalConfig.General.EnableNotify:
+		fmt.Println("Notifications are disabled (general.enable_notify: false)")
+	case ignored != "":
+		fmt.Printf("Never notifies, thresholds.ignore matched %s\n", ignored)
+	case resolution.Duration == 0:
+		fmt.Println("Every run notifies")
+	default:
+		fmt.Printf("Runs of %s or longer notify\n", resolution.Duration)
+	}
+	if count := streakThreshold(commandLine); count > 0 && ignored == "" {
+		fmt.Printf("Escalates after %d consecutive failures (streaks)\n", count)
+	}
+	if cooldown := cooldownFor(commandLine); cooldown != nil && ignored == "" {
+		fmt.Printf("Notifies at most every %s (thresholds rule %d cooldown)\n", formatDuration(cooldown.Period), cooldown.Rule)
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cmdbell/cmd-bell/client"
+)
+
+const (
+	// topInterval is how often `cmdbell top` redraws unless --interval says otherwise
+	topInterval = time.Second
+	// topTypicalTTL is how long the typical durations behind the ETAs are kept before history is read again
+	topTypicalTTL = 30 * time.Second
+	// topTypicalRuns is how many of a command's latest successful runs its typical duration is the median of
+	topTypicalRuns = 20
+	topNameWidth   = 70
+)
+
+// handleJobs lists the work the daemon tracks while it runs, oldest first. It shares the notify
+// scope with /mutes, which `cmdbell top` uses alongside it.
+func (hs *HTTPServer) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobs := []client.Job{}
+	if hs.jobs != nil {
+		for _, job := range hs.jobs() {
+			jobs = append(jobs, client.Job{Source: job.Source, Name: job.Name, Detail: job.Detail, PID: job.PID, Started: job.Started})
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Started.Before(jobs[j].Started) })
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(jobs); err != nil {
+		log.Printf("Failed to encode jobs: %v", err)
+	}
+}
+
+// topView is what `cmdbell top` shows between redraws
+type topView struct {
+	jobs     []client.Job
+	err      error // why the daemon could not be asked
+	unseen   int
+	selected int
+	status   string // the outcome of the last key, shown under the table
+	killing  int    // PID waiting for a second k before it is stopped
+
+	typical   map[string]time.Duration // median duration of past runs per command, for the ETAs
+	typicalAt time.Time
+}
+
+// handleTopCommand shows the jobs the daemon tracks, refreshed like top, with keys to mute,
+// stop or acknowledge. --once prints a single plain frame, for scripts.
+func handleTopCommand() {
+	interval := topInterval
+	once := false
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--once":
+			once = true
+		case args[i] == "--interval" && i+1 < len(args):
+			i++
+			parsed, err := time.ParseDuration(args[i])
+			if err != nil || parsed < 100*time.Millisecond {
+				errorf("❌ Invalid interval %q, expected a duration of at least 100ms such as 2s\n", args[i])
+				os.Exit(1)
+			}
+			interval = parsed
+		default:
+			printTopUsage()
+			os.Exit(1)
+		}
+	}
+
+	view := &topView{}
+	if once || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		view.refresh()
+		view.render(os.Stdout, false)
+		if view.err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	keys := make(chan string)
+	restore := enterFullScreen()
+	defer restore()
+	go readKeys(keys)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	view.refresh()
+	for {
+		view.render(os.Stdout, true)
+		select {
+		case <-ticker.C:
+			view.refresh()
+		case key := <-keys:
+			if key == "q" || key == "esc" {
+				return
+			}
+			view.handleKey(key)
+		case <-signals:
+			return
+		}
+	}
+}
+
+func (v *topView) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	v.jobs, v.err = localDaemonClient().Jobs(ctx)
+	v.selected = max(0, min(v.selected, len(v.jobs)-1))
+	v.unseen = unseenCount()
+	if time.Since(v.typicalAt) > topTypicalTTL {
+		v.typical = typicalDurations()
+		v.typicalAt = time.Now()
+	}
+}
+
+// typicalDurations returns the median duration of each command's latest successful runs in history
+func typicalDurations() map[string]time.Duration {
+	typical := map[string]time.Duration{}
+	store := getHistoryStore()
+	if store == nil {
+		return typical
+	}
+	entries, err := store.Query(HistoryFilter{})
+	if err != nil {
+		return typical
+	}
+
+	runs := map[string][]time.Duration{}
+	for _, entry := range entries {
+		if entry.Command == "" || !entry.Success || len(runs[entry.Command]) >= topTypicalRuns {
+			continue
+		}
+		runs[entry.Command] = append(runs[entry.Command], time.Duration(entry.DurationSeconds*float64(time.Second)))
+	}
+	for command, durations := range runs {
+		_, typical[command], _ = benchStats(durations)
+	}
+	return typical
+}
+
+// eta estimates how long a job has left from how long its command usually takes
+func (v *topView) eta(job client.Job, elapsed time.Duration) string {
+	typical, ok := v.typical[sanitizeCommand(job.Name)]
+	switch {
+	case !ok:
+		return "-"
+	case elapsed < typical:
+		return "~" + clockDuration(typical-elapsed)
+	default:
+		return "+" + clockDuration(elapsed-typical)
+	}
+}
+
+func (v *topView) render(w io.Writer, interactive bool) {
+	var b strings.Builder
+	if interactive {
+		b.WriteString("\x1b[H\x1b[2J")
+	}
+	fmt.Fprintf(&b, "CmdBell top · %d running · %d unseen · %s\n\n", len(v.jobs), v.unseen, time.Now().Format("15:04:05"))
+
+	switch {
+	case v.err != nil:
+		fmt.Fprintf(&b, "  Cannot reach the daemon: %v\n", v.err)
+	case len(v.jobs) == 0:
+		b.WriteString("  Nothing running\n")
+	default:
+		fmt.Fprintf(&b, "  %-11s %9s %9s  %s\n", "SOURCE", "ELAPSED", "ETA", "COMMAND")
+		for i, job := range v.jobs {
+			elapsed := time.Since(job.Started)
+			name := job.Name
+			if job.Detail != "" {
+				name += fmt.Sprintf(" (%s)", job.Detail)
+			}
+			if runes := []rune(name); len(runes) > topNameWidth {
+				name = string(runes[:topNameWidth-1]) + "…"
+			}
+			line := fmt.Sprintf("%-11s %9s %9s  %s", job.Source, clockDuration(elapsed), v.eta(job, elapsed), name)
+			if interactive && i == v.selected {
+				if plainOutput() {
+					fmt.Fprintf(&b, "> %s\n", line)
+				} else {
+					fmt.Fprintf(&b, "\x1b[7m> %s\x1b[0m\n", line)
+				}
+				continue
+			}
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+
+	if interactive {
+		fmt.Fprintf(&b, "\n%s\n\n↑/↓ select · m mute 1h · k stop · a acknowledge all · q quit\n", v.status)
+	}
+	fmt.Fprint(w, b.String())
+}
+
+func (v *topView) handleKey(key string) {
+	killing := v.killing
+	v.killing = 0
+
+	switch key {
+	case "up":
+		v.selected = max(0, v.selected-1)
+		return
+	case "down":
+		v.selected = min(max(0, len(v.jobs)-1), v.selected+1)
+		return
+	case "a":
+		unseen, err := unseenEvents()
+		if err != nil {
+			v.status = fmt.Sprintf("❌ Failed to read history: %v", err)
+			return
+		}
+		ids := make([]string, len(unseen))
+		for i, entry := range unseen {
+			ids[i] = entry.ID
+		}
+		changed, err := acknowledgeEvents(ids)
+		if err != nil {
+			v.status = fmt.Sprintf("❌ Failed to acknowledge: %v", err)
+			return
+		}
+		v.unseen = 0
+		v.status = fmt.Sprintf("✅ Acknowledged %d notifications", len(changed))
+		return
+	}
+
+	if len(v.jobs) == 0 {
+		return
+	}
+	job := v.jobs[v.selected]
+	switch key {
+	case "m":
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if _, err := localDaemonClient().Mute(ctx, client.MuteRequest{Pattern: job.Name}); err != nil {
+			v.status = fmt.Sprintf("❌ Failed to mute: %v", err)
+			return
+		}
+		v.status = fmt.Sprintf("🔕 Muted '%s' for 1h", displayCommand(job.Name))
+	case "k":
+		switch {
+		case job.PID == 0:
+			v.status = fmt.Sprintf("Only background jobs can be stopped from here, not %s jobs", job.Source)
+		case killing != job.PID:
+			v.killing = job.PID
+			v.status = fmt.Sprintf("Press k again to stop '%s' (pid %d)", displayCommand(job.Name), job.PID)
+		default:
+			if err := stopProcess(job.PID); err != nil {
+				v.status = fmt.Sprintf("❌ Failed to stop pid %d: %v", job.PID, err)
+				return
+			}
+			v.status = fmt.Sprintf("🛑 Stopped '%s' (pid %d)", displayCommand(job.Name), job.PID)
+		}
+	}
+}
+
+// stopProcess asks a process to exit, with SIGTERM where there are signals
+func stopProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return process.Kill()
+	}
+	return process.Signal(syscall.SIGTERM)
+}
+
+// clockDuration formats a duration like a stopwatch, 4:05 or 1:02:03, so columns line up
+func clockDuration(d time.Duration) string {
+	seconds := int(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// enterFullScreen switches to the alternate screen and, where stty is available, hands keys
+// over one at a time without echo, for top and the rerun picker. The returned func puts the
+// terminal back.
+func enterFullScreen() func() {
+	stty := func(args ...string) string {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		output, _ := cmd.Output()
+		return strings.TrimSpace(string(output))
+	}
+
+	saved := ""
+	if runtime.GOOS != "windows" {
+		saved = stty("-g")
+		stty("-icanon", "-echo", "min", "1")
+	}
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	return func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		if saved != "" {
+			stty(saved)
+		}
+	}
+}
+
+// readKeys sends each key pressed until stdin closes
+func readKeys(keys chan<- string) {
+	for {
+		key, err := readKey()
+		if err != nil {
+			return
+		}
+		keys <- key
+	}
+}
+
+// readKey waits for a key, naming the ones that are not characters: "up", "down", "enter",
+// "backspace" and "esc"
+func readKey() (string, error) {
+	buf := make([]byte, 8)
+	n, err := os.Stdin.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	switch input := string(buf[:n]); input {
+	case "\x1b[A", "\x1bOA":
+		return "up", nil
+	case "\x1b[B", "\x1bOB":
+		return "down", nil
+	case "\r", "\n":
+		return "enter", nil
+	case "\x7f", "\b":
+		return "backspace", nil
+	case "\x1b":
+		return "esc", nil
+	default:
+		return input, nil
+	}
+}
+
+func printTopUsage() {
+	fmt.Println("Usage: cmdbell top [--interval 1s] [--once]")
+	fmt.Println()
+	fmt.Println("Shows the container execs, marks and background jobs the daemon tracks, with how long")
+	fmt.Println("they have run and, from history, how long they usually take (~ left, + over).")
+	fmt.Println("Keys: ↑/↓ select, m mutes the command for 1h, k twice stops a background job,")
+	fmt.Println("a acknowledges all unseen notifications, q quits. --once prints one frame and exits.")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+)
+
+// debugEnabled reports whether pipeline tracing is on, via --verbose or CMDBELL_DEBUG=1.
+// The flag sets the variable so a daemon started from the same command inherits it.
+func debugEnabled() bool {
+	return os.Getenv("CMDBELL_DEBUG") == "1"
+}
+
+// dryRunAll reports whether --dry-run or CMDBELL_DRY_RUN=1 turned every channel into a dry run
+func dryRunAll() bool {
+	return os.Getenv("CMDBELL_DRY_RUN") == "1"
+}
+
+// tracef logs one pipeline stage ("received", "filtered", "routed" or "delivered") when tracing is on.
+// The daemon log receives it; CLI commands print it to stderr.
+func tracef(stage, format string, args ...interface{}) {
+	if !debugEnabled() {
+		return
+	}
+	log.Printf("🔍 %-9s "+format, append([]interface{}{stage}, args...)...)
+}
+
+// parseGlobalFlags consumes --verbose, --dry-run, --quiet, --profile and --fake-notify ahead of the subcommand
+func parseGlobalFlags() {
+	for len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "--verbose":
+			os.Setenv("CMDBELL_DEBUG", "1")
+		case "--dry-run":
+			os.Setenv("CMDBELL_DRY_RUN", "1")
+		case "--quiet", "-q":
+			os.Setenv("CMDBELL_QUIET", "1")
+		case "--correlation-id":
+			if len(os.Args) < 3 {
+				return
+			}
+			os.Setenv(correlationEnv, os.Args[2])
+			os.Args = append(os.Args[:1], os.Args[3:]...)
+			continue
+		case "--profile":
+			if len(os.Args) < 3 {
+				return
+			}
+			os.Setenv(profileEnv, os.Args[2])
+			os.Args = append(os.Args[:1], os.Args[3:]...)
+			continue
+		case "--fake-notify":
+			if len(os.Args) < 3 {
+				return
+			}
+			setFakeNotify(os.Args[2])
+			os.Args = append(os.Args[:1], os.Args[3:]...)
+			continue
+		default:
+			if name, ok := strings.CutPrefix(os.Args[1], "--profile="); ok {
+				os.Setenv(profileEnv, name)
+				break
+			}
+			if id, ok := strings.CutPrefix(os.Args[1], "--correlation-id="); ok {
+				os.Setenv(correlationEnv, id)
+				break
+			}
+			if path, ok := strings.CutPrefix(os.Args[1], "--fake-notify="); ok {
+				setFakeNotify(path)
+				break
+			}
+			return
+		}
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+}
+
+// dryRunChannel stands in for a channel with dry_run set: deliveries are logged, never sent
+type dryRunChannel struct {
+	Channel
+}
+
+func (c *dryRunChannel) Send(ctx context.Context, n *Notification) error {
+	payload, err := json.Marshal(newChannelPayload(n))
+	if err != nil {
+		return err
+	}
+	log.Printf("🧪 Dry run: would send to channel %s: %s", c.Name(), payload)
+	return nil
+}
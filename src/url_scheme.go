@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// URL scheme registration, so cmdbell:// links open through 'cmdbell open' from browsers,
+// launchers, Shortcuts and Windows toast buttons
+const (
+	urlSchemeDesktopFile = "cmdbell-url.desktop"
+	urlSchemeMacOSApp    = "CmdBell Links.app"
+	urlSchemeWindowsKey  = `HKCU\Software\Classes\cmdbell`
+	lsregister           = "/System/Library/Frameworks/CoreServices.framework/Frameworks/LaunchServices.framework/Support/lsregister"
+)
+
+// installURLScheme registers this executable as the handler of cmdbell:// links
+func installURLScheme() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %v", err)
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		if _, err := exec.LookPath("xdg-mime"); err != nil {
+			return "", errors.New("xdg-mime not found, install xdg-utils")
+		}
+		path, err := linuxURLSchemeFile()
+		if err != nil {
+			return "", err
+		}
+		entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=CmdBell links
+Exec="%s" open %%u
+NoDisplay=true
+Terminal=false
+MimeType=x-scheme-handler/cmdbell;
+`, executable)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := writeFileAtomic(path, []byte(entry), 0644); err != nil {
+			return "", err
+		}
+		exec.Command("update-desktop-database", filepath.Dir(path)).Run()
+		if output, err := exec.Command("xdg-mime", "default", urlSchemeDesktopFile, "x-scheme-handler/cmdbell").CombinedOutput(); err != nil {
+			return "", toolError("xdg-mime", err, output)
+		}
+		return path, nil
+
+	case "darwin":
+		// Only app bundles can claim a URL scheme, so a small AppleScript applet forwards the link
+		path, err := macOSURLSchemeApp()
+		if err != nil {
+			return "", err
+		}
+		script := fmt.Sprintf(`on open location theURL
+	do shell script quoted form of "%s" & " open " & quoted form of theURL
+end open location`, escapeAppleScript(executable))
+		os.RemoveAll(path)
+		if output, err := exec.Command("osacompile", "-o", path, "-e", script).CombinedOutput(); err != nil {
+			return "", toolError("osacompile", err, output)
+		}
+		plist := filepath.Join(path, "Contents", "Info.plist")
+		for _, args := range [][]string{
+			{"-replace", "CFBundleIdentifier", "-string", "dev.cmdbell.links"},
+			{"-replace", "LSUIElement", "-bool", "true"},
+			{"-replace", "CFBundleURLTypes", "-json", `[{"CFBundleURLName":"CmdBell link","CFBundleURLSchemes":["cmdbell"]}]`},
+		} {
+			if output, err := exec.Command("plutil", append(args, plist)...).CombinedOutput(); err != nil {
+				return "", toolError("plutil", err, output)
+			}
+		}
+		if output, err := exec.Command(lsregister, "-f", path).CombinedOutput(); err != nil {
+			return "", toolError("lsregister", err, output)
+		}
+		return path, nil
+
+	case "windows":
+		command := fmt.Sprintf(`"%s" open "%%1"`, executable)
+		for _, args := range [][]string{
+			{"add", urlSchemeWindowsKey, "/ve", "/d", "URL:CmdBell link", "/f"},
+			{"add", urlSchemeWindowsKey, "/v", "URL Protocol", "/d", "", "/f"},
+			{"add", urlSchemeWindowsKey + `\shell\open\command`, "/ve", "/d", command, "/f"},
+		} {
+			if output, err := exec.Command("reg", args...).CombinedOutput(); err != nil {
+				return "", toolError("reg", err, output)
+			}
+		}
+		return urlSchemeWindowsKey, nil
+
+	default:
+		return "", fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	}
+}
+
+// uninstallURLScheme removes what installURLScheme registered
+func uninstallURLScheme() error {
+	switch runtime.GOOS {
+	case "linux":
+		path, err := linuxURLSchemeFile()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		exec.Command("update-desktop-database", filepath.Dir(path)).Run()
+		return nil
+	case "darwin":
+		path, err := macOSURLSchemeApp()
+		if err != nil {
+			return err
+		}
+		exec.Command(lsregister, "-u", path).Run()
+		return os.RemoveAll(path)
+	case "windows":
+		if !urlSchemeRegistered() {
+			return nil
+		}
+		if output, err := exec.Command("reg", "delete", urlSchemeWindowsKey, "/f").CombinedOutput(); err != nil {
+			return toolError("reg", err, output)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	}
+}
+
+// urlSchemeRegistered reports whether cmdbell:// links reach CmdBell
+func urlSchemeRegistered() bool {
+	switch runtime.GOOS {
+	case "linux":
+		output, err := exec.Command("xdg-mime", "query", "default", "x-scheme-handler/cmdbell").Output()
+		return err == nil && strings.TrimSpace(string(output)) == urlSchemeDesktopFile
+	case "darwin":
+		path, err := macOSURLSchemeApp()
+		if err != nil {
+			return false
+		}
+		_, err = os.Stat(path)
+		return err == nil
+	case "windows":
+		return exec.Command("reg", "query", urlSchemeWindowsKey+`\shell\open\command`).Run() == nil
+	default:
+		return false
+	}
+}
+
+var (
+	windowsURLSchemeOnce sync.Once
+	windowsURLScheme     bool
+)
+
+// windowsURLSchemeRegistered is urlSchemeRegistered checked once, for toasts whose buttons
+// follow cmdbell:// links
+func windowsURLSchemeRegistered() bool {
+	windowsURLSchemeOnce.Do(func() {
+		windowsURLScheme = urlSchemeRegistered()
+	})
+	return windowsURLScheme
+}
+
+func linuxURLSchemeFile() (string, error) {
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if dataDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %v", err)
+		}
+		dataDir = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dataDir, "applications", urlSchemeDesktopFile), nil
+}
+
+func macOSURLSchemeApp() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, "Applications", urlSchemeMacOSApp), nil
+}
+
+// toolError describes a failed helper program with what it printed
+func toolError(name string, err error, output []byte) error {
+	if detail := strings.TrimSpace(string(output)); detail != "" {
+		return fmt.Errorf("%s failed: %v: %s", name, err, detail)
+	}
+	return fmt.Errorf("%s failed: %v", name, err)
+}
+
+// openTarget opens a URL or file with the desktop's default handler
+func openTarget(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		if _, err := exec.LookPath("xdg-open"); err != nil {
+			return errors.New("xdg-open not found")
+		}
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
+
+// handleURLSchemeCommand handles `cmdbell url-scheme [install|uninstall|status]`
+func handleURLSchemeCommand() {
+	subcommand := "status"
+	if len(os.Args) > 2 {
+		subcommand = os.Args[2]
+	}
+
+	switch subcommand {
+	case "install":
+		where, err := installURLScheme()
+		if err != nil {
+			errorf("❌ Failed to register cmdbell:// links: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("✅ cmdbell:// links now open with CmdBell (%s)\n", where)
+	case "uninstall":
+		if err := uninstallURLScheme(); err != nil {
+			errorf("❌ Failed to unregister cmdbell:// links: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("✅ cmdbell:// links are no longer handled by CmdBell\n")
+	case "status":
+		if urlSchemeRegistered() {
+			fmt.Println("✅ cmdbell:// links open with CmdBell")
+		} else {
+			fmt.Println("❌ cmdbell:// links are not registered, run 'cmdbell url-scheme install'")
+		}
+	default:
+		fmt.Println("Usage: cmdbell url-scheme [install|uninstall|status]")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// commandEnricher recognises a long-running VM or cloud tool and summarises what it did for the notification
+type commandEnricher interface {
+	// Output receives a copy of the command's stdout and stderr, or is nil when only arguments are used
+	Output() *lineWriter
+	Summary() string
+}
+
+// newCommandEnricher returns an enricher for known VM and cloud tools, or nil for anything else
+func newCommandEnricher(command string, args []string) commandEnricher {
+	switch filepath.Base(command) {
+	case "aws":
+		if firstArgs(args, "cloudformation", "deploy") {
+			return newCloudFormationEnricher(args[2:])
+		}
+	case "gcloud":
+		if firstArgs(args, "builds", "submit") {
+			return newGCloudBuildEnricher()
+		}
+	case "az":
+		if firstArgs(args, "deployment") && len(args) > 2 {
+			return newAzureDeploymentEnricher(args[2:])
+		}
+	case "terraform":
+		if firstArgs(args, "apply") || firstArgs(args, "destroy") {
+			return newTerraformApplyEnricher()
+		}
+	case "vagrant":
+		if len(args) > 0 && (args[0] == "up" || args[0] == "provision" || args[0] == "reload") {
+			return newVagrantEnricher()
+		}
+	case "packer":
+		if len(args) > 0 && args[0] == "build" {
+			return newPackerEnricher()
+		}
+	case "qemu-img":
+		if len(args) > 0 && args[0] == "convert" {
+			return newQemuImgEnricher(args[1:])
+		}
+	}
+	return nil
+}
+
+// ansiEscape matches colour and cursor sequences, including the private ones such as \x1b[?25l
+// that spinners use to hide the cursor, and window titles
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]|\x1b\][^\x07]*\x07`)
+
+// lineWriter splits written output into lines and passes each to onLine with the time it arrived
+type lineWriter struct {
+	mu      sync.Mutex
+	pending []byte
+	onLine  func(line string, at time.Time)
+	redraws bool // also end lines at a bare \r, which progress bars use to redraw in place
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if w.redraws {
+			i = bytes.IndexAny(w.pending, "\r\n")
+		}
+		if i < 0 {
+			break
+		}
+		line := ansiEscape.ReplaceAllString(strings.TrimRight(string(w.pending[:i]), "\r"), "")
+		w.pending = w.pending[i+1:]
+		w.onLine(line, time.Now())
+	}
+	return len(p), nil
+}
+
+// timedStep is a named phase whose duration runs until the next phase starts or the command ends
+type timedStep struct {
+	name  string
+	start time.Time
+	end   time.Time
+}
+
+func formatSteps(steps []timedStep) string {
+	now := time.Now()
+	parts := make([]string, 0, len(steps))
+	for _, step := range steps {
+		end := step.end
+		if end.IsZero() {
+			end = now
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", step.name, formatDuration(end.Sub(step.start))))
+	}
+	return strings.Join(parts, ", ")
+}
+
+var (
+	vagrantBoxPattern         = regexp.MustCompile(`==> (\S+): (?:Importing base box|Checking if box|Box) '([^']+)'`)
+	vagrantProvisionerPattern = regexp.MustCompile(`==> (\S+): Running provisioner: ([^.]+?)\.*$`)
+)
+
+// vagrantEnricher records box names and how long each provisioner ran
+type vagrantEnricher struct {
+	writer *lineWriter
+	boxes  []string
+	steps  []timedStep
+}
+
+func newVagrantEnricher() *vagrantEnricher {
+	e := &vagrantEnricher{}
+	e.writer = &lineWriter{onLine: e.handleLine}
+	return e
+}
+
+func (e *vagrantEnricher) Output() *lineWriter {
+	return e.writer
+}
+
+func (e *vagrantEnricher) handleLine(line string, at time.Time) {
+	if match := vagrantBoxPattern.FindStringSubmatch(line); match != nil {
+		if !containsString(e.boxes, match[2]) {
+			e.boxes = append(e.boxes, match[2])
+		}
+		return
+	}
+
+	if match := vagrantProvisionerPattern.FindStringSubmatch(line); match != nil {
+		if n := len(e.steps); n > 0 && e.steps[n-1].end.IsZero() {
+			e.steps[n-1].end = at
+		}
+		e.steps = append(e.steps, timedStep{name: match[1] + "/" + match[2], start: at})
+	}
+}
+
+func (e *vagrantEnricher) Summary() string {
+	e.writer.mu.Lock()
+	defer e.writer.mu.Unlock()
+
+	var parts []string
+	if len(e.boxes) > 0 {
+		parts = append(parts, "box "+strings.Join(e.boxes, ", "))
+	}
+	if len(e.steps) > 0 {
+		parts = append(parts, "provisioners: "+formatSteps(e.steps))
+	}
+	return strings.Join(parts, "; ")
+}
+
+var (
+	packerBuildFinishedPattern = regexp.MustCompile(`^Build '([^']+)' finished after (.+)\.$`)
+	packerBuildErroredPattern  = regexp.MustCompile(`^Build '([^']+)' errored after (.+?):`)
+	packerArtifactPattern      = regexp.MustCompile(`^--> ([^:]+): (.+)$`)
+)
+
+// packerEnricher records each build's outcome and duration as reported by packer, plus its artifacts
+type packerEnricher struct {
+	writer          *lineWriter
+	builds          []string
+	artifacts       []string
+	artifactPending bool // the artifact header ended with a colon, so the ID is on the next line
+}
+
+func newPackerEnricher() *packerEnricher {
+	e := &packerEnricher{}
+	e.writer = &lineWriter{onLine: e.handleLine}
+	return e
+}
+
+func (e *packerEnricher) Output() *lineWriter {
+	return e.writer
+}
+
+func (e *packerEnricher) handleLine(line string, at time.Time) {
+	line = strings.TrimSpace(line)
+	if e.artifactPending && line != "" {
+		e.artifacts = append(e.artifacts, line)
+		e.artifactPending = false
+		return
+	}
+
+	if match := packerBuildFinishedPattern.FindStringSubmatch(line); match != nil {
+		e.builds = append(e.builds, fmt.Sprintf("%s %s", match[1], match[2]))
+	} else if match := packerBuildErroredPattern.FindStringSubmatch(line); match != nil {
+		e.builds = append(e.builds, fmt.Sprintf("%s errored after %s", match[1], match[2]))
+	} else if match := packerArtifactPattern.FindStringSubmatch(line); match != nil {
+		if strings.HasSuffix(match[2], ":") {
+			e.artifactPending = true
+		} else {
+			e.artifacts = append(e.artifacts, match[2])
+		}
+	}
+}
+
+func (e *packerEnricher) Summary() string {
+	e.writer.mu.Lock()
+	defer e.writer.mu.Unlock()
+
+	var parts []string
+	if len(e.builds) > 0 {
+		parts = append(parts, "builds: "+strings.Join(e.builds, ", "))
+	}
+	if len(e.artifacts) > 0 {
+		parts = append(parts, "artifacts: "+strings.Join(e.artifacts, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// qemuImgEnricher describes a conversion from its arguments; qemu-img output carries nothing more useful
+type qemuImgEnricher struct {
+	source, target, format string
+}
+
+func newQemuImgEnricher(args []string) *qemuImgEnricher {
+	e := &qemuImgEnricher{}
+	var files []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-O" && i+1 < len(args):
+			i++
+			e.format = args[i]
+		case arg == "-f" || arg == "-o" || arg == "-t" || arg == "-T" || arg == "-F" || arg == "-B" || arg == "-s" || arg == "-l" || arg == "-m" || arg == "-S":
+			i++
+		case !strings.HasPrefix(arg, "-"):
+			files = append(files, filepath.Base(arg))
+		}
+	}
+	if len(files) >= 2 {
+		e.source = strings.Join(files[:len(files)-1], " + ")
+		e.target = files[len(files)-1]
+	}
+	return e
+}
+
+func (e *qemuImgEnricher) Output() *lineWriter {
+	return nil
+}
+
+func (e *qemuImgEnricher) Summary() string {
+	if e.target == "" {
+		return ""
+	}
+	summary := fmt.Sprintf("%s → %s", e.source, e.target)
+	if e.format != "" {
+		summary += fmt.Sprintf(" (%s)", e.format)
+	}
+	return summary
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// windowsToastAppID is the AppUserModelID toasts are shown under. CmdBell has no Start menu
+// shortcut of its own, so it borrows PowerShell's, whose toasts Action Center keeps.
+const windowsToastAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// windowsToastGroup groups CmdBell's toasts in Action Center so `cmdbell ack` can clear them
+const windowsToastGroup = "cmdbell"
+
+// windowsToastTypes loads the WinRT types toasts need into Windows PowerShell
+const windowsToastTypes = `
+	$ErrorActionPreference = 'Stop';
+	[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null;
+	[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null;
+`
+
+// sendWindowsToast shows an Action Center toast tagged with its event, or with style.ReplaceKey
+// so a later toast with the key replaces it. During quiet hours the popup is suppressed and the
+// toast goes straight to Action Center. Clicking a toast with copyText copies the command and
+// acknowledges the event, for which PowerShell stays around a few seconds. Action buttons open
+// cmdbell://action links.
+func sendWindowsToast(ctx context.Context, title, message, icon, copyText, eventID string, style notificationStyle) error {
+	tag := eventID
+	if style.ReplaceKey != "" {
+		tag = style.ReplaceKey
+	}
+	if tag == "" {
+		tag = windowsToastGroup
+	}
+
+	if copyText != "" {
+		message += "\nClick to copy the command"
+	}
+	var content bytes.Buffer
+	content.WriteString(`<toast><visual><binding template="ToastGeneric"><text>`)
+	xml.EscapeText(&content, []byte(icon+" "+title))
+	content.WriteString(`</text><text>`)
+	xml.EscapeText(&content, []byte(message))
+	content.WriteString(`</text></binding></visual>`)
+	// A bucket's sound is played separately, so the toast's own would be a second one
+	if style.Quiet || windowsSounds[style.Sound] {
+		content.WriteString(`<audio silent="true"/>`)
+	}
+	// Buttons follow cmdbell:// links, which Windows only hands to CmdBell once the scheme is registered
+	if len(style.Buttons) > 0 && eventID != "" && windowsURLSchemeRegistered() {
+		content.WriteString(`<actions>`)
+		for _, button := range style.Buttons {
+			content.WriteString(`<action activationType="protocol" content="`)
+			xml.EscapeText(&content, []byte(button.Button))
+			content.WriteString(`" arguments="`)
+			xml.EscapeText(&content, []byte(actionLink(button.Name, eventID)))
+			content.WriteString(`"/>`)
+		}
+		content.WriteString(`</actions>`)
+	}
+	content.WriteString(`</toast>`)
+
+	onClick, wait, sound := "", "", ""
+	if copyText != "" {
+		ackScript := ""
+		if ack := clickAckCommand(eventID); ack != nil {
+			ackScript = fmt.Sprintf(" & %s open %s | Out-Null;", powerShellQuote(ack[0]), powerShellQuote(ack[2]))
+		}
+		onClick = fmt.Sprintf("Register-ObjectEvent -InputObject $toast -EventName Activated -Action { Set-Clipboard -Value %s;%s } | Out-Null;",
+			powerShellQuote(copyText), ackScript)
+		wait = "for ($i = 0; $i -lt 60; $i++) { Start-Sleep -Milliseconds 100 };"
+	}
+	if windowsSounds[style.Sound] {
+		sound = fmt.Sprintf("[System.Media.SystemSounds]::%s.Play();", style.Sound)
+	}
+
+	script := windowsToastTypes + fmt.Sprintf(`
+		$xml = New-Object Windows.Data.Xml.Dom.XmlDocument;
+		$xml.LoadXml(%s);
+		$toast = New-Object Windows.UI.Notifications.ToastNotification $xml;
+		$toast.Tag = %s;
+		$toast.Group = %s;
+		$toast.SuppressPopup = $%t;
+		%s
+		[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier(%s).Show($toast);
+		%s
+		%s
+	`, powerShellQuote(content.String()), powerShellQuote(truncateToastTag(tag)), powerShellQuote(windowsToastGroup),
+		style.Quiet, onClick, powerShellQuote(windowsToastAppID), sound, wait)
+
+	return exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script).Run()
+}
+
+// truncateToastTag keeps tags within the 64 characters Windows accepts
+func truncateToastTag(tag string) string {
+	if len(tag) > 64 {
+		return tag[:64]
+	}
+	return tag
+}
+
+// clearWindowsToasts removes acknowledged events' toasts from Action Center, or all of
+// CmdBell's when all is set. Elsewhere it does nothing.
+func clearWindowsToasts(ids []string, all bool) error {
+	if runtime.GOOS != "windows" {
+		return nil
+	}
+
+	var script strings.Builder
+	script.WriteString(windowsToastTypes)
+	script.WriteString("$history = [Windows.UI.Notifications.ToastNotificationManager]::History;\n")
+	if all {
+		fmt.Fprintf(&script, "$history.RemoveGroup(%s, %s);\n", powerShellQuote(windowsToastGroup), powerShellQuote(windowsToastAppID))
+	} else {
+		for _, id := range ids {
+			fmt.Fprintf(&script, "$history.Remove(%s, %s, %s);\n",
+				powerShellQuote(truncateToastTag(id)), powerShellQuote(windowsToastGroup), powerShellQuote(windowsToastAppID))
+		}
+	}
+	return exec.Command("powershell", "-NoProfile", "-Command", script.String()).Run()
+}
+
+// powerShellQuote quotes s as a single-quoted PowerShell string
+func powerShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}